@@ -262,6 +262,9 @@ func (api *API) CreateIndex(ctx context.Context, indexName string, options Index
 	if err := api.validate(apiCreateIndex); err != nil {
 		return nil, errors.Wrap(err, "validating api method")
 	}
+	if options.PartitionN < 0 {
+		return nil, NewBadRequestError(errors.Errorf("invalid partitionN: %d", options.PartitionN))
+	}
 
 	// Populate the create index message.
 	ts := timestamp()
@@ -760,6 +763,40 @@ func (api *API) DeleteField(ctx context.Context, indexName string, fieldName str
 	return nil
 }
 
+// TruncateField deletes all of a field's data, on every node, while keeping
+// the field and its options. See Field.Truncate.
+func (api *API) TruncateField(ctx context.Context, indexName string, fieldName string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.TruncateField")
+	defer span.Finish()
+
+	if err := api.validate(apiTruncateField); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	// Find field.
+	f := api.holder.Field(indexName, fieldName)
+	if f == nil {
+		return newNotFoundError(ErrFieldNotFound, fieldName)
+	}
+
+	// Truncate the local field.
+	if err := f.Truncate(); err != nil {
+		return errors.Wrap(err, "truncating field")
+	}
+
+	// Send the truncate field message to all nodes.
+	err := api.server.SendSync(
+		&TruncateFieldMessage{
+			Index: indexName,
+			Field: fieldName,
+		})
+	if err != nil {
+		api.server.logger.Errorf("problem sending TruncateField message: %s", err)
+		return errors.Wrap(err, "sending TruncateField message")
+	}
+	return nil
+}
+
 // DeleteAvailableShard a shard ID from the available shard set cache.
 func (api *API) DeleteAvailableShard(_ context.Context, indexName, fieldName string, shardID uint64) error {
 	if err := api.validate(apiDeleteAvailableShard); err != nil {
@@ -793,7 +830,9 @@ func (api *API) DeleteAvailableShard(_ context.Context, indexName, fieldName str
 }
 
 // ExportCSV encodes the fragment designated by the index,field,shard as
-// CSV of the form <row>,<col>
+// CSV of the form <row>,<col>, in row-major order. The fragment is read one
+// row at a time, so its lock isn't held while keys are translated and the
+// CSV is written.
 func (api *API) ExportCSV(ctx context.Context, indexName string, fieldName string, shard uint64, w io.Writer) error {
 	span, _ := tracing.StartSpanFromContext(ctx, "API.ExportCSV")
 	defer span.Finish()
@@ -824,8 +863,10 @@ func (api *API) ExportCSV(ctx context.Context, indexName string, fieldName strin
 	}
 
 	// Find the fragment.
-	f := api.holder.fragment(indexName, fieldName, viewStandard, shard)
-	if f == nil {
+	f, err := api.holder.fragment(indexName, fieldName, viewStandard, shard)
+	if err != nil {
+		return errors.Wrap(err, "getting fragment")
+	} else if f == nil {
 		return ErrFragmentNotFound
 	}
 
@@ -854,7 +895,7 @@ func (api *API) ExportCSV(ctx context.Context, indexName string, fieldName strin
 		}
 
 		if index.Keys() {
-			if store := index.TranslateStore(snap.IDToShardPartition(indexName, columnID)); store == nil {
+			if store := index.TranslateStore(index.idPartition(columnID)); store == nil {
 				return errors.Wrap(err, "partition does not exist")
 			} else if colStr, err = store.TranslateID(columnID); err != nil {
 				return errors.Wrap(err, "translating column")
@@ -867,24 +908,19 @@ func (api *API) ExportCSV(ctx context.Context, indexName string, fieldName strin
 		return cw.Write([]string{rowStr, colStr})
 	}
 
-	citer, _, err := tx.ContainerIterator(indexName, fieldName, viewStandard, shard, 0)
+	rowIDs, err := f.rows(ctx, tx, 0)
 	if err != nil {
-		return err
+		return errors.Wrap(err, "listing rows")
 	}
-	var row, hi uint64
-	var failed error
-	process := func(u uint16) {
-		if err := fn(row, hi|uint64(u)); err != nil {
-			failed = err
+	for _, rowID := range rowIDs {
+		row, err := f.row(tx, rowID)
+		if err != nil {
+			return errors.Wrapf(err, "reading row %d", rowID)
 		}
-	}
-	for citer.Next() {
-		key, c := citer.Value()
-		hi = key << 16
-		row, hi = (hi / ShardWidth), (shard*ShardWidth)+(hi%ShardWidth)
-		roaring.ContainerCallback(c, process)
-		if failed != nil {
-			return errors.Wrap(err, "writing CSV")
+		for _, columnID := range row.Columns() {
+			if err := fn(rowID, columnID); err != nil {
+				return errors.Wrap(err, "writing CSV")
+			}
 		}
 	}
 
@@ -910,6 +946,20 @@ func (api *API) ShardNodes(ctx context.Context, indexName string, shard uint64)
 	return snap.ShardNodes(indexName, shard), nil
 }
 
+// MoveShard makes the node with the given ID the primary owner of a shard,
+// copying the shard's data to it if necessary. It must be called on the
+// primary node.
+func (api *API) MoveShard(ctx context.Context, indexName string, shard uint64, nodeID string) error {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.MoveShard")
+	defer span.Finish()
+
+	if err := api.validate(apiMoveShard); err != nil {
+		return errors.Wrap(err, "validating api method")
+	}
+
+	return api.cluster.MoveShard(indexName, shard, nodeID)
+}
+
 // PartitionNodes returns the node and all replicas which should contain a partition key data.
 func (api *API) PartitionNodes(ctx context.Context, partitionID int) ([]*disco.Node, error) {
 	span, _ := tracing.StartSpanFromContext(ctx, "API.PartitionNodes")
@@ -935,8 +985,10 @@ func (api *API) FragmentData(ctx context.Context, indexName, fieldName, viewName
 	}
 
 	// Retrieve fragment from holder.
-	f := api.holder.fragment(indexName, fieldName, viewName, shard)
-	if f == nil {
+	f, err := api.holder.fragment(indexName, fieldName, viewName, shard)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting fragment")
+	} else if f == nil {
 		return nil, ErrFragmentNotFound
 	}
 	return f, nil
@@ -1313,13 +1365,58 @@ func (r *txReadCloser) Close() error {
 type ImportOptions struct {
 	Clear          bool
 	IgnoreKeyCheck bool
-	Presorted      bool
 	suppressLog    bool
 
+	// Presorted indicates that the caller guarantees the input is already
+	// sorted, so the import can skip its own sorting. Values are sorted by
+	// column ID; for mutex set imports, column IDs must be strictly
+	// ascending, and for other set imports the (row ID, column ID) pairs
+	// must be in ascending order. If the guarantee doesn't hold, the result
+	// of the import is undefined (see importSortedParanoia).
+	Presorted bool
+
+	// Order describes the order of the row/column pairs in a non-mutex
+	// bulk import, so the import can specialize or skip its sort. A wrong
+	// Order doesn't corrupt the import, only slows it down (see also
+	// importSortedParanoia). Presorted implies ImportOrderRowMajor.
+	Order ImportOrder
+
+	// SkipCacheUpdate suppresses the row count cache maintenance an import
+	// otherwise does, for large loads into cached fields. The fragments it
+	// imports into are marked stale, and the caller is responsible for
+	// calling RecalculateCache once the load is done, which rebuilds them
+	// from storage. Until then, TopN and other cache reads may see stale or
+	// empty caches, and imports without the flag don't update them either.
+	// It's ignored for fields without a cache.
+	SkipCacheUpdate bool
+
+	// progress, if set, is called periodically during a roaring import;
+	// see importRoaringWithProgress.
+	progress func(importProgress)
+
 	// test Tx atomicity if > 0
 	SimPowerLossAfter int
 }
 
+// ImportOrder describes the order of the row/column pairs given to an
+// import.
+type ImportOrder int
+
+const (
+	// ImportOrderUnknown makes no promise about order; the import sorts
+	// the pairs itself.
+	ImportOrderUnknown ImportOrder = iota
+
+	// ImportOrderRowMajor promises pairs in ascending order by row, then by
+	// column within a row. That's the storage order, so nothing is sorted.
+	ImportOrderRowMajor
+
+	// ImportOrderColumnMajor promises pairs in ascending order by column,
+	// then by row within a column. The import only has to group the pairs
+	// by row, which is cheaper than sorting them.
+	ImportOrderColumnMajor
+)
+
 // ImportOption is a functional option type for API.Import.
 type ImportOption func(*ImportOptions) error
 
@@ -1348,6 +1445,24 @@ func OptImportOptionsPresorted(b bool) ImportOption {
 	}
 }
 
+// OptImportOptionsSkipCacheUpdate is a functional option on ImportOption
+// used to specify whether cache maintenance should be skipped.
+func OptImportOptionsSkipCacheUpdate(b bool) ImportOption {
+	return func(o *ImportOptions) error {
+		o.SkipCacheUpdate = b
+		return nil
+	}
+}
+
+// OptImportOptionsOrder is a functional option on ImportOption used to
+// describe the order of the imported pairs.
+func OptImportOptionsOrder(order ImportOrder) ImportOption {
+	return func(o *ImportOptions) error {
+		o.Order = order
+		return nil
+	}
+}
+
 func OptImportOptionsSuppressLog(b bool) ImportOption {
 	return func(o *ImportOptions) error {
 		o.suppressLog = b
@@ -1626,7 +1741,7 @@ func (api *API) ImportWithTx(ctx context.Context, qcx *Qcx, req *ImportRequest,
 	}
 
 	// Import into fragment.
-	err = field.Import(qcx, req.RowIDs, req.ColumnIDs, timestamps, req.Shard, options)
+	err = field.Import(ctx, qcx, req.RowIDs, req.ColumnIDs, timestamps, req.Shard, options)
 	if err != nil {
 		api.server.logger.Errorf("import error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
 		return errors.Wrap(err, "importing")
@@ -1947,17 +2062,17 @@ func (api *API) ImportValueWithTx(ctx context.Context, qcx *Qcx, req *ImportValu
 
 		// Import into fragment.
 		if len(req.Values) > 0 {
-			err = field.importValue(qcx, req.ColumnIDs, req.Values, shard, options)
+			err = field.importValue(ctx, qcx, req.ColumnIDs, req.Values, shard, options)
 			if err != nil {
 				api.server.logger.Errorf("import error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
 			}
 		} else if len(req.TimestampValues) > 0 {
-			err = field.importTimestampValue(qcx, req.ColumnIDs, req.TimestampValues, shard, options)
+			err = field.importTimestampValue(ctx, qcx, req.ColumnIDs, req.TimestampValues, shard, options)
 			if err != nil {
 				api.server.logger.Errorf("import error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
 			}
 		} else if len(req.FloatValues) > 0 {
-			err = field.importFloatValue(qcx, req.ColumnIDs, req.FloatValues, shard, options)
+			err = field.importFloatValue(ctx, qcx, req.ColumnIDs, req.FloatValues, shard, options)
 			if err != nil {
 				api.server.logger.Errorf("import error: index=%s, field=%s, shard=%d, columns=%d, err=%s", req.Index, req.Field, req.Shard, len(req.ColumnIDs), err)
 			}
@@ -2050,6 +2165,19 @@ func (api *API) ShardDistribution(ctx context.Context) map[string]interface{} {
 	return distByIndex
 }
 
+// OrphanFragments returns the paths of fragment storage on this node which
+// doesn't belong to the current schema, or to a shard this node holds. It
+// only reports them; nothing is deleted.
+func (api *API) OrphanFragments(ctx context.Context) ([]string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx, "API.OrphanFragments")
+	defer span.Finish()
+
+	if err := api.validate(apiOrphanFragments); err != nil {
+		return nil, errors.Wrap(err, "validating api method")
+	}
+	return api.cluster.findOrphanFragments()
+}
+
 // MaxShards returns the maximum shard number for each index in a map.
 // TODO (2.0): This method has been deprecated. Instead, use
 // AvailableShardsByIndex.
@@ -3222,6 +3350,9 @@ const (
 	apiMutexCheck
 	apiApplyChangeset
 	apiDeleteDataframe
+	apiMoveShard
+	apiTruncateField
+	apiOrphanFragments
 )
 
 var methodsCommon = map[apiMethod]struct{}{
@@ -3247,6 +3378,7 @@ var methodsDegraded = map[apiMethod]struct{}{
 	apiActiveQueries:     {},
 	apiPastQueries:       {},
 	apiPartitionNodes:    {},
+	apiOrphanFragments:   {},
 }
 
 var methodsNormal = map[apiMethod]struct{}{
@@ -3284,6 +3416,9 @@ var methodsNormal = map[apiMethod]struct{}{
 	apiMutexCheck:           {},
 	apiApplyChangeset:       {},
 	apiDeleteDataframe:      {},
+	apiMoveShard:            {},
+	apiTruncateField:        {},
+	apiOrphanFragments:      {},
 }
 
 func shardInShards(i dax.ShardNum, s dax.ShardNums) bool {
@@ -1356,6 +1356,94 @@ func TestAPI_CreateField(t *testing.T) {
 	}
 }
 
+func TestAPI_MoveShard(t *testing.T) {
+	c := test.MustRunCluster(t, 3)
+	defer c.Close()
+
+	ctx := context.Background()
+	coord := c.GetPrimary()
+	index := c.Idx()
+	if _, err := coord.API.CreateIndex(ctx, index, pilosa.IndexOptions{}); err != nil {
+		t.Fatalf("creating index: %v", err)
+	}
+	if _, err := coord.API.CreateField(ctx, index, "f"); err != nil {
+		t.Fatalf("creating field: %v", err)
+	}
+	c.Query(t, index, "Set(3, f=1) Set(7, f=1)")
+
+	owners, err := coord.API.ShardNodes(ctx, index, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var target, other *test.Command
+	for i := 0; i < 3; i++ {
+		node := c.GetNode(i)
+		if node.API.NodeID() == owners[0].ID {
+			continue
+		}
+		if target == nil {
+			target = node
+		} else {
+			other = node
+		}
+	}
+
+	// Only the primary can move shards.
+	if other.API.NodeID() != coord.API.NodeID() {
+		if err := other.API.MoveShard(ctx, index, 0, target.API.NodeID()); !errors.Is(err, pilosa.ErrNodeNotPrimary) {
+			t.Fatalf("expected not primary error, got %v", err)
+		}
+	}
+
+	if err := coord.API.MoveShard(ctx, index, 0, target.API.NodeID()); err != nil {
+		t.Fatal(err)
+	}
+	moved, err := coord.API.ShardNodes(ctx, index, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if moved[0].ID != target.API.NodeID() {
+		t.Fatalf("expected %s to be the primary, got %s", target.API.NodeID(), moved[0].ID)
+	}
+	hldr := test.Holder{Holder: target.Server.Holder()}
+	if cols := hldr.Row(index, "f", 1).Columns(); !reflect.DeepEqual(cols, []uint64{3, 7}) {
+		t.Fatalf("unexpected columns on target: %v", cols)
+	}
+
+	// Every node routes to the new owners, and a displaced owner no longer
+	// holds the shard.
+	for i := 0; i < 3; i++ {
+		node := c.GetNode(i)
+		got, err := node.API.ShardNodes(ctx, index, 0)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(got) != len(moved) {
+			t.Fatalf("node %s routes shard to %v, expected %v", node.API.NodeID(), got, moved)
+		}
+		for j := range got {
+			if got[j].ID != moved[j].ID {
+				t.Fatalf("node %s routes shard to %v, expected %v", node.API.NodeID(), got, moved)
+			}
+		}
+	}
+	for _, owner := range owners {
+		displaced := true
+		for _, node := range moved {
+			displaced = displaced && node.ID != owner.ID
+		}
+		if !displaced {
+			continue
+		}
+		for i := 0; i < 3; i++ {
+			if node := c.GetNode(i); node.API.NodeID() == owner.ID {
+				hldr := test.Holder{Holder: node.Server.Holder()}
+				if cols := hldr.ReadRow(index, "f", 1).Columns(); len(cols) != 0 {
+					t.Fatalf("expected displaced owner %s to have no data, got %v", owner.ID, cols)
+				}
+			}
+		}
+	}
+}
+
 func TestAPI_RBFDebugInfo(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
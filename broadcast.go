@@ -73,6 +73,9 @@ const (
 	messageTypeUNUSED3 // used to be ResizeAbortMessage
 	messageTypeUpdateField
 	messageTypeDeleteDataframe
+	messageTypeTruncateField
+	messageTypeSetCoordinator
+	messageTypeShardRoute
 )
 
 // MarshalInternalMessage serializes the pilosa message and adds pilosa internal
@@ -120,6 +123,12 @@ func getMessage(typ byte) Message {
 		return &UpdateFieldMessage{}
 	case messageTypeDeleteDataframe:
 		return &DeleteDataframeMessage{}
+	case messageTypeTruncateField:
+		return &TruncateFieldMessage{}
+	case messageTypeSetCoordinator:
+		return &SetCoordinatorMessage{}
+	case messageTypeShardRoute:
+		return &ShardRouteMessage{}
 	default:
 		panic(fmt.Sprintf("unknown message type %d", typ))
 	}
@@ -159,6 +168,12 @@ func getMessageType(m Message) byte {
 		return messageTypeUpdateField
 	case *DeleteDataframeMessage:
 		return messageTypeDeleteDataframe
+	case *TruncateFieldMessage:
+		return messageTypeTruncateField
+	case *SetCoordinatorMessage:
+		return messageTypeSetCoordinator
+	case *ShardRouteMessage:
+		return messageTypeShardRoute
 	default:
 		panic(fmt.Sprintf("don't have type for message %#v", m))
 	}
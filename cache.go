@@ -28,6 +28,9 @@ type cache interface {
 	Get(id uint64) uint64
 	Len() int
 
+	// Remove drops id from the cache, for rows which no longer have any bits.
+	Remove(id uint64)
+
 	// Returns a list of all IDs.
 	IDs() []uint64
 
@@ -84,6 +87,12 @@ func (c *lruCache) Get(id uint64) uint64 {
 // Len returns the number of items in the cache.
 func (c *lruCache) Len() int { return c.cache.Len() }
 
+// Remove removes an id from the cache.
+func (c *lruCache) Remove(id uint64) {
+	c.cache.Remove(id)
+	delete(c.counts, id)
+}
+
 // Invalidate is a no-op.
 func (c *lruCache) Invalidate() {}
 
@@ -234,6 +243,17 @@ func (c *rankCache) Len() int {
 	return len(c.entries)
 }
 
+// Remove removes an id from the cache.
+func (c *rankCache) Remove(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[id]; !ok {
+		return
+	}
+	delete(c.entries, id)
+	c.dirty = true
+}
+
 // IDs returns a list of all IDs in the cache.
 func (c *rankCache) IDs() []uint64 {
 	c.mu.Lock()
@@ -601,7 +621,8 @@ func (c nopCache) Invalidate()  {}
 func (c nopCache) Len() int     { return 0 }
 func (c nopCache) Recalculate() {}
 
-func (c nopCache) Clear() {}
+func (c nopCache) Clear()        {}
+func (c nopCache) Remove(uint64) {}
 
 func (c nopCache) Top() []bitmapPair {
 	return []bitmapPair{}
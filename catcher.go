@@ -231,3 +231,15 @@ func (c *catcherTx) GetSortedFieldViewList(idx *Index, shard uint64) (fvs []txke
 func (c *catcherTx) GetFieldSizeBytes(index, field string) (uint64, error) {
 	return 0, nil
 }
+
+func (c *catcherTx) BeforeCommit(fn func() error) {
+	c.b.BeforeCommit(fn)
+}
+
+func (c *catcherTx) AfterCommit(fn func()) {
+	c.b.AfterCommit(fn)
+}
+
+func (c *catcherTx) OnRollback(fn func()) {
+	c.b.OnRollback(fn)
+}
@@ -3,11 +3,17 @@
 package pilosa
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/featurebasedb/featurebase/v3/dax"
@@ -23,12 +29,20 @@ import (
 const (
 	defaultConfirmDownRetries = 10
 	defaultConfirmDownSleep   = 1 * time.Second
+
+	// coordinatorWaitInterval is how often awaitPrimaryNode checks for a
+	// coordinator, and coordinatorWaitTimeout how long it waits for one.
+	coordinatorWaitInterval = 50 * time.Millisecond
+	coordinatorWaitTimeout  = time.Minute
 )
 
 // cluster represents a collection of nodes.
 type cluster struct { //nolint: maligned
 	noder disco.Noder
 
+	// stator, if set, is consulted by NodesWithState for live node states.
+	stator disco.Stator
+
 	id   string
 	Node *disco.Node
 
@@ -47,6 +61,13 @@ type cluster struct { //nolint: maligned
 	// Maximum number of Set() or Clear() commands per request.
 	maxWritesPerRequest int
 
+	// Maximum number of key creations run at once on each local translate
+	// partition, or zero for no limit. keyCreateSems holds a semaphore for
+	// each partition keys have been created on.
+	maxKeyCreatesPerPartition int
+	keyCreateSemsMu           sync.Mutex
+	keyCreateSems             map[keyCreatePartition]chan struct{}
+
 	// Data directory path.
 	Path string
 
@@ -74,8 +95,30 @@ type cluster struct { //nolint: maligned
 
 	partitionAssigner string
 
+	// shardRouter, if set, overrides the assignment of shards to nodes in
+	// this cluster's snapshots.
+	shardRouter disco.ShardRouter
+
+	// shardMoves holds the shard routing overrides made by MoveShard. When
+	// set, it is also the shardRouter.
+	shardMoves *shardMoves
+
+	// coordinatorProvider, if set, overrides the choice of coordinator in
+	// this cluster's snapshots.
+	coordinatorProvider disco.CoordinatorProvider
+
+	// transferredCoordinator, if set, is the ID of the node the coordinator
+	// role was transferred to by TransferCoordinator, which is kept in
+	// coordinatorStore. It's ignored while there is a coordinatorProvider.
+	coordinatorMu          sync.RWMutex
+	transferredCoordinator string
+	coordinatorStore       disco.CoordinatorStore
+
 	serverlessStorage *storage.ResourceManager
 
+	// translateStats counts the outcomes of key lookups; see TranslateStats.
+	translateStats TranslateStats
+
 	// isComputeNode is set to true if this node is running as a DAX compute
 	// node.
 	isComputeNode bool
@@ -115,6 +158,26 @@ func (c *cluster) unprotectedPrimaryNode() *disco.Node {
 	return snap.PrimaryFieldTranslationNode()
 }
 
+// awaitPrimaryNode returns the primary node. While the coordinatorProvider
+// says there is none, as while the coordinator lease changes hands, it waits
+// for one, rather than acting as though the default coordinator were primary.
+func (c *cluster) awaitPrimaryNode(ctx context.Context) (*disco.Node, error) {
+	ctx, cancel := context.WithTimeout(ctx, coordinatorWaitTimeout)
+	defer cancel()
+	ticker := time.NewTicker(coordinatorWaitInterval)
+	defer ticker.Stop()
+	for {
+		if primary := c.primaryNode(); primary != nil || c.coordinatorProvider == nil {
+			return primary, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "waiting for a coordinator")
+		case <-ticker.C:
+		}
+	}
+}
+
 // nodeIDs returns the list of IDs in the cluster.
 func (c *cluster) nodeIDs() []string {
 	return disco.Nodes(c.Nodes()).IDs()
@@ -171,37 +234,356 @@ func (c *cluster) Nodes() []*disco.Node {
 	return result
 }
 
+// NodesWithState is like Nodes, but each node's State is looked up from the
+// cluster's stator rather than taken from the noder, which may be stale.
+// A node whose state can't be determined is reported as DOWN. Because this
+// calls the stator for every node, hot paths should use Nodes instead. If
+// the cluster has no stator, NodesWithState is equivalent to Nodes.
+func (c *cluster) NodesWithState(ctx context.Context) ([]*disco.Node, error) {
+	nodes := c.Nodes()
+	if c.stator == nil {
+		return nodes, nil
+	}
+	for _, node := range nodes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		state, err := c.stator.NodeState(ctx, node.ID)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			c.logger.Warnf("getting state of node %s: %v", node.ID, err)
+			state = disco.NodeStateDown
+		}
+		node.State = state
+	}
+	return nodes, nil
+}
+
+// UnderReplicatedShards returns the shards of the index which have fewer
+// than ReplicaN live copies, mapped to the number of live copies each has.
+// A copy is live if its node is STARTED according to NodesWithState.
+func (c *cluster) UnderReplicatedShards(ctx context.Context, indexName string) (map[uint64]int, error) {
+	idx := c.holder.Index(indexName)
+	if idx == nil {
+		return nil, newNotFoundError(ErrIndexNotFound, indexName)
+	}
+	nodes, err := c.NodesWithState(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting node states")
+	}
+	snap := c.newSnapshotFromNoder(disco.NewLocalNoder(nodes))
+
+	under := make(map[uint64]int)
+	for _, shard := range idx.AvailableShards(includeRemote).Slice() {
+		live := 0
+		for _, node := range snap.ShardNodes(indexName, shard) {
+			if node.State == disco.NodeStateStarted {
+				live++
+			}
+		}
+		if live < c.ReplicaN {
+			under[shard] = live
+		}
+	}
+	return under, nil
+}
+
+// validateNodeTopology returns an error if this cluster's partition or
+// replica count isn't positive, if two of the given nodes share an ID, or if
+// any other node was configured with a partition count, replica count, or
+// hasher different from this cluster's. Nodes which don't report these
+// values are not checked against them. This node's own, possibly earlier,
+// registration isn't checked; checkHasher catches a restart with a
+// different hasher. A replica count greater than the number of nodes is
+// allowed; snapshots clamp it to the number of nodes.
+func (c *cluster) validateNodeTopology(nodes []*disco.Node) error {
+	if c.partitionN <= 0 {
+		return errors.Errorf("invalid partitionN %d", c.partitionN)
+	} else if c.ReplicaN <= 0 {
+		return errors.Errorf("invalid replicaN %d", c.ReplicaN)
+	}
+
+	seen := make(map[string]struct{}, len(nodes))
+	for _, node := range nodes {
+		if _, ok := seen[node.ID]; ok {
+			return errors.Errorf("duplicate node ID %s", node.ID)
+		}
+		seen[node.ID] = struct{}{}
+
+		if c.Node != nil && node.ID == c.Node.ID {
+			continue
+		}
+		if node.PartitionN != 0 && node.PartitionN != c.partitionN {
+			return errors.Errorf("node %s has partitionN %d, but this node has %d", node.ID, node.PartitionN, c.partitionN)
+		}
+		if node.ReplicaN != 0 && node.ReplicaN != c.ReplicaN {
+			return errors.Errorf("node %s has replicaN %d, but this node has %d", node.ID, node.ReplicaN, c.ReplicaN)
+		}
+		if node.Hasher != "" && c.Hasher != nil && node.Hasher != c.hasherName() {
+			return errors.Errorf("node %s has hasher %s, but this node has %s", node.ID, node.Hasher, c.hasherName())
+		}
+	}
+	return nil
+}
+
+// hasherName returns the name of the way this cluster assigns partitions to
+// nodes: its hasher's name, or "modulus" if it assigns them by partition
+// number instead of hashing.
+func (c *cluster) hasherName() string {
+	if c.partitionAssigner == "modulus" {
+		return "modulus"
+	}
+	return c.Hasher.Name()
+}
+
+// hasherFile is the name of the file, in the cluster's data directory, which
+// holds the hasher the cluster was created with when the cluster's DisCo
+// can't store it.
+const hasherFile = ".hasher"
+
+// fileHasherStore is a disco.HasherStore kept in a file, for a DisCo which
+// doesn't implement it. Only this node reads the file, so it only keeps this
+// node from changing hashers; other nodes' hashers are checked by
+// validateNodeTopology.
+type fileHasherStore struct {
+	path string
+}
+
+// InitHasher implements disco.HasherStore.
+func (f *fileHasherStore) InitHasher(ctx context.Context, name string) (string, error) {
+	buf, err := os.ReadFile(f.path)
+	if err == nil {
+		return string(buf), nil
+	} else if !os.IsNotExist(err) {
+		return "", errors.Wrap(err, "reading hasher")
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(name), 0o600); err != nil {
+		return "", errors.Wrap(err, "writing hasher")
+	}
+	return name, errors.Wrap(os.Rename(tmp, f.path), "replacing hasher")
+}
+
+// checkHasher records this cluster's hasher the first time the cluster
+// starts, and returns an error if it was created with a different one,
+// since changing hashers would move almost every partition. The hasher is
+// kept by the cluster's DisCo, where every node can read it, if it
+// implements disco.HasherStore, and otherwise in the cluster's data
+// directory.
+func (c *cluster) checkHasher() error {
+	store, ok := c.disCo.(disco.HasherStore)
+	if !ok {
+		store = &fileHasherStore{path: filepath.Join(c.Path, hasherFile)}
+	}
+	name := c.hasherName()
+	recorded, err := store.InitHasher(context.Background(), name)
+	if err != nil {
+		return errors.Wrap(err, "recording hasher")
+	} else if recorded != name {
+		return errors.Errorf("cluster was created with hasher %s, but this node has %s", recorded, name)
+	}
+	return nil
+}
+
+// findOrphanFragments returns the paths of fragment storage on this node
+// which doesn't correspond to the current schema, or to a shard this node
+// holds, as a primary or replica, according to the cluster's snapshot. See
+// Holder.FindOrphanFragments.
+func (c *cluster) findOrphanFragments() ([]string, error) {
+	snap := c.NewSnapshot()
+	return c.holder.FindOrphanFragments(func(index string, shard uint64) bool {
+		return snap.OwnsShard(c.Node.ID, index, shard)
+	})
+}
+
 // shardDistributionByIndex returns a map of [nodeID][primaryOrReplica][]uint64,
 // where the int slices are lists of shards.
 func (c *cluster) shardDistributionByIndex(indexName string) map[string]map[string][]uint64 {
+	index := c.holder.Index(indexName)
+	available := index.AvailableShards(includeRemote).Slice()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Create a snapshot of the cluster to use for node/partition calculations.
+	return shardDistribution(c.NewSnapshot(), indexName, available)
+}
+
+// shardDistribution returns the shards' distribution among the snapshot's
+// nodes, in the form returned by shardDistributionByIndex.
+func shardDistribution(snap *disco.ClusterSnapshot, indexName string, shards []uint64) map[string]map[string][]uint64 {
 	dist := make(map[string]map[string][]uint64)
 
-	for _, node := range c.noder.Nodes() {
+	for _, node := range snap.Nodes {
 		nodeDist := make(map[string][]uint64)
 		nodeDist["primary-shards"] = make([]uint64, 0)
 		nodeDist["replica-shards"] = make([]uint64, 0)
 		dist[node.ID] = nodeDist
 	}
 
-	index := c.holder.Index(indexName)
-	available := index.AvailableShards(includeRemote).Slice()
+	for _, shard := range shards {
+		nodes := snap.ShardNodes(indexName, shard)
+		if len(nodes) == 0 {
+			continue
+		}
+		dist[nodes[0].ID]["primary-shards"] = append(dist[nodes[0].ID]["primary-shards"], shard)
+		for k := 1; k < len(nodes); k++ {
+			dist[nodes[k].ID]["replica-shards"] = append(dist[nodes[k].ID]["replica-shards"], shard)
+		}
+	}
+
+	return dist
+}
+
+// ClusterDescription is a serializable snapshot of a cluster's topology and
+// state, for debugging.
+type ClusterDescription struct {
+	Name        string             `json:"name"`
+	State       disco.ClusterState `json:"state"`
+	Coordinator string             `json:"coordinator"`
+	Hasher      string             `json:"hasher"`
+	PartitionN  int                `json:"partitionN"`
+	ReplicaN    int                `json:"replicaN"`
+	Nodes       []*disco.Node      `json:"nodes"`
+
+	// Shards holds each index's shard distribution, by index name, in the
+	// form returned by shardDistributionByIndex.
+	Shards map[string]map[string]map[string][]uint64 `json:"shards"`
+}
+
+// Describe returns a description of the cluster's nodes, state,
+// coordinator, and the distribution of each index's shards. The nodes are
+// read once, and everything else is computed from the same snapshot of
+// them, so the parts are consistent with each other. Node states are those
+// last seen by the noder, rather than looked up from a stator, so Describe
+// doesn't wait on other nodes.
+func (c *cluster) Describe() (*ClusterDescription, error) {
+	state, err := c.State()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting cluster state")
+	}
 
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	// Create a snapshot of the cluster to use for node/partition calculations.
+	nodes := c.Nodes()
+	snap := c.newSnapshotFromNoder(disco.NewLocalNoder(nodes))
+	desc := &ClusterDescription{
+		Name:       c.Name,
+		State:      state,
+		Hasher:     c.Hasher.Name(),
+		PartitionN: c.partitionN,
+		ReplicaN:   c.ReplicaN,
+		Nodes:      nodes,
+		Shards:     make(map[string]map[string]map[string][]uint64),
+	}
+	if coord := snap.PrimaryFieldTranslationNode(); coord != nil {
+		desc.Coordinator = coord.ID
+	}
+	if c.holder != nil {
+		for _, idx := range c.holder.Indexes() {
+			desc.Shards[idx.Name()] = shardDistribution(snap, idx.Name(), idx.AvailableShards(includeRemote).Slice())
+		}
+	}
+	return desc, nil
+}
+
+// ReplicaDivergence reports a fragment which differs between a shard's
+// primary and one of its replicas, or which couldn't be compared because one
+// of them couldn't be reached.
+type ReplicaDivergence struct {
+	Index string `json:"index"`
+	Field string `json:"field"`
+	View  string `json:"view"`
+	Shard uint64 `json:"shard"`
+
+	// Primary and Replica are the IDs of the nodes compared.
+	Primary string `json:"primary"`
+	Replica string `json:"replica"`
+
+	// Unreachable is the ID of the node, Primary or Replica, whose fragment
+	// couldn't be retrieved, in which case Err says why. It's empty for a
+	// genuine divergence.
+	Unreachable string `json:"unreachable,omitempty"`
+	Err         string `json:"error,omitempty"`
+}
+
+// VerifyReplicas compares the checksums of each fragment of an index on
+// each shard's primary with those on its replicas, retrieving the fragments
+// with the InternalClient, and reports the fragments which differ or
+// couldn't be retrieved. A fragment a node doesn't have is treated as
+// empty. The fields and views compared are those in the local schema.
+// Checksums only depend on which bits are set, so replicas which encode the
+// same bits differently don't diverge.
+func (c *cluster) VerifyReplicas(ctx context.Context, indexName string) ([]ReplicaDivergence, error) {
+	idx := c.holder.Index(indexName)
+	if idx == nil {
+		return nil, newNotFoundError(ErrIndexNotFound, indexName)
+	}
+	shards := idx.AvailableShards(includeRemote).Slice()
+	type fieldView struct{ field, view string }
+	var fieldViews []fieldView
+	for _, field := range idx.Fields() {
+		for _, view := range field.views() {
+			fieldViews = append(fieldViews, fieldView{field.Name(), view.name})
+		}
+	}
+
+	// The snapshot is taken up front, so no locks are held while fragments
+	// are retrieved.
+	c.mu.RLock()
 	snap := c.NewSnapshot()
+	c.mu.RUnlock()
 
-	for _, shard := range available {
-		p := snap.ShardToShardPartition(indexName, shard)
-		nodes := snap.PartitionNodes(p)
-		dist[nodes[0].ID]["primary-shards"] = append(dist[nodes[0].ID]["primary-shards"], shard)
-		for k := 1; k < len(nodes); k++ {
-			dist[nodes[k].ID]["replica-shards"] = append(dist[nodes[k].ID]["replica-shards"], shard)
+	var divergences []ReplicaDivergence
+	for _, shard := range shards {
+		nodes := snap.ShardNodes(indexName, shard)
+		if len(nodes) < 2 {
+			continue
+		}
+		primary := nodes[0]
+		for _, fv := range fieldViews {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			d := ReplicaDivergence{Index: indexName, Field: fv.field, View: fv.view, Shard: shard, Primary: primary.ID}
+			want, err := c.fragmentChecksum(ctx, primary, indexName, fv.field, fv.view, shard)
+			if err != nil {
+				// Nothing can be compared without the primary.
+				d.Unreachable, d.Err = primary.ID, err.Error()
+				for _, replica := range nodes[1:] {
+					d.Replica = replica.ID
+					divergences = append(divergences, d)
+				}
+				continue
+			}
+			for _, replica := range nodes[1:] {
+				d.Replica = replica.ID
+				got, err := c.fragmentChecksum(ctx, replica, indexName, fv.field, fv.view, shard)
+				if err != nil {
+					d.Unreachable, d.Err = replica.ID, err.Error()
+					divergences = append(divergences, d)
+					d.Unreachable, d.Err = "", ""
+				} else if !bytes.Equal(got, want) {
+					divergences = append(divergences, d)
+				}
+			}
 		}
 	}
+	return divergences, nil
+}
 
-	return dist
+// fragmentChecksum retrieves a fragment from node and returns the checksum
+// of its bits, as computed by roaringChecksum. A fragment the node doesn't
+// have has the checksum of no bits.
+func (c *cluster) fragmentChecksum(ctx context.Context, node *disco.Node, index, field, view string, shard uint64) ([]byte, error) {
+	data, err := c.retrieveFragmentData(ctx, index, field, view, shard, node)
+	if err != nil {
+		return nil, errors.Wrapf(err, "retrieving fragment from node %s", node.ID)
+	}
+	return roaringChecksum(data)
 }
 
 func (c *cluster) close() error {
@@ -229,6 +611,33 @@ func (c *cluster) unprotectedPrimaryReplicaNode() *disco.Node {
 	return cNodes[pos-1]
 }
 
+// TranslateStats counts the outcomes of looking up existing keys, by key:
+// found in a local translation store, found by asking another node, or not
+// found at all. The counts are cumulative from when the node started, and
+// aren't reset by reading them. Creating keys isn't counted.
+type TranslateStats struct {
+	LocalHits  uint64 `json:"localHits"`
+	RemoteHits uint64 `json:"remoteHits"`
+	Misses     uint64 `json:"misses"`
+}
+
+// TranslateStats returns the cluster's key lookup counts.
+func (c *cluster) TranslateStats() TranslateStats {
+	return TranslateStats{
+		LocalHits:  atomic.LoadUint64(&c.translateStats.LocalHits),
+		RemoteHits: atomic.LoadUint64(&c.translateStats.RemoteHits),
+		Misses:     atomic.LoadUint64(&c.translateStats.Misses),
+	}
+}
+
+// countTranslations adds to the cluster's key lookup counts. Lookups may
+// run concurrently, so the counts are updated atomically.
+func (c *cluster) countTranslations(localHits, remoteHits, misses int) {
+	atomic.AddUint64(&c.translateStats.LocalHits, uint64(localHits))
+	atomic.AddUint64(&c.translateStats.RemoteHits, uint64(remoteHits))
+	atomic.AddUint64(&c.translateStats.Misses, uint64(misses))
+}
+
 // TODO: remove this when it is no longer used
 func (c *cluster) translateFieldKeys(ctx context.Context, field *Field, keys []string, writable bool) ([]uint64, error) {
 	var trans map[string]uint64
@@ -288,6 +697,7 @@ func (c *cluster) findFieldKeys(ctx context.Context, field *Field, keys ...strin
 	}
 	if len(missing) == 0 {
 		// All keys were available locally.
+		c.countTranslations(len(localTranslations), 0, 0)
 		return localTranslations, nil
 	}
 
@@ -298,6 +708,7 @@ func (c *cluster) findFieldKeys(ctx context.Context, field *Field, keys ...strin
 	}
 	if c.Node.ID == primary.ID {
 		// The local copy is the authoritative copy.
+		c.countTranslations(len(localTranslations), 0, len(missing))
 		return localTranslations, nil
 	}
 
@@ -307,6 +718,7 @@ func (c *cluster) findFieldKeys(ctx context.Context, field *Field, keys ...strin
 	if err != nil {
 		return nil, errors.Wrapf(err, "translating field(%s/%s) keys(%v) remotely", field.Index(), field.Name(), keys)
 	}
+	c.countTranslations(len(localTranslations), len(remoteTranslations), len(missing)-len(remoteTranslations))
 
 	// Merge the remote translations into the local translations.
 	translations := localTranslations
@@ -367,8 +779,10 @@ func (c *cluster) createFieldKeys(ctx context.Context, field *Field, keys ...str
 	}
 
 	// The primary is the only node that can create field keys, since it owns the authoritative copy.
-	primary := c.primaryNode()
-	if primary == nil {
+	primary, err := c.awaitPrimaryNode(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "translating field(%s/%s) keys(%v)", field.Index(), field.Name(), keys)
+	} else if primary == nil {
 		return nil, errors.Errorf("translating field(%s/%s) keys(%v) - cannot find primary node", field.Index(), field.Name(), keys)
 	}
 	if c.Node.ID == primary.ID {
@@ -579,9 +993,9 @@ func (c *cluster) findIndexKeys(ctx context.Context, indexName string, keys ...s
 	snap := c.NewSnapshot()
 
 	// Split keys by partition.
-	keysByPartition := make(map[int][]string, c.partitionN)
+	keysByPartition := make(map[int][]string, idx.PartitionN())
 	for _, key := range keys {
-		partitionID := snap.KeyToKeyPartition(indexName, key)
+		partitionID := idx.keyPartition(key)
 		keysByPartition[partitionID] = append(keysByPartition[partitionID], key)
 
 		// This node only handles keys for the partition(s) that it owns.
@@ -628,6 +1042,7 @@ func (c *cluster) findIndexKeys(ctx context.Context, indexName string, keys ...s
 			if err != nil {
 				return errors.Wrapf(err, "translating index(%s) keys(%v) on node %s", indexName, keys, node.ID)
 			}
+			c.countTranslations(0, len(translations), len(keys)-len(translations))
 
 			remoteResults <- translations
 			return nil
@@ -649,6 +1064,7 @@ func (c *cluster) findIndexKeys(ctx context.Context, indexName string, keys ...s
 		if err != nil {
 			return nil, errors.Wrapf(err, "translating index(%s) keys(%v) on partition(%d)", idx.Name(), keys, partitionID)
 		}
+		c.countTranslations(len(t), 0, len(keys)-len(t))
 
 		// Merge the translations from this partition.
 		for key, id := range t {
@@ -695,9 +1111,9 @@ func (c *cluster) createIndexKeys(ctx context.Context, indexName string, keys ..
 	snap := c.NewSnapshot()
 
 	// Split keys by partition.
-	keysByPartition := make(map[int][]string, c.partitionN)
+	keysByPartition := make(map[int][]string, idx.PartitionN())
 	for _, key := range keys {
-		partitionID := snap.KeyToKeyPartition(indexName, key)
+		partitionID := idx.keyPartition(key)
 		keysByPartition[partitionID] = append(keysByPartition[partitionID], key)
 
 		// This node only handles keys for the partition(s) that it owns.
@@ -735,6 +1151,20 @@ func (c *cluster) createIndexKeys(ctx context.Context, indexName string, keys ..
 	var g errgroup.Group
 	defer g.Wait() //nolint:errcheck
 
+	// If the keys span several partitions, some may be created before
+	// another fails. So that a failed request leaves no new keys behind,
+	// the IDs each local partition's store reports allocating are noted,
+	// and deleted again if anything fails. Keys created on other nodes, or
+	// by stores which can't report their allocations, can't be deleted from
+	// here, but since creating keys is idempotent, a retry of the request
+	// reconciles them. On compute nodes, created keys are sent to the write
+	// log, so they're never deleted.
+	var allocatedMu sync.Mutex
+	var allocated map[int][]uint64
+	if len(keysByNode)+len(keysByPartition) > 1 && !c.isComputeNode {
+		allocated = make(map[int][]uint64, len(keysByPartition))
+	}
+
 	// Start translating keys remotely.
 	// On child calls, there are no remote results since we were only sent the keys that we own.
 	for node, keys := range keysByNode {
@@ -766,7 +1196,22 @@ func (c *cluster) createIndexKeys(ctx context.Context, indexName string, keys ..
 			default:
 			}
 
-			translations, err := idx.TranslateStore(partitionID).CreateKeys(keys...)
+			release, err := c.acquireKeyCreate(ctx, idx.Name(), partitionID)
+			if err != nil {
+				return err
+			}
+			var translations map[string]uint64
+			store := idx.TranslateStore(partitionID)
+			if a, ok := store.(keyAllocator); ok && allocated != nil {
+				var ids []uint64
+				translations, ids, err = a.createKeysAllocated(keys...)
+				allocatedMu.Lock()
+				allocated[partitionID] = ids
+				allocatedMu.Unlock()
+			} else {
+				translations, err = store.CreateKeys(keys...)
+			}
+			release()
 			if err != nil {
 				return errors.Wrapf(err, "translating index(%s) keys(%v) on partition(%d)", idx.Name(), keys, partitionID)
 			}
@@ -789,6 +1234,7 @@ func (c *cluster) createIndexKeys(ctx context.Context, indexName string, keys ..
 
 	// Wait for remote key sets.
 	if err := g.Wait(); err != nil {
+		c.deleteNewIndexKeys(idx, allocated)
 		return nil, err
 	}
 
@@ -805,6 +1251,65 @@ func (c *cluster) createIndexKeys(ctx context.Context, indexName string, keys ..
 	return translations, nil
 }
 
+// keyCreatePartition identifies one of an index's translate partitions.
+type keyCreatePartition struct {
+	index     string
+	partition int
+}
+
+// acquireKeyCreate waits until a key creation may run on a partition of an
+// index, or ctx is done, and returns a function to call once it has. Each
+// call holds at most one partition's semaphore, and releases it before
+// returning to the errgroup, so concurrent requests can't deadlock.
+func (c *cluster) acquireKeyCreate(ctx context.Context, index string, partitionID int) (func(), error) {
+	if c.maxKeyCreatesPerPartition <= 0 {
+		return func() {}, nil
+	}
+	key := keyCreatePartition{index: index, partition: partitionID}
+	c.keyCreateSemsMu.Lock()
+	sem := c.keyCreateSems[key]
+	if sem == nil {
+		if c.keyCreateSems == nil {
+			c.keyCreateSems = make(map[keyCreatePartition]chan struct{})
+		}
+		sem = make(chan struct{}, c.maxKeyCreatesPerPartition)
+		c.keyCreateSems[key] = sem
+	}
+	c.keyCreateSemsMu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// deleteNewIndexKeys deletes the given IDs, by partition, from the index's
+// local translation stores. It's used to undo a failed createIndexKeys,
+// including keys a failed CreateKeys committed before failing. Errors are
+// logged, since the caller is already failing.
+func (c *cluster) deleteNewIndexKeys(idx *Index, allocated map[int][]uint64) {
+	for partitionID, created := range allocated {
+		if len(created) == 0 {
+			continue
+		}
+		store := idx.TranslateStore(partitionID)
+		ids := roaring.NewBitmap(created...)
+		commitor, err := store.Delete(ids)
+		if err != nil {
+			if commitor != nil {
+				commitor.Rollback()
+			}
+			c.logger.Errorf("deleting index(%s) keys on partition(%d): %v", idx.Name(), partitionID, err)
+			continue
+		}
+		if err := commitor.Commit(); err != nil {
+			c.logger.Errorf("committing deletion of index(%s) keys on partition(%d): %v", idx.Name(), partitionID, err)
+		}
+	}
+}
+
 func (c *cluster) translateIndexIDs(ctx context.Context, indexName string, ids []uint64) ([]string, error) {
 	idSet := make(map[uint64]struct{})
 	for _, id := range ids {
@@ -835,9 +1340,14 @@ func (c *cluster) translateIndexIDSet(ctx context.Context, indexName string, idS
 	snap := c.NewSnapshot()
 
 	// Split ids by partition.
-	idsByPartition := make(map[int][]uint64, c.partitionN)
+	idsByPartition := make(map[int][]uint64, index.PartitionN())
 	for id := range idSet {
-		partitionID := snap.IDToShardPartition(indexName, id)
+		partitionID := index.idPartition(id)
+		if partitionID >= index.PartitionN() {
+			// No key-partition allocates this ID, so it has no key.
+			idMap[id] = ""
+			continue
+		}
 		// This node only handles keys for the partition(s) that it owns.
 		if c.isComputeNode {
 			if !intInPartitions(partitionID, index.translatePartitions) {
@@ -887,8 +1397,383 @@ func (c *cluster) translateIndexIDSet(ctx context.Context, indexName string, idS
 	return idMap, nil
 }
 
+// DeleteColumnKey deletes the keyed column identified by key from the
+// index: its bits are cleared from every field and view, and its key/ID
+// mapping is removed from the translate partition that owns it, on every
+// node holding a replica of the column's shard. Deleting a key which
+// doesn't exist is not an error.
+//
+// Fields whose foreign index is this index refer to the column by its ID,
+// which is reused once its key is deleted. Those references are cleared
+// first, from every shard of the referencing index: the column's row is
+// cleared from set, mutex and time fields, and int fields whose value is the
+// column's ID lose their value. If that fails, the column is left alone.
+func (c *cluster) DeleteColumnKey(ctx context.Context, indexName string, key string) error {
+	idx := c.holder.Index(indexName)
+	if idx == nil {
+		return newNotFoundError(ErrIndexNotFound, indexName)
+	} else if !idx.Keys() {
+		return errors.Errorf("cannot delete column key on unkeyed index %q", indexName)
+	}
+
+	// Look the key up on the primary for its partition.
+	ids, err := c.findIndexKeys(ctx, indexName, key)
+	if err != nil {
+		return errors.Wrap(err, "finding column key")
+	}
+	id, ok := ids[key]
+	if !ok {
+		return nil
+	}
+	shard := id / ShardWidth
+
+	snap := c.NewSnapshot()
+	for _, other := range c.holder.Indexes() {
+		for _, field := range other.Fields() {
+			if field.ForeignIndex() != indexName {
+				continue
+			}
+			if err := c.clearForeignReferences(ctx, snap, field, id); err != nil {
+				return errors.Wrapf(err, "clearing references from foreign index field %s/%s", other.Name(), field.Name())
+			}
+		}
+	}
+
+	// Delete the column from each replica of its shard. Column IDs are
+	// allocated so that a column's shard maps to the same partition as its
+	// key, even on an index with fewer key-partitions than the cluster (see
+	// Index.idPartition), so each replica also drops its copy of the key.
+	g, ctx := errgroup.WithContext(ctx)
+	for _, node := range snap.ShardNodes(indexName, shard) {
+		node := node
+		g.Go(func() error {
+			if node.ID == c.Node.ID {
+				_, err := DeleteRowsWithFlow(ctx, NewRow(id), idx, shard, true)
+				return errors.Wrap(err, "deleting column locally")
+			}
+			resp, err := c.InternalClient.QueryNode(ctx, dax.Address(node.URI.String()), indexName, &QueryRequest{
+				Query:  fmt.Sprintf("Delete(ConstRow(columns=[%d]))", id),
+				Shards: []uint64{shard},
+				Remote: true,
+			})
+			if err != nil {
+				return errors.Wrapf(err, "deleting column on node %s", node.ID)
+			}
+			return errors.Wrapf(resp.Err, "deleting column on node %s", node.ID)
+		})
+	}
+	return g.Wait()
+}
+
+// clearForeignReferences clears references to the column id of field's
+// foreign index from every replica of every shard of field's index.
+func (c *cluster) clearForeignReferences(ctx context.Context, snap *disco.ClusterSnapshot, field *Field, id uint64) error {
+	indexName := field.Index()
+	byNode := make(map[string][]uint64)
+	nodes := make(map[string]*disco.Node)
+	for _, shard := range field.idx.AvailableShards(includeRemote).Slice() {
+		for _, node := range snap.ShardNodes(indexName, shard) {
+			byNode[node.ID] = append(byNode[node.ID], shard)
+			nodes[node.ID] = node
+		}
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	for nodeID, shards := range byNode {
+		node, shards := nodes[nodeID], shards
+		g.Go(func() error {
+			if node.ID == c.Node.ID {
+				return c.clearLocalForeignReferences(field, shards, id)
+			}
+			return c.clearRemoteForeignReferences(ctx, node, field, shards, id)
+		})
+	}
+	return g.Wait()
+}
+
+// clearLocalForeignReferences clears references to the column id from this
+// node's fragments of field in shards.
+func (c *cluster) clearLocalForeignReferences(field *Field, shards []uint64, id uint64) (err0 error) {
+	qcx := c.holder.Txf().NewWritableQcx()
+	defer func() {
+		if err0 != nil {
+			qcx.Abort()
+			return
+		}
+		err0 = qcx.Finish()
+	}()
+
+	bsig := field.bsiGroup(field.Name())
+	for _, shard := range shards {
+		tx, finisher, err := qcx.GetTx(Txo{Write: writable, Index: field.idx, Shard: shard})
+		if err != nil {
+			return err
+		}
+		var frag *fragment
+		if bsig != nil {
+			if frag, err = c.holder.fragment(field.Index(), field.Name(), viewBSIGroupPrefix+field.Name(), shard); err == nil && frag != nil {
+				_, err = frag.clearValueEQ(tx, bsig.BitDepth, int64(id)-bsig.Base)
+			}
+		} else {
+			for _, view := range field.views() {
+				if frag, err = c.holder.fragment(field.Index(), field.Name(), view.name, shard); err != nil {
+					break
+				} else if frag != nil {
+					if _, err = frag.clearRow(tx, id); err != nil {
+						break
+					}
+				}
+			}
+		}
+		finisher(&err)
+		if err != nil {
+			return errors.Wrapf(err, "clearing shard %d", shard)
+		}
+	}
+	return nil
+}
+
+// clearRemoteForeignReferences clears references to the column id from
+// node's fragments of field in shards.
+func (c *cluster) clearRemoteForeignReferences(ctx context.Context, node *disco.Node, field *Field, shards []uint64, id uint64) error {
+	query := func(pql string) (*QueryResponse, error) {
+		resp, err := c.InternalClient.QueryNode(ctx, dax.Address(node.URI.String()), field.Index(), &QueryRequest{
+			Query:  pql,
+			Shards: shards,
+			Remote: true,
+		})
+		if err == nil {
+			err = resp.Err
+		}
+		return resp, errors.Wrapf(err, "querying node %s", node.ID)
+	}
+
+	if field.bsiGroup(field.Name()) == nil {
+		_, err := query(fmt.Sprintf("ClearRow(%s=%d)", field.Name(), id))
+		return err
+	}
+
+	// Int fields are cleared column by column, having found the columns
+	// whose value is id.
+	resp, err := query(fmt.Sprintf("Row(%s==%d)", field.Name(), id))
+	if err != nil {
+		return err
+	}
+	row, ok := resp.Results[0].(*Row)
+	if !ok {
+		return errors.Errorf("unexpected result type %T from node %s", resp.Results[0], node.ID)
+	}
+	var clears strings.Builder
+	for _, col := range row.Columns() {
+		fmt.Fprintf(&clears, "Clear(%d, %s=%d)", col, field.Name(), id)
+	}
+	if clears.Len() == 0 {
+		return nil
+	}
+	_, err = query(clears.String())
+	return err
+}
+
 func (c *cluster) NewSnapshot() *disco.ClusterSnapshot {
-	return disco.NewClusterSnapshot(c.noder, c.Hasher, c.partitionAssigner, c.ReplicaN)
+	return c.newSnapshotFromNoder(c.noder)
+}
+
+// newSnapshotFromNoder is like NewSnapshot, but takes its nodes from noder
+// instead of the cluster's own noder.
+func (c *cluster) newSnapshotFromNoder(noder disco.Noder) *disco.ClusterSnapshot {
+	snap := disco.NewClusterSnapshot(noder, c.Hasher, c.partitionAssigner, c.ReplicaN)
+	snap.ShardRouter = c.shardRouter
+	snap.CoordinatorProvider = c.coordinatorProvider
+	if id := c.transferredCoordinatorID(); id != "" && c.coordinatorProvider == nil {
+		snap.CoordinatorProvider = transferredCoordinator(id)
+	}
+	return snap
+}
+
+// transferredCoordinator is the disco.CoordinatorProvider used once the
+// coordinator role has been transferred to the node it names.
+type transferredCoordinator string
+
+func (id transferredCoordinator) CoordinatorID() (string, error) {
+	return string(id), nil
+}
+
+// transferredCoordinatorID returns the ID of the node the coordinator role
+// was last transferred to, or "" if it hasn't been.
+func (c *cluster) transferredCoordinatorID() string {
+	c.coordinatorMu.RLock()
+	defer c.coordinatorMu.RUnlock()
+	return c.transferredCoordinator
+}
+
+// setCoordinator makes nodeID the coordinator on this node, and resets
+// translation replication, so that field keys are created on, and
+// replicated from, the new coordinator.
+func (c *cluster) setCoordinator(nodeID string) {
+	c.coordinatorMu.Lock()
+	c.transferredCoordinator = nodeID
+	c.coordinatorMu.Unlock()
+	if err := c.translationSyncer.Reset(); err != nil {
+		c.logger.Errorf("resetting translation sync for coordinator %s: %v", nodeID, err)
+	}
+}
+
+// coordinatorFile is the name of the file, in the cluster's data directory,
+// which holds the node the coordinator role was transferred to when the
+// cluster's DisCo can't store it.
+const coordinatorFile = ".coordinator"
+
+// fileCoordinatorStore is a disco.CoordinatorStore kept in a file, for a
+// DisCo which doesn't implement it. Only the node which wrote the file reads
+// it, so other nodes only learn of a transfer as it's broadcast.
+type fileCoordinatorStore struct {
+	path string
+}
+
+// TransferredCoordinator implements disco.CoordinatorStore.
+func (f *fileCoordinatorStore) TransferredCoordinator(ctx context.Context) (string, error) {
+	buf, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrap(err, "reading coordinator")
+	}
+	return string(buf), nil
+}
+
+// SetTransferredCoordinator implements disco.CoordinatorStore. It replaces
+// the previous file only once the new one is complete.
+func (f *fileCoordinatorStore) SetTransferredCoordinator(ctx context.Context, nodeID string) error {
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(nodeID), 0o600); err != nil {
+		return errors.Wrap(err, "writing coordinator")
+	}
+	return errors.Wrap(os.Rename(tmp, f.path), "replacing coordinator")
+}
+
+// loadCoordinator restores the coordinator chosen by an earlier call to
+// TransferCoordinator. The choice is kept by the cluster's DisCo, where
+// every node can read it, if it implements disco.CoordinatorStore, and
+// otherwise in the cluster's data directory.
+func (c *cluster) loadCoordinator() error {
+	store, ok := c.disCo.(disco.CoordinatorStore)
+	if !ok {
+		store = &fileCoordinatorStore{path: filepath.Join(c.Path, coordinatorFile)}
+	}
+	id, err := store.TransferredCoordinator(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "reading transferred coordinator")
+	}
+	c.coordinatorMu.Lock()
+	defer c.coordinatorMu.Unlock()
+	c.coordinatorStore = store
+	c.transferredCoordinator = id
+	return nil
+}
+
+// TransferCoordinator makes another node the coordinator, the node which is
+// primary for field key translation, on every node in the cluster. It must
+// be called on the current coordinator, and the new one must be a started
+// member of the cluster. Should the new coordinator later leave the
+// cluster, the default coordinator is used again.
+//
+// This node stops creating field keys, and copies its field translations to
+// the new coordinator, before the new coordinator is stored and broadcast,
+// so the new coordinator has every key. The coordinator can't be
+// transferred while a coordinatorProvider, such as the coordinator lease,
+// chooses it.
+func (c *cluster) TransferCoordinator(newNodeID string) error {
+	ctx := context.Background()
+	if c.coordinatorProvider != nil {
+		return errors.New("the coordinator can't be transferred while a coordinator provider chooses it")
+	}
+	if coord := c.primaryNode(); coord == nil || c.Node == nil || coord.ID != c.Node.ID {
+		return errors.New("the coordinator can only be transferred by the current coordinator")
+	}
+	nodes, err := c.NodesWithState(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting node states")
+	}
+	var target *disco.Node
+	for _, node := range nodes {
+		if node.ID == newNodeID {
+			target = node
+			break
+		}
+	}
+	if target == nil {
+		return errors.Errorf("node %s is not in the cluster", newNodeID)
+	} else if target.State != disco.NodeStateStarted {
+		return errors.Errorf("node %s is %s, not %s", newNodeID, target.State, disco.NodeStateStarted)
+	}
+
+	if target.ID == c.Node.ID {
+		return nil
+	}
+
+	// Keys created after the copy would be missing on the new coordinator,
+	// so none are created from here on, unless the transfer fails.
+	restore := c.setFieldTranslateReadOnly()
+	if err := c.syncFieldTranslation(ctx, target); err != nil {
+		restore()
+		return errors.Wrapf(err, "copying field translation to %s", newNodeID)
+	}
+	if c.coordinatorStore != nil {
+		if err := c.coordinatorStore.SetTransferredCoordinator(ctx, newNodeID); err != nil {
+			restore()
+			return errors.Wrap(err, "storing new coordinator")
+		}
+	}
+	if err := c.broadcaster.SendSync(&SetCoordinatorMessage{NodeID: newNodeID}); err != nil {
+		return errors.Wrap(err, "broadcasting new coordinator")
+	}
+	c.setCoordinator(newNodeID)
+	return nil
+}
+
+// setFieldTranslateReadOnly makes every field translation store read-only,
+// and returns a function which restores each store's previous state.
+func (c *cluster) setFieldTranslateReadOnly() (restore func()) {
+	var stores []TranslateStore
+	var prev []bool
+	for _, idx := range c.holder.Indexes() {
+		for _, fld := range idx.Fields() {
+			if store := fld.TranslateStore(); store != nil {
+				stores = append(stores, store)
+				prev = append(prev, store.ReadOnly())
+				store.SetReadOnly(true)
+			}
+		}
+	}
+	return func() {
+		for i, store := range stores {
+			store.SetReadOnly(prev[i])
+		}
+	}
+}
+
+// syncFieldTranslation copies this node's translation store of every keyed
+// field to node, replacing the node's copy.
+func (c *cluster) syncFieldTranslation(ctx context.Context, node *disco.Node) error {
+	for _, idx := range c.holder.Indexes() {
+		for _, fld := range idx.Fields() {
+			store := fld.TranslateStore()
+			if !fld.Keys() || fld.ForeignIndex() != "" || store == nil {
+				continue
+			}
+			readerFunc := func() (io.Reader, error) {
+				var buf bytes.Buffer
+				if _, err := store.WriteTo(&buf); err != nil {
+					return nil, err
+				}
+				return &buf, nil
+			}
+			if err := c.InternalClient.ImportFieldKeys(ctx, &node.URI, idx.Name(), fld.Name(), true, readerFunc); err != nil {
+				return errors.Wrapf(err, "field %s/%s", idx.Name(), fld.Name())
+			}
+		}
+	}
+	return nil
 }
 
 // ClusterStatus describes the status of the cluster including its
@@ -949,6 +1834,13 @@ type DeleteFieldMessage struct {
 	Field string
 }
 
+// TruncateFieldMessage is an internal message indicating that all of a
+// field's data has been deleted.
+type TruncateFieldMessage struct {
+	Index string
+	Field string
+}
+
 // DeleteAvailableShardMessage is an internal message indicating available shard deletion.
 type DeleteAvailableShardMessage struct {
 	Index   string
@@ -970,6 +1862,20 @@ type DeleteViewMessage struct {
 	View  string
 }
 
+// SetCoordinatorMessage is an internal message indicating that the
+// coordinator role has been transferred to a node.
+type SetCoordinatorMessage struct {
+	NodeID string
+}
+
+// ShardRouteMessage is an internal message indicating that the nodes which
+// own a shard have been overridden, as by MoveShard.
+type ShardRouteMessage struct {
+	Index   string
+	Shard   uint64
+	NodeIDs []string
+}
+
 // NodeStateMessage is an internal message for broadcasting a node's state.
 type NodeStateMessage struct {
 	NodeID string `protobuf:"bytes,1,opt,name=NodeID,proto3" json:"NodeID,omitempty"`
@@ -3,15 +3,31 @@
 package pilosa
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"testing/quick"
+	"time"
 
 	"github.com/featurebasedb/featurebase/v3/disco"
 	pnet "github.com/featurebasedb/featurebase/v3/net"
 	"github.com/featurebasedb/featurebase/v3/roaring"
+	"golang.org/x/sync/errgroup"
 )
 
 // Ensure the cluster can fairly distribute partitions across the nodes.
@@ -179,3 +195,1743 @@ func TestCluster_Nodes(t *testing.T) {
 		}
 	})
 }
+
+// Ensure replicas of a partition are spread across zones when possible, and
+// that placement is deterministic.
+func TestCluster_ZonePlacement(t *testing.T) {
+	newNodes := func() []*disco.Node {
+		return []*disco.Node{
+			{ID: "node0", Zone: "a"},
+			{ID: "node1", Zone: "a"},
+			{ID: "node2", Zone: "b"},
+			{ID: "node3", Zone: "b"},
+		}
+	}
+	newSnap := func(replicaN int) *disco.ClusterSnapshot {
+		return disco.NewClusterSnapshot(disco.NewLocalNoder(newNodes()), &disco.Jmphasher{}, "jmp-hash", replicaN)
+	}
+
+	t.Run("Spread", func(t *testing.T) {
+		snap := newSnap(2)
+		for p := 0; p < snap.PartitionN; p++ {
+			nodes := snap.PartitionNodes(p)
+			if len(nodes) != 2 {
+				t.Fatalf("partition %d: expected 2 nodes, got %v", p, nodes)
+			} else if nodes[0].Zone == nodes[1].Zone {
+				t.Fatalf("partition %d: replicas co-located in zone %q: %v", p, nodes[0].Zone, nodes)
+			} else if nodes[0] != snap.PrimaryPartitionNode(p) {
+				t.Fatalf("partition %d: expected primary first, got %v", p, nodes)
+			}
+
+			// The other replica-computing methods must agree.
+			if other := snap.NonPrimaryReplicas(p); !reflect.DeepEqual(other, []string{nodes[1].ID}) {
+				t.Fatalf("partition %d: NonPrimaryReplicas %v disagrees with %v", p, other, nodes)
+			}
+			replicas, _ := snap.ReplicasForPrimary(snap.PrimaryNodeIndex(p))
+			if len(replicas) != 2 || !replicas[nodes[0].ID] {
+				t.Fatalf("partition %d: ReplicasForPrimary %v disagrees with %v", p, replicas, nodes)
+			} else if _, ok := replicas[nodes[1].ID]; !ok {
+				t.Fatalf("partition %d: ReplicasForPrimary %v disagrees with %v", p, replicas, nodes)
+			}
+		}
+		for shard := uint64(0); shard < 64; shard++ {
+			for _, n := range snap.ShardNodes("i", shard) {
+				if !snap.OwnsShard(n.ID, "i", shard) {
+					t.Fatalf("shard %d: OwnsShard disagrees with ShardNodes for %s", shard, n.ID)
+				}
+			}
+		}
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		a, b := newSnap(2), newSnap(2)
+		for p := 0; p < a.PartitionN; p++ {
+			if x, y := disco.Nodes(a.PartitionNodes(p)).IDs(), disco.Nodes(b.PartitionNodes(p)).IDs(); !reflect.DeepEqual(x, y) {
+				t.Fatalf("partition %d: placement differs between snapshots: %v != %v", p, x, y)
+			}
+		}
+	})
+
+	t.Run("FewerZonesThanReplicas", func(t *testing.T) {
+		snap := newSnap(3)
+		for p := 0; p < snap.PartitionN; p++ {
+			nodes := snap.PartitionNodes(p)
+			if len(nodes) != 3 {
+				t.Fatalf("partition %d: expected 3 nodes, got %v", p, nodes)
+			}
+			zones := map[string]int{}
+			ids := map[string]struct{}{}
+			for _, n := range nodes {
+				zones[n.Zone]++
+				ids[n.ID] = struct{}{}
+			}
+			if len(zones) != 2 || len(ids) != 3 {
+				t.Fatalf("partition %d: expected 3 distinct nodes in both zones, got %v", p, nodes)
+			}
+		}
+	})
+
+	t.Run("NoZones", func(t *testing.T) {
+		// Without zone labels, placement is the plain ring walk.
+		snap := disco.NewClusterSnapshot(disco.NewIDNoder([]string{"node0", "node1", "node2", "node3"}), &disco.Jmphasher{}, "jmp-hash", 2)
+		for p := 0; p < snap.PartitionN; p++ {
+			primary := snap.PrimaryNodeIndex(p)
+			nodes := snap.PartitionNodes(p)
+			if nodes[0] != snap.Nodes[primary] || nodes[1] != snap.Nodes[(primary+1)%4] {
+				t.Fatalf("partition %d: unexpected placement %v", p, nodes)
+			}
+		}
+	})
+}
+
+// fakeStator is a disco.Stator which returns canned node states.
+type fakeStator struct {
+	states map[string]disco.NodeState
+	calls  int
+}
+
+func (s *fakeStator) NodeState(ctx context.Context, nodeID string) (disco.NodeState, error) {
+	s.calls++
+	state, ok := s.states[nodeID]
+	if !ok {
+		return disco.NodeStateUnknown, errors.New("no such node")
+	}
+	return state, nil
+}
+
+// Ensure NodesWithState reports node states from the stator.
+func TestCluster_NodesWithState(t *testing.T) {
+	c := newCluster()
+	c.noder = disco.NewLocalNoder([]*disco.Node{
+		{ID: "node0", State: disco.NodeStateStarted},
+		{ID: "node1", State: disco.NodeStateStarted},
+		{ID: "node2", State: disco.NodeStateStarted},
+	})
+	stator := &fakeStator{states: map[string]disco.NodeState{
+		"node0": disco.NodeStateStarted,
+		"node1": disco.NodeStateStarting,
+	}}
+	c.stator = stator
+
+	nodes, err := c.NodesWithState(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []disco.NodeState{disco.NodeStateStarted, disco.NodeStateStarting, disco.NodeStateDown}
+	for i, node := range nodes {
+		if node.State != exp[i] {
+			t.Fatalf("node %s: expected state %s, got %s", node.ID, exp[i], node.State)
+		}
+	}
+
+	// Nodes must not consult the stator or be affected by the lookup above.
+	stator.calls = 0
+	for _, node := range c.Nodes() {
+		if node.State != disco.NodeStateStarted {
+			t.Fatalf("node %s: expected noder state, got %s", node.ID, node.State)
+		}
+	}
+	if stator.calls != 0 {
+		t.Fatalf("expected Nodes not to call the stator, got %d calls", stator.calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.NodesWithState(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// Ensure deleting a column key removes its bits, its key mapping, and
+// references to it from foreign index fields.
+func TestCluster_DeleteColumnKey(t *testing.T) {
+	h := newTestHolder(t)
+	c := newCluster()
+	c.holder = h
+	c.Node = &disco.Node{ID: "node0"}
+	c.noder = disco.NewLocalNoder([]*disco.Node{c.Node})
+	c.partitionN = h.partitionN
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{Keys: true, TrackExistence: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	ids, err := c.createIndexKeys(ctx, "i", "gone", "kept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	qcx := h.Txf().NewWritableQcx()
+	for _, id := range ids {
+		if _, err := f.SetBit(qcx, 1, id, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.existenceField().SetBit(qcx, 0, id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := qcx.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Columns 1 and 2 of index o refer to "gone" and "kept" respectively,
+	// by row and by value.
+	other, err := h.CreateIndex("o", "", IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := other.CreateField("ref", "", OptFieldForeignIndex("i"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	num, err := other.CreateField("num", "", OptFieldTypeInt(0, math.MaxInt64), OptFieldForeignIndex("i"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	qcx = h.Txf().NewWritableQcx()
+	for col, key := range map[uint64]string{1: "gone", 2: "kept"} {
+		if _, err := ref.SetBit(qcx, ids[key], col, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := num.SetValue(qcx, col, int64(ids[key])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := qcx.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.DeleteColumnKey(ctx, "i", "gone"); err != nil {
+		t.Fatal(err)
+	}
+	// Deleting a missing key is a no-op.
+	if err := c.DeleteColumnKey(ctx, "i", "never"); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := c.findIndexKeys(ctx, "i", "gone", "kept")
+	if err != nil {
+		t.Fatal(err)
+	} else if _, ok := found["gone"]; ok {
+		t.Fatal("expected deleted key mapping to be gone")
+	} else if found["kept"] != ids["kept"] {
+		t.Fatalf("expected kept key to map to %d, got %v", ids["kept"], found)
+	}
+
+	qcx = h.Txf().NewQcx()
+	defer qcx.Abort()
+	row, err := f.Row(qcx, 1)
+	if err != nil {
+		t.Fatal(err)
+	} else if got := row.Columns(); !reflect.DeepEqual(got, []uint64{ids["kept"]}) {
+		t.Fatalf("expected only column %d to remain, got %v", ids["kept"], got)
+	}
+
+	if row, err := ref.Row(qcx, ids["gone"]); err != nil {
+		t.Fatal(err)
+	} else if got := row.Columns(); len(got) != 0 {
+		t.Fatalf("expected references to deleted column to be cleared, got %v", got)
+	}
+	if row, err := ref.Row(qcx, ids["kept"]); err != nil {
+		t.Fatal(err)
+	} else if got := row.Columns(); !reflect.DeepEqual(got, []uint64{2}) {
+		t.Fatalf("expected reference to kept column to remain, got %v", got)
+	}
+	if _, exists, err := num.Value(qcx, 1); err != nil {
+		t.Fatal(err)
+	} else if exists {
+		t.Fatal("expected value referring to deleted column to be cleared")
+	}
+	if v, exists, err := num.Value(qcx, 2); err != nil {
+		t.Fatal(err)
+	} else if !exists || v != int64(ids["kept"]) {
+		t.Fatalf("expected value %d to remain, got %d (exists=%v)", ids["kept"], v, exists)
+	}
+}
+
+// Ensure deleting a column key on an index with fewer key-partitions than
+// the cluster removes the key, and that the column's shard is owned by the
+// nodes which translate its key, so they all drop their copy of it.
+func TestCluster_DeleteColumnKey_PartitionN(t *testing.T) {
+	h := newTestHolder(t)
+	c := newCluster()
+	c.holder = h
+	c.Node = &disco.Node{ID: "node0"}
+	c.noder = disco.NewLocalNoder([]*disco.Node{c.Node})
+	c.partitionN = h.partitionN
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{Keys: true, TrackExistence: true, PartitionN: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("k%d", i)
+	}
+	ids, err := c.createIndexKeys(ctx, "i", keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qcx := h.Txf().NewWritableQcx()
+	for _, id := range ids {
+		if _, err := idx.existenceField().SetBit(qcx, 0, id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := qcx.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	// On a larger cluster, each column's shard has the same nodes as its
+	// key's partition.
+	wide := newCluster()
+	wide.partitionN = h.partitionN
+	wide.ReplicaN = 2
+	wide.noder = disco.NewIDNoder([]string{"node0", "node1", "node2", "node3", "node4"})
+	snap := wide.NewSnapshot()
+	nodeIDs := func(nodes []*disco.Node) (ids []string) {
+		for _, node := range nodes {
+			ids = append(ids, node.ID)
+		}
+		return ids
+	}
+	for key, id := range ids {
+		partitionID := idx.keyPartition(key)
+		if p := idx.idPartition(id); p != partitionID {
+			t.Fatalf("key %q in partition %d, but its id %d in partition %d", key, partitionID, id, p)
+		}
+		shardNodes := nodeIDs(snap.ShardNodes("i", id/ShardWidth))
+		if keyNodes := nodeIDs(snap.PartitionNodes(partitionID)); !reflect.DeepEqual(shardNodes, keyNodes) {
+			t.Fatalf("key %q on nodes %v, but its shard on %v", key, keyNodes, shardNodes)
+		}
+	}
+
+	for _, key := range keys[:10] {
+		if err := c.DeleteColumnKey(ctx, "i", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found, err := c.findIndexKeys(ctx, "i", keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys[:10] {
+		if _, ok := found[key]; ok {
+			t.Fatalf("expected deleted key %q to be gone", key)
+		}
+	}
+	for _, key := range keys[10:] {
+		if found[key] != ids[key] {
+			t.Fatalf("expected kept key %q to map to %d, got %v", key, ids[key], found)
+		}
+	}
+}
+
+// Ensure inconsistent topologies, and nodes configured with a different
+// partition or replica count, are rejected.
+func TestCluster_ValidateNodeTopology(t *testing.T) {
+	c := newCluster()
+	c.partitionN = 256
+	c.ReplicaN = 2
+	c.Node = &disco.Node{ID: "node0", PartitionN: 256, ReplicaN: 2}
+
+	if err := c.validateNodeTopology([]*disco.Node{
+		c.Node,
+		{ID: "node1", PartitionN: 256, ReplicaN: 2},
+		{ID: "node2"}, // doesn't report its configuration
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := c.validateNodeTopology([]*disco.Node{
+		c.Node,
+		{ID: "node1", PartitionN: 128, ReplicaN: 2},
+	}); err == nil || !strings.Contains(err.Error(), "partitionN 128") {
+		t.Fatalf("expected partitionN mismatch, got %v", err)
+	}
+
+	if err := c.validateNodeTopology([]*disco.Node{
+		{ID: "node1", PartitionN: 256, ReplicaN: 3},
+	}); err == nil || !strings.Contains(err.Error(), "replicaN 3") {
+		t.Fatalf("expected replicaN mismatch, got %v", err)
+	}
+
+	if err := c.validateNodeTopology([]*disco.Node{
+		c.Node,
+		{ID: "node1", PartitionN: 256, ReplicaN: 2, Hasher: "rendezvous"},
+	}); err == nil || !strings.Contains(err.Error(), "hasher rendezvous") {
+		t.Fatalf("expected hasher mismatch, got %v", err)
+	}
+
+	if err := c.validateNodeTopology([]*disco.Node{
+		c.Node,
+		{ID: "node1"},
+		{ID: "node1"},
+	}); err == nil || !strings.Contains(err.Error(), "duplicate node ID node1") {
+		t.Fatalf("expected duplicate node ID, got %v", err)
+	}
+
+	// A fresh cluster with no nodes yet is valid.
+	if err := c.validateNodeTopology(nil); err != nil {
+		t.Fatalf("unexpected error for empty topology: %v", err)
+	}
+
+	c.ReplicaN = 0
+	if err := c.validateNodeTopology(nil); err == nil || !strings.Contains(err.Error(), "invalid replicaN 0") {
+		t.Fatalf("expected invalid replicaN, got %v", err)
+	}
+	c.ReplicaN, c.partitionN = 2, 0
+	if err := c.validateNodeTopology(nil); err == nil || !strings.Contains(err.Error(), "invalid partitionN 0") {
+		t.Fatalf("expected invalid partitionN, got %v", err)
+	}
+}
+
+// Ensure hashers are selected by name, and the selected hasher assigns
+// partitions to nodes.
+func TestCluster_Hasher(t *testing.T) {
+	if _, err := disco.NewHasher("md5"); err == nil {
+		t.Fatal("expected error for unknown hasher")
+	}
+	if h, err := disco.NewHasher(""); err != nil {
+		t.Fatal(err)
+	} else if h.Name() != disco.DefaultHasherName {
+		t.Fatalf("expected default hasher, got %s", h.Name())
+	}
+
+	noder := disco.NewIDNoder([]string{"node0", "node1", "node2", "node3", "node4"})
+	for _, name := range disco.HasherNames() {
+		t.Run(name, func(t *testing.T) {
+			h, err := disco.NewHasher(name)
+			if err != nil {
+				t.Fatal(err)
+			} else if h.Name() != name {
+				t.Fatalf("expected hasher %s, got %s", name, h.Name())
+			}
+
+			c := newCluster()
+			c.Hasher = h
+			c.noder = noder
+			snap := c.NewSnapshot()
+			used := make(map[int]bool)
+			for partition := 0; partition < 256; partition++ {
+				i := h.Hash(uint64(partition), 5)
+				if i < 0 || i >= 5 {
+					t.Fatalf("partition %d hashed out of range to %d", partition, i)
+				}
+				if got := snap.PrimaryNodeIndex(partition); got != i {
+					t.Fatalf("partition %d: expected node %d, got %d", partition, i, got)
+				}
+				used[i] = true
+			}
+			if len(used) != 5 {
+				t.Fatalf("expected partitions on all 5 nodes, got %d", len(used))
+			}
+		})
+	}
+}
+
+// Ensure the hasher is fixed when a cluster is first started, and a restart
+// with a different one is rejected.
+func TestCluster_CheckHasher(t *testing.T) {
+	dir := t.TempDir()
+	newHasherCluster := func(name, assigner string) *cluster {
+		h, err := disco.NewHasher(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := newCluster()
+		c.Path = dir
+		c.Hasher = h
+		c.partitionAssigner = assigner
+		return c
+	}
+
+	if err := newHasherCluster("rendezvous", "").checkHasher(); err != nil {
+		t.Fatalf("first start: %v", err)
+	}
+	if err := newHasherCluster("rendezvous", "").checkHasher(); err != nil {
+		t.Fatalf("restart with the same hasher: %v", err)
+	}
+	if err := newHasherCluster("jump-hash", "").checkHasher(); err == nil || !strings.Contains(err.Error(), "created with hasher rendezvous") {
+		t.Fatalf("expected hasher mismatch, got %v", err)
+	}
+	if err := newHasherCluster("rendezvous", "modulus").checkHasher(); err == nil || !strings.Contains(err.Error(), "this node has modulus") {
+		t.Fatalf("expected modulus assignment mismatch, got %v", err)
+	}
+}
+
+// Ensure shards with a replica on a down node are reported under-replicated.
+func TestCluster_UnderReplicatedShards(t *testing.T) {
+	h := newTestHolder(t)
+	c := newCluster()
+	c.holder = h
+	c.partitionN = h.partitionN
+	c.ReplicaN = 2
+	c.noder = disco.NewLocalNoder([]*disco.Node{
+		{ID: "node0", State: disco.NodeStateStarted},
+		{ID: "node1", State: disco.NodeStateStarted},
+		{ID: "node2", State: disco.NodeStateStarted},
+	})
+	c.stator = &fakeStator{states: map[string]disco.NodeState{
+		"node0": disco.NodeStateStarted,
+		"node2": disco.NodeStateStarted,
+	}} // node1 is unknown to the stator, so it's DOWN
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	qcx := h.Txf().NewWritableQcx()
+	for shard := uint64(0); shard < 10; shard++ {
+		if _, err := f.SetBit(qcx, 1, shard*ShardWidth, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := qcx.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	under, err := c.UnderReplicatedShards(context.Background(), "i")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap := c.NewSnapshot()
+	exp := make(map[uint64]int)
+	for shard := uint64(0); shard < 10; shard++ {
+		if disco.Nodes(snap.ShardNodes("i", shard)).ContainsID("node1") {
+			exp[shard] = 1
+		}
+	}
+	if len(exp) == 0 {
+		t.Fatal("expected node1 to own some shards")
+	}
+	if !reflect.DeepEqual(under, exp) {
+		t.Fatalf("expected under-replicated shards %v, got %v", exp, under)
+	}
+}
+
+// Ensure replicas are compared fragment by fragment, and unreachable
+// replicas are reported apart from divergent ones.
+func TestCluster_VerifyReplicas(t *testing.T) {
+	// Each node serves its own fragment data, by shard, archived as by
+	// fragment.WriteTo. A shard missing from a node's data is a fragment it
+	// doesn't have.
+	serve := func(data map[string][]byte) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/internal/fragment/data" || r.URL.Query().Get("field") != "f" {
+				http.NotFound(w, r)
+				return
+			}
+			b, ok := data[r.URL.Query().Get("shard")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			writeFragmentArchive(w, b)
+		}))
+	}
+	// Shard 0 agrees, although only one node has the fragment, and it's
+	// empty. Shard 1 has the same bits, in a different encoding. Shard 2
+	// diverges.
+	var buf bytes.Buffer
+	bm := roaring.NewBitmap(1, 2, 3)
+	bm.Optimize()
+	if _, err := bm.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	srv0 := serve(map[string][]byte{"0": roaringBytes(t), "1": roaringBytes(t, 1, 2, 3), "2": roaringBytes(t, 1, 2)})
+	defer srv0.Close()
+	srv1 := serve(map[string][]byte{"1": buf.Bytes(), "2": roaringBytes(t, 1, 3)})
+	defer srv1.Close()
+
+	h := newTestHolder(t)
+	c := newCluster()
+	c.holder = h
+	c.ReplicaN = 2
+	var nodes []*disco.Node
+	for i, srv := range []*httptest.Server{srv0, srv1} {
+		uri, err := pnet.NewURIFromAddress(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, &disco.Node{ID: fmt.Sprintf("node%d", i), URI: *uri})
+	}
+	c.Node = nodes[0]
+	c.noder = disco.NewLocalNoder(nodes)
+	c.InternalClient = NewInternalClientFromURI(&nodes[0].URI, http.DefaultClient)
+	ctx := context.Background()
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.createViewIfNotExists(viewStandard); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddRemoteAvailableShards(roaring.NewBitmap(0, 1, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.VerifyReplicas(ctx, "missing"); !errors.Is(err, ErrIndexNotFound) {
+		t.Fatalf("expected ErrIndexNotFound, got %v", err)
+	}
+
+	snap := c.NewSnapshot()
+	ds, err := c.VerifyReplicas(ctx, "i")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes2 := snap.ShardNodes("i", 2)
+	exp := []ReplicaDivergence{{Index: "i", Field: "f", View: viewStandard, Shard: 2, Primary: nodes2[0].ID, Replica: nodes2[1].ID}}
+	if !reflect.DeepEqual(ds, exp) {
+		t.Fatalf("expected %+v, got %+v", exp, ds)
+	}
+
+	// Once node1 is down, every shard has a fragment which can't be
+	// compared, whether node1 is its primary or its replica.
+	srv1.Close()
+	ds, err = c.VerifyReplicas(ctx, "i")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 3 {
+		t.Fatalf("expected 3 unreachable fragments, got %+v", ds)
+	}
+	for i, d := range ds {
+		if d.Shard != uint64(i) || d.Unreachable != "node1" || d.Err == "" {
+			t.Fatalf("expected shard %d to be unreachable on node1, got %+v", i, d)
+		}
+	}
+}
+
+// failingCreateStore is a TranslateStore whose CreateKeys commits only the
+// first commit keys and then fails, like a failed fsync partway through.
+type failingCreateStore struct {
+	TranslateStore
+	commit int
+}
+
+func (s *failingCreateStore) CreateKeys(keys ...string) (map[string]uint64, error) {
+	_, _, err := s.createKeysAllocated(keys...)
+	return nil, err
+}
+
+func (s *failingCreateStore) createKeysAllocated(keys ...string) (map[string]uint64, []uint64, error) {
+	if len(keys) > s.commit {
+		keys = keys[:s.commit]
+	}
+	_, allocated, err := s.TranslateStore.(keyAllocator).createKeysAllocated(keys...)
+	if err != nil {
+		return nil, allocated, err
+	}
+	return nil, allocated, errors.New("fsync failed")
+}
+
+// Ensure a failed multi-partition key creation leaves no new keys behind,
+// and can be retried.
+func TestCluster_CreateIndexKeys_Failure(t *testing.T) {
+	h := newTestHolder(t)
+	c := newCluster()
+	c.holder = h
+	c.partitionN = h.partitionN
+	c.Node = &disco.Node{ID: "node0"}
+	c.noder = disco.NewLocalNoder([]*disco.Node{c.Node})
+	ctx := context.Background()
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{Keys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 64)
+	byPartition := make(map[int][]string)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("k%d", i)
+		byPartition[idx.keyPartition(keys[i])] = append(byPartition[idx.keyPartition(keys[i])], keys[i])
+	}
+	failing := -1
+	for partitionID, keys := range byPartition {
+		if len(keys) > 1 {
+			failing = partitionID
+			break
+		}
+	}
+	if failing < 0 {
+		t.Fatal("expected two keys in some partition")
+	}
+	old, err := idx.TranslateStore(idx.keyPartition("k0")).CreateKeys("k0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := idx.TranslateStore(failing)
+	idx.mu.Lock()
+	idx.translateStores[failing] = &failingCreateStore{TranslateStore: store, commit: 1}
+	idx.mu.Unlock()
+	if _, err := c.createIndexKeys(ctx, "i", keys...); err == nil || !strings.Contains(err.Error(), "fsync failed") {
+		t.Fatalf("expected fsync failure, got %v", err)
+	}
+	for partitionID, keys := range byPartition {
+		found, err := idx.TranslateStore(partitionID).FindKeys(keys...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key := range found {
+			if key != "k0" {
+				t.Fatalf("expected key %s on partition %d to be deleted", key, partitionID)
+			}
+		}
+	}
+	if found, err := idx.TranslateStore(idx.keyPartition("k0")).FindKeys("k0"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(found, old) {
+		t.Fatalf("expected existing key to be kept, got %v", found)
+	}
+
+	// Once the failure is fixed, a retry creates every key.
+	idx.mu.Lock()
+	idx.translateStores[failing] = store
+	idx.mu.Unlock()
+	trans, err := c.createIndexKeys(ctx, "i", keys...)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(trans) != len(keys) || trans["k0"] != old["k0"] {
+		t.Fatalf("expected %d keys with k0=%d, got %v", len(keys), old["k0"], trans)
+	}
+}
+
+// trackingCreateStore is a TranslateStore which records how many CreateKeys
+// calls run on it at once, and on all the stores sharing its total.
+type trackingCreateStore struct {
+	TranslateStore
+	active, max           int32
+	totalActive, totalMax *int32
+}
+
+func (s *trackingCreateStore) CreateKeys(keys ...string) (map[string]uint64, error) {
+	raiseMax(&s.max, atomic.AddInt32(&s.active, 1))
+	raiseMax(s.totalMax, atomic.AddInt32(s.totalActive, 1))
+	defer atomic.AddInt32(&s.active, -1)
+	defer atomic.AddInt32(s.totalActive, -1)
+	time.Sleep(50 * time.Millisecond)
+	return s.TranslateStore.CreateKeys(keys...)
+}
+
+// raiseMax sets *max to n if n is larger.
+func raiseMax(max *int32, n int32) {
+	for {
+		old := atomic.LoadInt32(max)
+		if n <= old || atomic.CompareAndSwapInt32(max, old, n) {
+			return
+		}
+	}
+}
+
+// Ensure key creations on one partition are limited by
+// maxKeyCreatesPerPartition, while those on different partitions run at
+// once.
+func TestCluster_CreateIndexKeys_PerPartitionLimit(t *testing.T) {
+	h := newTestHolder(t)
+	c := newCluster()
+	c.holder = h
+	c.partitionN = h.partitionN
+	c.Node = &disco.Node{ID: "node0"}
+	c.noder = disco.NewLocalNoder([]*disco.Node{c.Node})
+	c.maxKeyCreatesPerPartition = 1
+	ctx := context.Background()
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{Keys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Find two keys in each of two partitions.
+	byPartition := make(map[int][]string)
+	var partitions []int
+	for i := 0; len(partitions) < 2; i++ {
+		key := fmt.Sprintf("k%d", i)
+		partitionID := idx.keyPartition(key)
+		if len(byPartition[partitionID]) == 2 {
+			continue
+		}
+		byPartition[partitionID] = append(byPartition[partitionID], key)
+		if len(byPartition[partitionID]) == 2 {
+			partitions = append(partitions, partitionID)
+		}
+	}
+
+	var totalActive, totalMax int32
+	stores := make(map[int]*trackingCreateStore)
+	idx.mu.Lock()
+	for _, partitionID := range partitions {
+		stores[partitionID] = &trackingCreateStore{TranslateStore: idx.translateStores[partitionID], totalActive: &totalActive, totalMax: &totalMax}
+		idx.translateStores[partitionID] = stores[partitionID]
+	}
+	idx.mu.Unlock()
+
+	// Each key is created by its own request.
+	var g errgroup.Group
+	results := make(map[string]uint64)
+	var resultsMu sync.Mutex
+	for _, partitionID := range partitions {
+		for _, key := range byPartition[partitionID] {
+			key := key
+			g.Go(func() error {
+				trans, err := c.createIndexKeys(ctx, "i", key)
+				if err != nil {
+					return err
+				}
+				resultsMu.Lock()
+				defer resultsMu.Unlock()
+				for k, id := range trans {
+					results[k] = id
+				}
+				return nil
+			})
+		}
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, partitionID := range partitions {
+		if max := atomic.LoadInt32(&stores[partitionID].max); max != 1 {
+			t.Fatalf("expected key creations on partition %d to run one at a time, got %d at once", partitionID, max)
+		}
+		found, err := idx.TranslateStore(partitionID).FindKeys(byPartition[partitionID]...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range byPartition[partitionID] {
+			if id, ok := found[key]; !ok || id != results[key] {
+				t.Fatalf("expected key %s to be created as %d, found %d (%v)", key, results[key], id, ok)
+			}
+		}
+	}
+	if max := atomic.LoadInt32(&totalMax); max != 2 {
+		t.Fatalf("expected key creations on different partitions to run at once, got %d at once", max)
+	}
+}
+
+// Ensure key lookups are counted as local hits, remote hits, or misses.
+func TestCluster_TranslateStats(t *testing.T) {
+	// The remote node knows every key except those starting with "x".
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var keys []string
+		if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		trans := make(map[string]uint64)
+		for i, key := range keys {
+			if !strings.HasPrefix(key, "x") {
+				trans[key] = uint64(100 + i)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(trans)
+	}))
+	defer srv.Close()
+	uri, err := pnet.NewURIFromAddress(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHolder(t)
+	c := newCluster()
+	c.holder = h
+	c.partitionN = h.partitionN
+	nodes := []*disco.Node{{ID: "node0", URI: *uri}, {ID: "node1", URI: *uri}}
+	c.noder = disco.NewLocalNoder(nodes)
+	// Make sure the local node isn't the primary.
+	if c.primaryNode().ID == nodes[0].ID {
+		c.Node = nodes[1]
+	} else {
+		c.Node = nodes[0]
+	}
+	c.InternalClient = NewInternalClientFromURI(uri, http.DefaultClient)
+	ctx := context.Background()
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{Keys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "", OptFieldKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.TranslateStore().CreateKeys("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Field keys missing locally are looked up on the primary.
+	if _, err := c.findFieldKeys(ctx, f, "a", "b", "r", "x"); err != nil {
+		t.Fatal(err)
+	} else if got, exp := c.TranslateStats(), (TranslateStats{LocalHits: 2, RemoteHits: 1, Misses: 1}); got != exp {
+		t.Fatalf("expected %+v, got %+v", exp, got)
+	}
+
+	// Index keys are looked up wherever their partition's primary is.
+	snap := c.NewSnapshot()
+	var local, remote []string
+	for i := 0; len(local) < 2 || len(remote) < 2; i++ {
+		key := fmt.Sprintf("k%d", i)
+		if snap.PrimaryPartitionNode(idx.keyPartition(key)).ID == c.Node.ID {
+			local = append(local, key)
+		} else {
+			remote = append(remote, key)
+		}
+	}
+	if _, err := idx.TranslateStore(idx.keyPartition(local[0])).CreateKeys(local[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.findIndexKeys(ctx, "i", local[0], local[1], remote[0], remote[1]); err != nil {
+		t.Fatal(err)
+	} else if got, exp := c.TranslateStats(), (TranslateStats{LocalHits: 3, RemoteHits: 3, Misses: 2}); got != exp {
+		t.Fatalf("expected %+v, got %+v", exp, got)
+	}
+}
+
+// Ensure a cluster's description covers its nodes, state, coordinator, and
+// shard distribution, and serializes.
+func TestCluster_Describe(t *testing.T) {
+	h := newTestHolder(t)
+	c := newCluster()
+	c.holder = h
+	c.Name = "c"
+	c.ReplicaN = 2
+	c.noder = disco.NewLocalNoder([]*disco.Node{
+		{ID: "node0", State: disco.NodeStateStarted},
+		{ID: "node1", State: disco.NodeStateStarted},
+		{ID: "node2", State: disco.NodeStateStarted},
+	})
+	c.coordinatorProvider = &fakeCoordinatorProvider{id: "node2"}
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	qcx := h.Txf().NewWritableQcx()
+	for shard := uint64(0); shard < 6; shard++ {
+		if _, err := f.SetBit(qcx, 1, shard*ShardWidth, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := qcx.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	desc, err := c.Describe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Name != "c" || desc.State != disco.ClusterStateNormal || desc.Coordinator != "node2" {
+		t.Fatalf("unexpected name, state, or coordinator: %s, %s, %s", desc.Name, desc.State, desc.Coordinator)
+	} else if desc.Hasher != "jump-hash" || desc.PartitionN != disco.DefaultPartitionN || desc.ReplicaN != 2 {
+		t.Fatalf("unexpected hasher, partitionN, or replicaN: %s, %d, %d", desc.Hasher, desc.PartitionN, desc.ReplicaN)
+	} else if got := disco.Nodes(desc.Nodes).IDs(); !reflect.DeepEqual(got, []string{"node0", "node1", "node2"}) {
+		t.Fatalf("unexpected nodes: %v", got)
+	}
+	if exp := c.shardDistributionByIndex("i"); !reflect.DeepEqual(desc.Shards, map[string]map[string]map[string][]uint64{"i": exp}) {
+		t.Fatalf("expected shards %v, got %v", exp, desc.Shards)
+	}
+	var primaries, replicas int
+	for _, dist := range desc.Shards["i"] {
+		primaries += len(dist["primary-shards"])
+		replicas += len(dist["replica-shards"])
+	}
+	if primaries != 6 || replicas != 6 {
+		t.Fatalf("expected 6 primary and 6 replica shards, got %d and %d", primaries, replicas)
+	}
+
+	if _, err := json.Marshal(desc); err != nil {
+		t.Fatalf("marshaling description: %v", err)
+	}
+}
+
+// Ensure that a non-primary node translates the keys it already has locally,
+// and only forwards the missing keys to the primary to be created.
+func TestCluster_CreateFieldKeys_ForwardsMissing(t *testing.T) {
+	var forwarded []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var keys []string
+		if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		forwarded = append(forwarded, keys...)
+		trans := make(map[string]uint64, len(keys))
+		for i, key := range keys {
+			trans[key] = uint64(100 + i)
+		}
+		_ = json.NewEncoder(w).Encode(trans)
+	}))
+	defer srv.Close()
+	uri, err := pnet.NewURIFromAddress(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHolder(t)
+	c := newCluster()
+	c.holder = h
+	c.partitionN = h.partitionN
+	nodes := []*disco.Node{{ID: "node0", URI: *uri}, {ID: "node1", URI: *uri}}
+	c.noder = disco.NewLocalNoder(nodes)
+	// Make sure the local node isn't the primary.
+	if c.primaryNode().ID == nodes[0].ID {
+		c.Node = nodes[1]
+	} else {
+		c.Node = nodes[0]
+	}
+	c.InternalClient = NewInternalClientFromURI(uri, http.DefaultClient)
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "", OptFieldKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := f.TranslateStore().CreateKeys("a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	trans, err := c.createFieldKeys(ctx, f, "a", "new0", "b", "new1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := map[string]uint64{"a": local["a"], "b": local["b"], "new0": 100, "new1": 101}; !reflect.DeepEqual(trans, exp) {
+		t.Fatalf("unexpected translations: got %v, exp %v", trans, exp)
+	} else if !reflect.DeepEqual(forwarded, []string{"new0", "new1"}) {
+		t.Fatalf("expected only missing keys to be forwarded, got %v", forwarded)
+	}
+
+	// Keys which all exist locally aren't forwarded at all.
+	forwarded = nil
+	if _, err := c.createFieldKeys(ctx, f, "b", "a"); err != nil {
+		t.Fatal(err)
+	} else if len(forwarded) != 0 {
+		t.Fatalf("expected no keys to be forwarded, got %v", forwarded)
+	}
+
+	unkeyed, err := idx.CreateField("unkeyed", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.createFieldKeys(ctx, unkeyed, "a"); err == nil {
+		t.Fatal("expected error creating keys on unkeyed field")
+	}
+}
+
+// pinShardRouter is a disco.ShardRouter which assigns the pinned shards to
+// fixed nodes, and leaves all others to the default assignment.
+type pinShardRouter map[uint64][]string
+
+func (r pinShardRouter) ShardNodeIDs(index string, shard uint64) []string {
+	return r[shard]
+}
+
+// Ensure a shard router overrides the default shard assignment.
+func TestCluster_ShardRouter(t *testing.T) {
+	c := newCluster()
+	c.ReplicaN = 2
+	c.Node = &disco.Node{ID: "node0"}
+	c.noder = disco.NewLocalNoder([]*disco.Node{
+		c.Node,
+		{ID: "node1", State: disco.NodeStateStarted},
+		{ID: "node2", State: disco.NodeStateStarted},
+	})
+	def := c.NewSnapshot()
+
+	c.shardRouter = pinShardRouter{0: {"node2", "node0"}}
+	snap := c.NewSnapshot()
+	if got := disco.Nodes(snap.ShardNodes("i", 0)).IDs(); !reflect.DeepEqual(got, []string{"node2", "node0"}) {
+		t.Fatalf("unexpected nodes for pinned shard: %v", got)
+	} else if !snap.OwnsShard("node0", "i", 0) || snap.OwnsShard("node1", "i", 0) {
+		t.Fatal("expected ownership of pinned shard to follow the router")
+	}
+	for shard := uint64(1); shard < 10; shard++ {
+		exp := disco.Nodes(def.ShardNodes("i", shard)).IDs()
+		if got := disco.Nodes(snap.ShardNodes("i", shard)).IDs(); !reflect.DeepEqual(got, exp) {
+			t.Fatalf("shard %d: expected default nodes %v, got %v", shard, exp, got)
+		}
+	}
+
+	// Snapshots built from node states use the router too, and keep the
+	// states of the routed nodes.
+	states := c.newSnapshotFromNoder(disco.NewLocalNoder(c.noder.Nodes()))
+	if nodes := states.ShardNodes("i", 0); len(nodes) != 2 || nodes[0].State != disco.NodeStateStarted {
+		t.Fatalf("unexpected routed nodes: %v", nodes)
+	}
+}
+
+// Ensure shard moves take precedence over the router they wrap, and survive
+// being reloaded.
+func TestCluster_ShardMovesPersist(t *testing.T) {
+	dir := t.TempDir()
+	load := func() *cluster {
+		c := newCluster()
+		c.Path = dir
+		c.shardRouter = pinShardRouter{1: {"node1"}}
+		if err := c.loadShardMoves(); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	c := load()
+	if err := c.shardMoves.set(context.Background(), "i", 0, []string{"node2", "node0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []*cluster{c, load()} {
+		if got := c.shardRouter.ShardNodeIDs("i", 0); !reflect.DeepEqual(got, []string{"node2", "node0"}) {
+			t.Fatalf("unexpected nodes for moved shard: %v", got)
+		} else if got := c.shardRouter.ShardNodeIDs("i", 1); !reflect.DeepEqual(got, []string{"node1"}) {
+			t.Fatalf("expected fallback router for pinned shard, got %v", got)
+		} else if got := c.shardRouter.ShardNodeIDs("j", 0); got != nil {
+			t.Fatalf("expected default assignment for other index, got %v", got)
+		}
+	}
+}
+
+// fakeCoordinatorProvider is a disco.CoordinatorProvider which names a fixed
+// coordinator, or fails.
+type fakeCoordinatorProvider struct {
+	id  string
+	err error
+}
+
+func (p *fakeCoordinatorProvider) CoordinatorID() (string, error) {
+	return p.id, p.err
+}
+
+// deliveringBroadcaster is a broadcaster which delivers SetCoordinatorMessages
+// sent with SendSync to other clusters, standing in for the other nodes.
+type deliveringBroadcaster struct {
+	nopBroadcaster
+	to []*cluster
+}
+
+func (b *deliveringBroadcaster) SendSync(m Message) error {
+	if m, ok := m.(*SetCoordinatorMessage); ok {
+		for _, c := range b.to {
+			c.setCoordinator(m.NodeID)
+		}
+	}
+	return nil
+}
+
+// Ensure the coordinator can hand its role to another started node, on every
+// node, after copying its field translation to it, that the choice is
+// stored, and that field key translation is routed to the new coordinator.
+func TestCluster_TransferCoordinator(t *testing.T) {
+	// The target records the field translation copied to it, or refuses
+	// it while fail is set.
+	var mu sync.Mutex
+	var fail int32
+	synced := make(map[string][]byte)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) != 0 {
+			http.Error(w, "refused", http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		synced[r.URL.Path] = body
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	uri, err := pnet.NewURIFromAddress(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newTestHolder(t)
+	nodes := []*disco.Node{
+		{ID: "node0", State: disco.NodeStateStarted, URI: *uri},
+		{ID: "node1", State: disco.NodeStateStarted, URI: *uri},
+		{ID: "node2", State: disco.NodeStateStarted, URI: *uri},
+	}
+	clusters := make([]*cluster, len(nodes))
+	for i, node := range nodes {
+		clusters[i] = newCluster()
+		clusters[i].holder = h
+		clusters[i].noder = disco.NewLocalNoder(nodes)
+		clusters[i].Node = node
+		clusters[i].InternalClient = NewInternalClientFromURI(uri, http.DefaultClient)
+	}
+	for _, c := range clusters {
+		c.broadcaster = &deliveringBroadcaster{to: clusters}
+	}
+
+	// Of the nodes other than the default coordinator, one is the target,
+	// and the other is down.
+	def := clusters[0].primaryNode()
+	var coord, target, down *cluster
+	for _, c := range clusters {
+		switch {
+		case c.Node.ID == def.ID:
+			coord = c
+		case target == nil:
+			target = c
+		default:
+			down = c
+		}
+	}
+	down.Node.State = disco.NodeStateDown
+	coord.Path = t.TempDir()
+	if err := coord.loadCoordinator(); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "", OptFieldKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coord.createFieldKeys(context.Background(), f, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := target.TransferCoordinator(target.Node.ID); err == nil {
+		t.Fatal("expected error transferring from a node which isn't the coordinator")
+	} else if err := coord.TransferCoordinator("node9"); err == nil {
+		t.Fatal("expected error transferring to an unknown node")
+	} else if err := coord.TransferCoordinator(down.Node.ID); err == nil {
+		t.Fatal("expected error transferring to a down node")
+	} else if got := coord.primaryNode().ID; got != def.ID {
+		t.Fatalf("expected failed transfers to leave coordinator %s, got %s", def.ID, got)
+	}
+
+	// A coordinator chosen by a provider can't be transferred.
+	coord.coordinatorProvider = &fakeCoordinatorProvider{id: def.ID}
+	if err := coord.TransferCoordinator(target.Node.ID); err == nil {
+		t.Fatal("expected error transferring with a coordinator provider")
+	}
+	coord.coordinatorProvider = nil
+
+	// A transfer which can't copy the translation leaves the coordinator
+	// creating keys.
+	atomic.StoreInt32(&fail, 1)
+	if err := coord.TransferCoordinator(target.Node.ID); err == nil {
+		t.Fatal("expected error when the translation can't be copied")
+	} else if got := coord.primaryNode().ID; got != def.ID {
+		t.Fatalf("expected failed copy to leave coordinator %s, got %s", def.ID, got)
+	} else if f.TranslateStore().ReadOnly() {
+		t.Fatal("expected failed copy to leave the translation writable")
+	}
+	atomic.StoreInt32(&fail, 0)
+
+	if err := coord.TransferCoordinator(target.Node.ID); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	data := synced["/internal/translate/field/i/f"]
+	mu.Unlock()
+	if len(data) == 0 {
+		t.Fatal("expected field translation to be copied to the new coordinator")
+	} else if !f.TranslateStore().ReadOnly() {
+		t.Fatal("expected the old coordinator to stop creating keys")
+	}
+	for _, c := range clusters {
+		if got := c.primaryNode().ID; got != target.Node.ID {
+			t.Fatalf("%s: expected coordinator %s, got %s", c.Node.ID, target.Node.ID, got)
+		}
+	}
+	if !target.NewSnapshot().IsPrimaryFieldTranslationNode(target.Node.ID) {
+		t.Fatal("expected new coordinator to be the primary field translation node")
+	}
+
+	// The choice survives a restart.
+	restarted := newCluster()
+	restarted.Path = coord.Path
+	if err := restarted.loadCoordinator(); err != nil {
+		t.Fatal(err)
+	} else if got := restarted.transferredCoordinatorID(); got != target.Node.ID {
+		t.Fatalf("expected stored coordinator %s, got %q", target.Node.ID, got)
+	}
+
+	// Keys are created on the new coordinator, rather than forwarded, once
+	// its translation sync makes its stores writable.
+	f.TranslateStore().SetReadOnly(false)
+	trans, err := target.createFieldKeys(context.Background(), f, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local, err := f.TranslateStore().FindKeys("b"); err != nil {
+		t.Fatal(err)
+	} else if local["b"] != trans["b"] {
+		t.Fatalf("expected key created locally, got %v and %v", trans, local)
+	}
+}
+
+// Ensure a coordinator provider overrides the default coordinator, and that
+// field key translation is routed to it.
+func TestCluster_CoordinatorProvider(t *testing.T) {
+	h := newTestHolder(t)
+	c := newCluster()
+	c.holder = h
+	nodes := []*disco.Node{{ID: "node0"}, {ID: "node1"}}
+	c.noder = disco.NewLocalNoder(nodes)
+	def := c.primaryNode()
+
+	// Make the local node the one which isn't the default coordinator, and
+	// have the provider name it.
+	c.Node = nodes[0]
+	if def.ID == nodes[0].ID {
+		c.Node = nodes[1]
+	}
+	p := &fakeCoordinatorProvider{id: c.Node.ID}
+	c.coordinatorProvider = p
+	if got := c.primaryNode().ID; got != c.Node.ID {
+		t.Fatalf("expected coordinator %s, got %s", c.Node.ID, got)
+	} else if !c.NewSnapshot().IsPrimaryFieldTranslationNode(c.Node.ID) {
+		t.Fatal("expected local node to be the primary field translation node")
+	}
+
+	// Keys are created locally, rather than forwarded to the default.
+	idx, err := h.CreateIndex("i", "", IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "", OptFieldKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	trans, err := c.createFieldKeys(context.Background(), f, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local, err := f.TranslateStore().FindKeys("a"); err != nil {
+		t.Fatal(err)
+	} else if local["a"] != trans["a"] {
+		t.Fatalf("expected key created locally, got %v and %v", trans, local)
+	}
+
+	// Failures and unknown nodes fall back to the default.
+	p.err = errors.New("no leader")
+	if got := c.primaryNode().ID; got != def.ID {
+		t.Fatalf("expected default coordinator %s after error, got %s", def.ID, got)
+	}
+	p.id, p.err = "node9", nil
+	if got := c.primaryNode().ID; got != def.ID {
+		t.Fatalf("expected default coordinator %s for unknown node, got %s", def.ID, got)
+	}
+
+	// While the provider has no coordinator, no node is primary, and key
+	// creation waits for one rather than falling back to the default.
+	p.err = disco.ErrNoCoordinator
+	if got := c.primaryNode(); got != nil {
+		t.Fatalf("expected no coordinator, got %s", got.ID)
+	} else if c.NewSnapshot().IsPrimaryFieldTranslationNode(def.ID) {
+		t.Fatal("expected default coordinator not to be primary without a coordinator")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*coordinatorWaitInterval)
+	defer cancel()
+	if _, err := c.createFieldKeys(ctx, f, "b"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected key creation to wait for a coordinator, got %v", err)
+	}
+	c.coordinatorProvider = &gapCoordinatorProvider{id: c.Node.ID, gaps: 3}
+	if _, err := c.createFieldKeys(context.Background(), f, "b"); err != nil {
+		t.Fatal(err)
+	} else if local, err := f.TranslateStore().FindKeys("b"); err != nil {
+		t.Fatal(err)
+	} else if _, ok := local["b"]; !ok {
+		t.Fatal("expected key created locally once the coordinator is named")
+	}
+}
+
+// gapCoordinatorProvider is a disco.CoordinatorProvider which has no
+// coordinator for its first gaps calls, and then names a fixed one.
+type gapCoordinatorProvider struct {
+	id   string
+	gaps int32
+}
+
+func (p *gapCoordinatorProvider) CoordinatorID() (string, error) {
+	if atomic.AddInt32(&p.gaps, -1) >= 0 {
+		return "", disco.ErrNoCoordinator
+	}
+	return p.id, nil
+}
+
+// writeFragmentArchive writes roaring data as a fragment archive, in the
+// format of fragment.WriteTo.
+func writeFragmentArchive(w io.Writer, data []byte) {
+	tw := tar.NewWriter(w)
+	_ = tw.WriteHeader(&tar.Header{Name: "data", Mode: 0600, Size: int64(len(data))})
+	_, _ = tw.Write(data)
+	_ = tw.Close()
+}
+
+// capturingSerializer is a Serializer which keeps the messages it marshals,
+// instead of encoding them.
+type capturingSerializer struct {
+	mu   sync.Mutex
+	msgs []Message
+}
+
+func (s *capturingSerializer) Marshal(m Message) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgs = append(s.msgs, m)
+	return nil, nil
+}
+
+func (s *capturingSerializer) Unmarshal([]byte, Message) error { return nil }
+
+// Ensure copyShard retrieves each fragment from another of the shard's
+// owners when one fails, but doesn't retry a fragment its source doesn't
+// have.
+func TestCluster_CopyShard_AlternateSource(t *testing.T) {
+	var failed, imported int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&failed, 1)
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	replica := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/internal/fragment/data" || r.URL.Query().Get("shard") != "0" {
+			http.NotFound(w, r)
+			return
+		}
+		writeFragmentArchive(w, roaringBytes(t, 1, 2, 3))
+	}))
+	defer replica.Close()
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index/i/shard/0/import-roaring" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&imported, 1)
+	}))
+	defer target.Close()
+
+	var nodes []*disco.Node
+	for i, srv := range []*httptest.Server{failing, replica, target} {
+		uri, err := pnet.NewURIFromAddress(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, &disco.Node{ID: fmt.Sprintf("node%d", i), URI: *uri})
+	}
+	failingNode, replicaNode, targetNode := nodes[0], nodes[1], nodes[2]
+
+	h := newTestHolder(t)
+	ser := &capturingSerializer{}
+	c := newCluster()
+	c.holder = h
+	c.InternalClient = NewInternalClientFromURI(&failingNode.URI, http.DefaultClient, WithSerializer(ser))
+	ctx := context.Background()
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.createViewIfNotExists(viewStandard); err != nil {
+		t.Fatal(err)
+	}
+
+	// The primary fails, so the fragment comes from the replica.
+	if err := c.copyShard(ctx, "i", 0, []*disco.Node{failingNode, replicaNode}, targetNode); err != nil {
+		t.Fatalf("copying shard: %v", err)
+	}
+	if failed, imported := atomic.LoadInt32(&failed), atomic.LoadInt32(&imported); failed != 1 || imported != 1 || len(ser.msgs) != 1 {
+		t.Fatalf("expected one failed retrieval and one import, got %d, %d, %d messages", failed, imported, len(ser.msgs))
+	}
+	req := ser.msgs[0].(*ImportRoaringShardRequest)
+	if len(req.Views) != 1 || req.Views[0].Field != "f" || req.Views[0].View != viewStandard || !bytes.Equal(req.Views[0].Set, roaringBytes(t, 1, 2, 3)) {
+		t.Fatalf("unexpected import request: %+v", req)
+	}
+
+	// A source without the fragment isn't retried elsewhere.
+	if err := c.copyShard(ctx, "i", 1, []*disco.Node{replicaNode, failingNode}, targetNode); err != nil {
+		t.Fatalf("copying empty shard: %v", err)
+	}
+	if failed, imported := atomic.LoadInt32(&failed), atomic.LoadInt32(&imported); failed != 1 || imported != 1 {
+		t.Fatalf("expected no retry or import for a missing fragment, got %d, %d", failed, imported)
+	}
+
+	// Each source is tried once before giving up.
+	if err := c.copyShard(ctx, "i", 0, []*disco.Node{failingNode}, targetNode); err == nil {
+		t.Fatal("expected error when every source fails")
+	} else if failed := atomic.LoadInt32(&failed); failed != 2 {
+		t.Fatalf("expected one more failed retrieval, got %d", failed-1)
+	}
+}
+
+// Ensure EstimateQueryCost counts the shards and nodes a query would touch,
+// measures the fragments of the shards this node owns, and accounts for
+// nodes which are down.
+func TestCluster_EstimateQueryCost(t *testing.T) {
+	h := newTestHolder(t)
+	c := newCluster()
+	c.holder = h
+	c.partitionN = h.partitionN
+	c.ReplicaN = 1
+	nodes := []*disco.Node{
+		{ID: "node0", URI: NewTestURIFromHostPort("node0", 10101), State: disco.NodeStateStarted},
+		{ID: "node1", URI: NewTestURIFromHostPort("node1", 10101), State: disco.NodeStateStarted},
+	}
+	c.Node = nodes[0]
+	c.noder = disco.NewLocalNoder(nodes)
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shard s has s+1 bits, all in one container.
+	const shardN = 8
+	qcx := h.Txf().NewWritableQcx()
+	for shard := uint64(0); shard < shardN; shard++ {
+		for i := uint64(0); i <= shard; i++ {
+			if _, err := f.SetBit(qcx, 1, shard*ShardWidth+i, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := qcx.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	// A shard's size is that of the database holding it.
+	shardSize := func(shard uint64) int64 {
+		tx := h.Txf().NewTx(Txo{Write: !writable, Index: idx, Shard: shard})
+		defer tx.Rollback()
+		return tx.(*RBFTx).SizeBytes()
+	}
+
+	if est := c.EstimateQueryCost("missing", nil); !reflect.DeepEqual(est, QueryCostEstimate{}) {
+		t.Fatalf("expected no cost for a missing index, got %+v", est)
+	}
+	if est := c.EstimateQueryCost("i", []uint64{}); !reflect.DeepEqual(est, QueryCostEstimate{}) {
+		t.Fatalf("expected no cost for no shards, got %+v", est)
+	}
+
+	// With one owner per shard, only node0's shards are measured.
+	snap := c.NewSnapshot()
+	primaries := make(map[string]struct{})
+	var exp QueryCostEstimate
+	var owned int64
+	var node1Shards []uint64
+	for shard := uint64(0); shard < shardN; shard++ {
+		owner := snap.ShardNodes("i", shard)[0].ID
+		primaries[owner] = struct{}{}
+		if owner == "node0" {
+			exp.Fragments++
+			exp.LocalBytes += shardSize(shard)
+			owned++
+		} else {
+			node1Shards = append(node1Shards, shard)
+		}
+	}
+	exp.Shards, exp.Nodes = shardN, len(primaries)
+	if owned > 0 {
+		exp.Bytes = exp.LocalBytes * shardN / owned
+	}
+	if est := c.EstimateQueryCost("i", nil); !reflect.DeepEqual(est, exp) {
+		t.Fatalf("expected %+v, got %+v", exp, est)
+	}
+
+	// Once node1 is down, its shards have no available owner.
+	nodes[1].State = disco.NodeStateDown
+	exp.UnavailableShards = node1Shards
+	exp.Nodes = 0
+	if owned > 0 {
+		exp.Nodes = 1
+	}
+	if est := c.EstimateQueryCost("i", nil); !reflect.DeepEqual(est, exp) {
+		t.Fatalf("expected %+v with node1 down, got %+v", exp, est)
+	}
+
+	// With a replica on each node, node0 takes every shard, and measures
+	// them all.
+	c.ReplicaN = 2
+	exp = QueryCostEstimate{Shards: shardN, Nodes: 1, Fragments: shardN}
+	for shard := uint64(0); shard < shardN; shard++ {
+		exp.LocalBytes += shardSize(shard)
+	}
+	exp.Bytes = exp.LocalBytes
+	if est := c.EstimateQueryCost("i", nil); !reflect.DeepEqual(est, exp) {
+		t.Fatalf("expected %+v with replicas, got %+v", exp, est)
+	}
+	if est := c.EstimateQueryCost("i", []uint64{3}); est.Shards != 1 || est.Fragments != 1 || est.LocalBytes != shardSize(3) {
+		t.Fatalf("expected shard 3 alone, got %+v", est)
+	}
+}
+
+// Ensure another node takes over the coordinator lease once the coordinator
+// stops renewing it, and right away once it's released.
+func TestCluster_CoordinatorLease(t *testing.T) {
+	const ttl = 100 * time.Millisecond
+
+	nodes := []*disco.Node{
+		{ID: "node0", State: disco.NodeStateStarted},
+		{ID: "node1", State: disco.NodeStateStarted},
+		{ID: "node2", State: disco.NodeStateStarted},
+	}
+	noder := disco.NewLocalNoder(nodes)
+	hasher := &disco.Jmphasher{}
+	leaser := disco.NewLocalLeaser()
+	leases := make(map[string]*disco.CoordinatorLease)
+	for _, node := range nodes {
+		leases[node.ID] = disco.NewCoordinatorLease(leaser, noder, hasher, node.ID, ttl)
+	}
+	renew := func(ids ...string) {
+		for _, id := range ids {
+			if err := leases[id].Renew(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	coordinator := func(id string) string {
+		c := newCluster()
+		c.noder = noder
+		c.coordinatorProvider = leases[id]
+		if node := c.NewSnapshot().PrimaryFieldTranslationNode(); node != nil {
+			return node.ID
+		}
+		return ""
+	}
+	expectCoordinator := func(exp string, ids ...string) {
+		t.Helper()
+		for _, id := range ids {
+			if got := coordinator(id); got != exp {
+				t.Fatalf("expected %s to see coordinator %s, got %s", id, exp, got)
+			}
+		}
+	}
+
+	// The default coordinator takes the lease first.
+	first := disco.PrimaryNode(nodes, hasher)
+	var i int
+	for i = range nodes {
+		if nodes[i] == first {
+			break
+		}
+	}
+	next, last := nodes[(i+1)%3].ID, nodes[(i+2)%3].ID
+	renew(next, last, first.ID)
+	renew(next, last)
+	expectCoordinator(first.ID, first.ID, next, last)
+
+	// The coordinator stops renewing; it stops naming itself before the
+	// lease expires, and, until the next holder is seen, no node is named.
+	time.Sleep(ttl - ttl/10)
+	if _, err := leases[first.ID].CoordinatorID(); err != disco.ErrNoCoordinator {
+		t.Fatalf("expected %s to stop naming itself once its lease could expire, got %v", first.ID, err)
+	}
+	expectCoordinator("", first.ID, next, last)
+
+	// The node after it takes over once the lease expires, and only that
+	// node.
+	start := time.Now()
+	for {
+		renew(next, last)
+		if id, err := leases[next].CoordinatorID(); err == nil && id == next {
+			break
+		} else if time.Since(start) > 2*ttl {
+			t.Fatalf("expected %s to take over within %s, coordinator is %q", next, 2*ttl, id)
+		}
+		time.Sleep(ttl / 10)
+	}
+	renew(next, last)
+	expectCoordinator(next, next, last)
+	if holder, err := leaser.LeaseHolder(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if holder != next {
+		t.Fatalf("expected lease holder %s, got %s", next, holder)
+	}
+
+	// A released lease goes to the next node without waiting for it to
+	// expire.
+	if err := leases[next].Release(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	renew(last, next)
+	expectCoordinator(last, last, next)
+}
+
+func TestCluster_FindOrphanFragments(t *testing.T) {
+	h := newTestHolder(t)
+	c := newCluster()
+	c.holder = h
+	c.partitionN = h.partitionN
+	c.ReplicaN = 1
+	c.noder = disco.NewIDNoder([]string{"node0", "node1", "node2"})
+	c.Node = &disco.Node{ID: "node0"}
+
+	idx, err := h.CreateIndex("i", "", IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	qcx := h.Txf().NewWritableQcx()
+	for shard := uint64(0); shard < 10; shard++ {
+		if _, err := f.SetBit(qcx, 1, shard*ShardWidth, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := qcx.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every shard this node isn't a primary or replica for is an orphan.
+	snap := c.NewSnapshot()
+	var exp []string
+	for shard := uint64(0); shard < 10; shard++ {
+		if !snap.OwnsShard("node0", "i", shard) {
+			exp = append(exp, filepath.Join(h.IndexesPath(), "i", backendsDir, rbfTxn.DirectoryName(), fmt.Sprintf("shard.%04v", shard)))
+		}
+	}
+	if len(exp) == 0 || len(exp) == 10 {
+		t.Fatalf("expected node0 to own some but not all shards, orphans %v", exp)
+	}
+	orphans, err := c.findOrphanFragments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(orphans)
+	sort.Strings(exp)
+	if !reflect.DeepEqual(orphans, exp) {
+		t.Fatalf("unexpected orphans:\n got %v\n exp %v", orphans, exp)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"github.com/featurebasedb/featurebase/v3/disco"
+)
+
+// QueryCostEstimate describes the work a query over some of an index's
+// shards would involve, for deciding whether, or when, to run it.
+type QueryCostEstimate struct {
+	// Shards is the number of shards the query would touch.
+	Shards int `json:"shards"`
+
+	// Nodes is the number of nodes the shards would be queried on. Each
+	// shard goes to its first available owner, as when executing.
+	Nodes int `json:"nodes"`
+
+	// UnavailableShards are the shards with no available owner, which
+	// would cause the query to fail.
+	UnavailableShards []uint64 `json:"unavailableShards,omitempty"`
+
+	// Fragments and LocalBytes are the number of fragments of the shards
+	// this node owns, and the size of the storage holding them, as read
+	// from its page metadata. Other nodes' shards can't be measured
+	// locally.
+	Fragments  int   `json:"fragments"`
+	LocalBytes int64 `json:"localBytes"`
+
+	// Bytes extrapolates LocalBytes to every shard by the average size of
+	// the shards this node owns. It's zero if this node owns none of them.
+	Bytes int64 `json:"bytes"`
+}
+
+// EstimateQueryCost estimates the cost of a query over shards of an index,
+// without executing it. Nil shards means all of the index's available
+// shards, as for a query without a shard list. Shards are assigned to nodes
+// as the executor would, skipping owners which aren't available; the sizes
+// of the shards this node owns are read from storage, and a size which
+// can't be read is logged and left out. An index which doesn't exist has no
+// cost.
+func (c *cluster) EstimateQueryCost(index string, shards []uint64) QueryCostEstimate {
+	var est QueryCostEstimate
+	idx := c.holder.Index(index)
+	if idx == nil {
+		return est
+	}
+	if shards == nil {
+		shards = idx.AvailableShards(includeRemote).Slice()
+	}
+	est.Shards = len(shards)
+
+	// Nodes' states come from Nodes, rather than the snapshot's noder,
+	// just as in executor.shardsByNode.
+	snap := c.newSnapshotFromNoder(disco.NewLocalNoder(c.Nodes()))
+	nodes := make(map[string]struct{})
+	var owned []uint64
+	for _, shard := range shards {
+		owners := snap.ShardNodes(index, shard)
+		assigned := false
+		for _, node := range owners {
+			if c.Node != nil && node.ID == c.Node.ID {
+				owned = append(owned, shard)
+			}
+			if !assigned && (node.State == disco.NodeStateStarted || node.State == disco.NodeStateUnknown) {
+				nodes[node.ID] = struct{}{}
+				assigned = true
+			}
+		}
+		if !assigned {
+			est.UnavailableShards = append(est.UnavailableShards, shard)
+		}
+	}
+	est.Nodes = len(nodes)
+
+	for _, shard := range owned {
+		n, size := c.localShardSize(idx, shard)
+		est.Fragments += n
+		est.LocalBytes += size
+	}
+	if len(owned) > 0 {
+		est.Bytes = est.LocalBytes * int64(len(shards)) / int64(len(owned))
+	}
+	return est
+}
+
+// localShardSize returns the number of local fragments of one of an index's
+// shards, and the size of the storage holding them. The size comes from the
+// storage's page metadata rather than from the fragments' containers: RBF
+// keeps each shard of an index in its own database, whose page count gives
+// its size, and other backends report the pages of each field with a local
+// fragment.
+func (c *cluster) localShardSize(idx *Index, shard uint64) (n int, size int64) {
+	var fields []string
+	for _, fld := range idx.Fields() {
+		local := false
+		for _, view := range fld.views() {
+			frag, err := view.Fragment(shard)
+			if err != nil {
+				c.logger.Warnf("estimating size of %s/%s/%d: %v", idx.Name(), fld.Name(), shard, err)
+				continue
+			}
+			if frag != nil {
+				n++
+				local = true
+			}
+		}
+		if local {
+			fields = append(fields, fld.Name())
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+
+	tx := idx.holder.txf.NewTx(Txo{Write: !writable, Index: idx, Shard: shard})
+	defer tx.Rollback()
+	if rtx, ok := tx.(*RBFTx); ok {
+		return n, rtx.SizeBytes()
+	}
+	for _, field := range fields {
+		fieldSize, err := tx.GetFieldSizeBytes(idx.Name(), field)
+		if err != nil {
+			c.logger.Warnf("estimating size of %s/%s/%d: %v", idx.Name(), field, shard, err)
+			continue
+		}
+		size += int64(fieldSize)
+	}
+	return n, size
+}
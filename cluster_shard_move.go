@@ -0,0 +1,341 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/featurebasedb/featurebase/v3/disco"
+	"github.com/pkg/errors"
+)
+
+// shardMovesFile is the name of the file, in the cluster's data directory,
+// which holds the shard routing overrides made by MoveShard when the
+// cluster's DisCo can't store them.
+const shardMovesFile = ".shardmoves"
+
+// shardMoves is a disco.ShardRouter which assigns the owners of shards
+// moved by MoveShard, and defers to another router, or to the default
+// assignment, for every other shard. Its overrides are kept in a
+// disco.ShardRoutes, so that they survive a restart, and in memory, where
+// overrides made on other nodes are applied as they're broadcast.
+type shardMoves struct {
+	mu       sync.RWMutex
+	store    disco.ShardRoutes
+	fallback disco.ShardRouter
+
+	// routes maps index name to shard to owning node IDs, primary first.
+	routes map[string]map[uint64][]string
+}
+
+// loadShardMoves returns a shardMoves kept in store, reading any overrides
+// already there. fallback may be nil.
+func loadShardMoves(ctx context.Context, store disco.ShardRoutes, fallback disco.ShardRouter) (*shardMoves, error) {
+	routes, err := store.ShardRoutes(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading shard moves")
+	}
+	return &shardMoves{
+		store:    store,
+		fallback: fallback,
+		routes:   routes,
+	}, nil
+}
+
+// ShardNodeIDs implements disco.ShardRouter.
+func (m *shardMoves) ShardNodeIDs(index string, shard uint64) []string {
+	m.mu.RLock()
+	ids, ok := m.routes[index][shard]
+	m.mu.RUnlock()
+	if ok {
+		return ids
+	}
+	if m.fallback != nil {
+		return m.fallback.ShardNodeIDs(index, shard)
+	}
+	return nil
+}
+
+// set stores an override of the owners of a shard, and then applies it.
+func (m *shardMoves) set(ctx context.Context, index string, shard uint64, ids []string) error {
+	// Don't route by an override which won't survive a restart.
+	if err := m.store.SetShardRoute(ctx, index, shard, ids); err != nil {
+		return err
+	}
+	m.apply(index, shard, ids)
+	return nil
+}
+
+// apply overrides the owners of a shard in memory only, as when the
+// override has been stored by another node.
+func (m *shardMoves) apply(index string, shard uint64, ids []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	shards := m.routes[index]
+	if shards == nil {
+		shards = make(map[uint64][]string)
+		m.routes[index] = shards
+	}
+	shards[shard] = ids
+}
+
+// fileShardRoutes is a disco.ShardRoutes kept in a file, for a DisCo which
+// doesn't implement it. Only the node which wrote the file reads it, so
+// other nodes only learn of its overrides as they're broadcast.
+type fileShardRoutes struct {
+	mu   sync.Mutex
+	path string
+}
+
+// ShardRoutes implements disco.ShardRoutes.
+func (f *fileShardRoutes) ShardRoutes(ctx context.Context) (map[string]map[uint64][]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.read()
+}
+
+// SetShardRoute implements disco.ShardRoutes. It replaces the previous file
+// only once the new one is complete.
+func (f *fileShardRoutes) SetShardRoute(ctx context.Context, index string, shard uint64, nodeIDs []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	routes, err := f.read()
+	if err != nil {
+		return err
+	}
+	if routes[index] == nil {
+		routes[index] = make(map[uint64][]string)
+	}
+	routes[index][shard] = nodeIDs
+
+	buf, err := json.Marshal(routes)
+	if err != nil {
+		return errors.Wrap(err, "encoding shard moves")
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o600); err != nil {
+		return errors.Wrap(err, "writing shard moves")
+	}
+	return errors.Wrap(os.Rename(tmp, f.path), "replacing shard moves")
+}
+
+func (f *fileShardRoutes) read() (map[string]map[uint64][]string, error) {
+	routes := make(map[string]map[uint64][]string)
+	buf, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return routes, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading shard moves")
+	}
+	if err := json.Unmarshal(buf, &routes); err != nil {
+		return nil, errors.Wrapf(err, "decoding shard moves from %s", f.path)
+	}
+	return routes, nil
+}
+
+// loadShardMoves installs a shardMoves router on the cluster, in front of
+// any router it already has, restoring the overrides made by earlier calls
+// to MoveShard. The overrides are kept by the cluster's DisCo, where every
+// node can read them, if it implements disco.ShardRoutes, and otherwise in
+// the cluster's data directory.
+func (c *cluster) loadShardMoves() error {
+	store, ok := c.disCo.(disco.ShardRoutes)
+	if !ok {
+		store = &fileShardRoutes{path: filepath.Join(c.Path, shardMovesFile)}
+	}
+	m, err := loadShardMoves(context.Background(), store, c.shardRouter)
+	if err != nil {
+		return err
+	}
+	c.shardMoves = m
+	c.shardRouter = m
+	return nil
+}
+
+// applyShardRoute applies an override of the owners of a shard stored by
+// another node. If this node is no longer one of the shard's owners, its
+// copy of the shard is deleted.
+func (c *cluster) applyShardRoute(index string, shard uint64, ids []string) error {
+	if c.shardMoves == nil {
+		return errors.New("shard moves are not enabled on this cluster")
+	}
+	c.shardMoves.apply(index, shard, ids)
+	return c.dropUnownedShard(index, shard, ids)
+}
+
+// dropUnownedShard deletes this node's fragments of a shard unless it's one
+// of the nodes in ids.
+func (c *cluster) dropUnownedShard(index string, shard uint64, ids []string) error {
+	if c.Node == nil {
+		return nil
+	}
+	for _, id := range ids {
+		if id == c.Node.ID {
+			return nil
+		}
+	}
+	idx := c.holder.Index(index)
+	if idx == nil {
+		return nil
+	}
+	for _, fld := range idx.Fields() {
+		for _, view := range fld.views() {
+			if err := view.deleteFragment(shard); err != nil && err != ErrFragmentNotFound {
+				return errors.Wrapf(err, "deleting %s/%s/%s/%d", index, fld.Name(), view.name, shard)
+			}
+		}
+	}
+	return nil
+}
+
+// MoveShard makes the node with targetNodeID the primary owner of a shard.
+// If the target isn't already one of the shard's owners, the shard's
+// fragments are first copied to it from the current primary, and it takes
+// the place of the last replica, so the shard keeps the same number of
+// owners. The new assignment is stored as a routing override and broadcast
+// to every node, and the displaced replica then deletes its copy of the
+// shard.
+//
+// MoveShard may only be called on the primary node. Writes which other
+// nodes route to the previous owners while the shard is being copied, before
+// they receive the override, may not reach the target.
+func (c *cluster) MoveShard(index string, shard uint64, targetNodeID string) error {
+	ctx := context.Background()
+
+	if primary := c.primaryNode(); primary == nil || c.Node == nil || c.Node.ID != primary.ID {
+		return ErrNodeNotPrimary
+	}
+	if c.shardMoves == nil {
+		return errors.New("shard moves are not enabled on this cluster")
+	}
+	if c.holder.Index(index) == nil {
+		return newNotFoundError(ErrIndexNotFound, index)
+	}
+	target := c.nodeByID(targetNodeID)
+	if target == nil {
+		return ErrNodeIDNotExists
+	}
+
+	owners := c.NewSnapshot().ShardNodes(index, shard)
+	if len(owners) == 0 {
+		return errors.Errorf("no nodes own shard %d of index %s", shard, index)
+	}
+	if owners[0].ID == target.ID {
+		return nil
+	}
+
+	ids := []string{target.ID}
+	isOwner := false
+	for _, node := range owners {
+		if node.ID == target.ID {
+			isOwner = true
+		} else {
+			ids = append(ids, node.ID)
+		}
+	}
+	if !isOwner {
+		if err := c.copyShard(ctx, index, shard, owners, target); err != nil {
+			return errors.Wrapf(err, "copying shard %d to %s", shard, target.ID)
+		}
+		ids = ids[:len(owners)]
+	}
+	if err := c.shardMoves.set(ctx, index, shard, ids); err != nil {
+		return errors.Wrap(err, "recording shard move")
+	}
+
+	// Every node, including the displaced replica, applies the override on
+	// receipt; the displaced replica deletes its copy as it does so.
+	if err := c.broadcaster.SendSync(&ShardRouteMessage{Index: index, Shard: shard, NodeIDs: ids}); err != nil {
+		return errors.Wrap(err, "broadcasting shard move")
+	}
+	return c.dropUnownedShard(index, shard, ids)
+}
+
+// copyShard copies every fragment of a shard to a node, in a single
+// transaction on the target. Each fragment is retrieved from the first of
+// sources, the shard's owners with the primary first, which can provide it.
+// A source which fails, other than by not having the fragment, is skipped in
+// favor of the next; each source is tried at most once per fragment, and the
+// copy fails if none of them can provide it. A source without the fragment
+// means the shard has no data for it, so that's not retried.
+func (c *cluster) copyShard(ctx context.Context, index string, shard uint64, sources []*disco.Node, to *disco.Node) error {
+	idx := c.holder.Index(index)
+	if idx == nil {
+		return newNotFoundError(ErrIndexNotFound, index)
+	}
+	if len(sources) == 0 {
+		return errors.Errorf("no sources for shard %d", shard)
+	}
+
+	req := &ImportRoaringShardRequest{Remote: true}
+	for _, fld := range idx.Fields() {
+		for _, view := range fld.views() {
+			data, err := c.retrieveFragmentDataFromAny(ctx, index, fld.Name(), view.name, shard, sources)
+			if err != nil {
+				return errors.Wrapf(err, "retrieving %s/%s", fld.Name(), view.name)
+			} else if len(data) == 0 {
+				continue
+			}
+			req.Views = append(req.Views, RoaringUpdate{
+				Field: fld.Name(),
+				View:  view.name,
+				Set:   data,
+			})
+		}
+	}
+	if len(req.Views) == 0 {
+		return nil
+	}
+	return c.InternalClient.ImportRoaringShard(ctx, &to.URI, index, shard, true, req)
+}
+
+// retrieveFragmentDataFromAny returns the roaring encoded contents of a
+// fragment from the first of sources which can provide it, as described by
+// copyShard.
+func (c *cluster) retrieveFragmentDataFromAny(ctx context.Context, index, field, view string, shard uint64, sources []*disco.Node) ([]byte, error) {
+	var err error
+	for _, node := range sources {
+		var data []byte
+		if data, err = c.retrieveFragmentData(ctx, index, field, view, shard, node); err == nil {
+			return data, nil
+		}
+		err = errors.Wrapf(err, "from node %s", node.ID)
+		c.logger.Warnf("retrieving fragment %s/%s/%s/%d: %v", index, field, view, shard, err)
+	}
+	return nil, err
+}
+
+// retrieveFragmentData returns the roaring encoded contents of a fragment on
+// node, or nil if the node has no such fragment.
+func (c *cluster) retrieveFragmentData(ctx context.Context, index, field, view string, shard uint64, node *disco.Node) ([]byte, error) {
+	rc, err := c.InternalClient.RetrieveShardFromURI(ctx, index, field, view, shard, node.URI)
+	if err == ErrFragmentNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// The fragment archive, as written by fragment.WriteTo, holds the
+	// fragment's data followed by its cache, which the target rebuilds.
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, errors.Wrap(err, "reading fragment archive")
+		}
+		if hdr.Name == "data" {
+			return io.ReadAll(tr)
+		}
+	}
+}
@@ -238,7 +238,7 @@ func (cmd *BackupCommand) backupIndexTranslation(ctx context.Context, ii *pilosa
 	logger := cmd.Logger()
 	logger.Printf("backing up index translation: %q", ii.Name)
 	if ii.Options.Keys {
-		if err := cmd.backupIndexTranslateData(ctx, ii.Name); err != nil {
+		if err := cmd.backupIndexTranslateData(ctx, ii); err != nil {
 			return err
 		}
 	}
@@ -372,8 +372,9 @@ func (cmd *BackupCommand) backupShardNode(ctx context.Context, indexName string,
 	return f.Close()
 }
 
-func (cmd *BackupCommand) backupIndexTranslateData(ctx context.Context, name string) error {
-	partitionN := disco.DefaultPartitionN
+func (cmd *BackupCommand) backupIndexTranslateData(ctx context.Context, ii *pilosa.IndexInfo) error {
+	name := ii.Name
+	partitionN := indexPartitionN(ii)
 
 	ch := make(chan int, partitionN)
 	for partitionID := 0; partitionID < partitionN; partitionID++ {
@@ -401,6 +402,16 @@ func (cmd *BackupCommand) backupIndexTranslateData(ctx context.Context, name str
 	return g.Wait()
 }
 
+// indexPartitionN returns the number of key-partitions an index's keys are
+// translated by: its own partition count, if it has one below the
+// cluster's, or else the cluster's.
+func indexPartitionN(ii *pilosa.IndexInfo) int {
+	if n := ii.Options.PartitionN; n > 0 && n < disco.DefaultPartitionN {
+		return n
+	}
+	return disco.DefaultPartitionN
+}
+
 func (cmd *BackupCommand) backupIndexPartitionTranslateData(ctx context.Context, name string, partitionID int) error {
 	logger := cmd.Logger()
 	logger.Printf("backing up index translation data: %s/%d", name, partitionID)
@@ -253,7 +253,7 @@ func (cmd *BackupTarCommand) backupTarIndex(ctx context.Context, tw *tar.Writer,
 
 	if ii.Options.Keys {
 		// Back up translation data after bitmap data so we ensurean translate all data.
-		if err := cmd.backupTarIndexTranslateData(ctx, tw, ii.Name); err != nil {
+		if err := cmd.backupTarIndexTranslateData(ctx, tw, ii); err != nil {
 			return err
 		}
 	}
@@ -340,9 +340,10 @@ func (cmd *BackupTarCommand) backupTarShardDataframe(ctx context.Context, tw *ta
 	return writeToTar(tw, filename, resp.Body, cmd.TempDir)
 }
 
-func (cmd *BackupTarCommand) backupTarIndexTranslateData(ctx context.Context, tw *tar.Writer, name string) error {
+func (cmd *BackupTarCommand) backupTarIndexTranslateData(ctx context.Context, tw *tar.Writer, ii *pilosa.IndexInfo) error {
 	// TODO: Fetch holder partition count.
-	partitionN := disco.DefaultPartitionN
+	name := ii.Name
+	partitionN := indexPartitionN(ii)
 	for partitionID := 0; partitionID < partitionN; partitionID++ {
 		if err := cmd.backupTarIndexPartitionTranslateData(ctx, tw, name, partitionID); err != nil {
 			return fmt.Errorf("cannot backup index translation data for partition %d on %q: %w", partitionID, name, err)
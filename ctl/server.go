@@ -47,8 +47,10 @@ func serverFlagSet(srv *server.Config, prefix string) *pflag.FlagSet {
 	flags.Uint64Var(&srv.MaxMapCount, pre("max-map-count"), srv.MaxMapCount, "Limits the maximum number of active mmaps. FeatureBase will fall back to reading files once this is exhausted. Set below your system's vm.max_map_count.")
 	flags.Uint64Var(&srv.MaxFileCount, pre("max-file-count"), srv.MaxFileCount, "Soft limit on the maximum number of fragment files FeatureBase keeps open simultaneously.")
 	flags.DurationVar((*time.Duration)(&srv.LongQueryTime), pre("long-query-time"), time.Duration(srv.LongQueryTime), "Duration that will trigger log and stat messages for slow queries. Zero to disable.")
+	flags.DurationVar((*time.Duration)(&srv.SlowImportThreshold), pre("slow-import-threshold"), time.Duration(srv.SlowImportThreshold), "Duration after which a fragment import is logged as slow. Zero to disable.")
 	flags.IntVar(&srv.QueryHistoryLength, pre("query-history-length"), srv.QueryHistoryLength, "Number of queries to remember in history.")
 	flags.Int64Var(&srv.MaxQueryMemory, pre("max-query-memory"), srv.MaxQueryMemory, "Maximum memory allowed per Extract() or SELECT query.")
+	flags.IntVar(&srv.MaxConcurrentQueryShards, pre("max-concurrent-query-shards"), srv.MaxConcurrentQueryShards, "Maximum number of a query's shards processed at once on each node. Zero is unlimited.")
 	flags.StringVar(&srv.VerChkAddress, pre("verchk-address"), srv.VerChkAddress, "Address to contact to check for latest version.")
 	flags.StringVar(&srv.UUIDFile, pre("uuid-file"), srv.UUIDFile, "File to store UUID used in checking latest version. If this is a relative path, the file will be stored in the server's data directory.")
 
@@ -63,6 +65,11 @@ func serverFlagSet(srv *server.Config, prefix string) *pflag.FlagSet {
 	flags.DurationVar((*time.Duration)(&srv.Cluster.LongQueryTime), pre("cluster.long-query-time"), time.Duration(srv.Cluster.LongQueryTime), "RENAMED TO 'long-query-time': Duration that will trigger log and stat messages for slow queries.") // negative duration indicates invalid value because 0 is meaningful
 	flags.StringVar(&srv.Cluster.Name, pre("cluster.name"), srv.Cluster.Name, "Human-readable name for the cluster.")
 	flags.StringVar(&srv.Cluster.PartitionToNodeAssignment, pre("cluster.partition-to-node-assignment"), srv.Cluster.PartitionToNodeAssignment, "How to assign partitions to nodes. jmp-hash or modulus")
+	flags.StringVar(&srv.Cluster.Hasher, pre("cluster.hasher"), srv.Cluster.Hasher, "Hash used to assign partitions to nodes: jump-hash or rendezvous. Can't be changed once the cluster exists, or combined with partition-to-node-assignment modulus.")
+	flags.StringVar(&srv.Cluster.Zone, pre("cluster.zone"), srv.Cluster.Zone, "Zone or rack label for this node. Replicas of a partition are spread across zones when possible.")
+	flags.StringVar(&srv.Cluster.Membership, pre("cluster.membership"), srv.Cluster.Membership, "How cluster membership is tracked: etcd, or local for a single node.")
+	flags.DurationVar((*time.Duration)(&srv.Cluster.CoordinatorLeaseTTL), pre("cluster.coordinator-lease-ttl"), time.Duration(srv.Cluster.CoordinatorLeaseTTL), "Lease duration after which another node takes over from a coordinator which stops renewing it. Zero disables the lease.")
+	flags.IntVar(&srv.Cluster.MaxKeyCreatesPerPartition, pre("cluster.max-key-creates-per-partition"), srv.Cluster.MaxKeyCreatesPerPartition, "Maximum number of key creations run at once on each translate partition. Zero is unlimited.")
 
 	// Translation
 	flags.StringVar(&srv.Translation.PrimaryURL, pre("translation.primary-url"), srv.Translation.PrimaryURL, "DEPRECATED: URL for primary translation node for replication.")
@@ -108,6 +115,12 @@ func serverFlagSet(srv *server.Config, prefix string) *pflag.FlagSet {
 
 	flags.StringVar(&srv.Storage.Backend, pre("storage.backend"), storage.DefaultBackend, "Storage backend to use: 'rbf' is only supported value.")
 	flags.BoolVar(&srv.Storage.FsyncEnabled, pre("storage.fsync"), true, "enable fsync fully safe flush-to-disk")
+	flags.StringVar(&srv.Storage.TranslateFsync, pre("storage.translate-fsync"), "", "fsync policy for key translation: 'always', 'batched' (periodic; a crash can lose recent keys), or 'never' (unsafe for production). Defaults to following storage.fsync.")
+	flags.BoolVar(&srv.Storage.CompressTranslateTransfers, pre("storage.compress-translate-transfers"), false, "ask other nodes to gzip translate partitions they send this node, such as during a resize. Nodes which don't support it send them uncompressed.")
+	flags.Int64Var(&srv.Storage.MaxFragmentSize, pre("storage.max-fragment-size"), 0, "maximum estimated size in bytes of a single fragment; imports which could exceed it are rejected. 0 means unlimited.")
+	flags.Uint64Var(&srv.Storage.MaxImportRowID, pre("storage.max-import-row-id"), 0, "highest row ID roaring imports may set bits in; imports with higher rows are rejected. 0 means unlimited.")
+	flags.BoolVar(&srv.Storage.ClampImportRows, pre("storage.clamp-import-rows"), false, "drop bits above storage.max-import-row-id from roaring imports, with a warning, instead of rejecting the import.")
+	flags.IntVar(&srv.Storage.MaxConcurrentImports, pre("storage.max-concurrent-imports"), 0, "maximum number of fragment imports to run at once; others wait their turn. 0 means unlimited.")
 
 	// RBF specific flags. See pilosa/rbf/cfg/cfg.go for definitions.
 	srv.RBFConfig.DefineFlags(flags, prefix)
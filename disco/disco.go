@@ -21,6 +21,7 @@ var (
 	ErrViewExists        error = fmt.Errorf("view already exists")
 	ErrViewDoesNotExist  error = fmt.Errorf("view does not exist")
 	ErrKeyDoesNotExist   error = fmt.Errorf("key does not exist")
+	ErrNoCoordinator     error = fmt.Errorf("no coordinator")
 )
 
 type Peer struct {
@@ -66,6 +67,7 @@ const (
 	NodeStateUnknown  NodeState = "UNKNOWN"
 	NodeStateStarting NodeState = "STARTING"
 	NodeStateStarted  NodeState = "STARTED"
+	NodeStateDown     NodeState = "DOWN"
 )
 
 // Schema is a map of all indexes, each of those being a map of fields, then
@@ -115,6 +117,38 @@ type Sharder interface {
 	SetShards(ctx context.Context, index, field string, shards []byte) error
 }
 
+// ShardRoutes stores the overrides of the nodes which own shards, such as
+// those made by moving a shard, where every node can read them.
+type ShardRoutes interface {
+	// ShardRoutes returns every override, as node IDs with the primary
+	// first, by shard and by index.
+	ShardRoutes(ctx context.Context) (map[string]map[uint64][]string, error)
+
+	// SetShardRoute overrides the nodes which own a shard.
+	SetShardRoute(ctx context.Context, index string, shard uint64, nodeIDs []string) error
+}
+
+// CoordinatorStore stores the node the coordinator role was transferred to,
+// where every node can read it.
+type CoordinatorStore interface {
+	// TransferredCoordinator returns the ID of the node the coordinator
+	// role was last transferred to, or "" if it hasn't been.
+	TransferredCoordinator(ctx context.Context) (string, error)
+
+	// SetTransferredCoordinator records that the coordinator role was
+	// transferred to nodeID.
+	SetTransferredCoordinator(ctx context.Context, nodeID string) error
+}
+
+// HasherStore stores the name of the hasher a cluster was created with,
+// where every node can read it, so the hasher can't change once the cluster
+// exists.
+type HasherStore interface {
+	// InitHasher records name as the cluster's hasher if none is recorded
+	// yet, and returns the recorded name.
+	InitHasher(ctx context.Context, name string) (string, error)
+}
+
 // NopDisCo represents a DisCo that doesn't do anything.
 var NopDisCo DisCo = &nopDisCo{}
 
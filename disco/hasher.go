@@ -2,6 +2,12 @@
 // SPDX-License-Identifier: Apache-2.0
 package disco
 
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
 // Hasher represents an interface to hash integers into buckets.
 type Hasher interface {
 	// Hashes the key into a number between [0,N).
@@ -28,6 +34,68 @@ func (h *Jmphasher) Name() string {
 	return "jump-hash"
 }
 
+// RendezvousHasher assigns each key to the bucket with the highest score
+// for that key (highest random weight hashing). Like jump hash, it moves
+// only about 1/n of the keys when a bucket is added, but it takes time
+// linear in the number of buckets. Implements Hasher.
+type RendezvousHasher struct{}
+
+// Hash returns the integer hash for the given key.
+func (h *RendezvousHasher) Hash(key uint64, n int) int {
+	best, bestScore := -1, uint64(0)
+	for i := 0; i < n; i++ {
+		if score := mix64(key ^ mix64(uint64(i))); best < 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// Name returns the name of this hash.
+func (h *RendezvousHasher) Name() string {
+	return "rendezvous"
+}
+
+// mix64 is the splitmix64 finalizer, which scrambles the bits of x.
+func mix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// DefaultHasherName is the name of the hasher used when none is configured.
+const DefaultHasherName = "jump-hash"
+
+// hashers maps each Hasher's name to a constructor for it.
+var hashers = map[string]func() Hasher{
+	"jump-hash":  func() Hasher { return &Jmphasher{} },
+	"rendezvous": func() Hasher { return &RendezvousHasher{} },
+}
+
+// HasherNames returns the names accepted by NewHasher, sorted.
+func HasherNames() []string {
+	names := make([]string, 0, len(hashers))
+	for name := range hashers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewHasher returns the Hasher with the given name. An empty name selects
+// the default, jump-hash.
+func NewHasher(name string) (Hasher, error) {
+	if name == "" {
+		name = DefaultHasherName
+	}
+	fn, ok := hashers[name]
+	if !ok {
+		return nil, errors.Errorf("unknown hasher %q, expected one of %v", name, HasherNames())
+	}
+	return fn(), nil
+}
+
 // PrimaryNode yields the node that would be selected as the primary from
 // a list, for a given ID. It assumes the list is already in the
 // expected order, as from Noder.Nodes().
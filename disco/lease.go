@@ -0,0 +1,210 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package disco
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Leaser keeps a single lease, which at most one node holds at a time, and
+// which expires unless its holder renews it. Acquiring the lease must be
+// atomic, so that two nodes never both believe they hold it.
+type Leaser interface {
+	// AcquireLease grants the lease to nodeID for ttl if no other node
+	// holds it, or renews it if nodeID does. It returns the ID of the node
+	// holding the lease afterwards.
+	AcquireLease(ctx context.Context, nodeID string, ttl time.Duration) (string, error)
+
+	// ReleaseLease gives up the lease if nodeID holds it.
+	ReleaseLease(ctx context.Context, nodeID string) error
+
+	// LeaseHolder returns the ID of the node holding the lease, or "" if
+	// it's free or has expired.
+	LeaseHolder(ctx context.Context) (string, error)
+}
+
+// localLeaser is a Leaser kept in memory, for nodes sharing a process.
+type localLeaser struct {
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+}
+
+// NewLocalLeaser returns a Leaser kept in memory.
+func NewLocalLeaser() *localLeaser {
+	return &localLeaser{}
+}
+
+func (l *localLeaser) AcquireLease(ctx context.Context, nodeID string, ttl time.Duration) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.holder == "" || l.holder == nodeID || now.After(l.expires) {
+		l.holder, l.expires = nodeID, now.Add(ttl)
+	}
+	return l.holder, nil
+}
+
+func (l *localLeaser) ReleaseLease(ctx context.Context, nodeID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == nodeID {
+		l.holder = ""
+	}
+	return nil
+}
+
+func (l *localLeaser) LeaseHolder(ctx context.Context) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Now().After(l.expires) {
+		return "", nil
+	}
+	return l.holder, nil
+}
+
+// CoordinatorLease is a CoordinatorProvider which makes the holder of a
+// lease the coordinator. Every node runs one. The holder renews the lease
+// while it's up; once the lease is released or expires, the node after the
+// last holder in the Noder's order, skipping nodes which are down, takes
+// it over. Should that node not take it within a lease's ttl, any node may.
+// Before any node has held the lease, the default coordinator takes it.
+//
+// A holder is only named as the coordinator until its lease could have
+// expired, less a margin for clock skew between the nodes and the Leaser,
+// so that two nodes never act as the coordinator at once. Until the next
+// holder is seen, there is no coordinator.
+type CoordinatorLease struct {
+	leaser Leaser
+	noder  Noder
+	hasher Hasher
+	nodeID string
+	ttl    time.Duration
+	skew   time.Duration
+
+	mu         sync.Mutex
+	holder     string
+	expires    time.Time
+	lastHolder string
+	freeSince  time.Time
+	released   bool
+}
+
+// NewCoordinatorLease returns a CoordinatorLease for the node nodeID, whose
+// lease lasts ttl. A tenth of ttl is allowed for clock skew.
+func NewCoordinatorLease(leaser Leaser, noder Noder, hasher Hasher, nodeID string, ttl time.Duration) *CoordinatorLease {
+	return &CoordinatorLease{
+		leaser: leaser,
+		noder:  noder,
+		hasher: hasher,
+		nodeID: nodeID,
+		ttl:    ttl,
+		skew:   ttl / 10,
+	}
+}
+
+// CoordinatorID returns the ID of the node which held the lease when it was
+// last checked by Renew. It returns ErrNoCoordinator if no node held it, or
+// if the lease could have expired since.
+func (l *CoordinatorLease) CoordinatorID() (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == "" || !time.Now().Before(l.expires) {
+		return "", ErrNoCoordinator
+	}
+	return l.holder, nil
+}
+
+// Renew checks the lease once, renewing it if this node holds it, and
+// taking it over if it's free and this node is next in line.
+func (l *CoordinatorLease) Renew(ctx context.Context) error {
+	// A lease this node acquires is granted after checked, so it lasts at
+	// least until a ttl after it. Another node's lease is trusted for as
+	// long, as its holder renews it several times per ttl.
+	checked := time.Now()
+	holder, err := l.leaser.LeaseHolder(ctx)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if holder == "" && l.freeSince.IsZero() {
+		l.freeSince = now
+	}
+	acquire := !l.released && (holder == l.nodeID || holder == "" && l.unprotectedNextInLine(now))
+	l.mu.Unlock()
+
+	if acquire {
+		checked = time.Now()
+		if holder, err = l.leaser.AcquireLease(ctx, l.nodeID, l.ttl); err != nil {
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.holder = holder
+	l.expires = checked.Add(l.ttl - l.skew)
+	if holder != "" {
+		l.lastHolder = holder
+		l.freeSince = time.Time{}
+	}
+	return nil
+}
+
+// unprotectedNextInLine returns whether this node should take over the free
+// lease.
+func (l *CoordinatorLease) unprotectedNextInLine(now time.Time) bool {
+	if now.Sub(l.freeSince) >= l.ttl {
+		return true
+	}
+	nodes := l.noder.Nodes()
+	next := PrimaryNode(nodes, l.hasher)
+	for i, node := range nodes {
+		if node.ID != l.lastHolder {
+			continue
+		}
+		for j := 1; j < len(nodes); j++ {
+			if n := nodes[(i+j)%len(nodes)]; n.State != NodeStateDown {
+				next = n
+				break
+			}
+		}
+		break
+	}
+	return next != nil && next.ID == l.nodeID
+}
+
+// Run renews the lease three times per ttl until ctx is done. Errors are
+// passed to onErr, if it's not nil, and the lease is checked again on the
+// next tick.
+func (l *CoordinatorLease) Run(ctx context.Context, onErr func(error)) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		if err := l.Renew(ctx); err != nil && onErr != nil {
+			onErr(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Release gives up the lease, if this node holds it, so the next node in
+// line takes it over without waiting for it to expire. The lease isn't
+// taken again afterwards.
+func (l *CoordinatorLease) Release(ctx context.Context) error {
+	l.mu.Lock()
+	l.released = true
+	if l.holder == l.nodeID {
+		l.holder = ""
+	}
+	l.mu.Unlock()
+	return l.leaser.ReleaseLease(ctx, l.nodeID)
+}
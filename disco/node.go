@@ -15,6 +15,23 @@ type Node struct {
 	GRPCURI   net.URI   `json:"grpc-uri"`
 	IsPrimary bool      `json:"isPrimary"`
 	State     NodeState `json:"state"`
+
+	// Zone is an optional failure-domain label (zone, rack, etc.). Replica
+	// placement avoids putting more than one replica of a partition in the
+	// same zone when possible. Nodes with no zone are unconstrained.
+	Zone string `json:"zone,omitempty"`
+
+	// PartitionN and ReplicaN are the partition count and replica count the
+	// node was configured with. All nodes in a cluster must agree on them,
+	// or they'll disagree about which nodes own which data. Zero means
+	// unknown.
+	PartitionN int `json:"partitionN,omitempty"`
+	ReplicaN   int `json:"replicaN,omitempty"`
+
+	// Hasher is the name of the hasher the node assigns partitions to
+	// nodes with. Like PartitionN, all nodes must agree on it. Empty means
+	// unknown.
+	Hasher string `json:"hasher,omitempty"`
 }
 
 func (n *Node) Clone() *Node {
@@ -27,6 +44,10 @@ func (n *Node) Clone() *Node {
 	other.GRPCURI = n.GRPCURI
 	other.IsPrimary = n.IsPrimary
 	other.State = n.State
+	other.Zone = n.Zone
+	other.PartitionN = n.PartitionN
+	other.ReplicaN = n.ReplicaN
+	other.Hasher = n.Hasher
 	return &other
 }
 
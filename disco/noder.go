@@ -28,6 +28,11 @@ type Noder interface {
 	ClusterState(context.Context) (ClusterState, error)
 }
 
+// Stator reports the current state of an individual node.
+type Stator interface {
+	NodeState(ctx context.Context, nodeID string) (NodeState, error)
+}
+
 // localNoder is a simple implementation of the Noder interface
 // which maintains an instance of the `nodes` slice.
 type localNoder struct {
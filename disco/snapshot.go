@@ -34,6 +34,36 @@ type ClusterSnapshot struct {
 	ReplicaN int
 
 	PartitionAssignment string
+
+	// ShardRouter, if set, overrides the partition-based assignment of
+	// shards to nodes.
+	ShardRouter ShardRouter
+
+	// CoordinatorProvider, if set, overrides the hash-based choice of the
+	// coordinator.
+	CoordinatorProvider CoordinatorProvider
+}
+
+// ShardRouter overrides the nodes which own a shard. It can be used to make
+// shard ownership deterministic in tests, or to pin particular shards to
+// particular nodes.
+type ShardRouter interface {
+	// ShardNodeIDs returns the IDs of the nodes which own the shard, with
+	// the primary first and replicas following in a consistent order. If it
+	// returns nil, the default assignment is used for that shard.
+	ShardNodeIDs(index string, shard uint64) []string
+}
+
+// CoordinatorProvider supplies the coordinator: the node which is primary
+// for field key translation. It can be used to defer the choice to an
+// external leader election, such as one run with etcd or consul.
+type CoordinatorProvider interface {
+	// CoordinatorID returns the ID of the coordinator node. If it returns
+	// ErrNoCoordinator, there is no coordinator for now, as while a lease
+	// changes hands, and no node acts as one. If it returns another error,
+	// or the ID of a node which isn't in the cluster, the default
+	// coordinator is used.
+	CoordinatorID() (string, error)
 }
 
 // NewClusterSnapshot returns a new instance of ClusterSnapshot.
@@ -85,23 +115,59 @@ func (c *ClusterSnapshot) IDToShardPartition(index string, id uint64) int {
 // KeyToKeyPartition returns the key-partition that the given key belongs to.
 // NOTE: The key-partition is DIFFERENT from the shard-partition.
 func (c *ClusterSnapshot) KeyToKeyPartition(index, key string) int {
+	return KeyToKeyPartition(index, key, c.PartitionN)
+}
+
+// KeyToKeyPartition returns the key-partition that the given key belongs to,
+// for an index with partitionN key-partitions.
+func KeyToKeyPartition(index, key string, partitionN int) int {
 	// Hash the bytes and mod by partition count.
 	h := fnv.New64a()
 	_, _ = h.Write([]byte(index))
 	_, _ = h.Write([]byte(key))
-	return int(h.Sum64() % uint64(c.PartitionN))
+	return int(h.Sum64() % uint64(partitionN))
 }
 
 // ShardNodes returns a list of nodes that own a shard.
 func (c *ClusterSnapshot) ShardNodes(index string, shard uint64) []*Node {
+	if nodes, ok := c.routedShardNodes(index, shard); ok {
+		return nodes
+	}
 	return c.PartitionNodes(c.ShardToShardPartition(index, shard))
 }
 
+// routedShardNodes returns the nodes which the ShardRouter assigns to the
+// shard. IDs which aren't in the snapshot are skipped, so the returned nodes
+// carry the snapshot's node state. It returns false if there is no router,
+// or if the router doesn't override this shard.
+func (c *ClusterSnapshot) routedShardNodes(index string, shard uint64) ([]*Node, bool) {
+	if c.ShardRouter == nil {
+		return nil, false
+	}
+	ids := c.ShardRouter.ShardNodeIDs(index, shard)
+	if ids == nil {
+		return nil, false
+	}
+	nodes := make([]*Node, 0, len(ids))
+	for _, id := range ids {
+		for _, node := range c.Nodes {
+			if node.ID == id {
+				nodes = append(nodes, node)
+				break
+			}
+		}
+	}
+	return nodes, true
+}
+
 // OwnsShard returns true if a host owns a fragment.
 func (c *ClusterSnapshot) OwnsShard(nodeID string, index string, shard uint64) (ret bool) {
+	if nodes, ok := c.routedShardNodes(index, shard); ok {
+		return Nodes(nodes).ContainsID(nodeID)
+	}
 	idx := c.PrimaryNodeIndex(c.ShardToShardPartition(index, shard))
-	for i := 0; i < c.ReplicaN; i++ {
-		if c.Nodes[(idx+i)%len(c.Nodes)].ID == nodeID {
+	for _, i := range c.replicaNodeIndexes(idx) {
+		if c.Nodes[i].ID == nodeID {
 			return true
 		}
 	}
@@ -123,24 +189,97 @@ func (c *ClusterSnapshot) PartitionNodes(partitionID int) []*Node {
 	}
 	// Collect nodes around the ring.
 	nodes := make([]*Node, 0, c.ReplicaN)
-	for i := 0; i < c.ReplicaN; i++ {
-		nodes = append(nodes, c.Nodes[(nodeIndex+i)%len(c.Nodes)])
+	for _, i := range c.replicaNodeIndexes(nodeIndex) {
+		nodes = append(nodes, c.Nodes[i])
 	}
 
 	return nodes
 }
 
+// replicaNodeIndexes returns the positions in c.Nodes of the ReplicaN nodes
+// which hold a partition whose primary is at position primary, in replica
+// order. Nodes are taken walking around the ring from the primary, skipping
+// any node whose zone is already used by a selected replica. If there are
+// fewer distinct zones than replicas, the remaining replicas are filled in
+// ring order from the skipped nodes. Nodes with an empty zone never conflict,
+// so a cluster without zone labels gets the plain ring walk. The result
+// depends only on the (sorted) node list, so it is the same on every node.
+func (c *ClusterSnapshot) replicaNodeIndexes(primary int) []int {
+	nodeN := len(c.Nodes)
+	replicaN := c.ReplicaN
+	if replicaN > nodeN {
+		replicaN = nodeN
+	}
+	if primary < 0 || replicaN <= 0 {
+		return nil
+	}
+
+	idxs := make([]int, 0, replicaN)
+	var skipped []int
+	zones := make(map[string]struct{}, replicaN)
+	for i := 0; i < nodeN && len(idxs) < replicaN; i++ {
+		pos := (primary + i) % nodeN
+		zone := c.Nodes[pos].Zone
+		if zone != "" {
+			if _, ok := zones[zone]; ok {
+				skipped = append(skipped, pos)
+				continue
+			}
+			zones[zone] = struct{}{}
+		}
+		idxs = append(idxs, pos)
+	}
+
+	// Not enough distinct zones; co-locate the remaining replicas.
+	for _, pos := range skipped {
+		if len(idxs) == replicaN {
+			break
+		}
+		idxs = append(idxs, pos)
+	}
+	return idxs
+}
+
 // PrimaryFieldTranslationNode is the primary node responsible for translating
 // field keys. The primary could be any node in the cluster, but we arbitrarily
-// define it to be the node responsible for partition 0.
+// define it to be the node responsible for partition 0, unless the
+// CoordinatorProvider names another. It returns nil if the
+// CoordinatorProvider says there is no coordinator for now.
 func (c *ClusterSnapshot) PrimaryFieldTranslationNode() *Node {
+	node, ok := c.providedCoordinator()
+	if ok {
+		return node
+	}
 	return c.PrimaryPartitionNode(0)
 }
 
+// providedCoordinator returns the node which the CoordinatorProvider names
+// as the coordinator, which is nil if it has none for now. It returns false
+// if there is no provider, it fails, or it names a node which isn't in the
+// snapshot.
+func (c *ClusterSnapshot) providedCoordinator() (*Node, bool) {
+	if c.CoordinatorProvider == nil {
+		return nil, false
+	}
+	id, err := c.CoordinatorProvider.CoordinatorID()
+	if err == ErrNoCoordinator {
+		return nil, true
+	} else if err != nil {
+		return nil, false
+	}
+	for _, node := range c.Nodes {
+		if node.ID == id {
+			return node, true
+		}
+	}
+	return nil, false
+}
+
 // IsPrimaryFieldTranslationNode returns true if nodeID represents the primary
 // node responsible for field translation.
 func (c *ClusterSnapshot) IsPrimaryFieldTranslationNode(nodeID string) bool {
-	return c.PrimaryFieldTranslationNode().ID == nodeID
+	primary := c.PrimaryFieldTranslationNode()
+	return primary != nil && primary.ID == nodeID
 }
 
 // PrimaryPartitionNode returns the primary node of the given partition.
@@ -175,13 +314,11 @@ func (c *ClusterSnapshot) PrimaryNodeIndex(partition int) int {
 // given partition.
 func (c *ClusterSnapshot) NonPrimaryReplicas(partition int) (nonPrimaryReplicas []string) {
 	primary := c.PrimaryNodeIndex(partition)
-	nodeN := len(c.Nodes)
 
-	// Collect nodes around the ring.
-	for i := 1; i < nodeN; i++ {
-		node := c.Nodes[(primary+i)%nodeN]
-		if i < c.ReplicaN {
-			nonPrimaryReplicas = append(nonPrimaryReplicas, node.ID)
+	// Collect replica nodes, skipping the primary.
+	for _, i := range c.replicaNodeIndexes(primary) {
+		if i != primary {
+			nonPrimaryReplicas = append(nonPrimaryReplicas, c.Nodes[i].ID)
 		}
 	}
 	return
@@ -197,15 +334,13 @@ func (c *ClusterSnapshot) ReplicasForPrimary(primary int) (replicaNodeIDs, nonRe
 	replicaNodeIDs = make(map[string]bool)
 	nonReplicas = make(map[string]bool)
 
-	nodeN := len(c.Nodes)
-
-	// Collect nodes around the ring.
-	for i := 0; i < nodeN; i++ {
-		node := c.Nodes[(primary+i)%nodeN]
-		if i < c.ReplicaN {
-			// mark true if primary
-			replicaNodeIDs[node.ID] = (i == 0)
-		} else {
+	// Collect replica nodes; every other node is a non-replica.
+	for _, i := range c.replicaNodeIndexes(primary) {
+		// mark true if primary
+		replicaNodeIDs[c.Nodes[i].ID] = (i == primary)
+	}
+	for _, node := range c.Nodes {
+		if _, ok := replicaNodeIDs[node.ID]; !ok {
 			nonReplicas[node.ID] = false
 		}
 	}
@@ -216,9 +351,8 @@ func (c *ClusterSnapshot) ReplicasForPrimary(primary int) (replicaNodeIDs, nonRe
 func (c *ClusterSnapshot) ContainsShards(index string, availableShards *roaring.Bitmap, node *Node) []uint64 {
 	var shards []uint64
 	_ = availableShards.ForEach(func(i uint64) error {
-		p := c.ShardToShardPartition(index, i)
-		// Determine the nodes for partition.
-		nodes := c.PartitionNodes(p)
+		// Determine the nodes for the shard.
+		nodes := c.ShardNodes(index, i)
 		for _, n := range nodes {
 			if n.ID == node.ID {
 				shards = append(shards, i)
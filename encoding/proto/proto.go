@@ -98,6 +98,30 @@ func (s Serializer) Unmarshal(buf []byte, m pilosa.Message) error {
 		}
 		s.decodeDeleteFieldMessage(msg, mt)
 		return nil
+	case *pilosa.TruncateFieldMessage:
+		msg := &pb.DeleteFieldMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling TruncateFieldMessage")
+		}
+		s.decodeTruncateFieldMessage(msg, mt)
+		return nil
+	case *pilosa.SetCoordinatorMessage:
+		msg := &pb.NodeStateMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling SetCoordinatorMessage")
+		}
+		s.decodeSetCoordinatorMessage(msg, mt)
+		return nil
+	case *pilosa.ShardRouteMessage:
+		msg := &pb.ShardRouteMessage{}
+		err := proto.Unmarshal(buf, msg)
+		if err != nil {
+			return errors.Wrap(err, "unmarshaling ShardRouteMessage")
+		}
+		s.decodeShardRouteMessage(msg, mt)
+		return nil
 	case *pilosa.DeleteAvailableShardMessage:
 		msg := &pb.DeleteAvailableShardMessage{}
 		err := proto.Unmarshal(buf, msg)
@@ -325,6 +349,8 @@ func (s Serializer) encodeToProto(m pilosa.Message) proto.Message {
 		return s.encodeUpdateFieldMessage(mt)
 	case *pilosa.DeleteFieldMessage:
 		return s.encodeDeleteFieldMessage(mt)
+	case *pilosa.TruncateFieldMessage:
+		return s.encodeTruncateFieldMessage(mt)
 	case *pilosa.DeleteAvailableShardMessage:
 		return s.encodeDeleteAvailableShardMessage(mt)
 	case *pilosa.CreateViewMessage:
@@ -335,6 +361,10 @@ func (s Serializer) encodeToProto(m pilosa.Message) proto.Message {
 		return s.encodeClusterStatus(mt)
 	case *pilosa.NodeStateMessage:
 		return s.encodeNodeStateMessage(mt)
+	case *pilosa.SetCoordinatorMessage:
+		return s.encodeSetCoordinatorMessage(mt)
+	case *pilosa.ShardRouteMessage:
+		return s.encodeShardRouteMessage(mt)
 	case *pilosa.RecalculateCaches:
 		return s.encodeRecalculateCaches(mt)
 	case *pilosa.LoadSchemaMessage:
@@ -638,6 +668,10 @@ func (s Serializer) encodeFieldOptions(o *pilosa.FieldOptions) *pb.FieldOptions
 		ForeignIndex:   o.ForeignIndex,
 		NoStandardView: o.NoStandardView,
 		TrackExistence: o.TrackExistence,
+		RunThreshold:   o.RunThreshold,
+
+		TombstoneWindow:          int64(o.TombstoneWindow),
+		CacheRecalculateInterval: int64(o.CacheRecalculateInterval),
 	}
 }
 
@@ -700,6 +734,7 @@ func (s Serializer) encodeIndexMeta(m *pilosa.IndexOptions) *pb.IndexMeta {
 		Description:    m.Description,
 		Keys:           m.Keys,
 		TrackExistence: m.TrackExistence,
+		PartitionN:     uint32(m.PartitionN),
 	}
 }
 
@@ -740,6 +775,15 @@ func (s Serializer) encodeDeleteFieldMessage(m *pilosa.DeleteFieldMessage) *pb.D
 	}
 }
 
+// encodeTruncateFieldMessage encodes m as a pb.DeleteFieldMessage, which
+// has the same fields; the message type byte distinguishes them.
+func (s Serializer) encodeTruncateFieldMessage(m *pilosa.TruncateFieldMessage) *pb.DeleteFieldMessage {
+	return &pb.DeleteFieldMessage{
+		Index: m.Index,
+		Field: m.Field,
+	}
+}
+
 func (s Serializer) encodeDeleteAvailableShardMessage(m *pilosa.DeleteAvailableShardMessage) *pb.DeleteAvailableShardMessage {
 	return &pb.DeleteAvailableShardMessage{
 		Index:   m.Index,
@@ -771,6 +815,22 @@ func (s Serializer) encodeNodeStateMessage(m *pilosa.NodeStateMessage) *pb.NodeS
 	}
 }
 
+// encodeSetCoordinatorMessage encodes m as a pb.NodeStateMessage without a
+// state; the message type byte distinguishes them.
+func (s Serializer) encodeSetCoordinatorMessage(m *pilosa.SetCoordinatorMessage) *pb.NodeStateMessage {
+	return &pb.NodeStateMessage{
+		NodeID: m.NodeID,
+	}
+}
+
+func (s Serializer) encodeShardRouteMessage(m *pilosa.ShardRouteMessage) *pb.ShardRouteMessage {
+	return &pb.ShardRouteMessage{
+		Index:   m.Index,
+		Shard:   m.Shard,
+		NodeIDs: m.NodeIDs,
+	}
+}
+
 func (s Serializer) encodeNodeEventMessage(m *pilosa.NodeEvent) *pb.NodeEventMessage {
 	return &pb.NodeEventMessage{
 		Event: uint32(m.Event),
@@ -975,6 +1035,9 @@ func (s Serializer) decodeFieldOptions(options *pb.FieldOptions, m *pilosa.Field
 	m.ForeignIndex = options.ForeignIndex
 	m.NoStandardView = options.NoStandardView
 	m.TrackExistence = options.TrackExistence
+	m.RunThreshold = options.RunThreshold
+	m.TombstoneWindow = time.Duration(options.TombstoneWindow)
+	m.CacheRecalculateInterval = time.Duration(options.CacheRecalculateInterval)
 }
 
 func (s Serializer) decodeDecimal(d *pb.Decimal, m *pql.Decimal) {
@@ -1044,6 +1107,7 @@ func (s Serializer) decodeIndexMeta(pb *pb.IndexMeta, m *pilosa.IndexOptions) {
 		m.Description = pb.Description
 		m.Keys = pb.Keys
 		m.TrackExistence = pb.TrackExistence
+		m.PartitionN = int(pb.PartitionN)
 	}
 }
 
@@ -1075,6 +1139,11 @@ func (s Serializer) decodeDeleteFieldMessage(pb *pb.DeleteFieldMessage, m *pilos
 	m.Field = pb.Field
 }
 
+func (s Serializer) decodeTruncateFieldMessage(pb *pb.DeleteFieldMessage, m *pilosa.TruncateFieldMessage) {
+	m.Index = pb.Index
+	m.Field = pb.Field
+}
+
 func (s Serializer) decodeDeleteAvailableShardMessage(pb *pb.DeleteAvailableShardMessage, m *pilosa.DeleteAvailableShardMessage) {
 	m.Index = pb.Index
 	m.Field = pb.Field
@@ -1098,6 +1167,16 @@ func (s Serializer) decodeNodeStateMessage(pb *pb.NodeStateMessage, m *pilosa.No
 	m.State = pb.State
 }
 
+func (s Serializer) decodeSetCoordinatorMessage(pb *pb.NodeStateMessage, m *pilosa.SetCoordinatorMessage) {
+	m.NodeID = pb.NodeID
+}
+
+func (s Serializer) decodeShardRouteMessage(pb *pb.ShardRouteMessage, m *pilosa.ShardRouteMessage) {
+	m.Index = pb.Index
+	m.Shard = pb.Shard
+	m.NodeIDs = pb.NodeIDs
+}
+
 func (s Serializer) decodeNodeEventMessage(pb *pb.NodeEventMessage, m *pilosa.NodeEvent) {
 	m.Event = pilosa.NodeEventType(pb.Event)
 	m.Node = &disco.Node{}
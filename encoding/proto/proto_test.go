@@ -5,6 +5,7 @@ package proto
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/apache/arrow/go/v10/arrow"
 	"github.com/apache/arrow/go/v10/arrow/array"
@@ -381,3 +382,60 @@ func TestSerializer_ExtractedTable(t *testing.T) {
 		})
 	}
 }
+
+func TestSerializer_CreateIndexMessage(t *testing.T) {
+	testOneRoundTrip(t, Serializer{}, &pilosa.CreateIndexMessage{
+		Index:     "i",
+		CreatedAt: 1,
+		Owner:     "o",
+		Meta: pilosa.IndexOptions{
+			Keys:           true,
+			TrackExistence: true,
+			PartitionN:     4,
+			Description:    "d",
+		},
+	}, nil, nil, nil)
+}
+
+func TestSerializer_FieldOptions(t *testing.T) {
+	s := Serializer{}
+	opts := pilosa.FieldOptions{
+		Type:         pilosa.FieldTypeSet,
+		CacheType:    pilosa.CacheTypeRanked,
+		CacheSize:    100,
+		RunThreshold: 8,
+
+		TombstoneWindow:          time.Minute,
+		CacheRecalculateInterval: time.Hour,
+	}
+	var got pilosa.FieldOptions
+	s.decodeFieldOptions(s.encodeFieldOptions(&opts), &got)
+	if got.RunThreshold != opts.RunThreshold {
+		t.Fatalf("expected run threshold %d, got %d", opts.RunThreshold, got.RunThreshold)
+	} else if got.TombstoneWindow != opts.TombstoneWindow {
+		t.Fatalf("expected tombstone window %s, got %s", opts.TombstoneWindow, got.TombstoneWindow)
+	} else if got.CacheRecalculateInterval != opts.CacheRecalculateInterval {
+		t.Fatalf("expected cache recalculate interval %s, got %s", opts.CacheRecalculateInterval, got.CacheRecalculateInterval)
+	}
+}
+
+func TestSerializer_TruncateFieldMessage(t *testing.T) {
+	testOneRoundTrip(t, Serializer{}, &pilosa.TruncateFieldMessage{
+		Index: "i",
+		Field: "f",
+	}, nil, nil, nil)
+}
+
+func TestSerializer_SetCoordinatorMessage(t *testing.T) {
+	testOneRoundTrip(t, Serializer{}, &pilosa.SetCoordinatorMessage{
+		NodeID: "node1",
+	}, nil, nil, nil)
+}
+
+func TestSerializer_ShardRouteMessage(t *testing.T) {
+	testOneRoundTrip(t, Serializer{}, &pilosa.ShardRouteMessage{
+		Index:   "i",
+		Shard:   3,
+		NodeIDs: []string{"node1", "node0"},
+	}, nil, nil, nil)
+}
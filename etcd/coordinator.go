@@ -0,0 +1,36 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package etcd
+
+import (
+	"context"
+
+	"github.com/featurebasedb/featurebase/v3/disco"
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// transferredCoordinatorKey holds the ID of the node the coordinator role
+// was last transferred to.
+const transferredCoordinatorKey = "/coordinator/transferred"
+
+var _ disco.CoordinatorStore = &Etcd{}
+
+// TransferredCoordinator implements disco.CoordinatorStore.
+func (e *Etcd) TransferredCoordinator(ctx context.Context) (string, error) {
+	val, err := e.getKeyBytes(ctx, transferredCoordinatorKey)
+	if errors.Cause(err) == disco.ErrKeyDoesNotExist {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+// SetTransferredCoordinator implements disco.CoordinatorStore.
+func (e *Etcd) SetTransferredCoordinator(ctx context.Context, nodeID string) error {
+	return e.retryClient(func(cli *clientv3.Client) (err error) {
+		_, err = cli.Put(ctx, transferredCoordinatorKey, nodeID)
+		return err
+	})
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package etcd
+
+import (
+	"context"
+	"time"
+
+	"github.com/featurebasedb/featurebase/v3/disco"
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/clientv3util"
+)
+
+// coordinatorLeaseKey holds the ID of the node holding the coordinator
+// lease. It's attached to an etcd lease, so it's deleted when that expires.
+const coordinatorLeaseKey = "/coordinator/lease"
+
+var _ disco.Leaser = &Etcd{}
+
+// AcquireLease implements disco.Leaser. The key is only created if it's
+// missing, in a transaction, so only one node ever holds the lease. The
+// ttl is rounded up to whole seconds.
+func (e *Etcd) AcquireLease(ctx context.Context, nodeID string, ttl time.Duration) (string, error) {
+	kv, err := e.coordinatorLease(ctx)
+	if err != nil {
+		return "", err
+	}
+	if kv != nil {
+		if holder := string(kv.Value); holder != nodeID {
+			return holder, nil
+		}
+		err := e.retryClient(func(cli *clientv3.Client) (err error) {
+			_, err = cli.KeepAliveOnce(ctx, clientv3.LeaseID(kv.Lease))
+			return err
+		})
+		if err != nil {
+			return "", errors.Wrap(err, "renewing coordinator lease")
+		}
+		return nodeID, nil
+	}
+
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	var lease *clientv3.LeaseGrantResponse
+	if err := e.retryClient(func(cli *clientv3.Client) (err error) {
+		lease, err = cli.Grant(ctx, seconds)
+		return err
+	}); err != nil {
+		return "", errors.Wrap(err, "granting coordinator lease")
+	}
+	var resp *clientv3.TxnResponse
+	if err := e.retryClient(func(cli *clientv3.Client) (err error) {
+		resp, err = cli.Txn(ctx).
+			If(clientv3util.KeyMissing(coordinatorLeaseKey)).
+			Then(clientv3.OpPut(coordinatorLeaseKey, nodeID, clientv3.WithLease(lease.ID))).
+			Else(clientv3.OpGet(coordinatorLeaseKey)).
+			Commit()
+		return err
+	}); err != nil {
+		return "", errors.Wrap(err, "acquiring coordinator lease")
+	}
+	if resp.Succeeded {
+		return nodeID, nil
+	}
+
+	// Another node took the lease first.
+	if err := e.retryClient(func(cli *clientv3.Client) (err error) {
+		_, err = cli.Revoke(ctx, lease.ID)
+		return err
+	}); err != nil {
+		return "", errors.Wrap(err, "revoking unused lease")
+	}
+	if kvs := resp.Responses[0].GetResponseRange().Kvs; len(kvs) > 0 {
+		return string(kvs[0].Value), nil
+	}
+	return "", nil
+}
+
+// ReleaseLease implements disco.Leaser.
+func (e *Etcd) ReleaseLease(ctx context.Context, nodeID string) error {
+	err := e.retryClient(func(cli *clientv3.Client) (err error) {
+		_, err = cli.Txn(ctx).
+			If(clientv3.Compare(clientv3.Value(coordinatorLeaseKey), "=", nodeID)).
+			Then(clientv3.OpDelete(coordinatorLeaseKey)).
+			Commit()
+		return err
+	})
+	return errors.Wrap(err, "releasing coordinator lease")
+}
+
+// LeaseHolder implements disco.Leaser.
+func (e *Etcd) LeaseHolder(ctx context.Context) (string, error) {
+	kv, err := e.coordinatorLease(ctx)
+	if err != nil || kv == nil {
+		return "", err
+	}
+	return string(kv.Value), nil
+}
+
+// coordinatorLease returns the coordinator lease key, or nil if no node
+// holds the lease.
+func (e *Etcd) coordinatorLease(ctx context.Context) (*mvccpb.KeyValue, error) {
+	var resp *clientv3.GetResponse
+	err := e.retryClient(func(cli *clientv3.Client) (err error) {
+		resp, err = cli.Get(ctx, coordinatorLeaseKey)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "getting coordinator lease")
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	return resp.Kvs[0], nil
+}
@@ -1106,6 +1106,18 @@ func (e *Etcd) Nodes() []*disco.Node {
 	return e.populateNodeStates(context.TODO())
 }
 
+// NodeState implements the Stator interface. It returns the state from the
+// most recent heartbeat seen for the node.
+func (e *Etcd) NodeState(ctx context.Context, nodeID string) (disco.NodeState, error) {
+	e.nodeMu.Lock()
+	defer e.nodeMu.Unlock()
+	data, ok := e.knownNodes[nodeID]
+	if !ok {
+		return disco.NodeStateUnknown, fmt.Errorf("unknown node: %s", nodeID)
+	}
+	return data.heartbeat, nil
+}
+
 // PrimaryNodeID implements the Noder interface.
 func (e *Etcd) PrimaryNodeID(hasher disco.Hasher) string {
 	return disco.PrimaryNodeID(e.NodeIDs(), hasher)
@@ -0,0 +1,40 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package etcd
+
+import (
+	"context"
+
+	"github.com/featurebasedb/featurebase/v3/disco"
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/clientv3util"
+)
+
+// hasherKey holds the name of the hasher the cluster was created with.
+const hasherKey = "/cluster/hasher"
+
+var _ disco.HasherStore = &Etcd{}
+
+// InitHasher implements disco.HasherStore. The key is only created if it's
+// missing, in a transaction, so the first node to start decides the hasher.
+func (e *Etcd) InitHasher(ctx context.Context, name string) (string, error) {
+	var resp *clientv3.TxnResponse
+	if err := e.retryClient(func(cli *clientv3.Client) (err error) {
+		resp, err = cli.Txn(ctx).
+			If(clientv3util.KeyMissing(hasherKey)).
+			Then(clientv3.OpPut(hasherKey, name)).
+			Else(clientv3.OpGet(hasherKey)).
+			Commit()
+		return err
+	}); err != nil {
+		return "", errors.Wrap(err, "initializing hasher")
+	}
+	if resp.Succeeded {
+		return name, nil
+	}
+	if kvs := resp.Responses[0].GetResponseRange().Kvs; len(kvs) > 0 {
+		return string(kvs[0].Value), nil
+	}
+	return "", errors.New("hasher key exists, but has no value")
+}
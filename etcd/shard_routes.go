@@ -0,0 +1,64 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/featurebasedb/featurebase/v3/disco"
+	"github.com/pkg/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// shardRoutePrefix holds a key for each shard whose owners have been
+// overridden, /shardroute/<index>/<shard>, whose value is the JSON encoded
+// IDs of its owners.
+const shardRoutePrefix = "/shardroute/"
+
+var _ disco.ShardRoutes = &Etcd{}
+
+// ShardRoutes implements disco.ShardRoutes.
+func (e *Etcd) ShardRoutes(ctx context.Context) (map[string]map[uint64][]string, error) {
+	keys, vals, err := e.getKeyWithPrefix(ctx, shardRoutePrefix)
+	if errors.Cause(err) == disco.ErrKeyDoesNotExist {
+		return map[string]map[uint64][]string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	routes := make(map[string]map[uint64][]string)
+	for i, key := range keys {
+		index, shardStr := path.Split(strings.TrimPrefix(key, shardRoutePrefix))
+		index = strings.TrimSuffix(index, "/")
+		shard, err := strconv.ParseUint(shardStr, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing shard route key %s", key)
+		}
+		var ids []string
+		if err := json.Unmarshal(vals[i], &ids); err != nil {
+			return nil, errors.Wrapf(err, "decoding shard route %s", key)
+		}
+		if routes[index] == nil {
+			routes[index] = make(map[uint64][]string)
+		}
+		routes[index][shard] = ids
+	}
+	return routes, nil
+}
+
+// SetShardRoute implements disco.ShardRoutes.
+func (e *Etcd) SetShardRoute(ctx context.Context, index string, shard uint64, nodeIDs []string) error {
+	buf, err := json.Marshal(nodeIDs)
+	if err != nil {
+		return errors.Wrap(err, "encoding shard route")
+	}
+	key := path.Join(shardRoutePrefix, index, strconv.FormatUint(shard, 10))
+	return e.retryClient(func(cli *clientv3.Client) (err error) {
+		_, err = cli.Put(ctx, key, string(buf))
+		return err
+	})
+}
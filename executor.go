@@ -77,6 +77,10 @@ type executor struct {
 	// Maximum per-request memory usage (Extract() only)
 	maxMemory int64
 
+	// Maximum number of a call's shards mapped at once by mapperLocal.
+	// Zero is unlimited.
+	maxConcurrentShards int
+
 	// Temporary flag to be removed when stablized
 	dataframeEnabled   bool
 	datafameUseParquet bool
@@ -106,6 +110,13 @@ func optExecutorMaxMemory(v int64) executorOption {
 	}
 }
 
+func optExecutorMaxConcurrentShards(n int) executorOption {
+	return func(e *executor) error {
+		e.maxConcurrentShards = n
+		return nil
+	}
+}
+
 func emptyResult(c *pql.Call) interface{} {
 	switch c.Name {
 	case "Clear", "ClearRow":
@@ -2185,8 +2196,10 @@ func (e *executor) executeSumCountShard(ctx context.Context, qcx *Qcx, index str
 		return ValCount{}, nil
 	}
 
-	fragment := e.Holder.fragment(index, fieldName, viewBSIGroupPrefix+fieldName, shard)
-	if fragment == nil {
+	fragment, err := e.Holder.fragment(index, fieldName, viewBSIGroupPrefix+fieldName, shard)
+	if err != nil {
+		return ValCount{}, err
+	} else if fragment == nil {
 		return ValCount{}, nil
 	}
 
@@ -2284,8 +2297,10 @@ func (e *executor) executeMinRowShard(ctx context.Context, qcx *Qcx, index strin
 		return PairField{}, ErrFieldNotFound
 	}
 
-	fragment := e.Holder.fragment(index, fieldName, viewStandard, shard)
-	if fragment == nil {
+	fragment, err := e.Holder.fragment(index, fieldName, viewStandard, shard)
+	if err != nil {
+		return PairField{}, err
+	} else if fragment == nil {
 		return PairField{}, nil
 	}
 
@@ -2328,8 +2343,10 @@ func (e *executor) executeMaxRowShard(ctx context.Context, qcx *Qcx, index strin
 		return PairField{}, ErrFieldNotFound
 	}
 
-	fragment := e.Holder.fragment(index, fieldName, viewStandard, shard)
-	if fragment == nil {
+	fragment, err := e.Holder.fragment(index, fieldName, viewStandard, shard)
+	if err != nil {
+		return PairField{}, err
+	} else if fragment == nil {
 		return PairField{}, nil
 	}
 
@@ -2489,8 +2506,10 @@ func (e *executor) executeTopKShardSet(ctx context.Context, tx Tx, filter *Row,
 	span, ctx := tracing.StartSpanFromContext(ctx, "executor.executeTopKShardSet")
 	defer span.Finish()
 
-	f := e.Holder.fragment(index, field, viewStandard, shard)
-	if f == nil {
+	f, err := e.Holder.fragment(index, field, viewStandard, shard)
+	if err != nil {
+		return nil, err
+	} else if f == nil {
 		return nil, nil
 	}
 
@@ -2519,8 +2538,10 @@ func (e *executor) executeTopKShardTime(ctx context.Context, tx Tx, filter *Row,
 	// Fetch fragments.
 	fragments := make([]*fragment, 0, len(views))
 	for _, view := range views {
-		f := e.Holder.fragment(index, field, view, shard)
-		if f == nil {
+		f, err := e.Holder.fragment(index, field, view, shard)
+		if err != nil {
+			return nil, err
+		} else if f == nil {
 			continue
 		}
 
@@ -2908,8 +2929,10 @@ func (e *executor) executeTopNShard(ctx context.Context, qcx *Qcx, index string,
 		fieldName = defaultField
 	}
 
-	f := e.Holder.fragment(index, fieldName, viewStandard, shard)
-	if f == nil {
+	f, err := e.Holder.fragment(index, fieldName, viewStandard, shard)
+	if err != nil {
+		return nil, err
+	} else if f == nil {
 		return &PairsField{}, nil
 	} else if f.CacheType == CacheTypeNone {
 		return nil, fmt.Errorf("cannot compute TopN(), field has no cache: %q", fieldName)
@@ -4163,8 +4186,10 @@ func (e *executor) executeRowsShard(ctx context.Context, qcx *Qcx, index string,
 		if err := ctx.Err(); err != nil {
 			return nil, err
 		}
-		frag := e.Holder.fragment(index, fieldName, view, shard)
-		if frag == nil {
+		frag, err := e.Holder.fragment(index, fieldName, view, shard)
+		if err != nil {
+			return nil, err
+		} else if frag == nil {
 			continue
 		}
 
@@ -4846,8 +4871,10 @@ func (e *executor) executeExtractShard(ctx context.Context, qcx *Qcx, index stri
 			// Handle a set field by listing the rows and then intersecting them with the filter.
 
 			// Extract the standard view fragment.
-			fragment := e.Holder.fragment(index, name, viewStandard, shard)
-			if fragment == nil {
+			fragment, err := e.Holder.fragment(index, name, viewStandard, shard)
+			if err != nil {
+				return nil, err
+			} else if fragment == nil {
 				// There is nothing here.
 				continue
 			}
@@ -4886,8 +4913,10 @@ func (e *executor) executeExtractShard(ctx context.Context, qcx *Qcx, index stri
 
 			dedup := make(map[uint64]map[uint64]struct{})
 			for _, view := range views {
-				fragment := e.Holder.fragment(index, name, view, shard)
-				if fragment == nil {
+				fragment, err := e.Holder.fragment(index, name, view, shard)
+				if err != nil {
+					return nil, err
+				} else if fragment == nil {
 					// There is nothing here.
 					continue
 				}
@@ -4939,8 +4968,10 @@ func (e *executor) executeExtractShard(ctx context.Context, qcx *Qcx, index stri
 			// Handle bool fields by scanning the true and false rows and assigning an integer.
 
 			// Extract the standard view fragment.
-			fragment := e.Holder.fragment(index, name, viewStandard, shard)
-			if fragment == nil {
+			fragment, err := e.Holder.fragment(index, name, viewStandard, shard)
+			if err != nil {
+				return nil, err
+			} else if fragment == nil {
 				// There is nothing here.
 				continue
 			}
@@ -4970,8 +5001,10 @@ func (e *executor) executeExtractShard(ctx context.Context, qcx *Qcx, index stri
 			// Handle an int/decimal field by rotating a BSI matrix.
 
 			// Extract the BSI view fragment.
-			fragment := e.Holder.fragment(index, name, viewBSIGroupPrefix+name, shard)
-			if fragment == nil {
+			fragment, err := e.Holder.fragment(index, name, viewBSIGroupPrefix+name, shard)
+			if err != nil {
+				return nil, err
+			} else if fragment == nil {
 				// There is nothing here.
 				continue
 			}
@@ -5067,8 +5100,10 @@ func (e *executor) getNullRowShard(ctx context.Context, tx Tx, idx *Index, fld *
 	}
 
 	var existenceRow *Row
-	existenceFrag := e.Holder.fragment(idx.name, existenceFieldName, viewStandard, shard)
-	if existenceFrag == nil {
+	existenceFrag, err := e.Holder.fragment(idx.name, existenceFieldName, viewStandard, shard)
+	if err != nil {
+		return nil, err
+	} else if existenceFrag == nil {
 		// no existence frag -> no existence bits are set -> there are no
 		// records in this shard. therefore no records in this shard are
 		// null. more simply, we don't have to compute the things we want
@@ -5076,17 +5111,18 @@ func (e *executor) getNullRowShard(ctx context.Context, tx Tx, idx *Index, fld *
 		// will stay empty.
 		return NewRow(), nil
 	} else {
-		var err error
 		if existenceRow, err = existenceFrag.row(tx, 0); err != nil {
 			return nil, err
 		}
 	}
 
 	var notNull *Row
-	var err error
 
 	// Retrieve notNull from fragment if it exists.
-	if frag := e.Holder.fragment(idx.name, fld.name, existenceView, shard); frag != nil {
+	frag, err := e.Holder.fragment(idx.name, fld.name, existenceView, shard)
+	if err != nil {
+		return nil, err
+	} else if frag != nil {
 		if notNull, err = frag.notNull(tx); err != nil {
 			return nil, errors.Wrap(err, "getting fragment not null")
 		}
@@ -5110,8 +5146,10 @@ func (e *executor) getNonNullRowShard(ctx context.Context, tx Tx, idx *Index, fl
 		return nil, fmt.Errorf("field does not support existence tracking: %s", fld.name)
 	}
 	// Retrieve fragment.
-	frag := e.Holder.fragment(idx.name, fld.name, existenceView, shard)
-	if frag == nil {
+	frag, err := e.Holder.fragment(idx.name, fld.name, existenceView, shard)
+	if err != nil {
+		return nil, err
+	} else if frag == nil {
 		return NewRow(), nil
 	}
 	return frag.notNull(tx)
@@ -5189,8 +5227,10 @@ func (e *executor) executeRowShard(ctx context.Context, qcx *Qcx, index string,
 		return nil, errors.New("only support != for null, not for other values, on set/mutex fields")
 	}
 	if c.Name == "Row" && timeNotSet && !f.options.NoStandardView {
-		frag := e.Holder.fragment(index, fieldName, viewStandard, shard)
-		if frag == nil {
+		frag, err := e.Holder.fragment(index, fieldName, viewStandard, shard)
+		if err != nil {
+			return nil, err
+		} else if frag == nil {
 			return NewRow(), nil
 		}
 
@@ -5214,14 +5254,16 @@ func (e *executor) executeRowShard(ctx context.Context, qcx *Qcx, index string,
 	// Union bitmaps across all time-based views.
 	rows := make([]*Row, 0, len(views))
 	tx, finisher, err := qcx.GetTx(Txo{Write: !writable, Index: idx, Shard: shard})
+	if err != nil {
+		return nil, err
+	}
 	defer finisher(&err0)
 	for _, view := range views {
-		f := e.Holder.fragment(index, fieldName, view, shard)
-		if f == nil {
-			continue
-		}
+		f, err := e.Holder.fragment(index, fieldName, view, shard)
 		if err != nil {
 			return nil, err
+		} else if f == nil {
+			continue
 		}
 
 		row, err := f.row(tx, rowID)
@@ -5308,8 +5350,10 @@ func (e *executor) executeRowBSIGroupShard(ctx context.Context, qcx *Qcx, fld *F
 		}
 
 		// Retrieve fragment.
-		frag := e.Holder.fragment(fld.idx.name, fld.name, viewName, shard)
-		if frag == nil {
+		frag, err := e.Holder.fragment(fld.idx.name, fld.name, viewName, shard)
+		if err != nil {
+			return nil, err
+		} else if frag == nil {
 			return NewRow(), nil
 		}
 
@@ -5333,8 +5377,10 @@ func (e *executor) executeRowBSIGroupShard(ctx context.Context, qcx *Qcx, fld *F
 		}
 
 		// Retrieve fragment.
-		frag := e.Holder.fragment(fld.idx.name, fld.name, viewName, shard)
-		if frag == nil {
+		frag, err := e.Holder.fragment(fld.idx.name, fld.name, viewName, shard)
+		if err != nil {
+			return nil, err
+		} else if frag == nil {
 			return NewRow(), nil
 		}
 
@@ -5453,8 +5499,10 @@ func (e *executor) executeInnerUnionRowsShard(ctx context.Context, qcx *Qcx, ind
 	// Simply return row if times are not set.
 	timeNotSet := fromTime.IsZero() && toTime.IsZero()
 	if timeNotSet {
-		frag := e.Holder.fragment(index, fieldName, viewStandard, shard)
-		if frag == nil {
+		frag, err := e.Holder.fragment(index, fieldName, viewStandard, shard)
+		if err != nil {
+			return nil, err
+		} else if frag == nil {
 			return NewRow(), nil
 		}
 
@@ -5478,14 +5526,16 @@ func (e *executor) executeInnerUnionRowsShard(ctx context.Context, qcx *Qcx, ind
 	// Union bitmaps across all time-based views.
 	rows := make([]*Row, 0, len(views))
 	tx, finisher, err := qcx.GetTx(Txo{Write: !writable, Index: idx, Shard: shard})
+	if err != nil {
+		return nil, err
+	}
 	defer finisher(&err0)
 	for _, view := range views {
-		f := e.Holder.fragment(index, fieldName, view, shard)
-		if f == nil {
-			continue
-		}
+		f, err := e.Holder.fragment(index, fieldName, view, shard)
 		if err != nil {
 			return nil, err
+		} else if f == nil {
+			continue
 		}
 
 		row, err := f.unionRows(ctx, tx, rowIDs)
@@ -5576,8 +5626,10 @@ func (e *executor) executeNotShard(ctx context.Context, qcx *Qcx, index string,
 	defer finisher(nil)
 
 	var existenceRow *Row
-	existenceFrag := e.Holder.fragment(index, existenceFieldName, viewStandard, shard)
-	if existenceFrag == nil {
+	existenceFrag, err := e.Holder.fragment(index, existenceFieldName, viewStandard, shard)
+	if err != nil {
+		return nil, err
+	} else if existenceFrag == nil {
 		existenceRow = NewRow()
 	} else {
 		if existenceRow, err = existenceFrag.row(tx, 0); err != nil {
@@ -5674,8 +5726,10 @@ func (e *executor) executeConstRow(ctx context.Context, qcx *Qcx, index string,
 func (e *executor) executeConstRowShard(ctx context.Context, qcx *Qcx, index string, c *pql.Call, shard uint64, src *Row) (res *Row, err error) {
 	idx := e.Holder.Index(index)
 	var existenceRow *Row
-	existenceFrag := e.Holder.fragment(index, existenceFieldName, viewStandard, shard)
-	if existenceFrag == nil {
+	existenceFrag, err := e.Holder.fragment(index, existenceFieldName, viewStandard, shard)
+	if err != nil {
+		return nil, err
+	} else if existenceFrag == nil {
 		existenceRow = NewRow()
 	} else {
 		tx, finisher, err := qcx.GetTx(Txo{Write: !writable, Index: idx, Fragment: existenceFrag, Shard: shard})
@@ -5795,8 +5849,10 @@ func (e *executor) executeAllCallShard(ctx context.Context, qcx *Qcx, index stri
 	}
 
 	var existenceRow *Row
-	existenceFrag := e.Holder.fragment(index, existenceFieldName, viewStandard, shard)
-	if existenceFrag == nil {
+	existenceFrag, err := e.Holder.fragment(index, existenceFieldName, viewStandard, shard)
+	if err != nil {
+		return nil, err
+	} else if existenceFrag == nil {
 		existenceRow = NewRow()
 	} else {
 		tx, finisher, err := qcx.GetTx(Txo{Write: !writable, Index: idx, Fragment: existenceFrag, Shard: shard})
@@ -6058,8 +6114,10 @@ func (e *executor) executeClearRowShard(ctx context.Context, qcx *Qcx, index str
 	// Remove the row from all views.
 	changed := false
 	for _, view := range field.views() {
-		fragment := e.Holder.fragment(index, fieldName, view.name, shard)
-		if fragment == nil {
+		fragment, err := e.Holder.fragment(index, fieldName, view.name, shard)
+		if err != nil {
+			return false, err
+		} else if fragment == nil {
 			continue
 		}
 		cleared, err := fragment.clearRow(tx, rowID)
@@ -6160,8 +6218,10 @@ func (e *executor) executeSetRowShard(ctx context.Context, qcx *Qcx, index strin
 
 	// Set the row on the standard view.
 	changed := false
-	fragment := e.Holder.fragment(index, fieldName, viewStandard, shard)
-	if fragment == nil {
+	fragment, err := e.Holder.fragment(index, fieldName, viewStandard, shard)
+	if err != nil {
+		return false, err
+	} else if fragment == nil {
 		// Since the destination fragment doesn't exist, create one.
 		view, err := field.createViewIfNotExists(viewStandard)
 		if err != nil {
@@ -6420,7 +6480,7 @@ func (e *executor) shardsByNode(nodes []*disco.Node, index string, shards []uint
 	// We use e.Cluster.Nodes() here instead of e.Cluster.noder because we need
 	// the node states in order to ensure that we don't include an unavailable
 	// node in the map of nodes to which we distribute the query.
-	snap := disco.NewClusterSnapshot(disco.NewLocalNoder(e.Cluster.Nodes()), e.Cluster.Hasher, e.Cluster.partitionAssigner, e.Cluster.ReplicaN)
+	snap := e.Cluster.newSnapshotFromNoder(disco.NewLocalNoder(e.Cluster.Nodes()))
 
 loop:
 	for _, shard := range shards {
@@ -6762,9 +6822,35 @@ func (e *executor) mapperLocal(ctx context.Context, shards []uint64, mapFn mapFu
 
 	ch := make(chan mapResponse, len(shards))
 
+	// Reduce results as they arrive.
+	var result interface{}
 	expected := 0
+	receive := func() {
+		resp := <-ch
+		expected--
+		if resp.err != nil && err == nil {
+			err = resp.err
+		}
+		if resp.err == nil && ctx.Err() == nil {
+			// Only useful to do a possibly-expensive
+			// reduce if we don't already know we don't
+			// need it.
+			result = reduceFn(ctx, result, resp.result)
+			if resultErr, ok := result.(error); ok {
+				cancel()
+				err = resultErr
+			}
+		}
+	}
+
 shardLoop:
 	for _, shard := range shards {
+		// With a limit, wait for one of this call's shards to finish
+		// before starting another. Only jobs already sent are waited
+		// on, so this can't wait on work which hasn't been started.
+		for e.maxConcurrentShards > 0 && expected >= e.maxConcurrentShards {
+			receive()
+		}
 		j := job{
 			shard:           shard,
 			mapFn:           mapFn,
@@ -6788,25 +6874,8 @@ shardLoop:
 	// Even if our context is done, or the executor is shutting down,
 	// we still have to wait for responses, because the responders are
 	// going to send them and block waiting for us to receive them.
-
-	// Reduce results
-	var result interface{}
 	for expected > 0 {
-		resp := <-ch
-		expected--
-		if resp.err != nil && err == nil {
-			err = resp.err
-		}
-		if resp.err == nil && ctx.Err() == nil {
-			// Only useful to do a possibly-expensive
-			// reduce if we don't already know we don't
-			// need it.
-			result = reduceFn(ctx, result, resp.result)
-			if resultErr, ok := result.(error); ok {
-				cancel()
-				err = resultErr
-			}
-		}
+		receive()
 	}
 	return result, err
 }
@@ -8751,8 +8820,10 @@ func newGroupByIterator(executor *executor, qcx *Qcx, rowIDs []RowIDs, children
 		if isTimeField {
 			var fragments []*fragment
 			for _, viewName := range views {
-				fragment := holder.fragment(index, fieldName, viewName, shard)
-				if fragment != nil {
+				fragment, err := holder.fragment(index, fieldName, viewName, shard)
+				if err != nil {
+					return nil, err
+				} else if fragment != nil {
 					fragments = append(fragments, fragment)
 				}
 			}
@@ -8766,8 +8837,10 @@ func newGroupByIterator(executor *executor, qcx *Qcx, rowIDs []RowIDs, children
 				return nil, err
 			}
 		} else {
-			frag := holder.fragment(index, fieldName, viewName, shard)
-			if frag == nil { // this means this whole shard doesn't have all it needs to continue
+			frag, err := holder.fragment(index, fieldName, viewName, shard)
+			if err != nil {
+				return nil, err
+			} else if frag == nil { // this means this whole shard doesn't have all it needs to continue
 				return nil, nil
 			}
 
@@ -9139,8 +9212,10 @@ func DeleteRowsWithFlowWithKeys(ctx context.Context, columns *roaring.Bitmap, id
 	var err error // store columns in exits field ToBeDelete row commited
 	holder := idx.Holder()
 	if normalFlow { // normalFlow is the standard path, "not normal" is recoverory
-		existenceFragment = holder.fragment(idx.Name(), existenceFieldName, viewStandard, shard)
-		if existenceFragment == nil {
+		existenceFragment, err = holder.fragment(idx.Name(), existenceFieldName, viewStandard, shard)
+		if err != nil {
+			return false, err
+		} else if existenceFragment == nil {
 			// no exists field
 			return false, errors.New("can't bulk delete without existence field")
 		}
@@ -9185,8 +9260,10 @@ func DeleteRowsWithFlowWithKeys(ctx context.Context, columns *roaring.Bitmap, id
 
 	for _, field := range idx.Fields() {
 		for _, view := range field.views() {
-			frag := view.Fragment(shard)
-			if frag == nil {
+			frag, err := view.Fragment(shard)
+			if err != nil {
+				return false, err
+			} else if frag == nil {
 				continue
 			}
 			c, err := frag.clearRecordsByBitmap(writeTx, columns)
@@ -9234,8 +9311,10 @@ func DeleteRowsWithOutKeysFlow(ctx context.Context, columns *roaring.Bitmap, idx
 	}()
 	for _, field := range idx.Fields() {
 		for _, view := range field.views() {
-			frag := view.Fragment(shard)
-			if frag == nil {
+			frag, err := view.Fragment(shard)
+			if err != nil {
+				return false, err
+			} else if frag == nil {
 				continue
 			}
 			c, err := frag.clearRecordsByBitmap(writeTx, columns)
@@ -9312,10 +9391,13 @@ func (c *NopCommitor) Commit() error {
 }
 
 func deleteKeyTranslation(ctx context.Context, idx *Index, shard uint64, records *roaring.Bitmap) (Commitor, error) {
-	// ShardToShardParition ...
-	paritionID := disco.ShardToShardPartition(idx.name, shard, idx.holder.partitionN)
-
-	return idx.TranslateStore(paritionID).Delete(records)
+	// The shard's columns were allocated by the key-partition with the
+	// shard's partition number, if the index has that many.
+	store := idx.TranslateStore(idx.idPartition(shard * ShardWidth))
+	if store == nil {
+		return &NopCommitor{}, nil
+	}
+	return store.Delete(records)
 }
 
 func (e *executor) executeSort(ctx context.Context, qcx *Qcx, index string, c *pql.Call, shards []uint64, opt *ExecOptions) (*SortedRow, error) {
@@ -9421,8 +9503,10 @@ func (e *executor) executeSortShard(ctx context.Context, qcx *Qcx, index string,
 
 	switch f.Type() {
 	case FieldTypeBool:
-		fragment := e.Holder.fragment(index, f.name, viewStandard, shard)
-		if fragment == nil {
+		fragment, err := e.Holder.fragment(index, f.name, viewStandard, shard)
+		if err != nil {
+			return nil, err
+		} else if fragment == nil {
 			return nil, errors.New("bool fragment not found")
 		}
 		falses, err := fragment.row(tx, falseRowID)
@@ -9475,8 +9559,10 @@ func (e *executor) executeSortShard(ctx context.Context, qcx *Qcx, index string,
 	case FieldTypeDecimal, FieldTypeInt, FieldTypeTimestamp:
 		return f.SortShardRow(tx, shard, filter, sort_desc)
 	case FieldTypeMutex:
-		fragment := e.Holder.fragment(index, f.name, viewStandard, shard)
-		if fragment == nil {
+		fragment, err := e.Holder.fragment(index, f.name, viewStandard, shard)
+		if err != nil {
+			return nil, err
+		} else if fragment == nil {
 			return nil, errors.Errorf("fragment not found for field %s", f.name)
 		}
 		rows, err := fragment.rows(ctx, tx, 0)
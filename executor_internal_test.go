@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"sort"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -596,3 +597,43 @@ func TestExecutor_DeleteRows(t *testing.T) {
 		t.Fatalf("expected delete to not clear bit but it did")
 	}
 }
+
+// Ensure mapperLocal maps no more than the configured number of a call's
+// shards at once, and still reduces every shard.
+func TestExecutor_MaxConcurrentShards(t *testing.T) {
+	e := newExecutor(optExecutorWorkerPoolSize(8), optExecutorMaxConcurrentShards(2))
+	defer e.Close()
+
+	var active, peak int64
+	mapFn := func(ctx context.Context, shard uint64, mopt *mapOptions) (interface{}, error) {
+		n := atomic.AddInt64(&active, 1)
+		defer atomic.AddInt64(&active, -1)
+		for {
+			p := atomic.LoadInt64(&peak)
+			if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return shard, nil
+	}
+	reduceFn := func(ctx context.Context, prev, v interface{}) interface{} {
+		sum, _ := prev.(uint64)
+		return sum + v.(uint64)
+	}
+
+	shards := make([]uint64, 20)
+	var exp uint64
+	for i := range shards {
+		shards[i] = uint64(i)
+		exp += uint64(i)
+	}
+	result, err := e.mapperLocal(context.Background(), shards, mapFn, reduceFn, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if result != exp {
+		t.Fatalf("expected sum %d, got %v", exp, result)
+	} else if peak > 2 {
+		t.Fatalf("expected at most 2 shards at once, got %d", peak)
+	}
+}
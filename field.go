@@ -18,6 +18,7 @@ import (
 	"unicode"
 
 	"github.com/featurebasedb/featurebase/v3/pql"
+	"github.com/featurebasedb/featurebase/v3/rbf"
 	"github.com/featurebasedb/featurebase/v3/roaring"
 	"github.com/featurebasedb/featurebase/v3/testhook"
 	"github.com/featurebasedb/featurebase/v3/tracing"
@@ -116,6 +117,10 @@ type Field struct {
 
 	// track whether we're shutting down
 	closing chan struct{}
+
+	// lastCacheRecalculate is when the holder last recalculated the
+	// field's caches for its CacheRecalculateInterval.
+	lastCacheRecalculate time.Time
 }
 
 // FieldOption is a functional option type for pilosa.fieldOptions.
@@ -377,6 +382,46 @@ func OptFieldTypeBool() FieldOption {
 	}
 }
 
+// OptFieldRunThreshold is a functional option on FieldOptions used to
+// store imported containers with n runs or fewer as run containers, even
+// when an array or bitmap would be smaller. Zero leaves the choice to the
+// usual roaring rules.
+func OptFieldRunThreshold(n int32) FieldOption {
+	return func(fo *FieldOptions) error {
+		if n < 0 || n > rbf.RLEMaxSize {
+			return errors.Errorf("invalid run threshold %d: must be between 0 and %d", n, rbf.RLEMaxSize)
+		}
+		fo.RunThreshold = n
+		return nil
+	}
+}
+
+// OptFieldTombstoneWindow is a functional option on FieldOptions used to
+// suppress sets of a bit within window of the bit being cleared, so a late
+// set racing with a delete can't resurrect the bit. Zero disables it.
+func OptFieldTombstoneWindow(window time.Duration) FieldOption {
+	return func(fo *FieldOptions) error {
+		if window < 0 {
+			return errors.Errorf("invalid tombstone window %s: must not be negative", window)
+		}
+		fo.TombstoneWindow = window
+		return nil
+	}
+}
+
+// OptFieldCacheRecalculateInterval is a functional option on FieldOptions
+// used to recalculate the field's caches every interval, rather than
+// maintaining them on every write. Zero keeps maintaining them on write.
+func OptFieldCacheRecalculateInterval(interval time.Duration) FieldOption {
+	return func(fo *FieldOptions) error {
+		if interval < 0 {
+			return errors.Errorf("invalid cache recalculate interval %s: must not be negative", interval)
+		}
+		fo.CacheRecalculateInterval = interval
+		return nil
+	}
+}
+
 // OptFieldTrackExistence exists mostly to allow the
 // FieldFromFieldOptions/FieldOptionsFromField round-trip to work.
 // If you are actually creating a field, via api.CreateField,
@@ -488,6 +533,72 @@ func (f *Field) LocalAvailableShards() *roaring.Bitmap {
 	return b
 }
 
+// ViewShardCoverage returns, for each of the field's views, a bitmap of the
+// local shards that view has fragments for. A view with no fragments maps
+// to an empty bitmap.
+func (f *Field) ViewShardCoverage() map[string]*roaring.Bitmap {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	coverage := make(map[string]*roaring.Bitmap, len(f.viewMap))
+	for name, view := range f.viewMap {
+		if shards := view.availableShards(); shards != nil {
+			coverage[name] = shards.Clone()
+		} else {
+			coverage[name] = roaring.NewBitmap()
+		}
+	}
+	return coverage
+}
+
+// RecomputeAvailableShards rebuilds the field's local available shards from
+// storage, for use when the tracked shards are lost or inconsistent. Every
+// shard of the index which has data on disk is checked, and a shard is
+// available in a view if that view's fragment for the shard holds any bits.
+// Each view's known shards are replaced, and their union is returned.
+func (f *Field) RecomputeAvailableShards() (*roaring.Bitmap, error) {
+	shards, err := f.holder.txf.GetShardsForIndex(f.idx, "", false)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting shards for index")
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	byView := make(map[string]*roaring.Bitmap, len(f.viewMap))
+	for name := range f.viewMap {
+		byView[name] = roaring.NewSliceBitmap()
+	}
+	for shard := range shards {
+		if err := func() error {
+			tx := f.holder.txf.NewTx(Txo{Write: !writable, Index: f.idx, Shard: shard})
+			defer tx.Rollback()
+			for name, b := range byView {
+				n, err := tx.Count(f.index, f.name, name, shard)
+				if err != nil {
+					return errors.Wrapf(err, "counting view %s shard %d", name, shard)
+				} else if n > 0 {
+					if _, err := b.Add(shard); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}(); err != nil {
+			return nil, err
+		}
+	}
+
+	all := roaring.NewBitmap()
+	for name, view := range f.viewMap {
+		view.mu.Lock()
+		view.setKnownShards(byView[name])
+		view.mu.Unlock()
+		all.UnionInPlace(byView[name])
+	}
+	return all, nil
+}
+
 // AddRemoteAvailableShards merges the set of available shards into the current known set
 // and saves the set to a file.
 func (f *Field) AddRemoteAvailableShards(b *roaring.Bitmap) error {
@@ -886,6 +997,9 @@ func (f *Field) applyOptions(opt FieldOptions) error {
 	default:
 		return errors.New("invalid field type")
 	}
+	f.options.RunThreshold = opt.RunThreshold
+	f.options.TombstoneWindow = opt.TombstoneWindow
+	f.options.CacheRecalculateInterval = opt.CacheRecalculateInterval
 
 	return nil
 }
@@ -1150,6 +1264,19 @@ func (f *Field) views() []*view {
 	return other
 }
 
+// cacheRecalculationDue returns true, and records now as the time of the
+// last recalculation, if the field recalculates its caches periodically and
+// interval has passed since the last one. It's only called by the holder's
+// recalculation goroutine.
+func (f *Field) cacheRecalculationDue(now time.Time) bool {
+	interval := f.Options().CacheRecalculateInterval
+	if interval <= 0 || now.Sub(f.lastCacheRecalculate) < interval {
+		return false
+	}
+	f.lastCacheRecalculate = now
+	return true
+}
+
 // recalculateCaches recalculates caches on every view in the field.
 func (f *Field) recalculateCaches() {
 	for _, view := range f.views() {
@@ -1157,6 +1284,45 @@ func (f *Field) recalculateCaches() {
 	}
 }
 
+// rollupTimeViews merges the given shard of each of the source time quantum
+// views into the coarser target view, so that the target holds the union of
+// the sources. Each source must be finer than the target and fall within
+// its time period, e.g. "standard_20230102" may be rolled up into
+// "standard_202301". Sources with no data in the shard are skipped.
+func (f *Field) rollupTimeViews(ctx context.Context, tx Tx, shard uint64, sources []string, target string) error {
+	targetTime := viewTimePart(target)
+	if targetTime == "" {
+		return errors.Errorf("target view %q is not a time view", target)
+	}
+	for _, name := range sources {
+		if sourceTime := viewTimePart(name); len(sourceTime) <= len(targetTime) || !strings.HasPrefix(sourceTime, targetTime) {
+			return errors.Errorf("view %q can't be rolled up into %q", name, target)
+		}
+		view := f.view(name)
+		if view == nil {
+			return errors.Wrapf(ErrInvalidView, "view %q", name)
+		}
+		frag, err := view.Fragment(shard)
+		if err != nil {
+			return err
+		} else if frag == nil {
+			continue
+		}
+		bm, err := tx.RoaringBitmap(f.index, f.name, name, shard)
+		if err != nil {
+			return errors.Wrapf(err, "reading view %q", name)
+		}
+		var buf bytes.Buffer
+		if _, err := bm.WriteTo(&buf); err != nil {
+			return errors.Wrapf(err, "encoding view %q", name)
+		}
+		if err := frag.importRoaringIntoView(ctx, tx, target, buf.Bytes()); err != nil {
+			return errors.Wrapf(err, "rolling up view %q", name)
+		}
+	}
+	return nil
+}
+
 // createViewIfNotExists returns the named view, creating it if necessary.
 // Additionally, a CreateViewMessage is sent to the cluster.
 func (f *Field) createViewIfNotExists(name string) (*view, error) {
@@ -1256,6 +1422,48 @@ func (f *Field) deleteView(name string) error {
 	return nil
 }
 
+// Truncate deletes all of the field's data on this node while keeping the
+// field, its options, and its views. Every fragment is deleted from storage
+// along with its cache file, and the field's available shards, local and
+// remote, are reset. The field's row key translations are kept, so row keys
+// keep their IDs; columns, and the index's existence field, are unaffected.
+// Other nodes are truncated by API.TruncateField.
+func (f *Field) Truncate() error {
+	// The index's record of which shards have data for the field is only
+	// read when opening the field, but it shouldn't outlive the data. The
+	// index lock is taken before, not while, holding the field's, as in
+	// Index.DeleteField.
+	f.idx.mu.Lock()
+	f.idx.fieldView2shard.removeField(f.name)
+	f.idx.mu.Unlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, view := range f.viewMap {
+		frags, err := view.allFragments()
+		if err != nil {
+			return err
+		}
+		for _, frag := range frags {
+			if err := view.deleteFragment(frag.shard); err != nil {
+				return errors.Wrapf(err, "deleting fragment: %s/%d", view.name, frag.shard)
+			}
+			if err := os.Remove(frag.cachePath()); err != nil && !os.IsNotExist(err) {
+				return errors.Wrapf(err, "removing cache: %s/%d", view.name, frag.shard)
+			}
+		}
+	}
+
+	f.remoteAvailableShardsMu.Lock()
+	f.remoteAvailableShards = roaring.NewBitmap()
+	f.remoteAvailableShardsMu.Unlock()
+	if err := f.saveAvailableShards(); err != nil {
+		return errors.Wrap(err, "saving available shards")
+	}
+	return nil
+}
+
 // Row returns a row of the standard view.
 // It seems this method is only being used by the test
 // package, and the fact that it's only allowed on
@@ -1577,9 +1785,9 @@ func (f *Field) MaxForShard(qcx *Qcx, shard uint64, filter *Row) (ValCount, erro
 		return ValCount{}, nil
 	}
 
-	fragment := view.Fragment(shard)
-	if fragment == nil {
-		return ValCount{}, nil
+	fragment, err := view.Fragment(shard)
+	if err != nil || fragment == nil {
+		return ValCount{}, err
 	}
 
 	max, cnt, err := fragment.max(tx, filter, bsig.BitDepth)
@@ -1607,9 +1815,9 @@ func (f *Field) MinForShard(qcx *Qcx, shard uint64, filter *Row) (ValCount, erro
 		return ValCount{}, nil
 	}
 
-	fragment := view.Fragment(shard)
-	if fragment == nil {
-		return ValCount{}, nil
+	fragment, err := view.Fragment(shard)
+	if err != nil || fragment == nil {
+		return ValCount{}, err
 	}
 
 	min, cnt, err := fragment.min(tx, filter, bsig.BitDepth)
@@ -1741,9 +1949,9 @@ func (f *Field) MarkNotExisting(tx Tx, columnIDs []uint64, shard uint64) error {
 	if v == nil {
 		return nil
 	}
-	frag := v.Fragment(shard)
-	if frag == nil {
-		return nil
+	frag, err := v.Fragment(shard)
+	if err != nil || frag == nil {
+		return err
 	}
 	copyCols := make([]uint64, len(columnIDs))
 	for i := range columnIDs {
@@ -1761,15 +1969,15 @@ func (f *Field) Existing(tx Tx, shard uint64) (*Row, error) {
 	if v == nil {
 		return nil, nil
 	}
-	frag := v.Fragment(shard)
-	if frag == nil {
-		return nil, nil
+	frag, err := v.Fragment(shard)
+	if err != nil || frag == nil {
+		return nil, err
 	}
 	return frag.row(tx, bsiExistsBit)
 }
 
 // Import bulk imports data.
-func (f *Field) Import(qcx *Qcx, rowIDs, columnIDs []uint64, timestamps []int64, shard uint64, options *ImportOptions) (err0 error) {
+func (f *Field) Import(ctx context.Context, qcx *Qcx, rowIDs, columnIDs []uint64, timestamps []int64, shard uint64, options *ImportOptions) (err0 error) {
 	// Determine quantum if timestamps are set.
 	q := f.TimeQuantum()
 	if len(timestamps) > 0 {
@@ -1836,7 +2044,7 @@ func (f *Field) Import(qcx *Qcx, rowIDs, columnIDs []uint64, timestamps []int64,
 				// case and go ahead and import those bits naively
 			}
 		}
-		err1 = frag.bulkImport(tx, rowIDs, columnIDs, options)
+		err1 = frag.bulkImport(ctx, tx, rowIDs, columnIDs, options)
 		return err1
 	}
 
@@ -1918,7 +2126,7 @@ func (f *Field) Import(qcx *Qcx, rowIDs, columnIDs []uint64, timestamps []int64,
 			return errors.Wrap(err, "creating fragment")
 		}
 
-		err1 = frag.bulkImport(tx, data.RowIDs, data.ColumnIDs, options)
+		err1 = frag.bulkImport(ctx, tx, data.RowIDs, data.ColumnIDs, options)
 		if err1 != nil {
 			return err1
 		}
@@ -1939,7 +2147,7 @@ func (f *Field) Import(qcx *Qcx, rowIDs, columnIDs []uint64, timestamps []int64,
 // importFloatValue imports floating point values. In current usage, this
 // should only ever be called with data for a single shard; the API calls
 // around this are splitting it up per shard.
-func (f *Field) importFloatValue(qcx *Qcx, columnIDs []uint64, values []float64, shard uint64, options *ImportOptions) error {
+func (f *Field) importFloatValue(ctx context.Context, qcx *Qcx, columnIDs []uint64, values []float64, shard uint64, options *ImportOptions) error {
 	// convert values to int64 values based on scale
 	ivalues := make([]int64, len(values))
 	bsig := f.bsiGroup(f.name)
@@ -1951,13 +2159,13 @@ func (f *Field) importFloatValue(qcx *Qcx, columnIDs []uint64, values []float64,
 		ivalues[i] = int64(fval * mult)
 	}
 	// then call importValue
-	return f.importValue(qcx, columnIDs, ivalues, shard, options)
+	return f.importValue(ctx, qcx, columnIDs, ivalues, shard, options)
 }
 
 // importTimestampValue imports timestamp values. In current usage, this
 // should only ever be called with data for a single shard; the API calls
 // around this are splitting it up per shard.
-func (f *Field) importTimestampValue(qcx *Qcx, columnIDs []uint64, values []time.Time, shard uint64, options *ImportOptions) error {
+func (f *Field) importTimestampValue(ctx context.Context, qcx *Qcx, columnIDs []uint64, values []time.Time, shard uint64, options *ImportOptions) error {
 	ivalues := make([]int64, len(values))
 	bsig := f.bsiGroup(f.name)
 	if bsig == nil {
@@ -1967,13 +2175,13 @@ func (f *Field) importTimestampValue(qcx *Qcx, columnIDs []uint64, values []time
 	for i, t := range values {
 		ivalues[i] = TimestampToVal(f.options.TimeUnit, t)
 	}
-	return f.importValue(qcx, columnIDs, ivalues, shard, options)
+	return f.importValue(ctx, qcx, columnIDs, ivalues, shard, options)
 }
 
 // importValue bulk imports range-encoded value data. This function should
 // only be called with data for a single shard; the API calls that wrap
 // this handle splitting the data up per-shard.
-func (f *Field) importValue(qcx *Qcx, columnIDs []uint64, values []int64, shard uint64, options *ImportOptions) (err0 error) {
+func (f *Field) importValue(ctx context.Context, qcx *Qcx, columnIDs []uint64, values []int64, shard uint64, options *ImportOptions) (err0 error) {
 	// no data to import
 	if len(columnIDs) == 0 {
 		return nil
@@ -2073,7 +2281,7 @@ func (f *Field) importValue(qcx *Qcx, columnIDs []uint64, values []int64, shard
 	// possibly rollback.
 	defer finisher(&err0)
 
-	return frag.importValue(tx, columnIDs, values, requiredDepth, options.Clear)
+	return frag.importValue(ctx, tx, columnIDs, values, requiredDepth, options.Clear)
 }
 
 func (f *Field) importRoaring(ctx context.Context, tx Tx, data []byte, shard uint64, viewName string, clear bool) error {
@@ -2195,6 +2403,10 @@ type FieldOptions struct {
 	TimeQuantum    TimeQuantum   `json:"timeQuantum,omitempty"`
 	ForeignIndex   string        `json:"foreignIndex"`
 	TTL            time.Duration `json:"ttl,omitempty"`
+	RunThreshold   int32         `json:"runThreshold,omitempty"`
+
+	TombstoneWindow          time.Duration `json:"tombstoneWindow,omitempty"`
+	CacheRecalculateInterval time.Duration `json:"cacheRecalculateInterval,omitempty"`
 }
 
 // newFieldOptions returns a new instance of FieldOptions
@@ -2590,8 +2802,10 @@ func (f *Field) SortShardRow(tx Tx, shard uint64, filter *Row, sort_desc bool) (
 		return nil, errors.New("view is nil")
 	}
 
-	fragment := view.Fragment(shard)
-	if fragment == nil {
+	fragment, err := view.Fragment(shard)
+	if err != nil {
+		return nil, err
+	} else if fragment == nil {
 		return nil, errors.New("fragment is nil")
 	}
 
@@ -31,7 +31,10 @@ func CorruptAMutex(tb testing.TB, field *Field, qcx *Qcx) {
 	if v == nil {
 		tb.Fatalf("creating view failed")
 	}
-	frags := v.allFragments()
+	frags, err := v.allFragments()
+	if err != nil {
+		tb.Fatal(err)
+	}
 	for _, frag := range frags {
 		func() {
 			tx, finisher, err := qcx.GetTx(Txo{Write: true, Index: field.idx, Shard: frag.shard})
@@ -365,6 +368,134 @@ func TestField_RowTime(t *testing.T) {
 
 }
 
+// Ensure daily views can be rolled up into a monthly view.
+func TestField_RollupTimeViews(t *testing.T) {
+	_, idx, f := newTestField(t, OptFieldTypeTime(TimeQuantum("D"), "0"))
+
+	qcx := f.holder.Txf().NewWritableQcx()
+	defer qcx.Abort()
+	testFieldSetBit(t, qcx, f, 1, 1, time.Date(2010, time.January, 5, 12, 0, 0, 0, time.UTC))
+	testFieldSetBit(t, qcx, f, 1, 2, time.Date(2010, time.January, 5, 12, 0, 0, 0, time.UTC))
+	testFieldSetBit(t, qcx, f, 1, 2, time.Date(2010, time.January, 6, 12, 0, 0, 0, time.UTC))
+	testFieldSetBit(t, qcx, f, 1, 3, time.Date(2010, time.January, 6, 12, 0, 0, 0, time.UTC))
+	testFieldSetBit(t, qcx, f, 2, 4, time.Date(2010, time.January, 6, 12, 0, 0, 0, time.UTC))
+	PanicOn(qcx.Finish())
+
+	tx := f.holder.txf.NewTx(Txo{Write: writable, Index: idx, Shard: 0})
+	defer tx.Rollback()
+
+	// The source view exists, so only the period check can reject it.
+	if _, err := f.createViewIfNotExists("standard_201001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.rollupTimeViews(context.Background(), tx, 0, []string{"standard_201001"}, "standard_2011"); err == nil || !strings.Contains(err.Error(), "can't be rolled up") {
+		t.Fatalf("expected error rolling up a view outside the target period, got %v", err)
+	}
+	if err := f.rollupTimeViews(context.Background(), tx, 0, []string{"standard_2010"}, "standard_201001"); err == nil || !strings.Contains(err.Error(), "can't be rolled up") {
+		t.Fatalf("expected error rolling up a coarser view, got %v", err)
+	}
+	if err := f.rollupTimeViews(context.Background(), tx, 0, []string{"standard_20100105", "standard_20100106"}, "standard_201001"); err != nil {
+		t.Fatal(err)
+	}
+
+	month := f.view("standard_201001")
+	if month == nil {
+		t.Fatal("expected monthly view to be created")
+	}
+	frag, err := month.Fragment(0)
+	if err != nil {
+		t.Fatal(err)
+	} else if frag == nil {
+		t.Fatal("expected monthly fragment to be created")
+	}
+	for rowID, exp := range map[uint64][]uint64{1: {1, 2, 3}, 2: {4}} {
+		if got := frag.mustRow(tx, rowID).Columns(); !reflect.DeepEqual(got, exp) {
+			t.Fatalf("row %d: expected %v, got %v", rowID, exp, got)
+		}
+	}
+	PanicOn(tx.Commit())
+}
+
+// Ensure each view's shard coverage is reported separately.
+func TestField_ViewShardCoverage(t *testing.T) {
+	_, _, f := newTestField(t, OptFieldTypeTime(TimeQuantum("D"), "0"))
+
+	qcx := f.holder.Txf().NewWritableQcx()
+	defer qcx.Abort()
+	testFieldSetBit(t, qcx, f, 1, 1, time.Date(2010, time.January, 5, 12, 0, 0, 0, time.UTC))
+	testFieldSetBit(t, qcx, f, 1, 3*ShardWidth+1, time.Date(2010, time.January, 6, 12, 0, 0, 0, time.UTC))
+	PanicOn(qcx.Finish())
+
+	if _, err := f.createViewIfNotExists("standard_20100107"); err != nil {
+		t.Fatal(err)
+	}
+
+	coverage := f.ViewShardCoverage()
+	got := make(map[string][]uint64, len(coverage))
+	for name, shards := range coverage {
+		got[name] = shards.Slice()
+	}
+	if exp := map[string][]uint64{
+		"standard":          {0, 3},
+		"standard_20100105": {0},
+		"standard_20100106": {3},
+		"standard_20100107": nil,
+	}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected coverage %v, got %v", exp, got)
+	}
+
+	// The bitmaps are copies, so changing them doesn't change the views.
+	coverage["standard"].Add(9)
+	if got := f.view("standard").availableShards().Slice(); !reflect.DeepEqual(got, []uint64{0, 3}) {
+		t.Fatalf("expected view shards [0 3], got %v", got)
+	}
+}
+
+// Ensure a field's available shards can be rebuilt from storage.
+func TestField_RecomputeAvailableShards(t *testing.T) {
+	_, idx, f := newTestField(t, OptFieldTypeTime(TimeQuantum("D"), "0", true))
+
+	qcx := f.holder.Txf().NewWritableQcx()
+	defer qcx.Abort()
+	testFieldSetBit(t, qcx, f, 1, 1, time.Date(2010, time.January, 5, 12, 0, 0, 0, time.UTC))
+	testFieldSetBit(t, qcx, f, 1, 3*ShardWidth+1, time.Date(2010, time.January, 6, 12, 0, 0, 0, time.UTC))
+	PanicOn(qcx.Finish())
+
+	// Creating an empty fragment tracks its shard, but since the shard
+	// has no data it isn't really available.
+	if _, err := f.view("standard_20100105").CreateFragmentIfNotExists(5); err != nil {
+		t.Fatal(err)
+	}
+	tx := f.holder.txf.NewTx(Txo{Write: writable, Index: idx, Shard: 5})
+	PanicOn(tx.Commit())
+	if got := f.LocalAvailableShards().Slice(); !reflect.DeepEqual(got, []uint64{0, 3, 5}) {
+		t.Fatalf("expected tracked shards [0 3 5], got %v", got)
+	}
+
+	// Lose the tracked shards.
+	for _, view := range f.views() {
+		view.mu.Lock()
+		view.setKnownShards(roaring.NewSliceBitmap())
+		view.mu.Unlock()
+	}
+	if n := f.LocalAvailableShards().Count(); n != 0 {
+		t.Fatalf("expected no tracked shards, got %d", n)
+	}
+
+	exp := []uint64{0, 3}
+	shards, err := f.RecomputeAvailableShards()
+	if err != nil {
+		t.Fatal(err)
+	} else if got := shards.Slice(); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected recomputed shards %v, got %v", exp, got)
+	} else if got := f.LocalAvailableShards().Slice(); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected tracked shards %v after recompute, got %v", exp, got)
+	}
+	if got := f.view("standard_20100106").availableShards().Slice(); !reflect.DeepEqual(got, []uint64{3}) {
+		t.Fatalf("expected view shards [3], got %v", got)
+	}
+}
+
 func TestField_PersistAvailableShards(t *testing.T) {
 	availableShardFileFlushDuration.Set(200 * time.Millisecond) //shorten the default time to force a file write
 	_, _, f := newTestField(t)
@@ -485,7 +616,7 @@ func TestBSIGroup_importValue(t *testing.T) {
 			[]uint64{100},
 		},
 	} {
-		if err := f.importValue(qcx, tt.columnIDs, tt.values, 0, options); err != nil {
+		if err := f.importValue(context.Background(), qcx, tt.columnIDs, tt.values, 0, options); err != nil {
 			t.Fatalf("test %d, importing values: %s", i, err.Error())
 		}
 		PanicOn(qcx.Finish())
@@ -500,13 +631,54 @@ func TestBSIGroup_importValue(t *testing.T) {
 	} // loop
 }
 
+// Ensure importing values wider than a field's bit depth expands the bit
+// depth to fit the batch, so callers don't have to size it first.
+func TestBSIGroup_importValueExpandsBitDepth(t *testing.T) {
+	_, _, f := newTestField(t, OptFieldTypeInt(-1<<20, 1<<20))
+	bsig := f.bsiGroup(f.name)
+
+	qcx := f.idx.holder.txf.NewQcx()
+	defer qcx.Abort()
+	if err := f.importValue(context.Background(), qcx, []uint64{1}, []int64{200}, 0, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	} else if bsig.BitDepth != 8 {
+		t.Fatalf("expected bit depth 8, got %d", bsig.BitDepth)
+	}
+
+	// An empty batch is a no-op.
+	if err := f.importValue(context.Background(), qcx, nil, nil, 0, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The widest value is negative, and needs 20 bits.
+	columnIDs := []uint64{2, 3, 4, 5}
+	values := []int64{-(1<<19 + 3), 5, 1 << 18, -7}
+	if err := f.importValue(context.Background(), qcx, columnIDs, values, 0, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	PanicOn(qcx.Finish())
+	qcx.Reset()
+	if bsig.BitDepth != 20 || f.Options().BitDepth != 20 {
+		t.Fatalf("expected bit depth 20, got %d (options %d)", bsig.BitDepth, f.Options().BitDepth)
+	}
+
+	for i, columnID := range append([]uint64{1}, columnIDs...) {
+		exp := append([]int64{200}, values...)[i]
+		if value, exists, err := f.Value(qcx, columnID); err != nil {
+			t.Fatal(err)
+		} else if !exists || value != exp {
+			t.Fatalf("column %d: expected %d, got %d (exists=%v)", columnID, exp, value, exists)
+		}
+	}
+}
+
 // benchmarkImportValues is a helper function to explore, very roughly, the cost
 // of setting values using the special setter used for imports.
 func benchmarkFieldImportValues(b *testing.B, qcx *Qcx, bitDepth uint64, f *Field, cfunc func(uint64) uint64) {
 	batches := makeBenchmarkImportValueData(b, bitDepth, cfunc)
 	for _, req := range batches {
 		// NOTE: We assume everything's in Shard 0 for now.
-		err := f.importValue(qcx, req.ColumnIDs, req.Values, 0, &ImportOptions{})
+		err := f.importValue(context.Background(), qcx, req.ColumnIDs, req.Values, 0, &ImportOptions{})
 		if err != nil {
 			b.Fatalf("error importing values: %s", err)
 		}
@@ -581,7 +753,7 @@ func TestIntField_MinMaxForShard(t *testing.T) {
 		},
 	} {
 		t.Run(test.name+strconv.Itoa(i), func(t *testing.T) {
-			if err := f.importValue(qcx, test.columnIDs, test.values, 0, options); err != nil {
+			if err := f.importValue(context.Background(), qcx, test.columnIDs, test.values, 0, options); err != nil {
 				t.Fatalf("test %d, importing values: %s", i, err.Error())
 			}
 			PanicOn(qcx.Finish())
@@ -737,7 +909,7 @@ func TestDecimalField_MinMaxForShard(t *testing.T) {
 		t.Run(test.name+strconv.Itoa(i), func(t *testing.T) {
 			qcx := f.idx.holder.txf.NewQcx()
 
-			err := f.importFloatValue(qcx, test.columnIDs, test.values, 0, options)
+			err := f.importFloatValue(context.Background(), qcx, test.columnIDs, test.values, 0, options)
 			if err != nil {
 				qcx.Abort()
 				t.Fatalf("test %d, importing values: %s", i, err.Error())
@@ -799,7 +971,7 @@ func TestBSIGroup_TxReopenDB(t *testing.T) {
 			[]uint64{100},
 		},
 	} {
-		if err := f.importValue(qcx, tt.columnIDs, tt.values, 0, options); err != nil {
+		if err := f.importValue(context.Background(), qcx, tt.columnIDs, tt.values, 0, options); err != nil {
 			t.Fatalf("test %d, importing values: %s", i, err.Error())
 		}
 		PanicOn(qcx.Finish())
@@ -944,3 +1116,70 @@ func TestFieldViewsByTimeRange(t *testing.T) {
 		t.Logf("views: %v", views)
 	}
 }
+
+// Ensure truncating a field deletes its data but keeps the field and its
+// options, and that it can be imported into again.
+func TestField_Truncate(t *testing.T) {
+	h, _, f := newTestField(t, OptFieldTypeSet(CacheTypeRanked, 100))
+
+	importBits := func(rowID uint64, columnIDs ...uint64) {
+		t.Helper()
+		qcx := h.Txf().NewWritableQcx()
+		defer qcx.Abort()
+		rowIDs := make([]uint64, len(columnIDs))
+		for i := range rowIDs {
+			rowIDs[i] = rowID
+		}
+		for i, columnID := range columnIDs {
+			if err := f.Import(context.Background(), qcx, rowIDs[i:i+1], []uint64{columnID}, nil, columnID/ShardWidth, &ImportOptions{}); err != nil {
+				t.Fatalf("importing: %v", err)
+			}
+		}
+		if err := qcx.Finish(); err != nil {
+			t.Fatalf("finishing qcx: %v", err)
+		}
+	}
+	rowColumns := func(rowID uint64) []uint64 {
+		t.Helper()
+		qcx := h.Txf().NewQcx()
+		defer qcx.Abort()
+		r, err := f.Row(qcx, rowID)
+		if err != nil {
+			t.Fatalf("getting row: %v", err)
+		}
+		return r.Columns()
+	}
+
+	importBits(1, 1, 2, 2*ShardWidth+3)
+	if err := f.AddRemoteAvailableShards(roaring.NewBitmap(5)); err != nil {
+		t.Fatalf("adding remote shards: %v", err)
+	}
+	if got := f.AvailableShards(false).Slice(); !reflect.DeepEqual(got, []uint64{0, 2, 5}) {
+		t.Fatalf("expected shards [0 2 5] before truncating, got %v", got)
+	}
+
+	if err := f.Truncate(); err != nil {
+		t.Fatalf("truncating: %v", err)
+	}
+	if h.Field("i", "f") != f {
+		t.Fatal("expected field to still exist")
+	} else if opts := f.Options(); opts.CacheType != CacheTypeRanked || opts.CacheSize != 100 {
+		t.Fatalf("expected options to be kept, got %s/%d", opts.CacheType, opts.CacheSize)
+	} else if f.view(viewStandard) == nil {
+		t.Fatal("expected standard view to be kept")
+	}
+	if got := f.AvailableShards(false).Count(); got != 0 {
+		t.Fatalf("expected no shards after truncating, got %d", got)
+	}
+	if got := rowColumns(1); len(got) != 0 {
+		t.Fatalf("expected no bits after truncating, got %v", got)
+	}
+
+	importBits(1, 4, 2*ShardWidth+5)
+	if got, exp := rowColumns(1), []uint64{4, 2*ShardWidth + 5}; !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v after re-importing, got %v", exp, got)
+	}
+	if got := f.AvailableShards(false).Slice(); !reflect.DeepEqual(got, []uint64{0, 2}) {
+		t.Fatalf("expected shards [0 2] after re-importing, got %v", got)
+	}
+}
@@ -10,13 +10,16 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"math/big"
 	"math/bits"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/featurebasedb/featurebase/v3/logger"
@@ -110,6 +113,29 @@ type fragment struct {
 
 	CacheSize uint32
 
+	// cacheStale is set when an import skips cache maintenance (see
+	// ImportOptions.SkipCacheUpdate). While it's set, imports leave the
+	// cache alone, and RecalculateCache rebuilds it from storage. It's
+	// saved with the cache, so it survives reopening the fragment.
+	cacheStale bool
+
+	// cacheRecalculateInterval, if nonzero, is how often the field's caches
+	// are recalculated by the holder. Writes then mark the cache stale
+	// rather than maintaining it. It's set from the field's
+	// CacheRecalculateInterval option.
+	cacheRecalculateInterval time.Duration
+
+	// runThreshold, if nonzero, is the number of runs at or below which
+	// imported containers are stored as run containers. It's set from the
+	// field's RunThreshold option.
+	runThreshold int32
+
+	// tombstones, if non-nil, records bits recently cleared by clearBit or
+	// a clearing bulkImport, and suppresses sets of them by setBit or
+	// bulkImport until they expire. It's set from the field's
+	// TombstoneWindow option.
+	tombstones *tombstones
+
 	// Cached checksums for each block.
 	checksums map[int][]byte
 
@@ -119,6 +145,35 @@ type fragment struct {
 	// mutexVector is used for mutex field types. It's checked for an
 	// existing value (to clear) prior to setting a new value.
 	mutexVector vector
+
+	// writeBuffer, if non-nil, coalesces setBit/clearBit calls. See
+	// enableWriteBuffer.
+	writeBuffer *fragmentWriteBuffer
+
+	// sizeBound caches the size estimate checked against the maximum
+	// fragment size by imports.
+	sizeBound fragmentSizeBound
+
+	// OnRowChanged, if non-nil, is called with the ID of each row changed
+	// by setBit, clearBit, setRow, clearRow, or an import, so that derived
+	// results can be invalidated. It's called once the mutation's Tx has
+	// been committed, outside the fragment's mutex, and once per row for all
+	// of the Tx's mutations; it isn't called if the Tx is rolled back. It
+	// may be called for a row that an import touched without changing. It
+	// must be set before the fragment is used.
+	OnRowChanged func(rowID uint64)
+	rowChanges   fragmentRowChanges
+
+	// opened is set, atomically, once Open succeeds. When the holder opens
+	// fragments lazily (HolderConfig.LazyFragmentOpen), ensureOpen opens
+	// the fragment on its first access, holding openMu so that concurrent
+	// accesses open it only once.
+	opened uint32
+	openMu sync.Mutex
+
+	// pinned is set, atomically, while the fragment is pinned by
+	// Holder.PinShards.
+	pinned uint32
 }
 
 // newFragment returns a new instance of fragment.
@@ -146,6 +201,8 @@ func newFragment(holder *Holder, idx *Index, fld *Field, vw *view, shard uint64)
 // cachePath returns the path to the fragment's cache data.
 func (f *fragment) cachePath() string { return f.path() + cacheExt }
 
+// bitDepth returns the number of magnitude bits used by the fragment's BSI
+// data, in a new read transaction.
 func (f *fragment) bitDepth() (uint64, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
@@ -154,7 +211,20 @@ func (f *fragment) bitDepth() (uint64, error) {
 		return 0, errors.Wrapf(err, "beginning new tx(false, %s, %d)", f.index(), f.shard)
 	}
 	defer tx.Rollback()
+	return f.unprotectedBitDepth(tx)
+}
+
+// bitDepthTx is like bitDepth, but reads through an existing transaction.
+func (f *fragment) bitDepthTx(tx Tx) (uint64, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.unprotectedBitDepth(tx)
+}
 
+// unprotectedBitDepth returns the number of magnitude bits used by the
+// fragment's BSI data: the highest set bit-slice row, less the exists and
+// sign rows. An empty fragment has a bit depth of 0.
+func (f *fragment) unprotectedBitDepth(tx Tx) (uint64, error) {
 	maxRowID, _, err := f.maxRow(tx, nil)
 	if err != nil {
 		return 0, errors.Wrapf(err, "getting fragment max row id")
@@ -187,16 +257,40 @@ func (f *fragment) Open() error {
 
 		// Clear checksums.
 		f.checksums = make(map[int][]byte)
+
+		// The size bound is recomputed by the first import.
+		f.sizeBound.invalidate()
 		return nil
 	}(); err != nil {
 		f.close()
 		return err
 	}
 
+	atomic.StoreUint32(&f.opened, 1)
 	_ = testhook.Opened(f.holder.Auditor, f, nil)
 	return nil
 }
 
+// isOpen returns true if the fragment has been opened.
+func (f *fragment) isOpen() bool {
+	return atomic.LoadUint32(&f.opened) == 1
+}
+
+// ensureOpen opens the fragment if it hasn't been opened. Concurrent calls
+// open it only once. If opening fails, the error is returned, and the next
+// call tries again.
+func (f *fragment) ensureOpen() error {
+	if f.isOpen() {
+		return nil
+	}
+	f.openMu.Lock()
+	defer f.openMu.Unlock()
+	if f.isOpen() {
+		return nil
+	}
+	return f.Open()
+}
+
 // openCache initializes the cache from row ids persisted to disk.
 func (f *fragment) openCache() error {
 	// Determine cache type from field name.
@@ -228,6 +322,10 @@ func (f *fragment) openCache() error {
 		return nil
 	}
 
+	// A cache flushed while stale waits to be rebuilt, as it would have
+	// been had the fragment stayed open.
+	f.cacheStale = pb.Stale
+
 	tx := f.idx.holder.txf.NewTx(Txo{Write: !writable, Index: f.idx, Fragment: f, Shard: f.shard})
 	defer tx.Rollback()
 
@@ -281,6 +379,9 @@ func (f *fragment) mutexCheck(tx Tx, details bool, limit int) (map[uint64][]uint
 
 // row returns a row by ID.
 func (f *fragment) row(tx Tx, rowID uint64) (*Row, error) {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return nil, err
+	}
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	return f.unprotectedRow(tx, rowID)
@@ -332,12 +433,50 @@ func (f *fragment) rowFromStorage(tx Tx, rowID uint64) (*Row, error) {
 	return row, nil
 }
 
+// rowBytes returns a row's columns, relative to the start of the fragment's
+// shard, as a serialized roaring bitmap. An empty row gives an empty
+// bitmap's serialization.
+func (f *fragment) rowBytes(tx Tx, rowID uint64) ([]byte, error) {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return nil, err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	data, err := tx.OffsetRange(f.index(), f.field(), f.view(), f.shard, 0, rowID*ShardWidth, (rowID+1)*ShardWidth)
+	if err != nil {
+		return nil, errors.Wrap(err, "OffsetRange")
+	}
+	var buf bytes.Buffer
+	if _, err := data.WriteTo(&buf); err != nil {
+		return nil, errors.Wrap(err, "writing row")
+	}
+	return buf.Bytes(), nil
+}
+
 // setBit sets a bit for a given column & row within the fragment.
 // This updates both the on-disk storage and the in-cache bitmap.
 func (f *fragment) setBit(tx Tx, rowID, columnID uint64) (changed bool, err error) {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
 	f.mu.Lock() // controls access to the file.
 	defer f.mu.Unlock()
 
+	if f.tombstones != nil {
+		pos, err := f.pos(rowID, columnID)
+		if err != nil {
+			return false, errors.Wrap(err, "getting bit pos")
+		} else if f.tombstones.has(pos) {
+			return false, nil
+		}
+	}
+
+	if f.writeBuffer != nil && f.mutexVector == nil {
+		return f.bufferedWrite(tx, rowID, columnID, true)
+	}
+
 	doSetFunc := func() error {
 		// handle mutux field type
 		if f.mutexVector != nil {
@@ -389,15 +528,23 @@ func (f *fragment) unprotectedSetBit(tx Tx, rowID, columnID uint64) (changed boo
 
 	// Invalidate block checksum.
 	delete(f.checksums, int(rowID/HashBlockSize))
+	f.recordRowChanged(tx, rowID)
+	// A single bit grows the fragment by no more than a new container.
+	f.sizeBound.grow(containerSizeBound(1))
 
-	// If we're using a cache, update it. Otherwise skip the
-	// possibly-expensive count operation.
+	// If we're using a cache, update it, unless it's recalculated
+	// periodically instead. Otherwise skip the possibly-expensive count
+	// operation.
 	if f.CacheType != CacheTypeNone {
-		n, err := tx.CountRange(f.index(), f.field(), f.view(), f.shard, rowID*ShardWidth, (rowID+1)*ShardWidth)
-		if err != nil {
+		if deferred, err := f.unprotectedDeferCacheUpdate(); err != nil {
 			return false, err
+		} else if !deferred {
+			n, err := tx.CountRange(f.index(), f.field(), f.view(), f.shard, rowID*ShardWidth, (rowID+1)*ShardWidth)
+			if err != nil {
+				return false, err
+			}
+			f.cache.Add(rowID, n)
 		}
-		f.cache.Add(rowID, n)
 	}
 
 	CounterSetBit.Inc()
@@ -408,9 +555,24 @@ func (f *fragment) unprotectedSetBit(tx Tx, rowID, columnID uint64) (changed boo
 // clearBit clears a bit for a given column & row within the fragment.
 // This updates both the on-disk storage and the in-cache bitmap.
 func (f *fragment) clearBit(tx Tx, rowID, columnID uint64) (changed bool, err error) {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	return f.unprotectedClearBit(tx, rowID, columnID)
+	if f.writeBuffer != nil && f.mutexVector == nil {
+		changed, err = f.bufferedWrite(tx, rowID, columnID, false)
+	} else {
+		changed, err = f.unprotectedClearBit(tx, rowID, columnID)
+	}
+	// The tombstone is recorded even if the bit wasn't set, since the set
+	// it guards against may not have arrived yet.
+	if err == nil && f.tombstones != nil {
+		f.tombstones.add(pos(rowID, columnID))
+	}
+	return changed, err
 }
 
 // unprotectedClearBit TODO should be replaced by an invocation of
@@ -437,15 +599,21 @@ func (f *fragment) unprotectedClearBit(tx Tx, rowID, columnID uint64) (changed b
 
 	// Invalidate block checksum.
 	delete(f.checksums, int(rowID/HashBlockSize))
+	f.recordRowChanged(tx, rowID)
 
-	// If we're using a cache, update it. Otherwise skip the
-	// possibly-expensive count operation.
+	// If we're using a cache, update it, unless it's recalculated
+	// periodically instead. Otherwise skip the possibly-expensive count
+	// operation.
 	if f.CacheType != CacheTypeNone {
-		n, err := tx.CountRange(f.index(), f.field(), f.view(), f.shard, rowID*ShardWidth, (rowID+1)*ShardWidth)
-		if err != nil {
+		if deferred, err := f.unprotectedDeferCacheUpdate(); err != nil {
 			return changed, err
+		} else if !deferred {
+			n, err := tx.CountRange(f.index(), f.field(), f.view(), f.shard, rowID*ShardWidth, (rowID+1)*ShardWidth)
+			if err != nil {
+				return changed, err
+			}
+			f.cache.Add(rowID, n)
 		}
-		f.cache.Add(rowID, n)
 	}
 
 	CounterClearBit.Inc()
@@ -456,9 +624,20 @@ func (f *fragment) unprotectedClearBit(tx Tx, rowID, columnID uint64) (changed b
 // setRow replaces an existing row (specified by rowID) with the given
 // Row. This updates both the on-disk storage and the in-cache bitmap.
 func (f *fragment) setRow(tx Tx, row *Row, rowID uint64) (changed bool, err error) {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	return f.unprotectedSetRow(tx, row, rowID)
+	if err := f.unprotectedFlushWriteBuffer(tx); err != nil {
+		return false, err
+	}
+	if changed, err = f.unprotectedSetRow(tx, row, rowID); changed {
+		f.recordRowChanged(tx, rowID)
+	}
+	return changed, err
 }
 
 func (f *fragment) unprotectedSetRow(tx Tx, row *Row, rowID uint64) (changed bool, err error) {
@@ -467,6 +646,7 @@ func (f *fragment) unprotectedSetRow(tx Tx, row *Row, rowID uint64) (changed boo
 	// of this is worth having `changed`.
 	// For now we will assume changed is always true.
 	changed = true
+	f.sizeBound.invalidate()
 
 	// First container of the row in storage.
 	headContainerKey := rowID << shardVsContainerExponent
@@ -509,12 +689,82 @@ func (f *fragment) unprotectedSetRow(tx Tx, row *Row, rowID uint64) (changed boo
 	return changed, nil
 }
 
+// setRowFromBitmap replaces the columns of a row with the positions set in
+// bm, which are relative to the start of the shard.
+func (f *fragment) setRowFromBitmap(tx Tx, bm *roaring.Bitmap, rowID uint64) (changed bool, err error) {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.unprotectedFlushWriteBuffer(tx); err != nil {
+		return false, err
+	}
+	if changed, err = f.unprotectedSetRowFromBitmap(tx, bm, rowID); changed {
+		f.recordRowChanged(tx, rowID)
+	}
+	return changed, err
+}
+
+// unprotectedSetRowFromBitmap is like unprotectedSetRow, but takes the row's
+// columns from the shard-relative positions of bm rather than from a Row,
+// and only writes the containers which differ, so changed is exact.
+func (f *fragment) unprotectedSetRowFromBitmap(tx Tx, bm *roaring.Bitmap, rowID uint64) (changed bool, err error) {
+	if bm.Any() && bm.Max() >= ShardWidth {
+		return false, errors.Errorf("position %d out of bounds for shard width %d", bm.Max(), ShardWidth)
+	}
+	f.sizeBound.invalidate()
+
+	headContainerKey := rowID << shardVsContainerExponent
+	for i := uint64(0); i < (1 << shardVsContainerExponent); i++ {
+		existing, err := tx.Container(f.index(), f.field(), f.view(), f.shard, headContainerKey+i)
+		if err != nil {
+			return changed, err
+		}
+		c := bm.Containers.Get(i)
+		n := c.N()
+		if n == existing.N() && (n == 0 || roaring.IntersectionCount(c, existing) == n) {
+			continue
+		}
+		changed = true
+		if n == 0 {
+			err = tx.RemoveContainer(f.index(), f.field(), f.view(), f.shard, headContainerKey+i)
+		} else {
+			err = tx.PutContainer(f.index(), f.field(), f.view(), f.shard, headContainerKey+i, c)
+		}
+		if err != nil {
+			return changed, err
+		}
+	}
+
+	if changed {
+		if f.CacheType != CacheTypeNone {
+			f.cache.BulkAdd(rowID, bm.Count())
+		}
+		CounterSetRow.Inc()
+	}
+	return changed, nil
+}
+
 // clearRow clears a row for a given rowID within the fragment.
 // This updates both the on-disk storage and the in-cache bitmap.
 func (f *fragment) clearRow(tx Tx, rowID uint64) (changed bool, err error) {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	return f.unprotectedClearRow(tx, rowID)
+	if err := f.unprotectedFlushWriteBuffer(tx); err != nil {
+		return false, err
+	}
+	if changed, err = f.unprotectedClearRow(tx, rowID); changed {
+		f.recordRowChanged(tx, rowID)
+	}
+	return changed, err
 }
 
 func (f *fragment) unprotectedClearRow(tx Tx, rowID uint64) (changed bool, err error) {
@@ -545,133 +795,569 @@ func (f *fragment) unprotectedClearRow(tx Tx, rowID uint64) (changed bool, err e
 	return changed, nil
 }
 
-// clearBlock clears all rows for a given block.
-// This updates both the on-disk storage and the in-cache bitmap.
+// clearBlock clears all rows for a given block, which is the HashBlockSize
+// rows starting at block*HashBlockSize, so that the block can be repaired
+// from another replica without clearing the rest of the fragment. A block
+// with no bits set is left unchanged.
+// This updates both the on-disk storage and the in-cache bitmap, and
+// invalidates the block's checksum.
 func (f *fragment) clearBlock(tx Tx, block int) (changed bool, err error) {
+	if block < 0 {
+		return false, errors.Errorf("invalid block: %d", block)
+	}
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	if err := f.unprotectedFlushWriteBuffer(tx); err != nil {
+		return false, err
+	}
 
-	firstRow := uint64(block * HashBlockSize)
-	err = func() error {
-		var rowChanged bool
-		for rowID := uint64(firstRow); rowID < firstRow+HashBlockSize; rowID++ {
-			if chang, err := f.unprotectedClearRow(tx, rowID); err != nil {
-				return errors.Wrapf(err, "clearing row: %d", rowID)
-			} else if chang {
-				rowChanged = true
-			}
+	firstRow := uint64(block) * HashBlockSize
+	for rowID := firstRow; rowID < firstRow+HashBlockSize; rowID++ {
+		rowChanged, err := f.unprotectedClearRow(tx, rowID)
+		if err != nil {
+			return changed, errors.Wrapf(err, "clearing row: %d", rowID)
+		} else if rowChanged {
+			changed = true
+			f.recordRowChanged(tx, rowID)
 		}
-		changed = rowChanged
-		return nil
-	}()
-	return changed, err
+	}
+	if changed {
+		delete(f.checksums, block)
+	}
+	return changed, nil
 }
 
+// bit returns whether a bit is set, taking writes buffered under tx into
+// account. It doesn't lock; callers must hold the fragment's mutex while
+// write buffering is enabled.
 func (f *fragment) bit(tx Tx, rowID, columnID uint64) (bool, error) {
 	pos, err := f.pos(rowID, columnID)
 	if err != nil {
 		return false, err
 	}
+	if set, ok := f.writeBuffer.pendingBit(tx, pos); ok {
+		return set, nil
+	}
 	return tx.Contains(f.index(), f.field(), f.view(), f.shard, pos)
 }
 
-// value uses a column of bits to read a multi-bit value.
-func (f *fragment) value(tx Tx, columnID uint64, bitDepth uint64) (value int64, exists bool, err error) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+// rowContains returns true if columnID is set in rowID, reading only the
+// container holding that bit. Unlike bit, it takes the fragment's lock, and
+// a column outside the fragment's shard isn't an error; it simply isn't in
+// any of this fragment's rows.
+func (f *fragment) rowContains(tx Tx, rowID, columnID uint64) (bool, error) {
+	if columnID/ShardWidth != f.shard {
+		return false, nil
+	}
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return false, err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return tx.Contains(f.index(), f.field(), f.view(), f.shard, pos(rowID, columnID))
+}
 
-	// If existence bit is unset then ignore remaining bits.
-	if v, err := f.bit(tx, bsiExistsBit, columnID); err != nil {
-		return 0, false, errors.Wrap(err, "getting existence bit")
-	} else if !v {
-		return 0, false, nil
+// symmetricDifferenceCount returns the number of columns set in exactly one
+// of rows a and b, |A XOR B|, by walking the rows' containers side by side
+// rather than materializing either row. With the rows' counts, it gives
+// distance metrics such as Hamming and Jaccard distance.
+func (f *fragment) symmetricDifferenceCount(tx Tx, a, b uint64) (uint64, error) {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return 0, err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if a == b {
+		return 0, nil
 	}
 
-	// Compute other bits into a value.
-	for i := uint64(0); i < bitDepth; i++ {
-		if v, err := f.bit(tx, uint64(bsiOffsetBit+i), columnID); err != nil {
-			return 0, false, errors.Wrapf(err, "getting value bit %d", i)
-		} else if v {
-			value |= (1 << i)
+	ia, err := f.newRowContainerIterator(tx, a)
+	if err != nil {
+		return 0, err
+	}
+	defer ia.close()
+	ib, err := f.newRowContainerIterator(tx, b)
+	if err != nil {
+		return 0, err
+	}
+	defer ib.close()
+
+	var n uint64
+	for !ia.done || !ib.done {
+		switch {
+		case ib.done || (!ia.done && ia.key < ib.key):
+			n += uint64(ia.c.N())
+			ia.next()
+		case ia.done || ib.key < ia.key:
+			n += uint64(ib.c.N())
+			ib.next()
+		default:
+			n += uint64(ia.c.N() + ib.c.N() - 2*roaring.IntersectionCount(ia.c, ib.c))
+			ia.next()
+			ib.next()
 		}
 	}
+	return n, nil
+}
 
-	// Negate if sign bit set.
-	if v, err := f.bit(tx, bsiSignBit, columnID); err != nil {
-		return 0, false, errors.Wrap(err, "getting sign bit")
-	} else if v {
-		value = -value
+// jaccard returns the Jaccard similarity of rows a and b, |A∩B| / |A∪B|,
+// counting the intersection and union in one pass over the rows'
+// containers. If both rows are empty it returns 0 rather than NaN, so any
+// row is 1 with itself unless it's empty.
+func (f *fragment) jaccard(tx Tx, a, b uint64) (float64, error) {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return 0, err
 	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 
-	return value, true, nil
+	ia, err := f.newRowContainerIterator(tx, a)
+	if err != nil {
+		return 0, err
+	}
+	defer ia.close()
+	ib, err := f.newRowContainerIterator(tx, b)
+	if err != nil {
+		return 0, err
+	}
+	defer ib.close()
+
+	var intersection, union uint64
+	for !ia.done || !ib.done {
+		switch {
+		case ib.done || (!ia.done && ia.key < ib.key):
+			union += uint64(ia.c.N())
+			ia.next()
+		case ia.done || ib.key < ia.key:
+			union += uint64(ib.c.N())
+			ib.next()
+		default:
+			n := uint64(roaring.IntersectionCount(ia.c, ib.c))
+			intersection += n
+			union += uint64(ia.c.N()+ib.c.N()) - n
+			ia.next()
+			ib.next()
+		}
+	}
+	if union == 0 {
+		return 0, nil
+	}
+	return float64(intersection) / float64(union), nil
 }
 
-// clearValue uses a column of bits to clear a multi-bit value.
-func (f *fragment) clearValue(tx Tx, columnID uint64, bitDepth uint64, value int64) (changed bool, err error) {
-	return f.setValueBase(tx, columnID, bitDepth, value, true)
-}
+// unionCount returns the number of columns set in any of the rows, by
+// unioning the rows' containers position by position and counting the
+// result, rather than building a Row. Repeated row IDs are read once.
+func (f *fragment) unionCount(tx Tx, rowIDs []uint64) (uint64, error) {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return 0, err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 
-// setValue uses a column of bits to set a multi-bit value.
-func (f *fragment) setValue(tx Tx, columnID uint64, bitDepth uint64, value int64) (changed bool, err error) {
-	return f.setValueBase(tx, columnID, bitDepth, value, false)
-}
+	union := make(map[uint64]*roaring.Container)
+	seen := make(map[uint64]struct{}, len(rowIDs))
+	for _, rowID := range rowIDs {
+		if _, ok := seen[rowID]; ok {
+			continue
+		}
+		seen[rowID] = struct{}{}
 
-func (f *fragment) positionsForValue(columnID uint64, bitDepth uint64, value int64, clear bool, toSet, toClear []uint64) ([]uint64, []uint64, error) {
-	// Convert value to an unsigned representation.
-	uvalue := uint64(value)
-	if value < 0 {
-		uvalue = uint64(-value)
+		it, err := f.newRowContainerIterator(tx, rowID)
+		if err != nil {
+			return 0, err
+		}
+		for ; !it.done; it.next() {
+			if acc := union[it.key]; acc != nil {
+				union[it.key] = acc.UnionInPlace(it.c)
+			} else {
+				// Clone, so the union never writes to storage's containers.
+				union[it.key] = it.c.Clone()
+			}
+		}
+		it.close()
 	}
 
-	// Mark value as set.
-	if bit, err := f.pos(bsiExistsBit, columnID); err != nil {
-		return toSet, toClear, errors.Wrap(err, "getting not-null pos")
-	} else if clear {
-		toClear = append(toClear, bit)
-	} else {
-		toSet = append(toSet, bit)
+	var n uint64
+	for _, c := range union {
+		c.Repair()
+		n += uint64(c.N())
 	}
+	return n, nil
+}
 
-	// Mark sign.
-	if bit, err := f.pos(bsiSignBit, columnID); err != nil {
-		return toSet, toClear, errors.Wrap(err, "getting sign pos")
-	} else if value >= 0 || clear {
-		toClear = append(toClear, bit)
-	} else {
-		toSet = append(toSet, bit)
+// rowContainerIterator iterates over the containers of a single row of a
+// fragment, with keys relative to the start of the row.
+type rowContainerIterator struct {
+	citer      roaring.ContainerIterator
+	start, end uint64
+
+	// key and c are the current container, unless done is set.
+	key  uint64
+	c    *roaring.Container
+	done bool
+}
+
+// newRowContainerIterator returns an iterator over the containers of rowID,
+// positioned on its first container.
+func (f *fragment) newRowContainerIterator(tx Tx, rowID uint64) (*rowContainerIterator, error) {
+	start := rowID << shardVsContainerExponent
+	citer, _, err := tx.ContainerIterator(f.index(), f.field(), f.view(), f.shard, start)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting container iterator for row %d", rowID)
 	}
+	it := &rowContainerIterator{citer: citer, start: start, end: start + 1<<shardVsContainerExponent}
+	it.next()
+	return it, nil
+}
 
-	for i := uint64(0); i < bitDepth; i++ {
-		bit, err := f.pos(uint64(bsiOffsetBit+i), columnID)
-		if err != nil {
-			return toSet, toClear, errors.Wrap(err, "getting pos")
-		}
-		if uvalue&(1<<i) != 0 {
-			toSet = append(toSet, bit)
-		} else {
-			toClear = append(toClear, bit)
+// next advances the iterator to the row's next container, or sets done.
+func (it *rowContainerIterator) next() {
+	if !it.done && it.citer.Next() {
+		if key, c := it.citer.Value(); key < it.end {
+			it.key, it.c = key-it.start, c
+			return
 		}
 	}
+	it.done = true
+}
 
-	return toSet, toClear, nil
+func (it *rowContainerIterator) close() {
+	it.citer.Close()
 }
 
-// TODO get rid of this and use positionsForValue to generate a single write op, and set that with importPositions.
-func (f *fragment) setValueBase(tx Tx, columnID uint64, bitDepth uint64, value int64, clear bool) (changed bool, err error) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+// rowMinMaxColumn returns the smallest and largest columns set in a row,
+// reading only the row's container boundaries. exists is false if the row
+// is empty.
+func (f *fragment) rowMinMaxColumn(tx Tx, rowID uint64) (min, max uint64, exists bool, err error) {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return 0, 0, false, err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 
-	err = func() error {
-		// Convert value to an unsigned representation.
-		uvalue := uint64(value)
-		if value < 0 {
-			uvalue = uint64(-value)
+	it, err := f.newRowContainerIterator(tx, rowID)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	defer it.close()
+
+	base := f.shard * ShardWidth
+	for ; !it.done; it.next() {
+		if it.c.N() == 0 {
+			continue
+		}
+		if !exists {
+			min = base + it.key<<16 + uint64(it.c.Min())
+			exists = true
 		}
+		max = base + it.key<<16 + uint64(it.c.Max())
+	}
+	return min, max, exists, nil
+}
 
-		for i := uint64(0); i < bitDepth; i++ {
-			if uvalue&(1<<i) != 0 {
-				if c, err := f.unprotectedSetBit(tx, uint64(bsiOffsetBit+i), columnID); err != nil {
-					return err
+// rowCountAtLeast returns true if at least k columns are set in a row. It
+// stops reading the row's containers as soon as k is reached, so it's much
+// cheaper than counting a large row. Every row, even an empty one, has at
+// least zero columns set, so k=0 is always true.
+func (f *fragment) rowCountAtLeast(tx Tx, rowID uint64, k uint64) (bool, error) {
+	if k == 0 {
+		return true, nil
+	}
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return false, err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	it, err := f.newRowContainerIterator(tx, rowID)
+	if err != nil {
+		return false, err
+	}
+	defer it.close()
+
+	var n uint64
+	for ; !it.done; it.next() {
+		if n += uint64(it.c.N()); n >= k {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// forEachBit calls fn for each bit set in the fragment, with its row ID and
+// absolute column ID, in row-major ascending order: by row, then by column
+// within the row. Iteration stops at the first error returned by fn. The
+// fragment's read lock is held throughout, blocking writers, so fn must not
+// do slow work such as I/O.
+//
+// The order follows from Tx.ContainerIterator yielding containers in
+// ascending key order, and from the values within each container being
+// sorted. Since exports and diffs depend on the order being reproducible,
+// forEachBit returns an error rather than yielding bits out of order if a
+// Tx breaks that contract.
+func (f *fragment) forEachBit(tx Tx, fn func(rowID, columnID uint64) error) error {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	citer, _, err := tx.ContainerIterator(f.index(), f.field(), f.view(), f.shard, 0)
+	if err != nil {
+		return errors.Wrap(err, "getting container iterator")
+	}
+	defer citer.Close()
+
+	var rowID, hi uint64
+	var failed error
+	process := func(u uint16) {
+		if failed == nil {
+			failed = fn(rowID, hi|uint64(u))
+		}
+	}
+	first, prevKey := true, uint64(0)
+	for citer.Next() {
+		key, c := citer.Value()
+		if !first && key <= prevKey {
+			return errors.Errorf("container iterator out of order: key %d follows %d", key, prevKey)
+		}
+		first, prevKey = false, key
+
+		pos := key << 16
+		rowID, hi = pos/ShardWidth, f.shard*ShardWidth+pos%ShardWidth
+		roaring.ContainerCallback(c, process)
+		if failed != nil {
+			return failed
+		}
+	}
+	return nil
+}
+
+// forEachContainer calls fn for each non-empty container in the fragment, in
+// ascending key order, with the container's key within the fragment (see
+// newRowContainerIterator). Iteration stops at the first error returned by
+// fn. It lets callers work on containers directly instead of materializing
+// rows.
+//
+// The containers may refer to tx's mapped storage, so they're only valid
+// during the call to fn: fn must Clone a container to keep it. They're
+// frozen before fn sees them, so they can't be modified in place. The read
+// lock is held throughout, so fn must not write to the fragment, but
+// separate Txs see their own snapshots and can iterate concurrently.
+func (f *fragment) forEachContainer(tx Tx, fn func(containerKey uint64, c *roaring.Container) error) error {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	citer, _, err := tx.ContainerIterator(f.index(), f.field(), f.view(), f.shard, 0)
+	if err != nil {
+		return errors.Wrap(err, "getting container iterator")
+	}
+	defer citer.Close()
+
+	for citer.Next() {
+		key, c := citer.Value()
+		if c.N() == 0 {
+			continue
+		}
+		if err := fn(key, c.Freeze()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sampleBits returns a uniform random sample of n of the fragment's set
+// bits, as (rowID, columnID) pairs sorted by row and then column. If the
+// fragment has n or fewer bits set, all of them are returned. The sample is
+// drawn by reservoir sampling in a single pass over the fragment, and is
+// the same for the same seed and data.
+func (f *fragment) sampleBits(tx Tx, n int, seed int64) ([][2]uint64, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	sample := make([][2]uint64, 0, n)
+	var seen int64
+	err := f.forEachBit(tx, func(rowID, columnID uint64) error {
+		seen++
+		if len(sample) < n {
+			sample = append(sample, [2]uint64{rowID, columnID})
+		} else if i := rnd.Int63n(seen); i < int64(n) {
+			sample[i] = [2]uint64{rowID, columnID}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(sample, func(i, j int) bool {
+		if sample[i][0] != sample[j][0] {
+			return sample[i][0] < sample[j][0]
+		}
+		return sample[i][1] < sample[j][1]
+	})
+	return sample, nil
+}
+
+// value uses a column of bits to read a multi-bit value.
+func (f *fragment) value(tx Tx, columnID uint64, bitDepth uint64) (value int64, exists bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.unprotectedValue(tx, columnID, bitDepth)
+}
+
+// unprotectedValue is value without grabbing the mutex.
+func (f *fragment) unprotectedValue(tx Tx, columnID uint64, bitDepth uint64) (value int64, exists bool, err error) {
+	// If existence bit is unset then ignore remaining bits.
+	if v, err := f.bit(tx, bsiExistsBit, columnID); err != nil {
+		return 0, false, errors.Wrap(err, "getting existence bit")
+	} else if !v {
+		return 0, false, nil
+	}
+
+	// Compute other bits into a value.
+	for i := uint64(0); i < bitDepth; i++ {
+		if v, err := f.bit(tx, uint64(bsiOffsetBit+i), columnID); err != nil {
+			return 0, false, errors.Wrapf(err, "getting value bit %d", i)
+		} else if v {
+			value |= (1 << i)
+		}
+	}
+
+	// Negate if sign bit set.
+	if v, err := f.bit(tx, bsiSignBit, columnID); err != nil {
+		return 0, false, errors.Wrap(err, "getting sign bit")
+	} else if v {
+		value = -value
+	}
+
+	return value, true, nil
+}
+
+// clearValue uses a column of bits to clear a multi-bit value.
+func (f *fragment) clearValue(tx Tx, columnID uint64, bitDepth uint64, value int64) (changed bool, err error) {
+	return f.setValueBase(tx, columnID, bitDepth, value, true)
+}
+
+// setValue uses a column of bits to set a multi-bit value.
+func (f *fragment) setValue(tx Tx, columnID uint64, bitDepth uint64, value int64) (changed bool, err error) {
+	return f.setValueBase(tx, columnID, bitDepth, value, false)
+}
+
+// setValueIfGreater sets a column's value if the column has no value, or if
+// value is greater than its current value. The comparison and the write are
+// made under one lock, so concurrent calls can't lose the greatest value.
+func (f *fragment) setValueIfGreater(tx Tx, columnID uint64, bitDepth uint64, value int64) (changed bool, err error) {
+	return f.setValueIf(tx, columnID, bitDepth, value, func(current int64) bool { return value > current })
+}
+
+// setValueIfLess sets a column's value if the column has no value, or if
+// value is less than its current value.
+func (f *fragment) setValueIfLess(tx Tx, columnID uint64, bitDepth uint64, value int64) (changed bool, err error) {
+	return f.setValueIf(tx, columnID, bitDepth, value, func(current int64) bool { return value < current })
+}
+
+// setValueIfAbsent sets a column's value only if the column has no value,
+// returning whether it did. The check and the write are made under one
+// lock, in tx, so a value set concurrently is never overwritten.
+func (f *fragment) setValueIfAbsent(tx Tx, columnID uint64, bitDepth uint64, value int64) (set bool, err error) {
+	return f.setValueIf(tx, columnID, bitDepth, value, func(current int64) bool { return false })
+}
+
+// setValueIf sets a column's value if the column has no value, or if replace
+// returns true for its current value.
+func (f *fragment) setValueIf(tx Tx, columnID uint64, bitDepth uint64, value int64, replace func(current int64) bool) (changed bool, err error) {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current, exists, err := f.unprotectedValue(tx, columnID, bitDepth)
+	if err != nil {
+		return false, errors.Wrap(err, "getting current value")
+	} else if exists && !replace(current) {
+		return false, nil
+	}
+	return f.unprotectedSetValueBase(tx, columnID, bitDepth, value, false)
+}
+
+func (f *fragment) positionsForValue(columnID uint64, bitDepth uint64, value int64, clear bool, toSet, toClear []uint64) ([]uint64, []uint64, error) {
+	// Convert value to an unsigned representation.
+	uvalue := uint64(value)
+	if value < 0 {
+		uvalue = uint64(-value)
+	}
+
+	// Mark value as set.
+	if bit, err := f.pos(bsiExistsBit, columnID); err != nil {
+		return toSet, toClear, errors.Wrap(err, "getting not-null pos")
+	} else if clear {
+		toClear = append(toClear, bit)
+	} else {
+		toSet = append(toSet, bit)
+	}
+
+	// Mark sign.
+	if bit, err := f.pos(bsiSignBit, columnID); err != nil {
+		return toSet, toClear, errors.Wrap(err, "getting sign pos")
+	} else if value >= 0 || clear {
+		toClear = append(toClear, bit)
+	} else {
+		toSet = append(toSet, bit)
+	}
+
+	for i := uint64(0); i < bitDepth; i++ {
+		bit, err := f.pos(uint64(bsiOffsetBit+i), columnID)
+		if err != nil {
+			return toSet, toClear, errors.Wrap(err, "getting pos")
+		}
+		if uvalue&(1<<i) != 0 {
+			toSet = append(toSet, bit)
+		} else {
+			toClear = append(toClear, bit)
+		}
+	}
+
+	return toSet, toClear, nil
+}
+
+// TODO get rid of this and use positionsForValue to generate a single write op, and set that with importPositions.
+func (f *fragment) setValueBase(tx Tx, columnID uint64, bitDepth uint64, value int64, clear bool) (changed bool, err error) {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.unprotectedSetValueBase(tx, columnID, bitDepth, value, clear)
+}
+
+// unprotectedSetValueBase is setValueBase without grabbing the mutex.
+func (f *fragment) unprotectedSetValueBase(tx Tx, columnID uint64, bitDepth uint64, value int64, clear bool) (changed bool, err error) {
+	err = func() error {
+		// Convert value to an unsigned representation.
+		uvalue := uint64(value)
+		if value < 0 {
+			uvalue = uint64(-value)
+		}
+
+		for i := uint64(0); i < bitDepth; i++ {
+			if uvalue&(1<<i) != 0 {
+				if c, err := f.unprotectedSetBit(tx, uint64(bsiOffsetBit+i), columnID); err != nil {
+					return err
 				} else if c {
 					changed = true
 				}
@@ -749,6 +1435,82 @@ func (f *fragment) sum(tx Tx, filter *Row, bitDepth uint64) (sum int64, count ui
 	return sum, uint64(c32), nil
 }
 
+// sumBig is like sum, but accumulates into a big.Int so the result is
+// correct even when the total doesn't fit in an int64. It sums each bit
+// slice's contribution, which is the number of positive columns less the
+// number of negative columns having that bit set, shifted by the slice's
+// position.
+func (f *fragment) sumBig(tx Tx, filter *Row, bitDepth uint64) (sum *big.Int, count uint64, err error) {
+	sum = new(big.Int)
+	consider, err := f.row(tx, bsiExistsBit)
+	if err != nil {
+		return sum, count, err
+	} else if filter != nil {
+		consider = consider.Intersect(filter)
+	}
+	count = consider.Count()
+	if count == 0 {
+		return sum, 0, nil
+	}
+
+	sign, err := f.row(tx, bsiSignBit)
+	if err != nil {
+		return sum, count, err
+	}
+	negative := consider.Intersect(sign)
+	positive := consider.Difference(negative)
+
+	var slice big.Int
+	for i := uint64(0); i < bitDepth; i++ {
+		row, err := f.row(tx, bsiOffsetBit+i)
+		if err != nil {
+			return sum, count, err
+		}
+		n := int64(row.Intersect(positive).Count()) - int64(row.Intersect(negative).Count())
+		if n == 0 {
+			continue
+		}
+		slice.SetInt64(n)
+		sum.Add(sum, slice.Lsh(&slice, uint(i)))
+	}
+	return sum, count, nil
+}
+
+// valueHistogram returns the number of columns whose values fall into each
+// of the buckets delimited by edges, which must be strictly ascending. There
+// is one bucket fewer than there are edges; bucket i holds values from
+// edges[i] up to, but not including, edges[i+1], except that the last bucket
+// includes its upper edge. A bitmap can be passed in to optionally filter
+// the counted columns.
+func (f *fragment) valueHistogram(tx Tx, filter *Row, bitDepth uint64, edges []int64) ([]uint64, error) {
+	for i := 1; i < len(edges); i++ {
+		if edges[i] <= edges[i-1] {
+			return nil, errors.Errorf("histogram edges must be strictly ascending: %d follows %d", edges[i], edges[i-1])
+		}
+	}
+	if len(edges) < 2 {
+		return nil, nil
+	}
+	counts := make([]uint64, len(edges)-1)
+
+	// A filter with no columns in this shard counts nothing.
+	if filter != nil && filter.segment(f.shard) == nil {
+		return counts, nil
+	}
+	for i := range counts {
+		hi := edges[i+1]
+		if i < len(counts)-1 {
+			hi--
+		}
+		row, err := f.intersectValueRange(tx, filter, bitDepth, edges[i], hi)
+		if err != nil {
+			return nil, errors.Wrapf(err, "counting values between %d and %d", edges[i], hi)
+		}
+		counts[i] = row.Count()
+	}
+	return counts, nil
+}
+
 // min returns the min of a given bsiGroup as well as the number of columns involved.
 // A bitmap can be passed in to optionally filter the computed columns.
 func (f *fragment) min(tx Tx, filter *Row, bitDepth uint64) (min int64, count uint64, err error) {
@@ -831,6 +1593,107 @@ func (f *fragment) max(tx Tx, filter *Row, bitDepth uint64) (max int64, count ui
 	return f.maxUnsigned(tx, pos, bitDepth)
 }
 
+// distinctValues returns the number of distinct values of a given bsiGroup.
+// A bitmap can be passed in to optionally filter the counted columns. The
+// count is exact: each column's value is decoded and collected in a set.
+func (f *fragment) distinctValues(tx Tx, filter *Row, bitDepth uint64) (uint64, error) {
+	values, err := f.decodeValues(tx, filter, bitDepth)
+	if err != nil {
+		return 0, err
+	}
+	set := make(map[int64]struct{})
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return uint64(len(set)), nil
+}
+
+// decodeValues returns the value of each column of a given bsiGroup, in
+// column order, reading each of its rows once. A bitmap can be passed in to
+// optionally filter the decoded columns.
+func (f *fragment) decodeValues(tx Tx, filter *Row, bitDepth uint64) ([]int64, error) {
+	consider, err := f.row(tx, bsiExistsBit)
+	if err != nil {
+		return nil, err
+	} else if filter != nil {
+		consider = consider.Intersect(filter)
+	}
+	cols := consider.Columns()
+	if len(cols) == 0 {
+		return nil, nil
+	}
+
+	// Decode values one bit slice at a time. Both cols and the columns of
+	// each slice are sorted, so a single merge pass finds each column's
+	// position in cols.
+	forEach := func(rowID uint64, fn func(j int)) error {
+		row, err := f.row(tx, rowID)
+		if err != nil {
+			return err
+		}
+		j := 0
+		for _, col := range row.Intersect(consider).Columns() {
+			for cols[j] != col {
+				j++
+			}
+			fn(j)
+		}
+		return nil
+	}
+	mags := make([]uint64, len(cols))
+	for i := uint64(0); i < bitDepth; i++ {
+		if err := forEach(bsiOffsetBit+i, func(j int) { mags[j] |= 1 << i }); err != nil {
+			return nil, err
+		}
+	}
+	values := make([]int64, len(cols))
+	for j, mag := range mags {
+		values[j] = int64(mag)
+	}
+	if err := forEach(bsiSignBit, func(j int) { values[j] = -values[j] }); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// ValueStats summarizes the values of a bsiGroup in a fragment. Values are
+// as stored, before the field's base is applied. Sum is a big.Int, as for
+// sumBig, since the sum of int64 values can overflow an int64.
+type ValueStats struct {
+	Sum   *big.Int
+	Count uint64
+	Min   int64
+	Max   int64
+	Mean  float64
+}
+
+// valueStats returns the sum, count, min, max and mean of a given bsiGroup,
+// reading each of its rows once, rather than once each for sum, min and
+// max. A bitmap can be passed in to optionally filter the considered
+// columns; if no columns are considered, the stats are all zero.
+func (f *fragment) valueStats(tx Tx, filter *Row, bitDepth uint64) (stats ValueStats, err error) {
+	stats.Sum = new(big.Int)
+	values, err := f.decodeValues(tx, filter, bitDepth)
+	if err != nil || len(values) == 0 {
+		return stats, err
+	}
+
+	var v big.Int
+	for j, value := range values {
+		if j == 0 || value < stats.Min {
+			stats.Min = value
+		}
+		if j == 0 || value > stats.Max {
+			stats.Max = value
+		}
+		stats.Sum.Add(stats.Sum, v.SetInt64(value))
+	}
+	stats.Count = uint64(len(values))
+	sum, _ := new(big.Float).SetInt(stats.Sum).Float64()
+	stats.Mean = sum / float64(stats.Count)
+	return stats, nil
+}
+
 // maxUnsigned the highest value without considering the sign bit. Filter is required.
 func (f *fragment) maxUnsigned(tx Tx, filter *Row, bitDepth uint64) (max int64, count uint64, err error) {
 	count = filter.Count()
@@ -935,18 +1798,32 @@ func (f *fragment) maxRowID(tx Tx) (_ uint64, err error) {
 
 // rangeOp returns bitmaps with a bsiGroup value encoding matching the predicate.
 func (f *fragment) rangeOp(tx Tx, op pql.Token, bitDepth uint64, predicate int64) (*Row, error) {
+	return f.rangeOpFiltered(tx, op, bitDepth, predicate, nil)
+}
+
+// rangeOpFiltered is like rangeOp, but only returns columns which are also in
+// filter. A nil filter matches all columns. For EQ and NEQ, only the filter's
+// columns are scanned, which makes selective lookups cheaper than filtering
+// the result of rangeOp.
+func (f *fragment) rangeOpFiltered(tx Tx, op pql.Token, bitDepth uint64, predicate int64, filter *Row) (*Row, error) {
+	var row *Row
+	var err error
 	switch op {
 	case pql.EQ:
-		return f.rangeEQ(tx, bitDepth, predicate)
+		return f.rangeEQ(tx, bitDepth, predicate, filter)
 	case pql.NEQ:
-		return f.rangeNEQ(tx, bitDepth, predicate)
+		return f.rangeNEQ(tx, bitDepth, predicate, filter)
 	case pql.LT, pql.LTE:
-		return f.rangeLT(tx, bitDepth, predicate, op == pql.LTE)
+		row, err = f.rangeLT(tx, bitDepth, predicate, op == pql.LTE)
 	case pql.GT, pql.GTE:
-		return f.rangeGT(tx, bitDepth, predicate, op == pql.GTE)
+		row, err = f.rangeGT(tx, bitDepth, predicate, op == pql.GTE)
 	default:
 		return nil, ErrInvalidRangeOperation
 	}
+	if err != nil || filter == nil {
+		return row, err
+	}
+	return row.Intersect(filter), nil
 }
 
 func absInt64(v int64) uint64 {
@@ -960,12 +1837,20 @@ func absInt64(v int64) uint64 {
 	}
 }
 
-func (f *fragment) rangeEQ(tx Tx, bitDepth uint64, predicate int64) (*Row, error) {
+// rangeEQ returns the columns whose value equals predicate. If filter is
+// non-nil, only columns in filter are considered.
+func (f *fragment) rangeEQ(tx Tx, bitDepth uint64, predicate int64, filter *Row) (*Row, error) {
 	// Start with set of columns with values set.
 	b, err := f.row(tx, bsiExistsBit)
 	if err != nil {
 		return nil, err
 	}
+	if filter != nil {
+		b = b.Intersect(filter)
+	}
+	if !b.Any() {
+		return NewRow(), nil
+	}
 
 	upredicate := absInt64(predicate)
 	if uint64(bits.Len64(upredicate)) > bitDepth {
@@ -985,7 +1870,7 @@ func (f *fragment) rangeEQ(tx Tx, bitDepth uint64, predicate int64) (*Row, error
 	}
 
 	// Filter any bits that don't match the current bit value.
-	for i := int(bitDepth - 1); i >= 0; i-- {
+	for i := int(bitDepth - 1); i >= 0 && b.Any(); i-- {
 		row, err := f.row(tx, uint64(bsiOffsetBit+i))
 		if err != nil {
 			return nil, err
@@ -1002,15 +1887,20 @@ func (f *fragment) rangeEQ(tx Tx, bitDepth uint64, predicate int64) (*Row, error
 	return b, nil
 }
 
-func (f *fragment) rangeNEQ(tx Tx, bitDepth uint64, predicate int64) (*Row, error) {
+// rangeNEQ returns the columns which have a value not equal to predicate. If
+// filter is non-nil, only columns in filter are considered.
+func (f *fragment) rangeNEQ(tx Tx, bitDepth uint64, predicate int64, filter *Row) (*Row, error) {
 	// Start with set of columns with values set.
 	b, err := f.row(tx, bsiExistsBit)
 	if err != nil {
 		return nil, err
 	}
+	if filter != nil {
+		b = b.Intersect(filter)
+	}
 
 	// Get the equal bitmap.
-	eq, err := f.rangeEQ(tx, bitDepth, predicate)
+	eq, err := f.rangeEQ(tx, bitDepth, predicate, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -1047,7 +1937,7 @@ func (f *fragment) rangeLT(tx Tx, bitDepth uint64, predicate int64, allowEqualit
 		return b.Intersect(sign), nil
 	case predicate == 0 && allowEquality:
 		// Match all integers that are either negative or 0.
-		zeroes, err := f.rangeEQ(tx, bitDepth, 0)
+		zeroes, err := f.rangeEQ(tx, bitDepth, 0, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -1131,7 +2021,7 @@ func (f *fragment) rangeGT(tx Tx, bitDepth uint64, predicate int64, allowEqualit
 	switch {
 	case predicate == 0 && !allowEquality:
 		// Match all positive numbers except zero.
-		nonzero, err := f.rangeNEQ(tx, bitDepth, 0)
+		nonzero, err := f.rangeNEQ(tx, bitDepth, 0, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -1211,17 +2101,34 @@ func (f *fragment) notNull(tx Tx) (*Row, error) {
 
 // rangeBetween returns bitmaps with a bsiGroup value encoding matching any value between predicateMin and predicateMax.
 func (f *fragment) rangeBetween(tx Tx, bitDepth uint64, predicateMin, predicateMax int64) (*Row, error) {
+	return f.intersectValueRange(tx, nil, bitDepth, predicateMin, predicateMax)
+}
+
+// intersectValueRange returns the columns in filter whose bsiGroup values are
+// between predicateMin and predicateMax. Unlike intersecting the result of
+// rangeBetween with filter, it only compares the values of columns in
+// filter. A nil filter matches every column.
+func (f *fragment) intersectValueRange(tx Tx, filter *Row, bitDepth uint64, predicateMin, predicateMax int64) (*Row, error) {
+	if filter != nil && filter.segment(f.shard) == nil {
+		return NewRow(), nil
+	}
 	b, err := f.row(tx, bsiExistsBit)
 	if err != nil {
 		return nil, err
 	}
+	if filter != nil {
+		b = b.Intersect(filter)
+		if !b.Any() {
+			return NewRow(), nil
+		}
+	}
 
 	// Convert predicates to unsigned values.
 	upredicateMin, upredicateMax := absInt64(predicateMin), absInt64(predicateMax)
 
 	switch {
 	case predicateMin == predicateMax:
-		return f.rangeEQ(tx, bitDepth, predicateMin)
+		return f.rangeEQ(tx, bitDepth, predicateMin, filter)
 	case predicateMin >= 0:
 		// Handle positive-only values.
 		r, err := f.row(tx, bsiSignBit)
@@ -1258,6 +2165,33 @@ func (f *fragment) rangeBetween(tx Tx, bitDepth uint64, predicateMin, predicateM
 	}
 }
 
+// countRange returns the number of columns in filter whose bsiGroup values
+// are between lo and hi, as intersectValueRange(...).Count() would. A range
+// crossing zero is counted as its negative and non-negative halves, which
+// are disjoint, rather than as their union. A nil filter matches every
+// column, and lo > hi matches none.
+func (f *fragment) countRange(tx Tx, filter *Row, bitDepth uint64, lo, hi int64) (uint64, error) {
+	if lo > hi {
+		return 0, nil
+	}
+	if lo < 0 && hi >= 0 {
+		neg, err := f.countRange(tx, filter, bitDepth, lo, -1)
+		if err != nil {
+			return 0, err
+		}
+		pos, err := f.countRange(tx, filter, bitDepth, 0, hi)
+		if err != nil {
+			return 0, err
+		}
+		return neg + pos, nil
+	}
+	row, err := f.intersectValueRange(tx, filter, bitDepth, lo, hi)
+	if err != nil {
+		return 0, err
+	}
+	return row.Count(), nil
+}
+
 // rangeBetweenUnsigned returns BSI columns for a range of values. Disregards the sign bit.
 func (f *fragment) rangeBetweenUnsigned(tx Tx, filter *Row, bitDepth uint64, predicateMin, predicateMax uint64) (*Row, error) {
 	switch {
@@ -1315,11 +2249,19 @@ func (f *fragment) pos(rowID, columnID uint64) (uint64, error) {
 // top returns the top rows from the fragment.
 // If opt.Src is specified then only rows which intersect src are returned.
 func (f *fragment) top(tx Tx, opt topOptions) ([]Pair, error) {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return nil, err
+	}
 	// Retrieve pairs. If no row ids specified then return from cache.
 	pairs, err := f.topBitmapPairs(tx, opt.RowIDs)
 	if err != nil {
 		return nil, err
 	}
+	if opt.Exact {
+		if pairs, err = f.exactBitmapPairs(tx, pairs); err != nil {
+			return nil, err
+		}
+	}
 
 	// If row ids are provided, we don't want to truncate the result set
 	if len(opt.RowIDs) > 0 {
@@ -1478,6 +2420,26 @@ func (f *fragment) topBitmapPairs(tx Tx, rowIDs []uint64) ([]bitmapPair, error)
 	return pairs, nil
 }
 
+// exactBitmapPairs returns pairs with each count replaced by the row's
+// exact count in storage, sorted by the new counts. Rows which turn out to
+// be empty are dropped. The cache's counts can drift from storage, and
+// since top stops at the first row whose count is too low, its results can
+// be wrong unless the candidates are in exact order.
+func (f *fragment) exactBitmapPairs(tx Tx, pairs []bitmapPair) ([]bitmapPair, error) {
+	exact := make(bitmapPairs, 0, len(pairs))
+	for _, pair := range pairs {
+		row, err := f.row(tx, pair.ID)
+		if err != nil {
+			return nil, err
+		}
+		if n := row.Count(); n > 0 {
+			exact = append(exact, bitmapPair{ID: pair.ID, Count: n})
+		}
+	}
+	sort.Stable(&exact)
+	return exact, nil
+}
+
 // topOptions represents options passed into the Top() function.
 type topOptions struct {
 	// Number of rows to return.
@@ -1491,20 +2453,186 @@ type topOptions struct {
 	MinThreshold uint64
 
 	TanimotoThreshold uint64
+
+	// Exact recounts the candidate rows from storage, rather than trusting
+	// the cache's counts, which can drift after many updates.
+	Exact bool
 }
 
 // bulkImport bulk imports a set of bits.
-// The cache is updated to reflect the new data.
-func (f *fragment) bulkImport(tx Tx, rowIDs, columnIDs []uint64, options *ImportOptions) error {
+// The cache is updated to reflect the new data. If ctx is canceled, the
+// import stops between batches and returns the context's error, leaving tx
+// holding a partial import which the caller should roll back. The import
+// waits for a slot if the holder limits concurrent imports.
+func (f *fragment) bulkImport(ctx context.Context, tx Tx, rowIDs, columnIDs []uint64, options *ImportOptions) error {
+	span, ctx := f.startImportSpan(ctx, "fragment.bulkImport")
+	defer span.Finish()
+	span.LogKV("bits", len(columnIDs))
+	defer f.logSlowImport("bulkImport", f.slowImportStart(), len(columnIDs))
 	// Verify that there are an equal number of row ids and column ids.
 	if len(rowIDs) != len(columnIDs) {
 		return fmt.Errorf("mismatch of row/column len: %d != %d", len(rowIDs), len(columnIDs))
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	release, err := f.holder.acquireImport(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return err
+	}
 
-	if f.mutexVector != nil && !options.Clear {
-		return f.bulkImportMutex(tx, rowIDs, columnIDs, options)
+	if options.SkipCacheUpdate || f.cacheRecalculateInterval > 0 {
+		if err := f.markCacheStale(); err != nil {
+			return err
+		}
+	}
+
+	mutex := f.mutexVector != nil && !options.Clear
+	if options.Presorted && importSortedParanoia {
+		if err := checkImportSorted(rowIDs, columnIDs, mutex); err != nil {
+			return errors.Wrap(err, "presorted import")
+		}
+	} else if !mutex && importSortedParanoia {
+		if err := checkImportOrder(rowIDs, columnIDs, options.Order); err != nil {
+			return errors.Wrap(err, "ordered import")
+		}
+	}
+
+	if mutex {
+		return f.bulkImportMutex(ctx, tx, rowIDs, columnIDs, options)
+	}
+	return f.bulkImportStandard(ctx, tx, rowIDs, columnIDs, options)
+}
+
+// importBatchSize is the number of positions bulk imports write to storage
+// at a time. Cancellation is checked between batches.
+const importBatchSize = 65536
+
+// importChannel bulk imports the (rowID, columnID) pairs received from ch
+// until it's closed, without the caller having to materialize them all.
+// Pairs are collected into batches of importBatchSize, each of which is
+// imported with bulkImport as soon as it's full; the final, partial batch
+// is imported once ch is closed. Since at most one batch is buffered,
+// senders block while a batch is being written. If ctx is canceled, the
+// import stops and returns the context's error, leaving tx holding a
+// partial import.
+func (f *fragment) importChannel(ctx context.Context, tx Tx, ch <-chan [2]uint64, options *ImportOptions) error {
+	rowIDs := make([]uint64, 0, importBatchSize)
+	columnIDs := make([]uint64, 0, importBatchSize)
+	flush := func() error {
+		if len(rowIDs) == 0 {
+			return nil
+		}
+		// bulkImport may modify the slices, but they're reset afterwards.
+		err := f.bulkImport(ctx, tx, rowIDs, columnIDs, options)
+		rowIDs, columnIDs = rowIDs[:0], columnIDs[:0]
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case pair, ok := <-ch:
+			if !ok {
+				return flush()
+			}
+			rowIDs = append(rowIDs, pair[0])
+			columnIDs = append(columnIDs, pair[1])
+			if len(rowIDs) == importBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// importSortedParanoia enables a check that bulkImport input marked as
+// Presorted really is sorted. It costs a pass over the input, so it's off
+// by default; turn it on when debugging a producer.
+var importSortedParanoia = false
+
+// checkImportSorted returns an error if the given row/column pairs are not
+// in the order promised by ImportOptions.Presorted: strictly ascending
+// column IDs for a mutex import, or ascending (row, column) pairs otherwise.
+func checkImportSorted(rowIDs, columnIDs []uint64, mutex bool) error {
+	for i := 1; i < len(columnIDs); i++ {
+		if mutex {
+			if columnIDs[i] <= columnIDs[i-1] {
+				return errors.Errorf("column %d at %d does not follow column %d", columnIDs[i], i, columnIDs[i-1])
+			}
+			continue
+		}
+		if rowIDs[i] < rowIDs[i-1] || (rowIDs[i] == rowIDs[i-1] && columnIDs[i] < columnIDs[i-1]) {
+			return errors.Errorf("row/column %d/%d at %d does not follow %d/%d", rowIDs[i], columnIDs[i], i, rowIDs[i-1], columnIDs[i-1])
+		}
+	}
+	return nil
+}
+
+// checkImportOrder returns an error if the given row/column pairs are not
+// in the order promised by order.
+func checkImportOrder(rowIDs, columnIDs []uint64, order ImportOrder) error {
+	switch order {
+	case ImportOrderRowMajor:
+		return checkImportSorted(rowIDs, columnIDs, false)
+	case ImportOrderColumnMajor:
+		for i := 1; i < len(columnIDs); i++ {
+			if columnIDs[i] < columnIDs[i-1] || (columnIDs[i] == columnIDs[i-1] && rowIDs[i] < rowIDs[i-1]) {
+				return errors.Errorf("column/row %d/%d at %d does not follow %d/%d", columnIDs[i], rowIDs[i], i, columnIDs[i-1], rowIDs[i-1])
+			}
+		}
+	}
+	return nil
+}
+
+// sortImportPositions sorts positions computed from row/column pairs in
+// the given order into ascending order, which is the order storage wants
+// them in. Positions from row-major pairs are already sorted; positions from
+// column-major pairs are sorted within each row, so it's enough to group
+// them by row, keeping their order within the row.
+func sortImportPositions(positions []uint64, order ImportOrder) []uint64 {
+	switch order {
+	case ImportOrderRowMajor:
+		return positions
+	case ImportOrderColumnMajor:
+		counts := make(map[uint64]int)
+		for _, pos := range positions {
+			counts[pos/ShardWidth]++
+		}
+		rowIDs := make([]uint64, 0, len(counts))
+		for rowID := range counts {
+			rowIDs = append(rowIDs, rowID)
+		}
+		sort.Sort(uint64Slice(rowIDs))
+		offset := 0
+		for _, rowID := range rowIDs {
+			n := counts[rowID]
+			counts[rowID] = offset
+			offset += n
+		}
+		sorted := make([]uint64, len(positions))
+		for _, pos := range positions {
+			rowID := pos / ShardWidth
+			sorted[counts[rowID]] = pos
+			counts[rowID]++
+		}
+		return sorted
+	default:
+		sort.Sort(uint64Slice(positions))
+		return positions
 	}
-	return f.bulkImportStandard(tx, rowIDs, columnIDs, options)
 }
 
 // clearBitsReportingChanges is a special fancy case. For existence-tracking,
@@ -1554,6 +2682,9 @@ func (f *fragment) clearBitsReportingChanges(tx Tx, rowIDs, columnIDs []uint64)
 	clear := columnIDs[:next]
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	if err := f.unprotectedFlushWriteBuffer(tx); err != nil {
+		return nil, err
+	}
 	CounterClearingingN.Add(float64(len(clear)))
 	changed, err := tx.Removed(f.index(), f.field(), f.view(), f.shard, clear...)
 	if err != nil {
@@ -1565,7 +2696,7 @@ func (f *fragment) clearBitsReportingChanges(tx Tx, rowIDs, columnIDs []uint64)
 
 // bulkImportStandard performs a bulk import on a standard fragment. May mutate
 // its rowIDs and columnIDs arguments.
-func (f *fragment) bulkImportStandard(tx Tx, rowIDs, columnIDs []uint64, options *ImportOptions) (err error) {
+func (f *fragment) bulkImportStandard(ctx context.Context, tx Tx, rowIDs, columnIDs []uint64, options *ImportOptions) (err error) {
 	// rowSet maintains the set of rowIDs present in this import. It allows the
 	// cache to be updated once per row, instead of once per bit. TODO: consider
 	// sorting by rowID/columnID first and avoiding the map allocation here. (we
@@ -1595,15 +2726,57 @@ func (f *fragment) bulkImportStandard(tx Tx, rowIDs, columnIDs []uint64, options
 			rowSet[rowID] = struct{}{}
 		}
 	}
-	positions := columnIDs[:next]
+	order := options.Order
+	if options.Presorted {
+		order = ImportOrderRowMajor
+	}
+	positions := sortImportPositions(columnIDs[:next], order)
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	if options.Clear {
-		err = f.importPositions(tx, nil, positions, rowSet)
-	} else {
-		err = f.importPositions(tx, positions, nil, rowSet)
+	if f.tombstones != nil && !options.Clear {
+		positions = f.tombstones.filter(positions)
+	}
+	if !options.Clear && f.maxSize() > 0 {
+		if err := f.checkImportSize(tx, positionsSizeBound(positions)); err != nil {
+			return err
+		}
+	}
+	var keys []uint64
+	if !options.Clear && f.runThreshold > 0 {
+		keys = positionsContainerKeys(positions)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch := positions
+		if len(batch) > importBatchSize {
+			batch = batch[:importBatchSize]
+		}
+		positions = positions[len(batch):]
+
+		// The cache is only updated once the last batch is written.
+		var batchRowSet map[uint64]struct{}
+		if len(positions) == 0 {
+			batchRowSet = rowSet
+		}
+		if options.Clear {
+			err = f.importPositions(tx, nil, batch, batchRowSet)
+		} else {
+			err = f.importPositions(tx, batch, nil, batchRowSet)
+		}
+		if err != nil {
+			return errors.Wrap(err, "bulkImportStandard")
+		} else if options.Clear && f.tombstones != nil {
+			for _, pos := range batch {
+				f.tombstones.add(pos)
+			}
+		}
+		if len(positions) == 0 {
+			f.recordRowsChanged(tx, rowSet)
+			return errors.Wrap(f.applyRunThreshold(tx, keys), "bulkImportStandard")
+		}
 	}
-	return errors.Wrap(err, "bulkImportStandard")
 }
 
 // parallelSlices provides a sort.Interface for corresponding slices of
@@ -1707,6 +2880,64 @@ func (p *parallelSlices) fullPrune() {
 	}
 }
 
+// MutexConflictPolicy chooses how a mutex import resolves a batch which
+// gives more than one row for the same column.
+type MutexConflictPolicy int
+
+const (
+	// MutexLastWins keeps the row given last for a column, as mutex imports
+	// without a policy do.
+	MutexLastWins MutexConflictPolicy = iota
+
+	// MutexFirstWins keeps the row given first for a column.
+	MutexFirstWins
+
+	// MutexErrorOnConflict fails the import, without setting anything, if a
+	// column is given two different rows. Giving a column the same row more
+	// than once isn't a conflict.
+	MutexErrorOnConflict
+)
+
+// MutexConflictError is returned by a mutex import with the
+// MutexErrorOnConflict policy when a column is given two different rows.
+type MutexConflictError struct {
+	Column uint64
+	Rows   [2]uint64
+}
+
+func (e MutexConflictError) Error() string {
+	return fmt.Sprintf("mutex conflict: column %d given rows %d and %d", e.Column, e.Rows[0], e.Rows[1])
+}
+
+// resolveMutex sorts p by column, keeping one row for each column as policy
+// chooses. Rows given for the same column keep their relative order, so
+// "first" and "last" are as given. With MutexErrorOnConflict, it returns a
+// MutexConflictError for the lowest conflicting column.
+func (p *parallelSlices) resolveMutex(policy MutexConflictPolicy) error {
+	if !sort.IsSorted(p) {
+		sort.Stable(p)
+	}
+	n := 0
+	for i := range p.cols {
+		if n > 0 && p.cols[i] == p.cols[n-1] {
+			switch policy {
+			case MutexFirstWins:
+			case MutexErrorOnConflict:
+				if p.rows[i] != p.rows[n-1] {
+					return MutexConflictError{Column: p.cols[i], Rows: [2]uint64{p.rows[n-1], p.rows[i]}}
+				}
+			default:
+				p.rows[n-1] = p.rows[i]
+			}
+			continue
+		}
+		p.cols[n], p.rows[n] = p.cols[i], p.rows[i]
+		n++
+	}
+	p.cols, p.rows = p.cols[:n], p.rows[:n]
+	return nil
+}
+
 func (p *parallelSlices) Len() int {
 	return len(p.cols)
 }
@@ -1756,42 +2987,78 @@ func (f *fragment) importPositions(tx Tx, set, clear []uint64, rowSet map[uint64
 // that's correct. This was originally the tail end of importPositions, but
 // we want to be able to access the same logic from elsewhere.
 func (f *fragment) updateCaching(tx Tx, rowSet map[uint64]struct{}) error {
-	// Update cache counts for all affected rows.
+	// Invalidate block checksums for all affected rows.
 	for rowID := range rowSet {
-		// Invalidate block checksum.
 		delete(f.checksums, int(rowID/HashBlockSize))
+	}
+	return f.unprotectedUpdateCacheRows(tx, rowSet)
+}
 
-		if f.CacheType != CacheTypeNone {
-			start := rowID * ShardWidth
-			end := (rowID + 1) * ShardWidth
-
-			n, err := tx.CountRange(f.index(), f.field(), f.view(), f.shard, start, end)
-			if err != nil {
-				return errors.Wrap(err, "CountRange")
-			}
-
+// unprotectedUpdateCacheRows brings the cached counts of the given rows up
+// to date with storage, without rebuilding the rest of the cache. Rows which
+// no longer have any bits are evicted. This is how imports keep the cache
+// current; RecalculateCache is only needed to re-rank the whole cache.
+func (f *fragment) unprotectedUpdateCacheRows(tx Tx, rowSet map[uint64]struct{}) error {
+	if f.CacheType == CacheTypeNone || f.cacheStale || len(rowSet) == 0 {
+		return nil
+	}
+	for rowID := range rowSet {
+		n, err := tx.CountRange(f.index(), f.field(), f.view(), f.shard, rowID*ShardWidth, (rowID+1)*ShardWidth)
+		if err != nil {
+			return errors.Wrap(err, "CountRange")
+		}
+		if n == 0 {
+			f.cache.Remove(rowID)
+		} else {
 			f.cache.BulkAdd(rowID, n)
 		}
 	}
+	f.cache.Invalidate()
+	return nil
+}
 
-	if f.CacheType != CacheTypeNone {
-		f.cache.Invalidate()
+// importMutexBatch imports a batch of bits into a mutex fragment, resolving
+// columns given more than one row as policy chooses, rather than always
+// keeping the last as bulkImport does. The batch is resolved before
+// anything is written, so a MutexConflictError leaves the fragment as it
+// was.
+func (f *fragment) importMutexBatch(ctx context.Context, tx Tx, rowIDs, columnIDs []uint64, policy MutexConflictPolicy) error {
+	if f.mutexVector == nil {
+		return errors.New("mutex import only valid for mutex fields")
 	}
-	return nil
+	if len(rowIDs) != len(columnIDs) {
+		return fmt.Errorf("mismatch of row/column len: %d != %d", len(rowIDs), len(columnIDs))
+	}
+	p := parallelSlices{cols: columnIDs, rows: rowIDs}
+	if err := p.resolveMutex(policy); err != nil {
+		return err
+	}
+	if len(p.cols) == 0 {
+		return nil
+	}
+	return f.bulkImport(ctx, tx, p.rows, p.cols, &ImportOptions{Presorted: true})
 }
 
 // bulkImportMutex performs a bulk import on a fragment while ensuring
 // mutex restrictions. Because the mutex requirements must be checked
 // against storage, this method must acquire a write lock on the fragment
 // during the entire process, and it handles every bit independently.
-func (f *fragment) bulkImportMutex(tx Tx, rowIDs, columnIDs []uint64, options *ImportOptions) error {
+//
+// If options.Presorted is set, the input is trusted to have strictly
+// ascending column IDs and is not pruned or sorted. If it doesn't, a column
+// may end up with more than one row set, but only for the columns in this
+// batch, and storage is still written correctly (positions are sorted
+// regardless below).
+func (f *fragment) bulkImportMutex(ctx context.Context, tx Tx, rowIDs, columnIDs []uint64, options *ImportOptions) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	p := parallelSlices{cols: columnIDs, rows: rowIDs}
-	p.fullPrune()
-	columnIDs = p.cols
-	rowIDs = p.rows
+	if !options.Presorted {
+		p := parallelSlices{cols: columnIDs, rows: rowIDs}
+		p.fullPrune()
+		columnIDs = p.cols
+		rowIDs = p.rows
+	}
 
 	// create a mask of columns we care about
 	columns := roaring.NewSliceBitmap(columnIDs...)
@@ -1819,6 +3086,11 @@ func (f *fragment) bulkImportMutex(tx Tx, rowIDs, columnIDs []uint64, options *I
 	if unsorted {
 		sort.Slice(toSet, func(i, j int) bool { return toSet[i] < toSet[j] })
 	}
+	if f.maxSize() > 0 {
+		if err := f.checkImportSize(tx, positionsSizeBound(toSet)); err != nil {
+			return err
+		}
+	}
 
 	nextKey := toSet[0] >> 16
 	scratchContainer := roaring.NewContainerArray([]uint16{})
@@ -1892,10 +3164,16 @@ func (f *fragment) bulkImportMutex(tx Tx, rowIDs, columnIDs []uint64, options *I
 		return nil
 	})
 
+	// The rewrite is applied in a single pass, so this is the last chance
+	// to stop.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	err := tx.ApplyRewriter(f.index(), f.field(), f.view(), f.shard, 0, rewriteExisting)
 	if err != nil {
 		return err
 	}
+	f.recordRowsChanged(tx, rowSet)
 	return f.updateCaching(tx, rowSet)
 }
 
@@ -1908,8 +3186,16 @@ func (f *fragment) ClearRecords(tx Tx, recordIDs []uint64) (bool, error) {
 }
 
 func (f *fragment) clearRecordsByBitmap(tx Tx, columns *roaring.Bitmap) (changed bool, err error) {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	if err := f.unprotectedFlushWriteBuffer(tx); err != nil {
+		return false, err
+	}
 	return f.unprotectedClearRecordsByBitmap(tx, columns)
 }
 
@@ -1927,8 +3213,17 @@ func (f *fragment) unprotectedClearRecordsByBitmap(tx Tx, columns *roaring.Bitma
 		if existing == 0 {
 			return nil
 		}
+		var before *roaring.Container
+		if f.tombstones != nil {
+			before = data.Clone()
+		}
 		data = data.DifferenceInPlace(filter)
 		if data.N() != existing {
+			if before != nil {
+				for _, v := range before.Difference(data).Slice() {
+					f.tombstones.add(uint64(key)<<16 | uint64(v))
+				}
+			}
 			rowSet[key.Row()] = struct{}{}
 			changed = true
 			return writeback(key, data)
@@ -1943,8 +3238,19 @@ func (f *fragment) unprotectedClearRecordsByBitmap(tx Tx, columns *roaring.Bitma
 	return changed, f.updateCaching(tx, rowSet)
 }
 
-// importValue bulk imports a set of range-encoded values.
-func (f *fragment) importValue(tx Tx, columnIDs []uint64, values []int64, bitDepth uint64, clear bool) error {
+// importValue bulk imports a set of range-encoded values. If ctx is
+// canceled, the import stops between batches and returns the context's
+// error. If clear is true, the columns' values are cleared instead, and
+// values are ignored.
+func (f *fragment) importValue(ctx context.Context, tx Tx, columnIDs []uint64, values []int64, bitDepth uint64, clear bool) error {
+	span, _ := f.startImportSpan(ctx, "fragment.importValue")
+	defer span.Finish()
+	span.LogKV("values", len(columnIDs), "bitDepth", bitDepth)
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
@@ -1971,6 +3277,9 @@ func (f *fragment) importValue(tx Tx, columnIDs []uint64, values []int64, bitDep
 	// we will encounter.
 	prev := columnIDs[len(columnIDs)-1] + 1
 	for len(columnIDs) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		downTo := len(columnIDs) - batchSize
 		if downTo < 0 {
 			downTo = 0
@@ -1988,12 +3297,16 @@ func (f *fragment) importValue(tx Tx, columnIDs []uint64, values []int64, bitDep
 			prev = columnID
 			row = 0
 			if clear {
-				toClearByDepth[row]--
-				positionsByDepth[row][toClearByDepth[row]] = columnID
-			} else {
-				positionsByDepth[row][toSetByDepth[row]] = columnID
-				toSetByDepth[row]++
+				// Clear the exists bit, the sign bit, and every value bit.
+				for ; row < len(positionsByDepth); row++ {
+					toClearByDepth[row]--
+					positionsByDepth[row][toClearByDepth[row]] = columnID
+					columnID += ShardWidth
+				}
+				continue
 			}
+			positionsByDepth[row][toSetByDepth[row]] = columnID
+			toSetByDepth[row]++
 			row++
 			columnID += ShardWidth
 			if value < 0 {
@@ -2020,37 +3333,361 @@ func (f *fragment) importValue(tx Tx, columnIDs []uint64, values []int64, bitDep
 			}
 		}
 
-		for i := range positionsByDepth {
-			err := f.importPositions(tx, positionsByDepth[i][:toSetByDepth[i]], positionsByDepth[i][toClearByDepth[i]:], nil)
-			if err != nil {
-				return errors.Wrap(err, "importing positions")
-			}
+		for i := range positionsByDepth {
+			toSet := positionsByDepth[i][:toSetByDepth[i]]
+			f.growSizeBound(func() (int64, error) { return positionsSizeBound(toSet), nil })
+			err := f.importPositions(tx, toSet, positionsByDepth[i][toClearByDepth[i]:], nil)
+			if err != nil {
+				return errors.Wrap(err, "importing positions")
+			}
+		}
+		columnIDs = columnIDs[:downTo]
+	}
+
+	return nil
+}
+
+// importRoaringValues imports values encoded as roaring data in the BSI
+// layout, with an exists row, a sign row, and a row for each bit of the
+// values' magnitudes, as the fragment stores them. It's importValue for
+// data which is already encoded. The values of the columns in the data's
+// exists row replace their existing values. If clear is set, the values of
+// those columns are cleared instead, whatever the data's other rows hold.
+//
+// The data is rejected, without changes, if it has bits in rows too deep for
+// the field's range, or bits in any other row for columns which aren't in
+// its exists row.
+func (f *fragment) importRoaringValues(ctx context.Context, tx Tx, data []byte, clear bool) error {
+	bsig := f.fld.bsiGroup(f.field())
+	if bsig == nil {
+		return errors.Errorf("field %s has no BSI group", f.field())
+	}
+	maxDepth := bitDepthInt64(bsig.Min - bsig.Base)
+	if d := bitDepthInt64(bsig.Max - bsig.Base); d > maxDepth {
+		maxDepth = d
+	}
+
+	const rowContainers = 1 << shardVsContainerExponent
+	citer, err := roaring.NewContainerIterator(data)
+	if err != nil {
+		return errors.Wrap(err, "getting container iterator")
+	}
+	exists := make(map[uint64]*roaring.Container, rowContainers)
+	var depth uint64
+	for citer.Next() {
+		key, c := citer.Value()
+		if c.N() == 0 {
+			continue
+		}
+		rowID := key / rowContainers
+		if rowID == bsiExistsBit {
+			exists[key] = c.Clone()
+			continue
+		} else if rowID >= bsiOffsetBit+maxDepth {
+			citer.Close()
+			return errors.Errorf("value data has bit-slice row %d, deeper than the field's bit depth of %d", rowID, maxDepth)
+		} else if e := exists[key%rowContainers]; e == nil || roaring.IntersectionCount(c, e) != c.N() {
+			citer.Close()
+			return errors.Errorf("value data has bits in row %d for columns without an exists bit", rowID)
+		}
+		if rowID >= bsiOffsetBit && rowID-bsiOffsetBit+1 > depth {
+			depth = rowID - bsiOffsetBit + 1
+		}
+	}
+	citer.Close()
+
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return err
+	}
+	if clear {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return errors.Wrap(f.ImportRoaringBSI(ctx, tx, data, nil), "clearing values")
+	}
+	f.mu.Lock()
+	err = f.ImportRoaringBSI(ctx, tx, nil, data)
+	f.mu.Unlock()
+	if err != nil {
+		return errors.Wrap(err, "importing values")
+	}
+	// Queries only read as many bit-slice rows as the field's bit depth.
+	return f.fld.cacheBitDepth(depth)
+}
+
+// bulkClearValues clears the values of the given columns, as importValue
+// does when clearing.
+func (f *fragment) bulkClearValues(ctx context.Context, tx Tx, columnIDs []uint64, bitDepth uint64) error {
+	if len(columnIDs) == 0 {
+		return nil
+	}
+	return f.importValue(ctx, tx, columnIDs, make([]int64, len(columnIDs)), bitDepth, true)
+}
+
+// clearValuesWhere clears the values of the columns in filter whose values
+// satisfy op and predicate, as for rangeOp, and returns how many were
+// cleared. A nil filter matches all columns. Columns without a value never
+// match, so they're left alone.
+func (f *fragment) clearValuesWhere(tx Tx, filter *Row, op pql.Token, bitDepth uint64, predicate int64) (uint64, error) {
+	row, err := f.rangeOpFiltered(tx, op, bitDepth, predicate, filter)
+	if err != nil {
+		return 0, errors.Wrap(err, "finding matching values")
+	}
+	columnIDs := row.Columns()
+	if err := f.bulkClearValues(context.Background(), tx, columnIDs, bitDepth); err != nil {
+		return 0, errors.Wrap(err, "clearing values")
+	}
+	return uint64(len(columnIDs)), nil
+}
+
+// clearValueEQ clears the value of every column whose value equals value,
+// which may be negative, and returns how many were cleared. Columns without
+// a value are left alone.
+func (f *fragment) clearValueEQ(tx Tx, bitDepth uint64, value int64) (uint64, error) {
+	return f.clearValuesWhere(tx, nil, pql.EQ, bitDepth, value)
+}
+
+// importValueConst sets every column in [lo, hi), given as offsets within
+// the shard, to value. Rather than encoding the value once per column like
+// importValue, each bit-slice row is set or cleared across the whole range
+// at once. hi is clamped to ShardWidth.
+func (f *fragment) importValueConst(tx Tx, lo, hi uint64, value int64, bitDepth uint64) error {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	if hi > ShardWidth {
+		hi = ShardWidth
+	}
+	if lo >= hi {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sizeBound.invalidate()
+
+	uvalue := uint64(value)
+	if value < 0 {
+		uvalue = uint64(-value)
+	}
+
+	cols := roaring.NewBitmap().Flip(lo, hi-1)
+	set, clear := roaring.NewBitmap(), roaring.NewBitmap()
+	addRow := func(b *roaring.Bitmap, rowID uint64) {
+		b.UnionInPlace(cols.OffsetRange(rowID*ShardWidth, 0, ShardWidth))
+	}
+	addRow(set, bsiExistsBit)
+	if value < 0 {
+		addRow(set, bsiSignBit)
+	} else {
+		addRow(clear, bsiSignBit)
+	}
+	for i := uint64(0); i < bitDepth; i++ {
+		if uvalue&(1<<i) != 0 {
+			addRow(set, bsiOffsetBit+i)
+		} else {
+			addRow(clear, bsiOffsetBit+i)
 		}
-		columnIDs = columnIDs[:downTo]
 	}
 
-	return nil
+	var setBuf, clearBuf bytes.Buffer
+	if _, err := set.WriteTo(&setBuf); err != nil {
+		return errors.Wrap(err, "writing set bitmap")
+	}
+	if _, err := clear.WriteTo(&clearBuf); err != nil {
+		return errors.Wrap(err, "writing clear bitmap")
+	}
+	setIter, err := roaring.NewContainerIterator(setBuf.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "getting set iterator")
+	}
+	clearIter, err := roaring.NewContainerIterator(clearBuf.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "getting clear iterator")
+	}
+	rewriter, err := roaring.NewClearAndSetRewriter(clearIter, setIter)
+	if err != nil {
+		return errors.Wrap(err, "getting rewriter")
+	}
+	return errors.Wrap(tx.ApplyRewriter(f.index(), f.field(), f.view(), f.shard, 0, rewriter), "applying rewriter")
 }
 
 // importRoaring imports from the official roaring data format defined at
 // https://github.com/RoaringBitmap/RoaringFormatSpec or from pilosa's version
 // of the roaring format. The cache is updated to reflect the new data.
 func (f *fragment) importRoaring(ctx context.Context, tx Tx, data []byte, clear bool) error {
-	span, ctx := tracing.StartSpanFromContext(ctx, "fragment.importRoaring")
+	return f.importRoaringWithOptions(ctx, tx, data, &ImportOptions{Clear: clear})
+}
+
+// importRoaringWithProgress is importRoaring, calling progress every
+// importProgressInterval containers, and once all of them have been
+// imported, so that long imports can report how far they've got.
+func (f *fragment) importRoaringWithProgress(ctx context.Context, tx Tx, data []byte, clear bool, progress func(importProgress)) error {
+	return f.importRoaringWithOptions(ctx, tx, data, &ImportOptions{Clear: clear, progress: progress})
+}
+
+// importProgressInterval is the number of containers a roaring import
+// processes between calls to its progress function.
+const importProgressInterval = 1024
+
+// importProgress describes how far through its data a roaring import is.
+type importProgress struct {
+	containers      int64 // containers imported so far
+	totalContainers int64 // containers in the data
+	bits            int64 // bits in the containers imported so far
+}
+
+// progressIterator is a RoaringIterator which counts the containers taken
+// from it with NextContainer, calling fn every importProgressInterval of
+// them and once it's exhausted.
+type progressIterator struct {
+	roaring.RoaringIterator
+	fn       func(importProgress)
+	progress importProgress
+	done     bool
+}
+
+func (p *progressIterator) NextContainer() (uint64, *roaring.Container) {
+	key, c := p.RoaringIterator.NextContainer()
+	if c == nil {
+		if !p.done && p.progress.containers%importProgressInterval != 0 {
+			p.fn(p.progress)
+		}
+		p.done = true
+		return key, c
+	}
+	p.progress.containers++
+	p.progress.bits += int64(c.N())
+	if p.progress.containers%importProgressInterval == 0 {
+		p.fn(p.progress)
+	}
+	return key, c
+}
+
+// importRoaringWithOptions is importRoaring, clearing if options.Clear is
+// set, and skipping cache maintenance if options.SkipCacheUpdate is set.
+func (f *fragment) importRoaringWithOptions(ctx context.Context, tx Tx, data []byte, options *ImportOptions) error {
+	clear := options.Clear
+	span, ctx := f.startImportSpan(ctx, "fragment.importRoaring")
 	defer span.Finish()
+	span.LogKV("bytes", len(data))
+	changed := 0
+	start := f.slowImportStart()
+	defer func() { f.logSlowImport("importRoaring", start, changed) }()
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	release, err := f.holder.acquireImport(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
 
-	rowSet, updateCache, err := f.doImportRoaring(ctx, tx, data, clear)
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return err
+	}
+	if data, err = f.applyRoaringTombstones(data, clear); err != nil {
+		return err
+	}
+	if options.SkipCacheUpdate || f.cacheRecalculateInterval > 0 {
+		if err := f.markCacheStale(); err != nil {
+			return err
+		}
+	}
+	rowSet, updateCache, err := f.doImportRoaring(ctx, tx, data, clear, options.progress)
 	if err != nil {
 		return errors.Wrap(err, "doImportRoaring")
 	}
+	for rowID, changes := range rowSet {
+		if changes != 0 {
+			f.recordRowChanged(tx, rowID)
+		}
+		changed += changes
+	}
+	span.LogKV("changed", changed)
 	if updateCache {
-		return f.updateCachePostImport(ctx, rowSet)
+		return f.updateCachePostImport(tx, rowSet)
 	}
 	return nil
 }
 
+// startImportSpan starts a span for an import into the fragment, logging
+// which fragment it is.
+func (f *fragment) startImportSpan(ctx context.Context, operationName string) (tracing.Span, context.Context) {
+	span, ctx := tracing.StartSpanFromContext(ctx, operationName)
+	span.LogKV("index", f.index(), "field", f.field(), "view", f.view(), "shard", f.shard)
+	return span, ctx
+}
+
+// slowImportStart returns the time an import starts, for logSlowImport. If
+// slow imports aren't logged, it returns the zero time without reading the
+// clock.
+func (f *fragment) slowImportStart() time.Time {
+	if f.holder == nil || f.holder.cfg == nil || f.holder.cfg.SlowImportThreshold <= 0 {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// logSlowImport logs a warning if an import which started at start, as
+// returned by slowImportStart, took longer than the holder's
+// SlowImportThreshold.
+func (f *fragment) logSlowImport(op string, start time.Time, bits int) {
+	if start.IsZero() {
+		return
+	}
+	if d := time.Since(start); d > f.holder.cfg.SlowImportThreshold {
+		f.holder.Logger.Warnf("slow %s: index=%s field=%s view=%s shard=%d bits=%d duration=%s", op, f.index(), f.field(), f.view(), f.shard, bits, d)
+	}
+}
+
+// importRoaringIntoView imports roaring data into the fragment for the same
+// shard in another view of the same field, creating the view and fragment if
+// they don't exist. Positions are preserved, and the target fragment's cache
+// is updated as for importRoaring. tx must be a transaction on this
+// fragment's shard.
+func (f *fragment) importRoaringIntoView(ctx context.Context, tx Tx, viewName string, data []byte) error {
+	fld := f.idx.Field(f.field())
+	if fld == nil {
+		return newNotFoundError(ErrFieldNotFound, f.field())
+	}
+	view, err := fld.createViewIfNotExists(viewName)
+	if err != nil {
+		return errors.Wrap(err, "creating target view")
+	}
+	target, err := view.CreateFragmentIfNotExists(f.shard)
+	if err != nil {
+		return errors.Wrap(err, "creating target fragment")
+	}
+	return target.importRoaring(ctx, tx, data, false)
+}
+
 // ImportRoaringClearAndSet simply clears the bits in clear and sets the bits in set.
 func (f *fragment) ImportRoaringClearAndSet(ctx context.Context, tx Tx, clear, set []byte) error {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.addRoaringTombstones(clear); err != nil {
+		return errors.Wrap(err, "recording tombstones")
+	}
+	set, err = f.filterRoaringTombstones(set)
+	if err != nil {
+		return errors.Wrap(err, "filtering tombstones")
+	}
+	f.growSizeBound(func() (int64, error) { return roaringSizeBound(set) })
 	clearIter, err := roaring.NewContainerIterator(clear)
 	if err != nil {
 		return errors.Wrap(err, "getting clear iterator")
@@ -2074,8 +3711,6 @@ func (f *fragment) ImportRoaringClearAndSet(ctx context.Context, tx Tx, clear, s
 		// importRoaring does it as it tracks the number of bits
 		// changed per row. We could do that, but I think it'd require
 		// significant changes to the Rewriter API.
-		f.mu.Lock()
-		defer f.mu.Unlock()
 		return f.rebuildRankCache(ctx, tx)
 	}
 	return nil
@@ -2085,6 +3720,12 @@ func (f *fragment) ImportRoaringClearAndSet(ctx context.Context, tx Tx, clear, s
 // records to be cleared, and "set" as specifying the values to be set
 // which implies clearing any other values in those columns.
 func (f *fragment) ImportRoaringBSI(ctx context.Context, tx Tx, clear, set []byte) error {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	// In this first block, we take the first row of clear as records
 	// we want to unconditionally clear, and the first row of set as
 	// records we also want to clear because they're going to get set
@@ -2098,6 +3739,7 @@ func (f *fragment) ImportRoaringBSI(ctx context.Context, tx Tx, clear, set []byt
 		return errors.Wrap(err, "getting set/clear iterator")
 	}
 	clearIter := roaring.NewUnionContainerIterator(clearclearIter, setClearIter)
+	f.growSizeBound(func() (int64, error) { return roaringSizeBound(set) })
 
 	// Then we get the set iterator and create the rewriter.
 	setIter, err := roaring.NewContainerIterator(set)
@@ -2119,6 +3761,12 @@ func (f *fragment) ImportRoaringBSI(ctx context.Context, tx Tx, clear, set []byt
 // "set" as the existence row to also be cleared. Essentially it's for
 // FieldTypeMutex.
 func (f *fragment) ImportRoaringSingleValued(ctx context.Context, tx Tx, clear, set []byte) error {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	f.growSizeBound(func() (int64, error) { return roaringSizeBound(set) })
 	clearIter, err := roaring.NewRepeatedRowIteratorFromBytes(clear)
 	if err != nil {
 		return errors.Wrap(err, "getting cleariterator")
@@ -2137,13 +3785,30 @@ func (f *fragment) ImportRoaringSingleValued(ctx context.Context, tx Tx, clear,
 	return errors.Wrap(err, "pilosa.ImportRoaringSingleValued: ")
 }
 
-func (f *fragment) doImportRoaring(ctx context.Context, tx Tx, data []byte, clear bool) (map[uint64]int, bool, error) {
+func (f *fragment) doImportRoaring(ctx context.Context, tx Tx, data []byte, clear bool, progress func(importProgress)) (map[uint64]int, bool, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	rowSize := uint64(1 << shardVsContainerExponent)
 	span, _ := tracing.StartSpanFromContext(ctx, "importRoaring.ImportRoaringBits")
 	defer span.Finish()
 
+	if !clear {
+		var err error
+		if data, err = f.checkImportRows(data); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if !clear && f.maxSize() > 0 {
+		delta, err := roaringSizeBound(data)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "estimating import size")
+		}
+		if err := f.checkImportSize(tx, delta); err != nil {
+			return nil, false, err
+		}
+	}
+
 	var rowSet map[uint64]int
 	err := func() (err error) {
 		var rit roaring.RoaringIterator
@@ -2151,6 +3816,9 @@ func (f *fragment) doImportRoaring(ctx context.Context, tx Tx, data []byte, clea
 		if err != nil {
 			return err
 		}
+		if progress != nil {
+			rit = &progressIterator{RoaringIterator: rit, fn: progress, progress: importProgress{totalContainers: rit.Len()}}
+		}
 
 		_, rowSet, err = tx.ImportRoaringBits(f.index(), f.field(), f.view(), f.shard, rit, clear, true, rowSize)
 		return err
@@ -2158,38 +3826,74 @@ func (f *fragment) doImportRoaring(ctx context.Context, tx Tx, data []byte, clea
 	if err != nil {
 		return nil, false, err
 	}
+	if !clear && f.runThreshold > 0 {
+		keys, err := roaringContainerKeys(data)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := f.applyRunThreshold(tx, keys); err != nil {
+			return nil, false, err
+		}
+	}
 
 	updateCache := f.CacheType != CacheTypeNone
 	return rowSet, updateCache, err
 }
 
-func (f *fragment) updateCachePostImport(ctx context.Context, rowSet map[uint64]int) error {
+// updateCachePostImport updates the cache entries of the rows which an
+// import changed. rowSet maps row IDs to the number of bits changed.
+func (f *fragment) updateCachePostImport(tx Tx, rowSet map[uint64]int) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	anyChanged := false
 
+	changed := make(map[uint64]struct{}, len(rowSet))
 	for rowID, changes := range rowSet {
-		if changes == 0 {
-			continue
-		}
-		anyChanged = true
-		if changes < 0 {
-			absChanges := uint64(-1 * changes)
-			if absChanges <= f.cache.Get(rowID) {
-				f.cache.BulkAdd(rowID, f.cache.Get(rowID)-absChanges)
-			} else {
-				f.cache.BulkAdd(rowID, 0)
-			}
-		} else {
-			f.cache.BulkAdd(rowID, f.cache.Get(rowID)+uint64(changes))
+		if changes != 0 {
+			changed[rowID] = struct{}{}
 		}
 	}
-	// we only set this if we need to update the cache
-	if anyChanged {
-		f.cache.Invalidate()
+	return f.unprotectedUpdateCacheRows(tx, changed)
+}
+
+// mergeFrom adds the bits of src, read with srcTx, to the fragment. As for
+// importRoaring, every row the merge changed is recounted for the cache,
+// since src's cache, which may omit rows src ranked below its capacity,
+// can't say which rows those are.
+func (f *fragment) mergeFrom(ctx context.Context, tx, srcTx Tx, src *fragment) error {
+	done, err := f.holder.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	release, err := f.holder.acquireImport(ctx)
+	if err != nil {
+		return err
 	}
+	defer release()
 
-	return nil
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return err
+	}
+	src.mu.RLock()
+	bm, err := srcTx.RoaringBitmap(src.index(), src.field(), src.view(), src.shard)
+	src.mu.RUnlock()
+	if err != nil {
+		return errors.Wrap(err, "reading source fragment")
+	}
+	var buf bytes.Buffer
+	if _, err := bm.WriteTo(&buf); err != nil {
+		return errors.Wrap(err, "writing source bitmap")
+	}
+	rowSet, _, err := f.doImportRoaring(ctx, tx, buf.Bytes(), false, nil)
+	if err != nil {
+		return errors.Wrap(err, "doImportRoaring")
+	}
+	for rowID, changes := range rowSet {
+		if changes != 0 {
+			f.recordRowChanged(tx, rowID)
+		}
+	}
+	return f.updateCachePostImport(tx, rowSet)
 }
 
 // importRoaringOverwrite overwrites the specified block with the provided data.
@@ -2204,10 +3908,74 @@ func (f *fragment) importRoaringOverwrite(ctx context.Context, tx Tx, data []byt
 }
 
 // RecalculateCache rebuilds the cache regardless of invalidate time delay.
+// If an import skipped cache maintenance, the cache's counts are first
+// rebuilt from storage.
 func (f *fragment) RecalculateCache() {
 	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cacheStale {
+		if err := f.rebuildStaleCache(context.Background()); err != nil {
+			f.holder.Logger.Errorf("fragment: error rebuilding stale cache: err=%s, path=%s", err, f.path())
+			return
+		}
+	}
 	f.cache.Recalculate()
-	f.mu.Unlock()
+}
+
+// markCacheStale marks the cache as out of date with storage, if the
+// fragment has one, so that imports stop maintaining it until
+// RecalculateCache rebuilds it.
+func (f *fragment) markCacheStale() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.unprotectedMarkCacheStale()
+}
+
+// unprotectedMarkCacheStale is markCacheStale for callers holding f.mu.
+// When the cache becomes stale, it's flushed, so the cache file records
+// that it's stale and a restart rebuilds it rather than trusting it.
+func (f *fragment) unprotectedMarkCacheStale() error {
+	if f.CacheType == CacheTypeNone || f.cacheStale {
+		return nil
+	}
+	f.cacheStale = true
+	return errors.Wrap(f.flushCache(), "flushing stale cache")
+}
+
+// unprotectedDeferCacheUpdate returns true if the cache is recalculated
+// periodically, rather than maintained on write, marking it stale so the
+// next recalculation rebuilds it.
+func (f *fragment) unprotectedDeferCacheUpdate() (bool, error) {
+	if f.cacheRecalculateInterval <= 0 {
+		return false, nil
+	}
+	return true, f.unprotectedMarkCacheStale()
+}
+
+// rebuildStaleCache replaces the cache's counts with the row counts in
+// storage, in a new read transaction, and clears cacheStale. Unlike
+// rebuildRankCache, it rebuilds caches of any type.
+func (f *fragment) rebuildStaleCache(ctx context.Context) error {
+	tx, err := f.holder.BeginTx(false, f.idx, f.shard)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := f.unprotectedRows(ctx, tx, uint64(0))
+	if err != nil {
+		return err
+	}
+	f.cache.Clear()
+	for _, id := range rows {
+		n, err := tx.CountRange(f.index(), f.field(), f.view(), f.shard, id*ShardWidth, (id+1)*ShardWidth)
+		if err != nil {
+			return errors.Wrap(err, "CountRange")
+		}
+		f.cache.BulkAdd(id, n)
+	}
+	f.cacheStale = false
+	return nil
 }
 
 // FlushCache writes the cache data to disk.
@@ -2265,7 +4033,7 @@ func (f *fragment) flushCache() error {
 	ids := f.cache.IDs()
 
 	// Marshal cache data to bytes.
-	buf, err := proto.Marshal(&pb.Cache{IDs: ids})
+	buf, err := proto.Marshal(&pb.Cache{IDs: ids, Stale: f.cacheStale})
 	if err != nil {
 		return errors.Wrap(err, "marshalling")
 	}
@@ -2399,6 +4167,35 @@ func (f *fragment) ReadFrom(r io.Reader) (n int64, err error) {
 	return 0, nil
 }
 
+// readDataFromArchive adds the bits in a fragment archive, as written by
+// WriteTo, to the fragment within tx, which the caller commits. The
+// archive's cache is skipped, since it doesn't count the bits the fragment
+// already had; the caller should rebuild the cache once tx is committed.
+func (f *fragment) readDataFromArchive(tx Tx, r io.Reader) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "opening")
+		}
+
+		switch hdr.Name {
+		case "data":
+			if err := f.fillFragmentFromArchive(tx, tr); err != nil {
+				return errors.Wrap(err, "reading storage")
+			}
+		case "cache":
+		default:
+			return fmt.Errorf("invalid fragment archive file: %s", hdr.Name)
+		}
+	}
+}
+
 // should be morally equivalent to fragment.readStorageFromArchive()
 // below for RoaringTx, but also work on any Tx because it uses
 // tx.ImportRoaringBits().
@@ -2413,6 +4210,7 @@ func (f *fragment) fillFragmentFromArchive(tx Tx, r io.Reader) error {
 	if len(data) == 0 {
 		return nil
 	}
+	f.growSizeBound(func() (int64, error) { return roaringSizeBound(data) })
 
 	// For reference, compare to what fragment.go:313 fragment.importStorage() does.
 
@@ -2463,6 +4261,9 @@ func (f *fragment) minRowID(tx Tx) (uint64, bool, error) {
 // this container have been processed. The rows accumulated up to this point
 // (including this row if all filters passed) will be returned.
 func (f *fragment) rows(ctx context.Context, tx Tx, start uint64, filters ...roaring.BitmapFilter) ([]uint64, error) {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return nil, err
+	}
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	return f.unprotectedRows(ctx, tx, start, filters...)
@@ -2475,21 +4276,67 @@ func (f *fragment) unprotectedRows(ctx context.Context, tx Tx, start uint64, fil
 		rows = append(rows, row)
 		return nil
 	}
-	startKey := rowToKey(start)
-	filter := roaring.NewBitmapRowFilter(cb, filters...)
-	err := tx.ApplyFilter(f.index(), f.field(), f.view(), f.shard, startKey, filter)
-	if err != nil {
+	if err := f.unprotectedRowsStream(ctx, tx, start, cb, filters...); err != nil {
 		return nil, err
-	} else {
-		return rows, nil
 	}
+	return rows, nil
+}
+
+// rowsStream is like rows, but calls fn with each row ID in order instead of
+// collecting them, so memory use doesn't grow with the number of rows. If
+// fn returns an error, or ctx is cancelled, iteration stops and the error
+// is returned.
+func (f *fragment) rowsStream(ctx context.Context, tx Tx, start uint64, fn func(rowID uint64) error, filters ...roaring.BitmapFilter) error {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.unprotectedRowsStream(ctx, tx, start, fn, filters...)
+}
+
+// unprotectedRowsStream calls rowsStream without grabbing the mutex.
+func (f *fragment) unprotectedRowsStream(ctx context.Context, tx Tx, start uint64, fn func(rowID uint64) error, filters ...roaring.BitmapFilter) error {
+	cb := func(row uint64) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(row)
+	}
+	filter := roaring.NewBitmapRowFilter(cb, filters...)
+	return tx.ApplyFilter(f.index(), f.field(), f.view(), f.shard, rowToKey(start), filter)
 }
 
-// unionRows yields the union of the given rows in this fragment
+// unionRows yields the union of the given rows in this fragment. The rows'
+// containers are accumulated in a single pass over storage, so rows may be
+// given in any order and may repeat; no rows yields an empty row.
 func (f *fragment) unionRows(ctx context.Context, tx Tx, rows []uint64) (*Row, error) {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return nil, err
+	}
 	f.mu.RLock()
 	defer f.mu.RUnlock()
-	return f.unprotectedUnionRows(ctx, tx, rows)
+	return f.unprotectedUnionRows(ctx, tx, sortedUniqueRows(rows))
+}
+
+// sortedUniqueRows returns rows sorted and without duplicates, as
+// BitmapRowsUnion requires. rows is only copied if it isn't already.
+func sortedUniqueRows(rows []uint64) []uint64 {
+	for i := 1; i < len(rows); i++ {
+		if rows[i] <= rows[i-1] {
+			out := append([]uint64(nil), rows...)
+			sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+			n := 1
+			for _, row := range out[1:] {
+				if row != out[n-1] {
+					out[n] = row
+					n++
+				}
+			}
+			return out[:n]
+		}
+	}
+	return rows
 }
 
 // unprotectedRows calls rows without grabbing the mutex.
@@ -2511,6 +4358,62 @@ func (f *fragment) unprotectedUnionRows(ctx context.Context, tx Tx, rows []uint6
 	}
 }
 
+// rowsExactlyK returns the columns which are set in exactly k of the given
+// rows. Duplicate row IDs are counted once. The result is drawn only from
+// columns set in at least one of the rows, so k <= 0 yields an empty row;
+// callers wanting the columns set in none of the rows should take the
+// difference of an existence row and the union of the rows instead. If k
+// exceeds the number of distinct rows, the result is empty.
+func (f *fragment) rowsExactlyK(tx Tx, rowIDs []uint64, k int) (*Row, error) {
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return nil, err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	seen := make(map[uint64]struct{}, len(rowIDs))
+	// counts is a bit-sliced per-column counter: a column's count is the
+	// binary number whose i'th bit is set if the column is in counts[i].
+	var counts []*Row
+	for _, rowID := range rowIDs {
+		if _, ok := seen[rowID]; ok {
+			continue
+		}
+		seen[rowID] = struct{}{}
+
+		carry, err := f.unprotectedRow(tx, rowID)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(counts) && carry.Any(); i++ {
+			next := counts[i].Intersect(carry)
+			counts[i] = counts[i].Xor(carry)
+			carry = next
+		}
+		if carry.Any() {
+			counts = append(counts, carry)
+		}
+	}
+	if k <= 0 || k > len(seen) {
+		return NewRow(), nil
+	}
+
+	// Start from every column with a non-zero count, then keep only those
+	// whose count bits match k.
+	result := NewRow().Union(counts...)
+	for i, bits := range counts {
+		if k&(1<<uint(i)) != 0 {
+			result = result.Intersect(bits)
+		} else {
+			result = result.Difference(bits)
+		}
+	}
+	if k>>uint(len(counts)) != 0 {
+		return NewRow(), nil
+	}
+	return result, nil
+}
+
 type rowIterator interface {
 	// TODO(kuba) linter suggests to use io.Seeker
 	// Seek(offset int64, whence int) (int64, error)
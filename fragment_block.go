@@ -0,0 +1,148 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash"
+
+	"github.com/featurebasedb/featurebase/v3/roaring"
+	"github.com/pkg/errors"
+)
+
+// A block is the HashBlockSize rows starting at block*HashBlockSize. Blocks
+// are the unit in which replicas compare and repair fragments: each side
+// computes a checksum per block, and a block which differs is replaced with
+// the authoritative replica's data for it.
+
+// blockContainerKeys returns the range of container keys, [start, end), of
+// a block's rows.
+func blockContainerKeys(block int) (start, end uint64) {
+	start = uint64(block) * HashBlockSize << shardVsContainerExponent
+	return start, start + HashBlockSize<<shardVsContainerExponent
+}
+
+// blockContainers calls fn for each non-empty container in a block, in
+// ascending key order.
+func (f *fragment) blockContainers(tx Tx, block int, fn func(key uint64, c *roaring.Container)) error {
+	start, end := blockContainerKeys(block)
+	citer, _, err := tx.ContainerIterator(f.index(), f.field(), f.view(), f.shard, start)
+	if err != nil {
+		return errors.Wrapf(err, "getting container iterator for block %d", block)
+	}
+	defer citer.Close()
+	for citer.Next() {
+		key, c := citer.Value()
+		if key >= end {
+			break
+		} else if c.N() == 0 {
+			continue
+		}
+		fn(key, c)
+	}
+	return nil
+}
+
+// blockData returns a block's bits as roaring data, which applyBlockPatch
+// can apply to another replica of the fragment.
+func (f *fragment) blockData(tx Tx, block int) ([]byte, error) {
+	if block < 0 {
+		return nil, errors.Errorf("invalid block: %d", block)
+	}
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return nil, err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	bm := roaring.NewBitmap()
+	if err := f.blockContainers(tx, block, func(key uint64, c *roaring.Container) {
+		bm.Put(key, c.Clone())
+	}); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := bm.WriteTo(&buf); err != nil {
+		return nil, errors.Wrap(err, "writing block data")
+	}
+	return buf.Bytes(), nil
+}
+
+// blockChecksum returns a checksum of the bits set in a block. It depends
+// only on which bits are set, not on how their containers are encoded, so
+// replicas with the same bits have the same checksums.
+func (f *fragment) blockChecksum(tx Tx, block int) ([]byte, error) {
+	if block < 0 {
+		return nil, errors.Errorf("invalid block: %d", block)
+	}
+	if err := f.flushWriteBuffer(tx); err != nil {
+		return nil, err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	h := sha1.New()
+	if err := f.blockContainers(tx, block, func(key uint64, c *roaring.Container) {
+		hashContainer(h, key, c)
+	}); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// roaringChecksum returns a checksum of the bits set in roaring data, in
+// the same way as blockChecksum, so that it doesn't depend on the data's
+// container encodings.
+func roaringChecksum(data []byte) ([]byte, error) {
+	citer, err := roaring.NewContainerIterator(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting container iterator")
+	}
+	defer citer.Close()
+	h := sha1.New()
+	for citer.Next() {
+		if key, c := citer.Value(); c.N() > 0 {
+			hashContainer(h, key, c)
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// hashContainer writes a container's key and values to h.
+func hashContainer(h hash.Hash, key uint64, c *roaring.Container) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], key)
+	h.Write(buf[:]) //nolint:errcheck
+	roaring.ContainerCallback(c, func(v uint16) {
+		binary.BigEndian.PutUint16(buf[:2], v)
+		h.Write(buf[:2]) //nolint:errcheck
+	})
+}
+
+// applyBlockPatch replaces a block's bits with the roaring data for that
+// block from an authoritative replica, as returned by its blockData. Every
+// bit in data must be in the block's rows; otherwise nothing is changed.
+// The block is cleared and the patch imported in tx, so a failed patch is
+// rolled back with tx.
+func (f *fragment) applyBlockPatch(tx Tx, blockID int, data []byte) error {
+	if blockID < 0 {
+		return errors.Errorf("invalid block: %d", blockID)
+	}
+	start, end := blockContainerKeys(blockID)
+	citer, err := roaring.NewContainerIterator(data)
+	if err != nil {
+		return errors.Wrap(err, "getting container iterator")
+	}
+	for citer.Next() {
+		if key, c := citer.Value(); c.N() > 0 && (key < start || key >= end) {
+			citer.Close()
+			return errors.Errorf("patch for block %d has bits in row %d", blockID, key>>shardVsContainerExponent)
+		}
+	}
+	citer.Close()
+
+	return errors.Wrapf(f.importRoaringOverwrite(context.Background(), tx, data, blockID), "patching block %d", blockID)
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Ensure a divergent block can be patched from another replica's block
+// data, after which their checksums match.
+func TestFragment_ApplyBlockPatch(t *testing.T) {
+	a, _, atx := mustOpenFragment(t)
+	defer a.Clean(t)
+	b, _, btx := mustOpenFragment(t)
+	defer b.Clean(t)
+
+	// Both replicas agree on blocks 0 and 2, but not on block 1.
+	a.mustSetBits(atx, 5, 1, 2, 3)
+	b.mustSetBits(btx, 5, 1, 2, 3)
+	a.mustSetBits(atx, 250, 7)
+	b.mustSetBits(btx, 250, 7)
+	a.mustSetBits(atx, 100, 1, 2)
+	a.mustSetBits(atx, 150, 9)
+	b.mustSetBits(btx, 100, 2, 3, 70000)
+	for col := uint64(0); col < 5000; col++ {
+		b.mustSetBits(btx, 199, col)
+	}
+
+	checksums := func(block int) (x, y []byte) {
+		x, err := a.blockChecksum(atx, block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		y, err = b.blockChecksum(btx, block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return x, y
+	}
+	if x, y := checksums(0); !bytes.Equal(x, y) {
+		t.Fatal("expected block 0 checksums to match")
+	} else if x, y := checksums(1); bytes.Equal(x, y) {
+		t.Fatal("expected block 1 checksums to differ")
+	}
+
+	// A patch with bits outside its block is rejected without changes.
+	if err := a.applyBlockPatch(atx, 1, roaringBytes(t, 100*ShardWidth+5, 200*ShardWidth)); err == nil {
+		t.Fatal("expected error for patch outside block")
+	} else if !a.mustRow(atx, 100).Any() {
+		t.Fatal("expected rejected patch not to clear the block")
+	}
+	if err := a.applyBlockPatch(atx, -1, nil); err == nil {
+		t.Fatal("expected error for negative block")
+	}
+
+	data, err := b.blockData(btx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.applyBlockPatch(atx, 1, data); err != nil {
+		t.Fatal(err)
+	}
+	for block := 0; block < 3; block++ {
+		if x, y := checksums(block); !bytes.Equal(x, y) {
+			t.Fatalf("expected block %d checksums to match after patch", block)
+		}
+	}
+	if a.mustRow(atx, 150).Any() {
+		t.Fatal("expected patch to clear row missing from the replica")
+	} else if n := a.mustRow(atx, 199).Count(); n != 5000 {
+		t.Fatalf("expected patched row to have 5000 bits, got %d", n)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/featurebasedb/featurebase/v3/roaring"
+	"github.com/pkg/errors"
+)
+
+// maxImportRowID returns the configured maximum row ID for roaring imports,
+// or zero if it's unlimited, and whether bits above it should be dropped
+// rather than rejected.
+func (f *fragment) maxImportRowID() (max uint64, clamp bool) {
+	if f.holder == nil || f.holder.cfg == nil || f.holder.cfg.StorageConfig == nil {
+		return 0, false
+	}
+	cfg := f.holder.cfg.StorageConfig
+	return cfg.MaxImportRowID, cfg.ClampImportRows
+}
+
+// checkImportRows checks that roaring encoded data has no bits above the
+// maximum row ID for imports. Positions are shard-relative, so a container
+// key implies a row, and since no container spans two rows, only the
+// highest key in the data's header needs checking. If there are higher
+// rows, it returns an error wrapping ErrImportRowOutOfRange, or, if
+// clamping, a copy of the data without them. Otherwise it returns data.
+func (f *fragment) checkImportRows(data []byte) ([]byte, error) {
+	max, clamp := f.maxImportRowID()
+	if max == 0 {
+		return data, nil
+	}
+	rit, err := roaring.NewRoaringIterator(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting roaring iterator")
+	}
+	keys := rit.ContainerKeys()
+	if len(keys) == 0 || keys[len(keys)-1]>>shardVsContainerExponent <= max {
+		return data, nil
+	}
+	row := keys[len(keys)-1] >> shardVsContainerExponent
+	if !clamp {
+		return nil, errors.Wrapf(ErrImportRowOutOfRange, "%s/%s/%s/%d: row %d exceeds %d",
+			f.index(), f.field(), f.view(), f.shard, row, max)
+	}
+
+	f.holder.Logger.Warnf("%s/%s/%s/%d: dropping imported bits in rows above %d, up to row %d",
+		f.index(), f.field(), f.view(), f.shard, max, row)
+	citer, err := roaring.NewContainerIterator(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting container iterator")
+	}
+	defer citer.Close()
+	bm := roaring.NewBitmap()
+	for citer.Next() {
+		key, c := citer.Value()
+		if key>>shardVsContainerExponent > max {
+			break
+		}
+		bm.Put(key, c)
+	}
+	var buf bytes.Buffer
+	if _, err := bm.WriteTo(&buf); err != nil {
+		return nil, errors.Wrap(err, "writing clamped import")
+	}
+	return buf.Bytes(), nil
+}
+
+// importValidation describes what an import would write to a fragment.
+type importValidation struct {
+	// bits is the number of distinct positions the import would set or
+	// clear, whether or not they're already set.
+	bits int
+	// rows is the number of distinct rows those positions are in.
+	rows int
+}
+
+// validateImport checks row/column pairs as bulkImport would, and counts
+// the positions they give, without touching storage, the op-log or the
+// cache. Columns outside the fragment's shard are an error, reporting how
+// many there are and the first of them. The slices aren't modified.
+func (f *fragment) validateImport(rowIDs, columnIDs []uint64) (importValidation, error) {
+	var v importValidation
+	if len(rowIDs) != len(columnIDs) {
+		return v, fmt.Errorf("mismatch of row/column len: %d != %d", len(rowIDs), len(columnIDs))
+	}
+	positions := make([]uint64, 0, len(columnIDs))
+	var outOfBounds int
+	var firstErr error
+	for i, columnID := range columnIDs {
+		pos, err := f.pos(rowIDs[i], columnID)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			outOfBounds++
+			continue
+		}
+		positions = append(positions, pos)
+	}
+	if firstErr != nil {
+		return v, errors.Wrapf(firstErr, "%d of %d columns out of bounds", outOfBounds, len(columnIDs))
+	}
+
+	sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
+	lastRow := uint64(0)
+	for i, pos := range positions {
+		if i > 0 && pos == positions[i-1] {
+			continue
+		}
+		v.bits++
+		if row := pos / ShardWidth; v.rows == 0 || row != lastRow {
+			v.rows++
+			lastRow = row
+		}
+	}
+	return v, nil
+}
+
+// validateImportRoaring checks roaring encoded data as importRoaring would,
+// and counts the positions it has, without touching storage, the op-log or
+// the cache. Data which doesn't decode, or has rows above the maximum row
+// ID for imports, is an error. If those rows would be dropped instead, they
+// aren't counted. Clearing data isn't checked against the maximum row ID,
+// just as importRoaring doesn't.
+func (f *fragment) validateImportRoaring(data []byte, clear bool) (importValidation, error) {
+	var v importValidation
+	if !clear {
+		var err error
+		if data, err = f.checkImportRows(data); err != nil {
+			return v, err
+		}
+	}
+	citer, err := roaring.NewContainerIterator(data)
+	if err != nil {
+		return v, errors.Wrap(err, "getting container iterator")
+	}
+	defer citer.Close()
+	lastRow := uint64(0)
+	for citer.Next() {
+		key, c := citer.Value()
+		n := c.N()
+		if n == 0 {
+			continue
+		}
+		v.bits += int(n)
+		if row := key >> shardVsContainerExponent; v.rows == 0 || row != lastRow {
+			v.rows++
+			lastRow = row
+		}
+	}
+	return v, nil
+}
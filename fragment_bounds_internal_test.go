@@ -0,0 +1,89 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// Ensure roaring imports with bits above the maximum row ID are rejected
+// without writing anything, or clamped if configured.
+func TestFragment_ImportRoaringMaxRowID(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	f.holder.cfg.StorageConfig.MaxImportRowID = 10
+
+	data := roaringBytes(t, 1*ShardWidth+1, 10*ShardWidth+2, 11*ShardWidth+3, 1000*ShardWidth+4)
+	if err := f.importRoaringT(tx, data, false); errors.Cause(err) != ErrImportRowOutOfRange {
+		t.Fatalf("expected ErrImportRowOutOfRange, got %v", err)
+	}
+	for _, rowID := range []uint64{1, 10, 11, 1000} {
+		if n := f.mustRow(tx, rowID).Count(); n != 0 {
+			t.Fatalf("expected rejected import to write nothing, row %d has %d bits", rowID, n)
+		}
+	}
+
+	// Imports within the limit are unaffected.
+	if err := f.importRoaringT(tx, roaringBytes(t, 10*ShardWidth+5), false); err != nil {
+		t.Fatalf("in-range import: %v", err)
+	}
+
+	f.holder.cfg.StorageConfig.ClampImportRows = true
+	if err := f.importRoaringT(tx, data, false); err != nil {
+		t.Fatalf("clamped import: %v", err)
+	}
+	for rowID, exp := range map[uint64][]uint64{1: {1}, 10: {2, 5}, 11: nil, 1000: nil} {
+		if got := f.mustRow(tx, rowID).Columns(); !sliceEq(got, exp) {
+			t.Fatalf("row %d: expected %v, got %v", rowID, exp, got)
+		}
+	}
+}
+
+// Ensure validating an import counts what it would write, reports invalid
+// positions, and leaves the fragment unchanged.
+func TestFragment_ValidateImport(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	f.holder.cfg.StorageConfig.MaxImportRowID = 10
+
+	rowIDs := []uint64{1, 1, 1, 2}
+	columnIDs := []uint64{3, 3, 4, 5}
+	if v, err := f.validateImport(rowIDs, columnIDs); err != nil {
+		t.Fatal(err)
+	} else if v.bits != 3 || v.rows != 2 {
+		t.Fatalf("expected 3 bits in 2 rows, got %d bits in %d rows", v.bits, v.rows)
+	} else if !sliceEq(columnIDs, []uint64{3, 3, 4, 5}) {
+		t.Fatalf("expected columns to be left alone, got %v", columnIDs)
+	}
+	if _, err := f.validateImport([]uint64{1, 2, 3}, []uint64{3, ShardWidth + 1, ShardWidth + 2}); err == nil {
+		t.Fatal("expected error for columns outside the shard")
+	}
+	if _, err := f.validateImport([]uint64{1}, []uint64{3, 4}); err == nil {
+		t.Fatal("expected error for mismatched rows and columns")
+	}
+
+	data := roaringBytes(t, 1*ShardWidth+1, 1*ShardWidth+70000, 3*ShardWidth+2)
+	if v, err := f.validateImportRoaring(data, false); err != nil {
+		t.Fatal(err)
+	} else if v.bits != 3 || v.rows != 2 {
+		t.Fatalf("expected 3 bits in 2 rows, got %d bits in %d rows", v.bits, v.rows)
+	}
+	if _, err := f.validateImportRoaring(roaringBytes(t, 11*ShardWidth), false); errors.Cause(err) != ErrImportRowOutOfRange {
+		t.Fatalf("expected ErrImportRowOutOfRange, got %v", err)
+	}
+	if _, err := f.validateImportRoaring([]byte("not roaring"), false); err == nil {
+		t.Fatal("expected error for malformed data")
+	}
+
+	if rows, err := f.rows(context.Background(), tx, 0); err != nil {
+		t.Fatal(err)
+	} else if len(rows) != 0 {
+		t.Fatalf("expected validation to write nothing, got rows %v", rows)
+	}
+	if n := f.cache.Len(); n != 0 {
+		t.Fatalf("expected validation not to touch the cache, got %d entries", n)
+	}
+}
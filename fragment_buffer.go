@@ -0,0 +1,201 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultWriteBufferSize is the number of pending bit writes after which
+	// a fragment write buffer is flushed.
+	defaultWriteBufferSize = 4096
+
+	// defaultWriteBufferWindow is the maximum age of the oldest pending bit
+	// write before a fragment write buffer is flushed.
+	defaultWriteBufferWindow = 100 * time.Millisecond
+)
+
+// fragmentWriteBuffer coalesces individual setBit/clearBit calls on a
+// fragment so that they can be applied to storage in a single
+// importPositions call, rather than one storage operation per bit.
+//
+// Pending writes are keyed by storage position; a later write to the same
+// position replaces an earlier one, so a position is never both set and
+// cleared in the same flush. The buffer is bound to the Tx that it was
+// filled under, and it is protected by the fragment's mutex. Writes pending
+// under a Tx are only visible through that Tx. They're flushed into it by a
+// hook registered on it when it's committed, and dropped by another if it's
+// rolled back.
+type fragmentWriteBuffer struct {
+	size   int
+	window time.Duration
+
+	tx      Tx
+	pending map[uint64]bool // position -> true for set, false for clear
+	oldest  time.Time
+
+	// hooked is the last Tx the buffer registered its commit and rollback
+	// hooks on, so that refilling the buffer under it doesn't register them
+	// again.
+	hooked Tx
+
+	// flushes counts the number of times the buffer was applied to
+	// storage, and writes counts the bit writes it absorbed.
+	flushes int
+	writes  int
+}
+
+// enableWriteBuffer turns on write buffering for setBit and clearBit on this
+// fragment. Pending writes are flushed once size writes are buffered, once
+// the oldest pending write is older than window, before any read or other
+// write to the fragment through the same Tx, and when that Tx is
+// committed. A size or window of zero selects the default. Mutex fragments are never buffered, since setting a bit on
+// them requires consulting storage.
+func (f *fragment) enableWriteBuffer(size int, window time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if size <= 0 {
+		size = defaultWriteBufferSize
+	}
+	if window <= 0 {
+		window = defaultWriteBufferWindow
+	}
+	f.writeBuffer = &fragmentWriteBuffer{
+		size:    size,
+		window:  window,
+		pending: make(map[uint64]bool),
+	}
+}
+
+// disableWriteBuffer flushes any pending writes into tx and turns write
+// buffering off.
+func (f *fragment) disableWriteBuffer(tx Tx) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.unprotectedFlushWriteBuffer(tx); err != nil {
+		return err
+	}
+	f.writeBuffer = nil
+	return nil
+}
+
+// bufferedWrite records a single bit write in the write buffer and reports
+// whether it changes the state of the bit, taking earlier pending writes
+// into account. It flushes the buffer if a threshold has been reached.
+func (f *fragment) bufferedWrite(tx Tx, rowID, columnID uint64, set bool) (changed bool, err error) {
+	wb := f.writeBuffer
+	pos, err := f.pos(rowID, columnID)
+	if err != nil {
+		return false, errors.Wrap(err, "getting bit pos")
+	}
+	if wb.tx != nil && wb.tx != tx {
+		return false, errors.New("fragment write buffer holds writes from another transaction")
+	}
+
+	prev, ok := wb.pending[pos]
+	if !ok {
+		prev, err = tx.Contains(f.index(), f.field(), f.view(), f.shard, pos)
+		if err != nil {
+			return false, errors.Wrap(err, "checking existing bit")
+		}
+	}
+	if len(wb.pending) == 0 {
+		wb.tx = tx
+		wb.oldest = time.Now()
+		if wb.hooked != tx {
+			wb.hooked = tx
+			tx.BeforeCommit(func() error {
+				return errors.Wrapf(f.flushWriteBuffer(tx), "flushing %s/%s/%s/%d", f.index(), f.field(), f.view(), f.shard)
+			})
+			tx.OnRollback(func() { f.discardWriteBuffer(tx) })
+		}
+	}
+	wb.pending[pos] = set
+	wb.writes++
+	if prev != set {
+		f.recordRowChanged(tx, rowID)
+		if set {
+			f.sizeBound.grow(containerSizeBound(1))
+		}
+	}
+
+	if len(wb.pending) >= wb.size || time.Since(wb.oldest) >= wb.window {
+		if err := f.unprotectedFlushWriteBuffer(tx); err != nil {
+			return false, err
+		}
+	}
+	return prev != set, nil
+}
+
+// flushWriteBuffer applies any writes buffered under tx to it. It is a
+// no-op if write buffering is not enabled. Writes buffered under another Tx
+// are left pending: tx can't see them until that Tx commits, and they're
+// flushed into it when it does.
+func (f *fragment) flushWriteBuffer(tx Tx) error {
+	f.mu.RLock()
+	wb := f.writeBuffer
+	f.mu.RUnlock()
+	if wb == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.unprotectedFlushWriteBuffer(tx)
+}
+
+// unprotectedFlushWriteBuffer is flushWriteBuffer without grabbing the mutex.
+func (f *fragment) unprotectedFlushWriteBuffer(tx Tx) error {
+	wb := f.writeBuffer
+	if wb == nil || len(wb.pending) == 0 || wb.tx != tx {
+		return nil
+	}
+
+	var set, clear []uint64
+	rowSet := make(map[uint64]struct{})
+	for pos, isSet := range wb.pending {
+		if isSet {
+			set = append(set, pos)
+		} else {
+			clear = append(clear, pos)
+		}
+		rowSet[pos/ShardWidth] = struct{}{}
+	}
+	sort.Slice(set, func(i, j int) bool { return set[i] < set[j] })
+	sort.Slice(clear, func(i, j int) bool { return clear[i] < clear[j] })
+
+	if err := f.importPositions(tx, set, clear, rowSet); err != nil {
+		return errors.Wrap(err, "flushing write buffer")
+	}
+	wb.pending = make(map[uint64]bool)
+	wb.tx = nil
+	wb.flushes++
+	return nil
+}
+
+// discardWriteBuffer drops any writes buffered under tx, which is being
+// rolled back.
+func (f *fragment) discardWriteBuffer(tx Tx) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	wb := f.writeBuffer
+	if wb == nil || wb.tx != tx {
+		return
+	}
+	wb.pending = make(map[uint64]bool)
+	wb.tx = nil
+}
+
+// pendingBit returns the state of the bit at pos if a write to it is
+// buffered under tx, and whether there is one. It's safe to call on a nil
+// buffer.
+func (wb *fragmentWriteBuffer) pendingBit(tx Tx, pos uint64) (set, ok bool) {
+	if wb == nil || wb.tx != tx {
+		return false, false
+	}
+	set, ok = wb.pending[pos]
+	return set, ok
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Ensure buffered writes are visible to reads and are applied in batches.
+func TestFragment_WriteBuffer(t *testing.T) {
+	f, idx, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	f.enableWriteBuffer(64, time.Hour)
+
+	// Interleave buffered sets and clears with reads of the same row.
+	for i := uint64(0); i < 100; i++ {
+		if changed, err := f.setBit(tx, 7, i); err != nil {
+			t.Fatal(err)
+		} else if !changed {
+			t.Fatalf("expected set of column %d to report a change", i)
+		}
+		if i%10 == 9 {
+			if n := f.mustRow(tx, 7).Count(); n != i+1 {
+				t.Fatalf("read-your-writes: expected count %d, got %d", i+1, n)
+			}
+		}
+	}
+
+	// Setting an already-set bit, including one that is still pending in
+	// the buffer, must not report a change.
+	if changed, err := f.setBit(tx, 7, 3); err != nil {
+		t.Fatal(err)
+	} else if changed {
+		t.Fatal("expected set of existing bit to report no change")
+	}
+	if _, err := f.setBit(tx, 7, 200); err != nil {
+		t.Fatal(err)
+	} else if changed, err := f.setBit(tx, 7, 200); err != nil {
+		t.Fatal(err)
+	} else if changed {
+		t.Fatal("expected set of pending bit to report no change")
+	}
+
+	// A clear followed by a set of the same bit leaves it set.
+	if changed, err := f.clearBit(tx, 7, 5); err != nil {
+		t.Fatal(err)
+	} else if !changed {
+		t.Fatal("expected clear of set bit to report a change")
+	}
+	if _, err := f.clearBit(tx, 7, 6); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.setBit(tx, 7, 5); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := f.bit(tx, 7, 6); err != nil {
+		t.Fatal(err)
+	} else if v {
+		t.Fatal("expected column 6 to be cleared")
+	}
+	if n := f.mustRow(tx, 7).Count(); n != 100 {
+		t.Fatalf("expected count 100, got %d", n)
+	}
+
+	wb := f.writeBuffer
+	if wb.writes != 106 {
+		t.Fatalf("expected 106 buffered writes, got %d", wb.writes)
+	}
+	// One flush for each of the ten reads in the loop, and one for the
+	// final row read; bit() reads pending writes without flushing them.
+	if wb.flushes != 11 {
+		t.Fatalf("expected 11 flushes for %d writes, got %d", wb.writes, wb.flushes)
+	}
+
+	// Pending writes must survive a commit.
+	if _, err := f.setBit(tx, 8, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	tx = idx.holder.txf.NewTx(Txo{Write: !writable, Index: idx, Fragment: f, Shard: f.shard})
+	defer tx.Rollback()
+	if n := f.mustRow(tx, 8).Count(); n != 1 {
+		t.Fatalf("expected committed buffered write, got count %d", n)
+	}
+	if n := f.mustRow(tx, 7).Count(); n != 100 {
+		t.Fatalf("expected committed count 100, got %d", n)
+	}
+}
+
+// Ensure the write buffer is flushed once its time window elapses.
+func TestFragment_WriteBuffer_Window(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	f.enableWriteBuffer(1000, time.Millisecond)
+	if _, err := f.setBit(tx, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := f.setBit(tx, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if f.writeBuffer.flushes != 1 || len(f.writeBuffer.pending) != 0 {
+		t.Fatalf("expected window to trigger a flush, got flushes=%d pending=%d", f.writeBuffer.flushes, len(f.writeBuffer.pending))
+	}
+}
+
+// Ensure writes buffered under a Qcx's Tx are invisible to other Txs, are
+// flushed when the Qcx commits it, and are dropped when it rolls it back.
+func TestFragment_WriteBuffer_Qcx(t *testing.T) {
+	f, idx, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	tx.Rollback()
+
+	f.enableWriteBuffer(1000, time.Hour)
+	qcx := idx.holder.Txf().NewWritableQcx()
+	defer qcx.Abort()
+
+	wtx, finisher, err := qcx.GetTx(Txo{Write: writable, Index: idx, Shard: f.shard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.setBit(wtx, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := f.bit(wtx, 1, 1); err != nil {
+		t.Fatal(err)
+	} else if !v {
+		t.Fatal("expected pending write to be visible to its own Tx")
+	}
+	rtx := idx.holder.txf.NewTx(Txo{Write: !writable, Index: idx, Fragment: f, Shard: f.shard})
+	if n, err := f.row(rtx, 1); err != nil {
+		t.Fatalf("reading through another Tx: %v", err)
+	} else if n.Count() != 0 {
+		t.Fatalf("expected pending write to be invisible to another Tx, got count %d", n.Count())
+	}
+	rtx.Rollback()
+	finisher(nil)
+
+	rtx = idx.holder.txf.NewTx(Txo{Write: !writable, Index: idx, Fragment: f, Shard: f.shard})
+	if n := f.mustRow(rtx, 1).Count(); n != 1 {
+		t.Fatalf("expected committed buffered write, got count %d", n)
+	}
+	rtx.Rollback()
+
+	// A rolled back Tx leaves nothing pending.
+	wtx, finisher, err = qcx.GetTx(Txo{Write: writable, Index: idx, Shard: f.shard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.setBit(wtx, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	rollback := errors.New("rollback")
+	finisher(&rollback)
+	if n := len(f.writeBuffer.pending); n != 0 {
+		t.Fatalf("expected rollback to drop pending writes, got %d", n)
+	}
+	rtx = idx.holder.txf.NewTx(Txo{Write: !writable, Index: idx, Fragment: f, Shard: f.shard})
+	defer rtx.Rollback()
+	if n := f.mustRow(rtx, 2).Count(); n != 0 {
+		t.Fatalf("expected rolled back write to be dropped, got count %d", n)
+	}
+}
+
+// Ensure writes buffered under a Tx are dropped when it's rolled back
+// directly, and when its commit fails, and that a Qcx's finisher reports
+// the failure rather than panicking.
+func TestFragment_WriteBuffer_TxHooks(t *testing.T) {
+	f, idx, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	f.enableWriteBuffer(1000, time.Hour)
+	if _, err := f.setBit(tx, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+	if n := len(f.writeBuffer.pending); n != 0 {
+		t.Fatalf("expected rollback to drop pending writes, got %d", n)
+	}
+
+	qcx := idx.holder.Txf().NewWritableQcx()
+	defer qcx.Abort()
+	wtx, finisher, err := qcx.GetTx(Txo{Write: writable, Index: idx, Shard: f.shard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.setBit(wtx, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+	boom := errors.New("boom")
+	wtx.BeforeCommit(func() error { return boom })
+	var err0 error
+	finisher(&err0)
+	if !errors.Is(err0, boom) {
+		t.Fatalf("expected commit error %v, got %v", boom, err0)
+	}
+	if n := len(f.writeBuffer.pending); n != 0 {
+		t.Fatalf("expected failed commit to drop pending writes, got %d", n)
+	}
+
+	rtx := idx.holder.txf.NewTx(Txo{Write: !writable, Index: idx, Fragment: f, Shard: f.shard})
+	defer rtx.Rollback()
+	for _, rowID := range []uint64{1, 2} {
+		if n := f.mustRow(rtx, rowID).Count(); n != 0 {
+			t.Fatalf("expected row %d's write to be dropped, got count %d", rowID, n)
+		}
+	}
+}
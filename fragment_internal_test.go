@@ -8,6 +8,8 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -17,11 +19,14 @@ import (
 	"sync"
 	"testing"
 	"testing/quick"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/featurebasedb/featurebase/v3/logger"
 	"github.com/featurebasedb/featurebase/v3/pql"
 	"github.com/featurebasedb/featurebase/v3/roaring"
 	"github.com/featurebasedb/featurebase/v3/testhook"
+	"github.com/featurebasedb/featurebase/v3/tracing"
 	. "github.com/featurebasedb/featurebase/v3/vprint" // nolint:staticcheck
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
@@ -139,6 +144,49 @@ func TestFragment_ClearRow(t *testing.T) {
 	}
 }
 
+// Ensure a fragment can clear a single block, leaving other blocks alone.
+func TestFragment_ClearBlock(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	// Rows 5 and 99 are in block 0; row 105 is in block 1.
+	f.mustSetBits(tx, 5, 1, 65536)
+	f.mustSetBits(tx, 99, 2)
+	f.mustSetBits(tx, HashBlockSize+5, 3)
+	f.checksums[0] = []byte("stale")
+	f.checksums[1] = []byte("valid")
+
+	if changed, err := f.clearBlock(tx, 0); err != nil {
+		t.Fatal(err)
+	} else if !changed {
+		t.Fatal("expected clearing block 0 to report a change")
+	}
+	for _, rowID := range []uint64{5, 99} {
+		if n := f.mustRow(tx, rowID).Count(); n != 0 {
+			t.Fatalf("expected row %d to be cleared, got count %d", rowID, n)
+		} else if n := f.cache.Get(rowID); n != 0 {
+			t.Fatalf("expected cached count 0 for row %d, got %d", rowID, n)
+		}
+	}
+	if cols := f.mustRow(tx, HashBlockSize+5).Columns(); !reflect.DeepEqual(cols, []uint64{3}) {
+		t.Fatalf("expected block 1 to be untouched, got %v", cols)
+	}
+	if _, ok := f.checksums[0]; ok {
+		t.Fatal("expected block 0 checksum to be invalidated")
+	} else if _, ok := f.checksums[1]; !ok {
+		t.Fatal("expected block 1 checksum to be kept")
+	}
+
+	// Clearing an empty block, or one past the last row, changes nothing.
+	for _, block := range []int{0, 1000} {
+		if changed, err := f.clearBlock(tx, block); err != nil {
+			t.Fatal(err)
+		} else if changed {
+			t.Fatalf("expected clearing empty block %d to report no change", block)
+		}
+	}
+}
+
 // Ensure a fragment can set a row.
 func TestFragment_SetRow(t *testing.T) {
 	f, idx, tx := mustOpenFragment(t)
@@ -214,6 +262,45 @@ func TestFragment_SetRow(t *testing.T) {
 	PanicOn(tx.Commit())
 }
 
+// Ensure a row can be replaced from a bitmap of shard-relative positions.
+func TestFragment_SetRowFromBitmap(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	f.mustSetBits(tx, 7, 1, 65536, 3<<16)
+	bm := roaring.NewBitmap(1, 65537, 140000, ShardWidth-1)
+	if changed, err := f.setRowFromBitmap(tx, bm, 7); err != nil {
+		t.Fatal(err)
+	} else if !changed {
+		t.Fatal("expected changed")
+	}
+	if cols := f.mustRow(tx, 7).Columns(); !reflect.DeepEqual(cols, []uint64{1, 65537, 140000, ShardWidth - 1}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	} else if n := f.cache.Get(7); n != 4 {
+		t.Fatalf("expected cached count 4, got %d", n)
+	}
+
+	// Setting the same columns again changes nothing.
+	if changed, err := f.setRowFromBitmap(tx, roaring.NewBitmap(1, 65537, 140000, ShardWidth-1), 7); err != nil {
+		t.Fatal(err)
+	} else if changed {
+		t.Fatal("expected no change")
+	}
+
+	// An empty bitmap clears the row.
+	if changed, err := f.setRowFromBitmap(tx, roaring.NewBitmap(), 7); err != nil {
+		t.Fatal(err)
+	} else if !changed {
+		t.Fatal("expected changed")
+	} else if n := f.mustRow(tx, 7).Count(); n != 0 {
+		t.Fatalf("expected empty row, got %d bits", n)
+	}
+
+	if _, err := f.setRowFromBitmap(tx, roaring.NewBitmap(ShardWidth), 7); err == nil {
+		t.Fatal("expected error for position outside the shard")
+	}
+}
+
 // Ensure a fragment can set & read a value.
 func TestFragment_SetValue(t *testing.T) {
 	t.Run("OK", func(t *testing.T) {
@@ -448,6 +535,109 @@ func TestFragment_SetValue(t *testing.T) {
 	})
 }
 
+// Ensure conditional value writes only ever move a column's value in one
+// direction, or, for setValueIfAbsent, never replace it.
+func TestFragment_SetValueIf(t *testing.T) {
+	const bitDepth = 16
+
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	type update struct {
+		value   int64
+		changed bool
+		exp     int64
+	}
+	for _, tt := range []struct {
+		name    string
+		fn      func(tx Tx, columnID uint64, bitDepth uint64, value int64) (bool, error)
+		updates []update
+	}{
+		{
+			name: "Greater",
+			fn:   f.setValueIfGreater,
+			updates: []update{
+				{-20, true, -20}, // unset columns take any value
+				{-30, false, -20},
+				{-5, true, -5},
+				{-5, false, -5},
+				{10, true, 10},
+				{3, false, 10},
+				{-100, false, 10},
+				{400, true, 400},
+			},
+		},
+		{
+			name: "Less",
+			fn:   f.setValueIfLess,
+			updates: []update{
+				{20, true, 20},
+				{30, false, 20},
+				{5, true, 5},
+				{5, false, 5},
+				{-10, true, -10},
+				{-3, false, -10},
+				{100, false, -10},
+				{-400, true, -400},
+			},
+		},
+		{
+			name: "Absent",
+			fn:   f.setValueIfAbsent,
+			updates: []update{
+				{7, true, 7},
+				{9, false, 7},
+				{-3, false, 7},
+				{7, false, 7},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			columnID := uint64(len(tt.name))
+			for i, u := range tt.updates {
+				if changed, err := tt.fn(tx, columnID, bitDepth, u.value); err != nil {
+					t.Fatal(err)
+				} else if changed != u.changed {
+					t.Fatalf("update %d (%d): expected changed=%v, got %v", i, u.value, u.changed, changed)
+				}
+				if value, exists, err := f.value(tx, columnID, bitDepth); err != nil {
+					t.Fatal(err)
+				} else if !exists || value != u.exp {
+					t.Fatalf("update %d (%d): expected value %d, got %d (exists=%v)", i, u.value, u.exp, value, exists)
+				}
+			}
+		})
+	}
+}
+
+// Ensure notNull returns exactly the columns which have a value.
+func TestFragment_NotNull(t *testing.T) {
+	f, _, tx := mustOpenFragment(t, OptFieldTypeInt(-100, 100))
+	defer f.Clean(t)
+
+	if row, err := f.notNull(tx); err != nil {
+		t.Fatal(err)
+	} else if n := row.Count(); n != 0 {
+		t.Fatalf("expected empty row, got %d bits", n)
+	}
+
+	const bitDepth = 7
+	for col, v := range map[uint64]int64{1: 0, 5: -20, 70000: 100, 900000: 3} {
+		if _, err := f.setValue(tx, col, bitDepth, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := f.clearValue(tx, 5, bitDepth, -20); err != nil {
+		t.Fatal(err)
+	}
+
+	if row, err := f.notNull(tx); err != nil {
+		t.Fatal(err)
+	} else if cols := row.Columns(); !reflect.DeepEqual(cols, []uint64{1, 70000, 900000}) {
+		t.Fatalf("unexpected columns: %v", cols)
+	}
+}
+
 // Ensure a fragment can sum values.
 func TestFragment_Sum(t *testing.T) {
 	const bitDepth = 16
@@ -476,48 +666,1149 @@ func TestFragment_Sum(t *testing.T) {
 	tx = idx.holder.txf.NewTx(Txo{Write: !writable, Index: idx, Fragment: f, Shard: f.shard})
 	defer tx.Rollback()
 
-	t.Run("NoFilter", func(t *testing.T) {
-		if sum, n, err := f.sum(tx, nil, bitDepth); err != nil {
+	t.Run("NoFilter", func(t *testing.T) {
+		if sum, n, err := f.sum(tx, nil, bitDepth); err != nil {
+			t.Fatal(err)
+		} else if n != 5 {
+			t.Fatalf("unexpected count: %d", n)
+		} else if got, exp := sum, int64(382+300-600+2818+300); got != exp {
+			t.Fatalf("unexpected sum: got: %d, exp: %d", sum, exp)
+		}
+	})
+
+	t.Run("WithFilter", func(t *testing.T) {
+		if sum, n, err := f.sum(tx, NewRow(2000, 4000, 5000), bitDepth); err != nil {
+			t.Fatal(err)
+		} else if n != 2 {
+			t.Fatalf("unexpected count: %d", n)
+		} else if got, exp := sum, int64(300+300); got != exp {
+			t.Fatalf("unexpected sum: got: %d, exp: %d", sum, exp)
+		}
+	})
+
+	tx.Rollback()
+	tx = idx.holder.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: f, Shard: f.shard})
+	defer tx.Rollback()
+
+	// verify that clearValue clears values
+	if _, err := f.clearValue(tx, 1000, bitDepth, 23); err != nil {
+		t.Fatal(err)
+	}
+
+	PanicOn(tx.Commit())
+	tx = idx.holder.txf.NewTx(Txo{Write: !writable, Index: idx, Fragment: f, Shard: f.shard})
+	defer tx.Rollback()
+
+	t.Run("ClearValue", func(t *testing.T) {
+		if sum, n, err := f.sum(tx, nil, bitDepth); err != nil {
+			t.Fatal(err)
+		} else if n != 4 {
+			t.Fatalf("unexpected count: %d", n)
+		} else if got, exp := sum, int64(3800-382-600); got != exp {
+			t.Fatalf("unexpected sum: got: %d, exp: %d", sum, exp)
+		}
+	})
+}
+
+// Ensure sumBig gives the exact sum of values whose total overflows int64.
+func TestFragment_SumBig(t *testing.T) {
+	const bitDepth = 63
+
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	vals := []int64{math.MaxInt64, math.MaxInt64, math.MaxInt64, -5, 1 << 40}
+	exp := new(big.Int)
+	for i, v := range vals {
+		if _, err := f.setValue(tx, uint64(i*100), bitDepth, v); err != nil {
+			t.Fatal(err)
+		}
+		exp.Add(exp, big.NewInt(v))
+	}
+
+	sum, n, err := f.sumBig(tx, nil, bitDepth)
+	if err != nil {
+		t.Fatal(err)
+	} else if n != uint64(len(vals)) {
+		t.Fatalf("unexpected count: %d", n)
+	} else if sum.Cmp(exp) != 0 {
+		t.Fatalf("unexpected sum: got %s, exp %s", sum, exp)
+	}
+
+	// The int64 sum wraps.
+	if old, _, err := f.sum(tx, nil, bitDepth); err != nil {
+		t.Fatal(err)
+	} else if big.NewInt(old).Cmp(exp) == 0 {
+		t.Fatalf("expected int64 sum to overflow, got %d", old)
+	}
+
+	// Negative values and filters are honored.
+	if sum, n, err := f.sumBig(tx, NewRow(300, 400), bitDepth); err != nil {
+		t.Fatal(err)
+	} else if n != 2 || sum.Cmp(big.NewInt(-5+1<<40)) != 0 {
+		t.Fatalf("unexpected filtered sum: %s (count %d)", sum, n)
+	}
+
+	// A filter with no columns in this shard sums to zero.
+	if sum, n, err := f.sumBig(tx, NewRow(), bitDepth); err != nil {
+		t.Fatal(err)
+	} else if n != 0 || sum.Sign() != 0 {
+		t.Fatalf("expected empty sum, got %s (count %d)", sum, n)
+	}
+}
+
+// Ensure a fragment can count its BSI values into histogram buckets.
+func TestFragment_ValueHistogram(t *testing.T) {
+	const bitDepth = 8
+
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	// One column for each value from -50 to 49.
+	for i := 0; i < 100; i++ {
+		if _, err := f.setValue(tx, uint64(i), bitDepth, int64(i-50)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	edges := []int64{-50, -25, 0, 1, 25, 49}
+	counts, err := f.valueHistogram(tx, nil, bitDepth, edges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []uint64{25, 25, 1, 24, 25}; !reflect.DeepEqual(counts, exp) {
+		t.Fatalf("expected counts %v, got %v", exp, counts)
+	}
+	var total uint64
+	for _, n := range counts {
+		total += n
+	}
+	if total != 100 {
+		t.Fatalf("expected counts to sum to 100, got %d", total)
+	}
+
+	// A bucket may span negative and positive values.
+	if counts, err := f.valueHistogram(tx, nil, bitDepth, []int64{-10, 10}); err != nil {
+		t.Fatal(err)
+	} else if exp := []uint64{21}; !reflect.DeepEqual(counts, exp) {
+		t.Fatalf("expected counts %v, got %v", exp, counts)
+	}
+
+	// Filters are honored.
+	if counts, err := f.valueHistogram(tx, NewRow(0, 1, 50, 99), bitDepth, edges); err != nil {
+		t.Fatal(err)
+	} else if exp := []uint64{2, 0, 1, 0, 1}; !reflect.DeepEqual(counts, exp) {
+		t.Fatalf("expected filtered counts %v, got %v", exp, counts)
+	}
+
+	// A filter with no columns in this shard counts nothing.
+	if counts, err := f.valueHistogram(tx, NewRow(), bitDepth, edges); err != nil {
+		t.Fatal(err)
+	} else if exp := make([]uint64, 5); !reflect.DeepEqual(counts, exp) {
+		t.Fatalf("expected empty counts %v, got %v", exp, counts)
+	}
+
+	// Edges must be strictly ascending.
+	if _, err := f.valueHistogram(tx, nil, bitDepth, []int64{0, 10, 10}); err == nil {
+		t.Fatal("expected error for repeated edge")
+	}
+	if _, err := f.valueHistogram(tx, nil, bitDepth, []int64{10, 0}); err == nil {
+		t.Fatal("expected error for descending edges")
+	}
+}
+
+// Ensure intersectValueRange matches intersecting the result of rangeBetween
+// with the filter.
+func TestFragment_IntersectValueRange(t *testing.T) {
+	const bitDepth = 10
+
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	rnd := rand.New(rand.NewSource(11))
+	filter := NewRow()
+	for col := uint64(0); col < 2000; col++ {
+		if rnd.Intn(4) > 0 {
+			if _, err := f.setValue(tx, col, bitDepth, rnd.Int63n(2000)-1000); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if rnd.Intn(2) == 0 {
+			filter.SetBit(col)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		lo := rnd.Int63n(2400) - 1200
+		hi := lo + rnd.Int63n(600)
+		if i%10 == 0 {
+			hi = lo
+		}
+		exp, err := f.rangeBetween(tx, bitDepth, lo, hi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := f.intersectValueRange(tx, filter, bitDepth, lo, hi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expCols, gotCols := exp.Intersect(filter).Columns(), got.Columns(); !sliceEq(expCols, gotCols) {
+			t.Fatalf("between %d and %d: expected %v, got %v", lo, hi, expCols, gotCols)
+		}
+
+		// A nil filter is rangeBetween.
+		if got, err := f.intersectValueRange(tx, nil, bitDepth, lo, hi); err != nil {
+			t.Fatal(err)
+		} else if !sliceEq(exp.Columns(), got.Columns()) {
+			t.Fatalf("between %d and %d without filter: expected %v, got %v", lo, hi, exp.Columns(), got.Columns())
+		}
+	}
+
+	// An empty filter matches nothing.
+	if got, err := f.intersectValueRange(tx, NewRow(), bitDepth, -1000, 1000); err != nil {
+		t.Fatal(err)
+	} else if got.Any() {
+		t.Fatalf("expected no columns for empty filter, got %v", got.Columns())
+	}
+}
+
+// Ensure countRange counts the columns rangeBetween returns.
+func TestFragment_CountRange(t *testing.T) {
+	const bitDepth = 10
+
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	rnd := rand.New(rand.NewSource(13))
+	filter := NewRow()
+	for col := uint64(0); col < 2000; col++ {
+		if rnd.Intn(4) > 0 {
+			if _, err := f.setValue(tx, col, bitDepth, rnd.Int63n(2000)-1000); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if rnd.Intn(2) == 0 {
+			filter.SetBit(col)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		lo := rnd.Int63n(2400) - 1200
+		hi := lo + rnd.Int63n(600)
+		if i%10 == 0 {
+			hi = lo
+		}
+		exp, err := f.rangeBetween(tx, bitDepth, lo, hi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := f.countRange(tx, nil, bitDepth, lo, hi); err != nil {
+			t.Fatal(err)
+		} else if n != exp.Count() {
+			t.Fatalf("between %d and %d: expected %d, got %d", lo, hi, exp.Count(), n)
+		}
+		if n, err := f.countRange(tx, filter, bitDepth, lo, hi); err != nil {
+			t.Fatal(err)
+		} else if expN := exp.Intersect(filter).Count(); n != expN {
+			t.Fatalf("between %d and %d with filter: expected %d, got %d", lo, hi, expN, n)
+		}
+	}
+
+	if n, err := f.countRange(tx, nil, bitDepth, 10, -10); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("expected 0 for lo > hi, got %d", n)
+	}
+	if n, err := f.countRange(tx, NewRow(), bitDepth, -1000, 1000); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("expected 0 for empty filter, got %d", n)
+	}
+}
+
+// Ensure a fragment reports the bit depth of its largest BSI value.
+func TestFragment_BitDepth(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	if bd, err := f.bitDepthTx(tx); err != nil {
+		t.Fatal(err)
+	} else if bd != 0 {
+		t.Fatalf("expected empty fragment bit depth 0, got %d", bd)
+	}
+
+	for i, tc := range []struct {
+		v   int64
+		exp uint64
+	}{
+		{1, 1},
+		{5, 3},
+		{-300, 9},
+		{70000, 17},
+		{-70000, 17},
+	} {
+		if _, err := f.setValue(tx, uint64(i), 32, tc.v); err != nil {
+			t.Fatal(err)
+		}
+		if bd, err := f.bitDepthTx(tx); err != nil {
+			t.Fatal(err)
+		} else if bd != tc.exp {
+			t.Fatalf("after setting %d: expected bit depth %d, got %d", tc.v, tc.exp, bd)
+		}
+	}
+}
+
+// Ensure forEachContainer visits every set bit once, in key order, and that
+// containers cloned during the call outlive it.
+func TestFragment_ForEachContainer(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	var total uint64
+	for _, rowID := range []uint64{0, 1, 9, 1000} {
+		for _, columnID := range []uint64{0, 3, 65535, 65536, 200000, ShardWidth - 1} {
+			if _, err := f.setBit(tx, rowID, columnID); err != nil {
+				t.Fatal(err)
+			}
+			total++
+		}
+	}
+	if err := f.bulkImportT(tx, []uint64{5, 5, 5}, []uint64{7, 8, 9}, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	total += 3
+
+	var n uint64
+	var keys []uint64
+	kept := make(map[uint64]*roaring.Container)
+	if err := f.forEachContainer(tx, func(key uint64, c *roaring.Container) error {
+		n += uint64(c.N())
+		keys = append(keys, key)
+		kept[key] = c.Clone()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n != total {
+		t.Fatalf("expected %d bits, got %d", total, n)
+	}
+	if !sort.SliceIsSorted(keys, func(i, j int) bool { return keys[i] < keys[j] }) {
+		t.Fatalf("expected ascending keys, got %v", keys)
+	}
+	if c := kept[5<<shardVsContainerExponent]; c == nil || c.N() != 3 || !c.Contains(8) {
+		t.Fatalf("expected cloned container for row 5 to hold its 3 bits, got %v", c)
+	}
+
+	// An error from fn stops the iteration.
+	calls := 0
+	errStop := errors.New("stop")
+	if err := f.forEachContainer(tx, func(key uint64, c *roaring.Container) error {
+		calls++
+		return errStop
+	}); err != errStop {
+		t.Fatalf("expected stop error, got %v", err)
+	} else if calls != 1 {
+		t.Fatalf("expected one call before stopping, got %d", calls)
+	}
+}
+
+// Ensure forEachBit yields bits in row-major ascending order, regardless of
+// the order they were written in.
+func TestFragment_ForEachBit(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	// Spread the bits across rows and containers, and write them in
+	// descending order.
+	var want [][2]uint64
+	for _, rowID := range []uint64{0, 1, 9, 1000} {
+		for _, columnID := range []uint64{0, 3, 65535, 65536, 200000, ShardWidth - 1} {
+			want = append(want, [2]uint64{rowID, columnID})
+		}
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		if _, err := f.setBit(tx, want[i][0], want[i][1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got [][2]uint64
+	if err := f.forEachBit(tx, func(rowID, columnID uint64) error {
+		got = append(got, [2]uint64{rowID, columnID})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// An error from fn stops the iteration.
+	n := 0
+	errStop := errors.New("stop")
+	if err := f.forEachBit(tx, func(rowID, columnID uint64) error {
+		n++
+		return errStop
+	}); err != errStop {
+		t.Fatalf("expected stop error, got %v", err)
+	} else if n != 1 {
+		t.Fatalf("expected one call before stopping, got %d", n)
+	}
+
+	// A Tx which yields containers out of order is rejected.
+	if err := f.forEachBit(reversedContainerTx{tx}, func(rowID, columnID uint64) error {
+		return nil
+	}); err == nil {
+		t.Fatal("expected error from out of order containers")
+	}
+}
+
+// Ensure sampled bits are set, repeatable for a seed, and spread evenly.
+func TestFragment_SampleBits(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	// 100 bits in each of 10 rows, spread across containers.
+	set := make(map[[2]uint64]bool)
+	for rowID := uint64(0); rowID < 10; rowID++ {
+		columnIDs := make([]uint64, 100)
+		for i := range columnIDs {
+			columnIDs[i] = uint64(i) * 9973
+			set[[2]uint64{rowID, columnIDs[i]}] = true
+		}
+		f.mustSetBits(tx, rowID, columnIDs...)
+	}
+
+	sample, err := f.sampleBits(tx, 500, 7)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(sample) != 500 {
+		t.Fatalf("expected 500 bits, got %d", len(sample))
+	}
+	perRow := make(map[uint64]int)
+	for i, bit := range sample {
+		if !set[bit] {
+			t.Fatalf("sampled bit %v isn't set", bit)
+		} else if i > 0 && !(sample[i-1][0] < bit[0] || sample[i-1][0] == bit[0] && sample[i-1][1] < bit[1]) {
+			t.Fatalf("sample isn't sorted and distinct at %v, %v", sample[i-1], bit)
+		}
+		perRow[bit[0]]++
+	}
+	for rowID := uint64(0); rowID < 10; rowID++ {
+		if n := perRow[rowID]; n < 25 || n > 75 {
+			t.Fatalf("expected about 50 bits from row %d, got %d", rowID, n)
+		}
+	}
+
+	if again, err := f.sampleBits(tx, 500, 7); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(again, sample) {
+		t.Fatal("expected the same sample for the same seed")
+	}
+
+	// Asking for more bits than are set returns them all.
+	if all, err := f.sampleBits(tx, 5000, 7); err != nil {
+		t.Fatal(err)
+	} else if len(all) != len(set) {
+		t.Fatalf("expected all %d bits, got %d", len(set), len(all))
+	}
+	if none, err := f.sampleBits(tx, 0, 7); err != nil {
+		t.Fatal(err)
+	} else if len(none) != 0 {
+		t.Fatalf("expected no bits, got %d", len(none))
+	}
+}
+
+// Ensure symmetric difference counts match XOR computed on the rows.
+func TestFragment_SymmetricDifferenceCount(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	// Rows 1 and 2 overlap across several containers, with a mix of
+	// container types; row 3 borders row 2 and must not be counted.
+	rnd := rand.New(rand.NewSource(7))
+	for i := 0; i < 5000; i++ {
+		f.mustSetBits(tx, 1, uint64(rnd.Intn(4*65536)))
+		f.mustSetBits(tx, 2, uint64(rnd.Intn(4*65536))+65536)
+	}
+	for col := uint64(0); col < 10000; col++ {
+		f.mustSetBits(tx, 1, 3*65536+col)
+		f.mustSetBits(tx, 2, 3*65536+col/2*2)
+	}
+	f.mustSetBits(tx, 3, 0, 1, 2, ShardWidth-1)
+
+	for _, tc := range []struct{ a, b uint64 }{
+		{1, 2}, {2, 1}, {1, 1}, {2, 3}, {1, 4}, {4, 5},
+	} {
+		exp := f.mustRow(tx, tc.a).Xor(f.mustRow(tx, tc.b)).Count()
+		if n, err := f.symmetricDifferenceCount(tx, tc.a, tc.b); err != nil {
+			t.Fatal(err)
+		} else if n != exp {
+			t.Fatalf("rows %d, %d: expected %d, got %d", tc.a, tc.b, exp, n)
+		}
+	}
+}
+
+// countingTx counts the containers read through its container iterators.
+type countingTx struct {
+	Tx
+	n int
+}
+
+func (tx *countingTx) ContainerIterator(index, field, view string, shard uint64, firstRoaringContainerKey uint64) (roaring.ContainerIterator, bool, error) {
+	citer, found, err := tx.Tx.ContainerIterator(index, field, view, shard, firstRoaringContainerKey)
+	if err != nil {
+		return nil, false, err
+	}
+	return &countingContainerIterator{ContainerIterator: citer, n: &tx.n}, found, nil
+}
+
+type countingContainerIterator struct {
+	roaring.ContainerIterator
+	n *int
+}
+
+func (it *countingContainerIterator) Next() bool {
+	*it.n++
+	return it.ContainerIterator.Next()
+}
+
+// Ensure a fragment checks a row's count against a threshold, reading only
+// as much of the row as it needs to.
+func TestFragment_RowCountAtLeast(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	// Row 1 has 100 bits in each of its first 10 containers.
+	for key := uint64(0); key < 10; key++ {
+		for i := uint64(0); i < 100; i++ {
+			f.mustSetBits(tx, 1, key<<16+i)
+		}
+	}
+	f.mustSetBits(tx, 2, 5)
+
+	for _, tc := range []struct {
+		rowID, k uint64
+		exp      bool
+	}{
+		{1, 0, true}, {1, 1, true}, {1, 1000, true}, {1, 1001, false},
+		{2, 1, true}, {2, 2, false},
+		{3, 0, true}, {3, 1, false},
+	} {
+		if ok, err := f.rowCountAtLeast(tx, tc.rowID, tc.k); err != nil {
+			t.Fatal(err)
+		} else if ok != tc.exp {
+			t.Fatalf("row %d, k=%d: expected %v, got %v", tc.rowID, tc.k, tc.exp, ok)
+		}
+	}
+
+	// Reaching k in the second container stops before the third.
+	ctx := &countingTx{Tx: tx}
+	if ok, err := f.rowCountAtLeast(ctx, 1, 150); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected at least 150 bits")
+	} else if ctx.n != 2 {
+		t.Fatalf("expected to read 2 containers, read %d", ctx.n)
+	}
+}
+
+// Ensure a fragment computes the Jaccard similarity of two rows.
+func TestFragment_Jaccard(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	rnd := rand.New(rand.NewSource(11))
+	for i := 0; i < 5000; i++ {
+		f.mustSetBits(tx, 1, uint64(rnd.Intn(4*65536)))
+		f.mustSetBits(tx, 2, uint64(rnd.Intn(4*65536))+65536)
+	}
+	f.mustSetBits(tx, 3, 0, 1, 2, ShardWidth-1)
+	f.mustSetBits(tx, 4, 5*65536)
+
+	for _, tc := range []struct{ a, b uint64 }{
+		{1, 2}, {2, 1}, {1, 3}, {2, 3}, {3, 4}, {1, 5},
+	} {
+		ra, rb := f.mustRow(tx, tc.a), f.mustRow(tx, tc.b)
+		exp := float64(ra.Intersect(rb).Count()) / float64(ra.Union(rb).Count())
+		if j, err := f.jaccard(tx, tc.a, tc.b); err != nil {
+			t.Fatal(err)
+		} else if j != exp {
+			t.Fatalf("rows %d, %d: expected %v, got %v", tc.a, tc.b, exp, j)
+		}
+	}
+	for _, tc := range []struct {
+		a, b uint64
+		exp  float64
+	}{
+		{1, 1, 1}, {3, 3, 1}, {5, 5, 0}, {5, 6, 0},
+	} {
+		if j, err := f.jaccard(tx, tc.a, tc.b); err != nil {
+			t.Fatal(err)
+		} else if j != tc.exp {
+			t.Fatalf("rows %d, %d: expected %v, got %v", tc.a, tc.b, tc.exp, j)
+		}
+	}
+}
+
+// Ensure unionCount counts the distinct columns set in any of the rows.
+func TestFragment_UnionCount(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	rows := make(map[uint64]map[uint64]struct{})
+	set := func(rowID uint64, cols ...uint64) {
+		if rows[rowID] == nil {
+			rows[rowID] = make(map[uint64]struct{})
+		}
+		for _, col := range cols {
+			rows[rowID][col] = struct{}{}
+		}
+		f.mustSetBits(tx, rowID, cols...)
+	}
+	rnd := rand.New(rand.NewSource(17))
+	for i := 0; i < 5000; i++ {
+		set(1, uint64(rnd.Intn(4*65536)))
+		set(2, uint64(rnd.Intn(4*65536))+65536)
+	}
+	// A dense run, overlapping rows 1 and 2.
+	dense := make([]uint64, 20000)
+	for i := range dense {
+		dense[i] = uint64(60000 + i)
+	}
+	set(3, dense...)
+	set(4, 0, 1, 2, ShardWidth-1)
+
+	for _, rowIDs := range [][]uint64{
+		{1}, {1, 2}, {2, 3}, {1, 2, 3, 4}, {3, 1, 3, 4, 1}, {4, 5}, {5}, {}, nil,
+	} {
+		exp := make(map[uint64]struct{})
+		for _, rowID := range rowIDs {
+			for col := range rows[rowID] {
+				exp[col] = struct{}{}
+			}
+		}
+		if n, err := f.unionCount(tx, rowIDs); err != nil {
+			t.Fatal(err)
+		} else if n != uint64(len(exp)) {
+			t.Fatalf("rows %v: expected %d, got %d", rowIDs, len(exp), n)
+		}
+	}
+
+	// Counting doesn't change the rows.
+	if n := f.mustRow(tx, 1).Count(); n != uint64(len(rows[1])) {
+		t.Fatalf("expected row 1 to keep %d columns, got %d", len(rows[1]), n)
+	}
+}
+
+// Ensure a fragment reports the first and last columns set in a row.
+func TestFragment_RowMinMaxColumn(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	rnd := rand.New(rand.NewSource(5))
+	for i := 0; i < 200; i++ {
+		f.mustSetBits(tx, 1, uint64(rnd.Int63n(ShardWidth)))
+	}
+	// Row 2 has a full bitmap container in the middle of the row, and a
+	// run container at its end.
+	var positions []uint64
+	for i := uint64(0); i < 5000; i++ {
+		positions = append(positions, 2*ShardWidth+3<<16+2*i)
+	}
+	for i := uint64(ShardWidth - 100); i < ShardWidth; i++ {
+		positions = append(positions, 2*ShardWidth+i)
+	}
+	if err := f.importRoaringT(tx, roaringBytes(t, positions...), false); err != nil {
+		t.Fatal(err)
+	}
+	f.mustSetBits(tx, 3, 77)
+
+	for _, rowID := range []uint64{1, 2, 3} {
+		cols := f.mustRow(tx, rowID).Columns()
+		min, max, exists, err := f.rowMinMaxColumn(tx, rowID)
+		if err != nil {
+			t.Fatal(err)
+		} else if !exists {
+			t.Fatalf("row %d: expected to exist", rowID)
+		} else if min != cols[0] || max != cols[len(cols)-1] {
+			t.Fatalf("row %d: expected min %d and max %d, got %d and %d", rowID, cols[0], cols[len(cols)-1], min, max)
+		}
+	}
+	if _, _, exists, err := f.rowMinMaxColumn(tx, 4); err != nil {
+		t.Fatal(err)
+	} else if exists {
+		t.Fatal("expected empty row not to exist")
+	}
+	if min, max, _, err := f.rowMinMaxColumn(tx, 3); err != nil {
+		t.Fatal(err)
+	} else if min != 77 || max != 77 {
+		t.Fatalf("expected single-bit row to have min and max 77, got %d and %d", min, max)
+	}
+}
+
+// Ensure unionRows matches the union of the rows' columns, whatever the
+// order of the rows.
+func TestFragment_UnionRows(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	rnd := rand.New(rand.NewSource(7))
+	for _, rowID := range []uint64{1, 2, 3} {
+		for i := 0; i < 300; i++ {
+			f.mustSetBits(tx, rowID, uint64(rnd.Int63n(ShardWidth)))
+		}
+	}
+	// Row 4 has a bitmap container overlapping row 1's bits.
+	var positions []uint64
+	for i := uint64(0); i < 6000; i++ {
+		positions = append(positions, 4*ShardWidth+i*3)
+	}
+	if err := f.importRoaringT(tx, roaringBytes(t, positions...), false); err != nil {
+		t.Fatal(err)
+	}
+
+	rowIDs := []uint64{1, 4, 2, 4, 9}
+	want := make(map[uint64]struct{})
+	for _, rowID := range rowIDs {
+		for _, col := range f.mustRow(tx, rowID).Columns() {
+			want[col] = struct{}{}
+		}
+	}
+	exp := make([]uint64, 0, len(want))
+	for col := range want {
+		exp = append(exp, col)
+	}
+	sort.Slice(exp, func(i, j int) bool { return exp[i] < exp[j] })
+
+	row, err := f.unionRows(context.Background(), tx, rowIDs)
+	if err != nil {
+		t.Fatal(err)
+	} else if got := row.Columns(); !sliceEq(got, exp) {
+		t.Fatalf("expected %d columns, got %d", len(exp), len(got))
+	} else if n := row.Count(); n != uint64(len(exp)) {
+		t.Fatalf("expected count %d, got %d", len(exp), n)
+	}
+
+	if row, err := f.unionRows(context.Background(), tx, nil); err != nil {
+		t.Fatal(err)
+	} else if n := row.Count(); n != 0 {
+		t.Fatalf("expected empty row, got %d bits", n)
+	}
+}
+
+// Ensure rowContains reports membership without building rows.
+func TestFragment_RowContains(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	f.mustSetBits(tx, 3, 10, ShardWidth-1)
+	for _, tt := range []struct {
+		rowID, columnID uint64
+		exp             bool
+	}{
+		{3, 10, true},
+		{3, ShardWidth - 1, true},
+		{3, 11, false},
+		{4, 10, false},
+		{3, ShardWidth + 10, false},
+	} {
+		if got, err := f.rowContains(tx, tt.rowID, tt.columnID); err != nil {
+			t.Fatal(err)
+		} else if got != tt.exp {
+			t.Fatalf("row %d, column %d: expected %v, got %v", tt.rowID, tt.columnID, tt.exp, got)
+		}
+	}
+}
+
+// reversedContainerTx is a Tx whose ContainerIterator yields containers in
+// descending key order.
+type reversedContainerTx struct {
+	Tx
+}
+
+func (tx reversedContainerTx) ContainerIterator(index, field, view string, shard uint64, ckey uint64) (roaring.ContainerIterator, bool, error) {
+	citer, found, err := tx.Tx.ContainerIterator(index, field, view, shard, ckey)
+	if err != nil {
+		return nil, false, err
+	}
+	defer citer.Close()
+	r := &sliceContainerIterator{i: -1}
+	for citer.Next() {
+		key, c := citer.Value()
+		r.keys = append([]uint64{key}, r.keys...)
+		r.containers = append([]*roaring.Container{c.Clone()}, r.containers...)
+	}
+	return r, found, nil
+}
+
+type sliceContainerIterator struct {
+	keys       []uint64
+	containers []*roaring.Container
+	i          int
+}
+
+func (it *sliceContainerIterator) Next() bool {
+	it.i++
+	return it.i < len(it.keys)
+}
+
+func (it *sliceContainerIterator) Value() (uint64, *roaring.Container) {
+	return it.keys[it.i], it.containers[it.i]
+}
+
+func (it *sliceContainerIterator) Close() {}
+
+// Ensure a constant value can be imported across a range of columns.
+func TestFragment_ImportValueConst(t *testing.T) {
+	const bitDepth = 16
+
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	// Existing values inside the range are overwritten; those outside it
+	// are left alone.
+	for _, col := range []uint64{9, 10, 70000, 200000} {
+		if _, err := f.setValue(tx, col, bitDepth, 0x7fff); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.importValueConst(tx, 10, 200000, -1234, bitDepth); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		col    uint64
+		value  int64
+		exists bool
+	}{
+		{9, 0x7fff, true},
+		{10, -1234, true},
+		{11, -1234, true},
+		{70000, -1234, true},
+		{199999, -1234, true},
+		{200000, 0x7fff, true},
+		{200001, 0, false},
+	} {
+		if v, exists, err := f.value(tx, tc.col, bitDepth); err != nil {
+			t.Fatal(err)
+		} else if v != tc.value || exists != tc.exists {
+			t.Fatalf("column %d: expected %d/%v, got %d/%v", tc.col, tc.value, tc.exists, v, exists)
+		}
+	}
+
+	// hi is clamped to the end of the shard.
+	if err := f.importValueConst(tx, ShardWidth-2, ShardWidth+100, 0, bitDepth); err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []uint64{ShardWidth - 2, ShardWidth - 1} {
+		if v, exists, err := f.value(tx, col, bitDepth); err != nil {
+			t.Fatal(err)
+		} else if v != 0 || !exists {
+			t.Fatalf("column %d: expected 0/true, got %d/%v", col, v, exists)
+		}
+	}
+	if n := f.mustRow(tx, bsiExistsBit).Count(); n != 200000-10+4 {
+		t.Fatalf("unexpected exists count: %d", n)
+	}
+}
+
+// cancelAfterContext is a context which reports itself canceled once Err
+// has been called n times.
+type cancelAfterContext struct {
+	context.Context
+	n int
+}
+
+func (c *cancelAfterContext) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+// Ensure a canceled import stops between batches, and that rolling back
+// the transaction restores the prior state.
+func TestFragment_ImportCancel(t *testing.T) {
+	f, idx, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	// Row 20 is clear of the BSI rows used by the value imports.
+	f.mustSetBits(tx, 20, 7)
+	if _, err := f.setValue(tx, 7, 8, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 3*importBatchSize + 1
+	rowIDs, columnIDs := make([]uint64, n), make([]uint64, n)
+	values := make([]int64, n)
+	for i := range columnIDs {
+		rowIDs[i], columnIDs[i], values[i] = 20, uint64(i), 5
+	}
+
+	for _, imp := range []struct {
+		name string
+		fn   func(ctx context.Context, tx Tx) error
+	}{
+		{"bulkImport", func(ctx context.Context, tx Tx) error {
+			// bulkImport reuses its arguments' storage.
+			rows, cols := append([]uint64(nil), rowIDs...), append([]uint64(nil), columnIDs...)
+			return f.bulkImport(ctx, tx, rows, cols, &ImportOptions{})
+		}},
+		{"importValue", func(ctx context.Context, tx Tx) error {
+			return f.importValue(ctx, tx, columnIDs, values, 8, false)
+		}},
+	} {
+		t.Run(imp.name, func(t *testing.T) {
+			tx := idx.holder.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: f, Shard: f.shard})
+			ctx := &cancelAfterContext{Context: context.Background(), n: 2}
+			if err := imp.fn(ctx, tx); err != context.Canceled {
+				tx.Rollback()
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+			tx.Rollback()
+
+			tx = idx.holder.txf.NewTx(Txo{Write: !writable, Index: idx, Fragment: f, Shard: f.shard})
+			defer tx.Rollback()
+			if cols := f.mustRow(tx, 20).Columns(); !reflect.DeepEqual(cols, []uint64{7}) {
+				t.Fatalf("expected prior row after rollback, got %d columns", len(cols))
+			}
+			if v, exists, err := f.value(tx, 7, 8); err != nil {
+				t.Fatal(err)
+			} else if v != 100 || !exists {
+				t.Fatalf("expected prior value 100 after rollback, got %d/%v", v, exists)
+			}
+		})
+	}
+}
+
+// Ensure importing from a channel matches importing the same pairs from
+// slices, including a final partial batch.
+func TestFragment_ImportChannel(t *testing.T) {
+	n := importBatchSize + importBatchSize/2
+	rowIDs, columnIDs := make([]uint64, n), make([]uint64, n)
+	for i := range rowIDs {
+		rowIDs[i], columnIDs[i] = uint64(i%7), uint64((i*7919)%ShardWidth)
+	}
+
+	want, _, wtx := mustOpenFragment(t)
+	defer want.Clean(t)
+	rows, cols := append([]uint64(nil), rowIDs...), append([]uint64(nil), columnIDs...)
+	if err := want.bulkImportT(wtx, rows, cols, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	ch := make(chan [2]uint64, 16)
+	go func() {
+		defer close(ch)
+		for i := range rowIDs {
+			ch <- [2]uint64{rowIDs[i], columnIDs[i]}
+		}
+	}()
+	if err := f.importChannel(context.Background(), tx, ch, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, exp := f.mustBits(t, tx), want.mustBits(t, wtx); len(got) != len(exp) {
+		t.Fatalf("expected %d bits, got %d", len(exp), len(got))
+	} else if !reflect.DeepEqual(got, exp) {
+		t.Fatal("channel import doesn't match slice import")
+	}
+
+	// A canceled import stops waiting for the channel.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := f.importChannel(ctx, tx, make(chan [2]uint64), &ImportOptions{}); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// mustBits returns every bit set in the fragment, as (rowID, columnID) pairs
+// in row-major order.
+func (f *fragment) mustBits(tb testing.TB, tx Tx) [][2]uint64 {
+	var bits [][2]uint64
+	if err := f.forEachBit(tx, func(rowID, columnID uint64) error {
+		bits = append(bits, [2]uint64{rowID, columnID})
+		return nil
+	}); err != nil {
+		tb.Fatal(err)
+	}
+	return bits
+}
+
+// Ensure distinct BSI values are counted exactly.
+func TestFragment_DistinctValues(t *testing.T) {
+	const bitDepth = 12
+
+	t.Run("AllDistinct", func(t *testing.T) {
+		f, _, tx := mustOpenFragment(t)
+		defer f.Clean(t)
+
+		// Every column holds its own value, including the negated ones.
+		for i := uint64(0); i < 1000; i++ {
+			v := int64(i)
+			if i%2 == 1 {
+				v = -v
+			}
+			if _, err := f.setValue(tx, i, bitDepth, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if n, err := f.distinctValues(tx, nil, bitDepth); err != nil {
+			t.Fatal(err)
+		} else if n != 1000 {
+			t.Fatalf("expected 1000 distinct values, got %d", n)
+		}
+	})
+
+	t.Run("Duplicates", func(t *testing.T) {
+		f, _, tx := mustOpenFragment(t)
+		defer f.Clean(t)
+
+		// -3 and 3 are distinct values.
+		vals := []int64{3, 3, -3, 7, 0, 7, -3, 3}
+		for i, v := range vals {
+			if _, err := f.setValue(tx, uint64(i*1000), bitDepth, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if n, err := f.distinctValues(tx, nil, bitDepth); err != nil {
+			t.Fatal(err)
+		} else if n != 4 {
+			t.Fatalf("expected 4 distinct values, got %d", n)
+		}
+		if n, err := f.distinctValues(tx, NewRow(0, 1000, 3000), bitDepth); err != nil {
+			t.Fatal(err)
+		} else if n != 2 {
+			t.Fatalf("expected 2 distinct filtered values, got %d", n)
+		}
+		if n, err := f.distinctValues(tx, NewRow(), bitDepth); err != nil {
+			t.Fatal(err)
+		} else if n != 0 {
+			t.Fatalf("expected empty filter to count 0, got %d", n)
+		}
+	})
+}
+
+// rowReadTx counts the rows read through OffsetRange.
+type rowReadTx struct {
+	Tx
+	n int
+}
+
+func (tx *rowReadTx) OffsetRange(index, field, view string, shard uint64, offset, start, end uint64) (*roaring.Bitmap, error) {
+	tx.n++
+	return tx.Tx.OffsetRange(index, field, view, shard, offset, start, end)
+}
+
+// Ensure valueStats agrees with sum, min and max, reading each row once.
+func TestFragment_ValueStats(t *testing.T) {
+	const bitDepth = 12
+
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	vals := []int64{382, -17, 0, 1024, -2000, 5, 5, -1}
+	for i, v := range vals {
+		if _, err := f.setValue(tx, uint64(i*1000), bitDepth, v); err != nil {
 			t.Fatal(err)
-		} else if n != 5 {
-			t.Fatalf("unexpected count: %d", n)
-		} else if got, exp := sum, int64(382+300-600+2818+300); got != exp {
-			t.Fatalf("unexpected sum: got: %d, exp: %d", sum, exp)
 		}
-	})
+	}
 
-	t.Run("WithFilter", func(t *testing.T) {
-		if sum, n, err := f.sum(tx, NewRow(2000, 4000, 5000), bitDepth); err != nil {
+	for _, tc := range []struct {
+		name   string
+		filter *Row
+	}{
+		{"All", nil},
+		{"Filtered", NewRow(0, 1000, 4000, 7000)},
+		{"Negative", NewRow(1000, 4000, 7000)},
+		{"Positive", NewRow(0, 3000, 5000)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sum, count, err := f.sum(tx, tc.filter, bitDepth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			min, _, err := f.min(tx, tc.filter, bitDepth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			max, _, err := f.max(tx, tc.filter, bitDepth)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rtx := &rowReadTx{Tx: tx}
+			stats, err := f.valueStats(rtx, tc.filter, bitDepth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp := ValueStats{Sum: big.NewInt(sum), Count: count, Min: min, Max: max, Mean: float64(sum) / float64(count)}
+			if stats.Sum.Cmp(exp.Sum) != 0 || stats.Count != exp.Count || stats.Min != exp.Min || stats.Max != exp.Max || stats.Mean != exp.Mean {
+				t.Fatalf("expected %+v, got %+v", exp, stats)
+			}
+			// The exists row, the sign row and each bit slice.
+			if rtx.n != bitDepth+2 {
+				t.Fatalf("expected %d row reads, got %d", bitDepth+2, rtx.n)
+			}
+		})
+	}
+
+	t.Run("EmptyFilter", func(t *testing.T) {
+		if stats, err := f.valueStats(tx, NewRow(), bitDepth); err != nil {
 			t.Fatal(err)
-		} else if n != 2 {
-			t.Fatalf("unexpected count: %d", n)
-		} else if got, exp := sum, int64(300+300); got != exp {
-			t.Fatalf("unexpected sum: got: %d, exp: %d", sum, exp)
+		} else if stats.Sum.Sign() != 0 || stats.Count != 0 || stats.Min != 0 || stats.Max != 0 || stats.Mean != 0 {
+			t.Fatalf("expected zero stats, got %+v", stats)
 		}
 	})
+}
 
-	tx.Rollback()
-	tx = idx.holder.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: f, Shard: f.shard})
-	defer tx.Rollback()
-
-	// verify that clearValue clears values
-	if _, err := f.clearValue(tx, 1000, bitDepth, 23); err != nil {
-		t.Fatal(err)
-	}
+// Ensure valueStats sums values near the int64 limits without overflowing,
+// and agrees with sumBig and distinctValues.
+func TestFragment_ValueStats_Overflow(t *testing.T) {
+	const bitDepth = 63
 
-	PanicOn(tx.Commit())
-	tx = idx.holder.txf.NewTx(Txo{Write: !writable, Index: idx, Fragment: f, Shard: f.shard})
-	defer tx.Rollback()
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
 
-	t.Run("ClearValue", func(t *testing.T) {
-		if sum, n, err := f.sum(tx, nil, bitDepth); err != nil {
+	vals := []int64{math.MaxInt64, math.MaxInt64, math.MaxInt64 - 1, -math.MaxInt64, 1}
+	exp := new(big.Int)
+	for i, v := range vals {
+		if _, err := f.setValue(tx, uint64(i), bitDepth, v); err != nil {
 			t.Fatal(err)
-		} else if n != 4 {
-			t.Fatalf("unexpected count: %d", n)
-		} else if got, exp := sum, int64(3800-382-600); got != exp {
-			t.Fatalf("unexpected sum: got: %d, exp: %d", sum, exp)
 		}
-	})
+		exp.Add(exp, big.NewInt(v))
+	}
+
+	stats, err := f.valueStats(tx, nil, bitDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sum.Cmp(exp) != 0 {
+		t.Fatalf("expected sum %v, got %v", exp, stats.Sum)
+	}
+	if sum, _, err := f.sumBig(tx, nil, bitDepth); err != nil {
+		t.Fatal(err)
+	} else if sum.Cmp(stats.Sum) != 0 {
+		t.Fatalf("expected sum to agree with sumBig's %v, got %v", sum, stats.Sum)
+	}
+	if stats.Count != 5 || stats.Min != -math.MaxInt64 || stats.Max != math.MaxInt64 {
+		t.Fatalf("unexpected count, min or max: %+v", stats)
+	}
+	sum, _ := new(big.Float).SetInt(exp).Float64()
+	if mean := sum / 5; stats.Mean != mean || stats.Mean <= 0 {
+		t.Fatalf("expected mean %v, got %v", sum/5, stats.Mean)
+	}
+	if n, err := f.distinctValues(tx, nil, bitDepth); err != nil {
+		t.Fatal(err)
+	} else if n != 4 {
+		t.Fatalf("expected 4 distinct values, got %d", n)
+	}
 }
 
 // Ensure a fragment can find the min and max of values.
@@ -913,6 +2204,41 @@ func TestFragment_Range(t *testing.T) {
 			t.Fatalf("unepxected coulmns: %+v", b.Columns())
 		}
 	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		f, _, tx := mustOpenFragment(t)
+		defer f.Clean(t)
+
+		for col := uint64(0); col < 1000; col++ {
+			if _, err := f.setValue(tx, col, bitDepth, int64(col%7)-3); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		filter := NewRow(3, 4, 10, 17, 500, 501, 998, 5000)
+		for _, op := range []pql.Token{pql.EQ, pql.NEQ, pql.LT, pql.GTE} {
+			for _, predicate := range []int64{-3, 0, 1, 3, 100} {
+				all, err := f.rangeOp(tx, op, bitDepth, predicate)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := f.rangeOpFiltered(tx, op, bitDepth, predicate, filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if exp := all.Intersect(filter).Columns(); !reflect.DeepEqual(got.Columns(), exp) {
+					t.Fatalf("%s %d: expected %v, got %v", op, predicate, exp, got.Columns())
+				}
+			}
+		}
+
+		// An empty filter matches nothing.
+		if b, err := f.rangeOpFiltered(tx, pql.EQ, bitDepth, 0, NewRow()); err != nil {
+			t.Fatal(err)
+		} else if b.Any() {
+			t.Fatalf("expected no columns, got %v", b.Columns())
+		}
+	})
 }
 
 // benchmarkSetValues is a helper function to explore, very roughly, the cost
@@ -988,7 +2314,7 @@ func makeBenchmarkImportValueData(b *testing.B, bitDepth uint64, cfunc func(uint
 func benchmarkImportValues(b *testing.B, tx Tx, bitDepth uint64, f *fragment, cfunc func(uint64) uint64) {
 	batches := makeBenchmarkImportValueData(b, bitDepth, cfunc)
 	for _, req := range batches {
-		err := f.importValue(tx, req.ColumnIDs, req.Values, bitDepth, false)
+		err := f.importValueT(tx, req.ColumnIDs, req.Values, bitDepth, false)
 		if err != nil {
 			b.Fatalf("error importing values: %s", err)
 		}
@@ -1047,7 +2373,7 @@ func BenchmarkFragment_RepeatedSmallImports(b *testing.B) {
 						}
 						b.StartTimer()
 						for i := 0; i < numUpdates; i++ {
-							err := f.bulkImportStandard(tx,
+							err := f.bulkImportStandard(context.Background(), tx,
 								updateRows[bitsPerUpdate*i:bitsPerUpdate*(i+1)],
 								updateRows[bitsPerUpdate*i:bitsPerUpdate*(i+1)],
 								&ImportOptions{},
@@ -1123,13 +2449,13 @@ func BenchmarkFragment_RepeatedSmallValueImports(b *testing.B) {
 					b.StopTimer()
 					f, _, tx := mustOpenFragment(b)
 
-					err := f.importValue(tx, initialCols, initialVals, 21, false)
+					err := f.importValueT(tx, initialCols, initialVals, 21, false)
 					if err != nil {
 						b.Fatalf("initial value import: %v", err)
 					}
 					b.StartTimer()
 					for j := 0; j < numUpdates; j++ {
-						err := f.importValue(tx,
+						err := f.importValueT(tx,
 							updateCols[valsPerUpdate*j:valsPerUpdate*(j+1)],
 							updateVals[valsPerUpdate*j:valsPerUpdate*(j+1)],
 							21,
@@ -1251,6 +2577,116 @@ func TestFragment_TopN_Intersect_Large(t *testing.T) {
 	}
 }
 
+// Ensure exact top rows are counted from storage, even when the cache's
+// counts are wrong.
+func TestFragment_TopN_Exact(t *testing.T) {
+	f, _, tx := mustOpenFragment(t, OptFieldTypeSet(CacheTypeRanked, DefaultCacheSize))
+	defer f.Clean(t)
+
+	f.mustSetBits(tx, 100, 1, 2, 3, 4)
+	f.mustSetBits(tx, 101, 1, 2, 3)
+	f.mustSetBits(tx, 102, 1, 5)
+	f.RecalculateCache()
+
+	// Desync the cache, so it ranks the rows backwards.
+	f.cache.BulkAdd(100, 1)
+	f.cache.BulkAdd(101, 2)
+	f.cache.BulkAdd(102, 9)
+	f.cache.Invalidate()
+
+	if pairs, err := f.top(tx, topOptions{N: 2}); err != nil {
+		t.Fatal(err)
+	} else if pairs[0] != (Pair{ID: 102, Count: 9}) {
+		t.Fatalf("expected the cache's count, got %v", pairs)
+	}
+
+	if pairs, err := f.top(tx, topOptions{N: 2, Exact: true}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(pairs, []Pair{
+		{ID: 100, Count: 4},
+		{ID: 101, Count: 3},
+	}) {
+		t.Fatalf("unexpected pairs: %s", spew.Sdump(pairs))
+	}
+
+	// With a source row, counts are of the intersection.
+	if pairs, err := f.top(tx, topOptions{N: 1, Src: NewRow(3, 4, 5), Exact: true}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(pairs, []Pair{
+		{ID: 100, Count: 2},
+	}) {
+		t.Fatalf("unexpected intersection pairs: %s", spew.Sdump(pairs))
+	}
+}
+
+// Ensure imports which skip cache maintenance leave the cache stale, even
+// across reopening the fragment, until RecalculateCache, which then matches
+// a load which didn't skip it.
+func TestFragment_SkipCacheUpdate(t *testing.T) {
+	load := func(skip bool) *fragment {
+		f, _, tx := mustOpenFragment(t)
+		opts := &ImportOptions{SkipCacheUpdate: skip}
+		var rowIDs, columnIDs []uint64
+		for rowID := uint64(1); rowID <= 3; rowID++ {
+			for col := uint64(0); col < 10*rowID; col++ {
+				rowIDs, columnIDs = append(rowIDs, rowID), append(columnIDs, col)
+			}
+		}
+		if err := f.bulkImportT(tx, rowIDs, columnIDs, opts); err != nil {
+			t.Fatal(err)
+		}
+		positions := make([]uint64, 25)
+		for i := range positions {
+			positions[i] = 4*ShardWidth + uint64(i)
+		}
+		if err := f.importRoaringWithOptions(context.Background(), tx, roaringBytes(t, positions...), opts); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if skip && f.cache.Len() != 0 {
+			t.Fatalf("expected skipped import not to fill the cache, got %d entries", f.cache.Len())
+		}
+		// The cache stays stale across reopening the fragment.
+		if err := f.Reopen(); err != nil {
+			t.Fatal(err)
+		} else if f.cacheStale != skip {
+			t.Fatalf("expected cacheStale to be %v after reopening, got %v", skip, f.cacheStale)
+		}
+		f.RecalculateCache()
+		if f.cacheStale {
+			t.Fatal("expected RecalculateCache to clear cacheStale")
+		}
+		return f
+	}
+	skipped, loaded := load(true), load(false)
+	defer skipped.Clean(t)
+	defer loaded.Clean(t)
+
+	exp := []bitmapPair{{ID: 3, Count: 30}, {ID: 4, Count: 25}, {ID: 2, Count: 20}, {ID: 1, Count: 10}}
+	if pairs := skipped.cache.Top(); !reflect.DeepEqual(pairs, exp) {
+		t.Fatalf("expected rebuilt cache %v, got %v", exp, pairs)
+	} else if pairs := loaded.cache.Top(); !reflect.DeepEqual(pairs, exp) {
+		t.Fatalf("expected maintained cache %v, got %v", exp, pairs)
+	}
+	stx, err := skipped.holder.BeginTx(false, skipped.idx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stx.Rollback()
+	ltx, err := loaded.holder.BeginTx(false, loaded.idx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ltx.Rollback()
+	for rowID := uint64(1); rowID <= 4; rowID++ {
+		if a, b := skipped.mustRow(stx, rowID).Columns(), loaded.mustRow(ltx, rowID).Columns(); !reflect.DeepEqual(a, b) {
+			t.Fatalf("row %d: expected %v, got %v", rowID, b, a)
+		}
+	}
+}
+
 // Ensure a fragment can return top rows when specified by ID.
 func TestFragment_TopN_IDs(t *testing.T) {
 	f, idx, tx := mustOpenFragment(t, OptFieldTypeSet(CacheTypeRanked, DefaultCacheSize))
@@ -1658,7 +3094,7 @@ func TestFragment_ImportSet(t *testing.T) {
 			defer f.Clean(t)
 
 			// Set import.
-			err := f.bulkImport(tx, test.setRowIDs, test.setColIDs, &ImportOptions{})
+			err := f.bulkImportT(tx, test.setRowIDs, test.setColIDs, &ImportOptions{})
 			if err != nil {
 				t.Fatalf("bulk importing ids: %v", err)
 			}
@@ -1672,7 +3108,7 @@ func TestFragment_ImportSet(t *testing.T) {
 			}
 
 			// Clear import.
-			err = f.bulkImport(tx, test.clearRowIDs, test.clearColIDs, &ImportOptions{Clear: true})
+			err = f.bulkImportT(tx, test.clearRowIDs, test.clearColIDs, &ImportOptions{Clear: true})
 			if err != nil {
 				t.Fatalf("bulk clearing ids: %v", err)
 			}
@@ -1776,7 +3212,7 @@ func TestFragment_ImportSet_WithTxCommit(t *testing.T) {
 			defer f.Clean(t)
 
 			// Set import.
-			err := f.bulkImport(tx, test.setRowIDs, test.setColIDs, &ImportOptions{})
+			err := f.bulkImportT(tx, test.setRowIDs, test.setColIDs, &ImportOptions{})
 			if err != nil {
 				t.Fatalf("bulk importing ids: %v", err)
 			}
@@ -1798,7 +3234,7 @@ func TestFragment_ImportSet_WithTxCommit(t *testing.T) {
 			defer tx.Rollback()
 
 			// Clear import.
-			err = f.bulkImport(tx, test.clearRowIDs, test.clearColIDs, &ImportOptions{Clear: true})
+			err = f.bulkImportT(tx, test.clearRowIDs, test.clearColIDs, &ImportOptions{Clear: true})
 			if err != nil {
 				t.Fatalf("bulk clearing ids: %v", err)
 			}
@@ -1834,12 +3270,12 @@ func TestFragment_ConcurrentImport(t *testing.T) {
 		eg.Go(func() error {
 			tx := idx.holder.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: f, Shard: shard})
 			defer func() { PanicOn(tx.Commit()) }()
-			return f.bulkImportStandard(tx, []uint64{1, 2}, []uint64{1, 2}, &ImportOptions{})
+			return f.bulkImportStandard(context.Background(), tx, []uint64{1, 2}, []uint64{1, 2}, &ImportOptions{})
 		})
 		eg.Go(func() error {
 			tx := idx.holder.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: f, Shard: shard})
 			defer func() { PanicOn(tx.Commit()) }()
-			return f.bulkImportStandard(tx, []uint64{3, 4}, []uint64{3, 4}, &ImportOptions{})
+			return f.bulkImportStandard(context.Background(), tx, []uint64{3, 4}, []uint64{3, 4}, &ImportOptions{})
 		})
 		err := eg.Wait()
 		if err != nil {
@@ -1848,6 +3284,100 @@ func TestFragment_ConcurrentImport(t *testing.T) {
 	})
 }
 
+// Ensure the holder's limit on concurrent imports is respected, and that
+// every import waiting for a slot completes.
+func TestFragment_ImportConcurrencyLimit(t *testing.T) {
+	const limit, n = 3, 12
+	f, idx, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	tx.Rollback()
+	idx.holder.importSem = make(chan struct{}, limit)
+	v := idx.Field(f.field()).view(f.view())
+
+	// Imports write to storage while holding their slot, so a Tx which
+	// stalls on writes can count the imports in flight.
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	stall := func() {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}
+
+	eg := errgroup.Group{}
+	data := roaringBytes(t, ShardWidth+1)
+	for i := 1; i <= n; i++ {
+		shard := uint64(i)
+		frag, err := v.CreateFragmentIfNotExists(shard)
+		if err != nil {
+			t.Fatalf("creating fragment: %v", err)
+		}
+		eg.Go(func() error {
+			tx := &stallingTx{Tx: idx.holder.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: frag, Shard: shard}), stall: stall}
+			defer tx.Rollback()
+			columnID := shard*ShardWidth + 1
+			var err error
+			if shard%2 == 0 {
+				err = frag.bulkImport(context.Background(), tx, []uint64{1}, []uint64{columnID}, &ImportOptions{})
+			} else {
+				err = frag.importRoaring(context.Background(), tx, data, false)
+			}
+			if err != nil {
+				return err
+			}
+			return tx.Commit()
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		t.Fatalf("importing: %v", err)
+	}
+	if peak > limit {
+		t.Fatalf("expected at most %d concurrent imports, got %d", limit, peak)
+	}
+	if len(idx.holder.importSem) != 0 {
+		t.Fatalf("expected all import slots released, %d held", len(idx.holder.importSem))
+	}
+
+	// An import waiting for a slot gives up when its context is done.
+	for i := 0; i < limit; i++ {
+		idx.holder.importSem <- struct{}{}
+	}
+	frag, err := v.Fragment(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx = idx.holder.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: frag, Shard: 1})
+	defer tx.Rollback()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := frag.importRoaring(ctx, tx, roaringBytes(t, 2*ShardWidth+2), false); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// stallingTx calls stall before each bulk write.
+type stallingTx struct {
+	Tx
+	stall func()
+}
+
+func (tx *stallingTx) Add(index, field, view string, shard uint64, a ...uint64) (int, error) {
+	tx.stall()
+	return tx.Tx.Add(index, field, view, shard, a...)
+}
+
+func (tx *stallingTx) ImportRoaringBits(index, field, view string, shard uint64, rit roaring.RoaringIterator, clear bool, log bool, rowSize uint64) (int, map[uint64]int, error) {
+	tx.stall()
+	return tx.Tx.ImportRoaringBits(index, field, view, shard, rit, clear, log, rowSize)
+}
+
 // Ensure a fragment can import mutually exclusive values.
 func TestFragment_ImportMutex(t *testing.T) {
 	tests := []struct {
@@ -1936,7 +3466,7 @@ func TestFragment_ImportMutex(t *testing.T) {
 			defer f.Clean(t)
 
 			// Set import.
-			err := f.bulkImport(tx, test.setRowIDs, test.setColIDs, &ImportOptions{})
+			err := f.bulkImportT(tx, test.setRowIDs, test.setColIDs, &ImportOptions{})
 			if err != nil {
 				t.Fatalf("bulk importing ids: %v", err)
 			}
@@ -1950,7 +3480,7 @@ func TestFragment_ImportMutex(t *testing.T) {
 			}
 
 			// Clear import.
-			err = f.bulkImport(tx, test.clearRowIDs, test.clearColIDs, &ImportOptions{Clear: true})
+			err = f.bulkImportT(tx, test.clearRowIDs, test.clearColIDs, &ImportOptions{Clear: true})
 			if err != nil {
 				t.Fatalf("bulk clearing ids: %v", err)
 			}
@@ -1966,6 +3496,181 @@ func TestFragment_ImportMutex(t *testing.T) {
 	}
 }
 
+// Ensure a presorted mutex import gives the same results as the sorting path
+// on sorted input, and that the paranoia check catches unsorted input.
+func TestFragment_ImportMutex_Presorted(t *testing.T) {
+	makeInput := func() (rows, cols []uint64) {
+		for col := uint64(0); col < 5000; col += 3 {
+			rows = append(rows, col%7)
+			cols = append(cols, col)
+		}
+		return rows, cols
+	}
+
+	var results [2][7][]uint64
+	for i, presorted := range []bool{false, true} {
+		f, _, tx := mustOpenFragment(t, OptFieldTypeMutex(DefaultCacheType, DefaultCacheSize))
+		// Pre-populate some columns so the import has to clear old values.
+		if err := f.bulkImportT(tx, []uint64{9, 9, 9}, []uint64{0, 3, 4}, &ImportOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		rows, cols := makeInput()
+		if err := f.bulkImportT(tx, rows, cols, &ImportOptions{Presorted: presorted}); err != nil {
+			t.Fatalf("importing (presorted=%v): %v", presorted, err)
+		}
+		for r := range results[i] {
+			results[i][r] = f.mustRow(tx, uint64(r)).Columns()
+		}
+		if cols := f.mustRow(tx, 9).Columns(); !reflect.DeepEqual(cols, []uint64{4}) {
+			t.Fatalf("presorted=%v: expected row 9 to keep only column 4, got %v", presorted, cols)
+		}
+		f.Clean(t)
+	}
+	if !reflect.DeepEqual(results[0], results[1]) {
+		t.Fatalf("presorted import differs from sorted import:\n%v\n%v", results[1], results[0])
+	}
+
+	defer func(v bool) { importSortedParanoia = v }(importSortedParanoia)
+	importSortedParanoia = true
+	f, _, tx := mustOpenFragment(t, OptFieldTypeMutex(DefaultCacheType, DefaultCacheSize))
+	defer f.Clean(t)
+	if err := f.bulkImportT(tx, []uint64{1, 2}, []uint64{5, 4}, &ImportOptions{Presorted: true}); err == nil {
+		t.Fatal("expected error importing unsorted input marked presorted")
+	}
+	if err := f.bulkImportT(tx, []uint64{1, 2}, []uint64{4, 5}, &ImportOptions{Presorted: true}); err != nil {
+		t.Fatalf("unexpected error importing sorted input: %v", err)
+	}
+}
+
+// Ensure importMutexBatch resolves columns given more than one row as its
+// policy says.
+func TestFragment_ImportMutexBatch(t *testing.T) {
+	// Column 1 is given rows 3, 5 and 6, column 2 row 4 twice.
+	rows := []uint64{3, 4, 5, 4, 6}
+	cols := []uint64{1, 2, 1, 2, 1}
+
+	for _, tc := range []struct {
+		name   string
+		policy MutexConflictPolicy
+		exp    map[uint64][]uint64
+		err    *MutexConflictError
+	}{
+		{"LastWins", MutexLastWins, map[uint64][]uint64{3: nil, 4: {2}, 5: nil, 6: {1}, 9: {0}}, nil},
+		{"FirstWins", MutexFirstWins, map[uint64][]uint64{3: {1}, 4: {2}, 5: nil, 6: nil, 9: {0}}, nil},
+		{"Error", MutexErrorOnConflict, map[uint64][]uint64{3: nil, 4: nil, 5: nil, 6: nil, 9: {0, 1}}, &MutexConflictError{Column: 1, Rows: [2]uint64{3, 5}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, _, tx := mustOpenFragment(t, OptFieldTypeMutex(DefaultCacheType, DefaultCacheSize))
+			defer f.Clean(t)
+			if err := f.bulkImportT(tx, []uint64{9, 9}, []uint64{0, 1}, &ImportOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			err := f.importMutexBatch(context.Background(), tx, append([]uint64(nil), rows...), append([]uint64(nil), cols...), tc.policy)
+			if tc.err == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				var conflict MutexConflictError
+				if !errors.As(err, &conflict) {
+					t.Fatalf("expected mutex conflict, got %v", err)
+				} else if conflict != *tc.err {
+					t.Fatalf("expected %+v, got %+v", *tc.err, conflict)
+				}
+			}
+			for rowID, exp := range tc.exp {
+				if got := f.mustRow(tx, rowID).Columns(); !reflect.DeepEqual(got, exp) && len(got)+len(exp) > 0 {
+					t.Fatalf("row %d: expected %v, got %v", rowID, exp, got)
+				}
+			}
+		})
+	}
+
+	// Giving a column the same row twice isn't a conflict.
+	f, _, tx := mustOpenFragment(t, OptFieldTypeMutex(DefaultCacheType, DefaultCacheSize))
+	defer f.Clean(t)
+	if err := f.importMutexBatch(context.Background(), tx, []uint64{4, 4}, []uint64{2, 2}, MutexErrorOnConflict); err != nil {
+		t.Fatal(err)
+	} else if got := f.mustRow(tx, 4).Columns(); !reflect.DeepEqual(got, []uint64{2}) {
+		t.Fatalf("expected row 4 to have column 2, got %v", got)
+	}
+}
+
+// Ensure imports hinted as row-major or column-major give the same results
+// as unhinted imports, that a wrong hint still imports correctly, and that
+// the paranoia check catches it.
+func TestFragment_ImportOrder(t *testing.T) {
+	// Pairs in row-major order, across several containers.
+	var rows, cols []uint64
+	for rowID := uint64(0); rowID < 5; rowID++ {
+		for col := rowID; col < 3*65536; col += 97 {
+			rows, cols = append(rows, rowID), append(cols, col)
+		}
+	}
+	rowMajor := func() ([]uint64, []uint64) {
+		return append([]uint64(nil), rows...), append([]uint64(nil), cols...)
+	}
+	columnMajor := func() ([]uint64, []uint64) {
+		r, c := rowMajor()
+		sort.Stable(&parallelSlices{rows: r, cols: c})
+		return r, c
+	}
+	shuffled := func() ([]uint64, []uint64) {
+		r, c := rowMajor()
+		rand.New(rand.NewSource(3)).Shuffle(len(r), func(i, j int) {
+			r[i], r[j], c[i], c[j] = r[j], r[i], c[j], c[i]
+		})
+		return r, c
+	}
+
+	for _, tc := range []struct {
+		name  string
+		input func() ([]uint64, []uint64)
+		order ImportOrder
+	}{
+		{"unknown", shuffled, ImportOrderUnknown},
+		{"row-major", rowMajor, ImportOrderRowMajor},
+		{"column-major", columnMajor, ImportOrderColumnMajor},
+		{"wrong-row-major", shuffled, ImportOrderRowMajor},
+		{"wrong-column-major", rowMajor, ImportOrderColumnMajor},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, _, tx := mustOpenFragment(t)
+			defer f.Clean(t)
+			r, c := tc.input()
+			if err := f.bulkImportT(tx, r, c, &ImportOptions{Order: tc.order}); err != nil {
+				t.Fatal(err)
+			}
+			for rowID := uint64(0); rowID < 5; rowID++ {
+				var exp []uint64
+				for i := range rows {
+					if rows[i] == rowID {
+						exp = append(exp, cols[i])
+					}
+				}
+				if got := f.mustRow(tx, rowID).Columns(); !reflect.DeepEqual(got, exp) {
+					t.Fatalf("row %d: expected %d columns, got %d", rowID, len(exp), len(got))
+				}
+			}
+		})
+	}
+
+	defer func(v bool) { importSortedParanoia = v }(importSortedParanoia)
+	importSortedParanoia = true
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	if r, c := columnMajor(); f.bulkImportT(tx, r, c, &ImportOptions{Order: ImportOrderRowMajor}) == nil {
+		t.Fatal("expected paranoia to catch column-major input hinted as row-major")
+	}
+	if r, c := rowMajor(); f.bulkImportT(tx, r, c, &ImportOptions{Order: ImportOrderColumnMajor}) == nil {
+		t.Fatal("expected paranoia to catch row-major input hinted as column-major")
+	}
+	if r, c := columnMajor(); f.bulkImportT(tx, r, c, &ImportOptions{Order: ImportOrderColumnMajor}) != nil {
+		t.Fatal("expected column-major input hinted as column-major to pass")
+	}
+}
+
 // Ensure a fragment can import mutually exclusive values.
 // Now with Commits in the middle.
 func TestFragment_ImportMutex_WithTxCommit(t *testing.T) {
@@ -2055,7 +3760,7 @@ func TestFragment_ImportMutex_WithTxCommit(t *testing.T) {
 			defer f.Clean(t)
 
 			// Set import.
-			err := f.bulkImport(tx, test.setRowIDs, test.setColIDs, &ImportOptions{})
+			err := f.bulkImportT(tx, test.setRowIDs, test.setColIDs, &ImportOptions{})
 			if err != nil {
 				t.Fatalf("bulk importing ids: %v", err)
 			}
@@ -2077,7 +3782,7 @@ func TestFragment_ImportMutex_WithTxCommit(t *testing.T) {
 			defer tx.Rollback()
 
 			// Clear import.
-			err = f.bulkImport(tx, test.clearRowIDs, test.clearColIDs, &ImportOptions{Clear: true})
+			err = f.bulkImportT(tx, test.clearRowIDs, test.clearColIDs, &ImportOptions{Clear: true})
 			if err != nil {
 				t.Fatalf("bulk clearing ids: %v", err)
 			}
@@ -2186,7 +3891,7 @@ func TestFragment_ImportBool(t *testing.T) {
 			defer f.Clean(t)
 
 			// Set import.
-			err := f.bulkImport(tx, test.setRowIDs, test.setColIDs, &ImportOptions{})
+			err := f.bulkImportT(tx, test.setRowIDs, test.setColIDs, &ImportOptions{})
 			if err != nil {
 				t.Fatalf("bulk importing ids: %v", err)
 			}
@@ -2200,7 +3905,7 @@ func TestFragment_ImportBool(t *testing.T) {
 			}
 
 			// Clear import.
-			err = f.bulkImport(tx, test.clearRowIDs, test.clearColIDs, &ImportOptions{Clear: true})
+			err = f.bulkImportT(tx, test.clearRowIDs, test.clearColIDs, &ImportOptions{Clear: true})
 			if err != nil {
 				t.Fatalf("bulk importing ids: %v", err)
 			}
@@ -2305,7 +4010,7 @@ func TestFragment_ImportBool_WithTxCommit(t *testing.T) {
 			defer f.Clean(t)
 
 			// Set import.
-			err := f.bulkImport(tx, test.setRowIDs, test.setColIDs, &ImportOptions{})
+			err := f.bulkImportT(tx, test.setRowIDs, test.setColIDs, &ImportOptions{})
 			if err != nil {
 				t.Fatalf("bulk importing ids: %v", err)
 			}
@@ -2327,7 +4032,7 @@ func TestFragment_ImportBool_WithTxCommit(t *testing.T) {
 			defer tx.Rollback()
 
 			// Clear import.
-			err = f.bulkImport(tx, test.clearRowIDs, test.clearColIDs, &ImportOptions{Clear: true})
+			err = f.bulkImportT(tx, test.clearRowIDs, test.clearColIDs, &ImportOptions{Clear: true})
 			if err != nil {
 				t.Fatalf("bulk importing ids: %v", err)
 			}
@@ -2376,7 +4081,7 @@ func BenchmarkFragment_Import(b *testing.B) {
 		f, idx, tx := mustOpenFragment(b)
 		_ = idx
 		b.StartTimer()
-		if err := f.bulkImport(tx, rowsUse, colsUse, options); err != nil {
+		if err := f.bulkImportT(tx, rowsUse, colsUse, options); err != nil {
 			b.Errorf("Error Building Sample: %s", err)
 		}
 		b.StopTimer()
@@ -2385,6 +4090,67 @@ func BenchmarkFragment_Import(b *testing.B) {
 	}
 }
 
+func BenchmarkFragment_ImportMutex(b *testing.B) {
+	rows := make([]uint64, ShardWidth/2)
+	cols := make([]uint64, ShardWidth/2)
+	for i := range cols {
+		rows[i] = uint64(i % 16)
+		cols[i] = uint64(i * 2)
+	}
+	rowsUse, colsUse := make([]uint64, len(rows)), make([]uint64, len(cols))
+	for _, presorted := range []bool{false, true} {
+		b.Run(fmt.Sprintf("presorted=%v", presorted), func(b *testing.B) {
+			b.ReportAllocs()
+			options := &ImportOptions{Presorted: presorted}
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				// since bulkImport modifies the input slices, we make new copies for each round
+				copy(rowsUse, rows)
+				copy(colsUse, cols)
+				f, _, tx := mustOpenFragment(b, OptFieldTypeMutex(DefaultCacheType, DefaultCacheSize))
+				b.StartTimer()
+				if err := f.bulkImportT(tx, rowsUse, colsUse, options); err != nil {
+					b.Errorf("importing: %s", err)
+				}
+				b.StopTimer()
+				tx.Rollback()
+				f.Clean(b)
+			}
+		})
+	}
+}
+
+func BenchmarkFragment_ImportOrder(b *testing.B) {
+	// Row-major input, which is the order the hint can skip sorting.
+	rows := make([]uint64, ShardWidth/2)
+	cols := make([]uint64, ShardWidth/2)
+	for i := range cols {
+		rows[i] = uint64(i / (ShardWidth / 32))
+		cols[i] = uint64(i%(ShardWidth/32)) * 16
+	}
+	rowsUse, colsUse := make([]uint64, len(rows)), make([]uint64, len(cols))
+	for _, order := range []ImportOrder{ImportOrderUnknown, ImportOrderRowMajor} {
+		b.Run(fmt.Sprintf("order=%d", order), func(b *testing.B) {
+			b.ReportAllocs()
+			options := &ImportOptions{Order: order}
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				// since bulkImport modifies the input slices, we make new copies for each round
+				copy(rowsUse, rows)
+				copy(colsUse, cols)
+				f, _, tx := mustOpenFragment(b)
+				b.StartTimer()
+				if err := f.bulkImportT(tx, rowsUse, colsUse, options); err != nil {
+					b.Errorf("importing: %s", err)
+				}
+				b.StopTimer()
+				tx.Rollback()
+				f.Clean(b)
+			}
+		})
+	}
+}
+
 var (
 	rowCases         = []uint64{2, 50, 1000, 10000, 100000}
 	colCases         = []uint64{20, 1000, 5000, 50000, 500000}
@@ -2477,7 +4243,7 @@ func BenchmarkImportStandard(b *testing.B) {
 					f, idx, tx := mustOpenFragment(b, OptFieldTypeSet(cacheType, 0))
 					_ = idx
 					b.StartTimer()
-					err := f.bulkImport(tx, rowIDs, columnIDs, &ImportOptions{})
+					err := f.bulkImportT(tx, rowIDs, columnIDs, &ImportOptions{})
 					if err != nil {
 						b.Errorf("import error: %v", err)
 					}
@@ -2631,7 +4397,7 @@ func BenchmarkImportIntoLargeFragment(b *testing.B) {
 		copy(rows, rowsOrig)
 		copy(cols, colsOrig)
 		b.StartTimer()
-		err = f.bulkImport(tx, rows, cols, opts)
+		err = f.bulkImportT(tx, rows, cols, opts)
 		b.StopTimer()
 		if err != nil {
 			b.Fatalf("bulkImport: %v", err)
@@ -2906,7 +4672,15 @@ func (f *fragment) Clean(t testing.TB) {
 // importRoaringT calls importRoaring with context.Background() for convenience
 func (f *fragment) importRoaringT(tx Tx, data []byte, clear bool) error {
 
-	return f.importRoaring(context.Background(), tx, data, clear)
+	return f.importRoaring(context.Background(), tx, data, clear)
+}
+
+func (f *fragment) bulkImportT(tx Tx, rowIDs, columnIDs []uint64, options *ImportOptions) error {
+	return f.bulkImport(context.Background(), tx, rowIDs, columnIDs, options)
+}
+
+func (f *fragment) importValueT(tx Tx, columnIDs []uint64, values []int64, bitDepth uint64, clear bool) error {
+	return f.importValue(context.Background(), tx, columnIDs, values, bitDepth, clear)
 }
 
 func newTestHolder(tb testing.TB) *Holder {
@@ -3013,6 +4787,91 @@ func addToBitmap(bm *roaring.Bitmap, rowID uint64, columnIDs ...uint64) {
 	bm.DirectAddN(columnIDs...)
 }
 
+// Ensure columns set in exactly k of a set of rows can be found.
+func TestFragment_RowsExactlyK(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	// Column 1: rows 10,11. Column 2: rows 10,11,12. Column 3: row 13.
+	// Column 4: rows 11,13. Column 5: all four rows.
+	f.mustSetBits(tx, 10, 1, 2, 5)
+	f.mustSetBits(tx, 11, 1, 2, 4, 5)
+	f.mustSetBits(tx, 12, 2, 5)
+	f.mustSetBits(tx, 13, 3, 4, 5)
+	f.mustSetBits(tx, 14, 1, 3)
+
+	rows := []uint64{10, 11, 12, 13}
+	for k, exp := range [][]uint64{nil, {3}, {1, 4}, {2}, {5}, nil} {
+		row, err := f.rowsExactlyK(tx, rows, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := row.Columns(); !reflect.DeepEqual(got, exp) && (len(got) != 0 || len(exp) != 0) {
+			t.Fatalf("k=%d: expected %v, got %v", k, exp, got)
+		}
+	}
+
+	// Duplicate row IDs are counted once.
+	row, err := f.rowsExactlyK(tx, []uint64{10, 10, 12}, 2)
+	if err != nil {
+		t.Fatal(err)
+	} else if got := row.Columns(); !reflect.DeepEqual(got, []uint64{2, 5}) {
+		t.Fatalf("expected [2 5] with duplicate rows, got %v", got)
+	}
+}
+
+// Ensure rowsStream yields the same rows as rows, and can stop early.
+func TestFragment_RowsStream(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	for row := uint64(0); row < 100; row++ {
+		f.mustSetBits(tx, row*3, row%4, 70000)
+	}
+
+	ctx := context.Background()
+	for _, filters := range [][]roaring.BitmapFilter{nil, {roaring.NewBitmapColumnFilter(1)}} {
+		exp, err := f.rows(ctx, tx, 30, filters...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []uint64
+		if err := f.rowsStream(ctx, tx, 30, func(rowID uint64) error {
+			got = append(got, rowID)
+			return nil
+		}, filters...); err != nil {
+			t.Fatal(err)
+		}
+		if len(exp) == 0 || !reflect.DeepEqual(got, exp) {
+			t.Fatalf("expected rows %v, got %v", exp, got)
+		}
+	}
+
+	// An error from the callback stops iteration.
+	errStop := errors.New("stop")
+	n := 0
+	if err := f.rowsStream(ctx, tx, 0, func(rowID uint64) error {
+		if n++; n == 5 {
+			return errStop
+		}
+		return nil
+	}); errors.Cause(err) != errStop {
+		t.Fatalf("expected stop error, got %v", err)
+	} else if n != 5 {
+		t.Fatalf("expected iteration to stop after 5 rows, got %d", n)
+	}
+
+	// So does context cancellation.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := f.rowsStream(cctx, tx, 0, func(rowID uint64) error {
+		t.Fatal("unexpected callback after cancellation")
+		return nil
+	}); errors.Cause(err) != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
 // Test Various methods of retrieving RowIDs
 func TestFragment_RowsIteration(t *testing.T) {
 	t.Run("firstContainer", func(t *testing.T) {
@@ -3189,7 +5048,7 @@ func TestFragment_RoaringImportTopN(t *testing.T) {
 			defer f.Clean(t)
 
 			options := &ImportOptions{}
-			err := f.bulkImport(tx, test.rowIDs, test.colIDs, options)
+			err := f.bulkImportT(tx, test.rowIDs, test.colIDs, options)
 			if err != nil {
 				t.Fatalf("bulk importing ids: %v", err)
 			}
@@ -3203,7 +5062,7 @@ func TestFragment_RoaringImportTopN(t *testing.T) {
 				t.Fatalf("post bulk import:\n  exp: %v\n  got: %v\n", expPairs, pairs)
 			}
 
-			err = f.bulkImport(tx, test.rowIDs2, test.colIDs2, options)
+			err = f.bulkImportT(tx, test.rowIDs2, test.colIDs2, options)
 			if err != nil {
 				t.Fatalf("bulk importing ids: %v", err)
 			}
@@ -3241,6 +5100,80 @@ func TestFragment_RoaringImportTopN(t *testing.T) {
 	}
 }
 
+// Ensure the incremental cache updates done by imports give the same TopN
+// results as a full rebuild of the cache.
+func TestFragment_ImportIncrementalCache(t *testing.T) {
+	for _, cacheType := range []string{CacheTypeRanked, CacheTypeLRU} {
+		t.Run(cacheType, func(t *testing.T) {
+			f, _, tx := mustOpenFragment(t, OptFieldTypeSet(cacheType, DefaultCacheSize))
+			defer f.Clean(t)
+
+			var rowIDs, colIDs []uint64
+			for row := uint64(0); row < 20; row++ {
+				for col := uint64(0); col <= row; col++ {
+					rowIDs = append(rowIDs, row)
+					colIDs = append(colIDs, col)
+				}
+			}
+			if err := f.bulkImportT(tx, rowIDs, colIDs, &ImportOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			// Grow row 3, and clear all of row 2 and part of row 19.
+			if err := f.importRoaringT(tx, roaringBytes(t, 3*ShardWidth+100, 3*ShardWidth+101), false); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.importRoaringT(tx, roaringBytes(t, 2*ShardWidth, 2*ShardWidth+1, 2*ShardWidth+2, 19*ShardWidth+5), true); err != nil {
+				t.Fatal(err)
+			}
+			if n := f.cache.Get(2); n != 0 {
+				t.Fatalf("expected emptied row to be evicted, got count %d", n)
+			}
+			for _, id := range f.cache.IDs() {
+				if id == 2 {
+					t.Fatal("expected emptied row to be evicted from cache IDs")
+				}
+			}
+
+			incremental, err := f.top(tx, topOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f.mu.Lock()
+			f.cache.Clear()
+			for row := uint64(0); row < 20; row++ {
+				n, err := tx.CountRange(f.index(), f.field(), f.view(), f.shard, row*ShardWidth, (row+1)*ShardWidth)
+				if err != nil {
+					f.mu.Unlock()
+					t.Fatal(err)
+				} else if n > 0 {
+					f.cache.BulkAdd(row, n)
+				}
+			}
+			f.cache.Recalculate()
+			f.mu.Unlock()
+
+			full, err := f.top(tx, topOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(incremental, full) {
+				t.Fatalf("incremental cache disagrees with rebuilt cache:\n  incremental: %v\n  full: %v", incremental, full)
+			}
+		})
+	}
+}
+
+// roaringBytes returns the serialized roaring bitmap of the given positions.
+func roaringBytes(tb testing.TB, positions ...uint64) []byte {
+	buf := &bytes.Buffer{}
+	if _, err := roaring.NewBitmap(positions...).WriteTo(buf); err != nil {
+		tb.Fatalf("writing roaring bitmap: %v", err)
+	}
+	return buf.Bytes()
+}
+
 func toRowsCols(roaring []uint64) (rowIDs, colIDs []uint64) {
 	rowIDs, colIDs = make([]uint64, len(roaring)), make([]uint64, len(roaring))
 	for i, bit := range roaring {
@@ -3931,7 +5864,7 @@ func TestFragmentBSIUnsigned(t *testing.T) {
 		defer tx.Rollback()
 
 		for i := minCheck; i < maxCheck; i++ {
-			row, err := f.rangeEQ(tx, k, int64(i))
+			row, err := f.rangeEQ(tx, k, int64(i), nil)
 			if err != nil {
 				t.Fatalf("failed to query fragment: %v", err)
 			}
@@ -4094,7 +6027,7 @@ func TestFragmentBSIUnsigned_WithTxCommit(t *testing.T) {
 	})
 	t.Run("==", func(t *testing.T) {
 		for i := minCheck; i < maxCheck; i++ {
-			row, err := f.rangeEQ(tx, k, int64(i))
+			row, err := f.rangeEQ(tx, k, int64(i), nil)
 			if err != nil {
 				t.Fatalf("failed to query fragment: %v", err)
 			}
@@ -4257,7 +6190,7 @@ func TestFragmentBSISigned(t *testing.T) {
 	})
 	t.Run("==", func(t *testing.T) {
 		for i := minCheck; i < maxCheck; i++ {
-			row, err := f.rangeEQ(tx, k, int64(i))
+			row, err := f.rangeEQ(tx, k, int64(i), nil)
 			if err != nil {
 				t.Fatalf("failed to query fragment: %v", err)
 			}
@@ -4273,6 +6206,447 @@ func TestFragmentBSISigned(t *testing.T) {
 	})
 }
 
+// Ensure values matching a predicate are cleared only within the filter.
+func TestFragment_ClearValuesWhere(t *testing.T) {
+	const bitDepth = 10
+
+	f, _, tx := mustOpenFragment(t, OptFieldTypeInt(-1000, 1000))
+	defer f.Clean(t)
+
+	// Column 6 has no value.
+	columnIDs := []uint64{0, 1, 2, 3, 4, 5, 7}
+	values := []int64{-20, 5, 10, 15, 20, 25, 30}
+	if err := f.importValueT(tx, columnIDs, values, bitDepth, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := f.clearValuesWhere(tx, NewRow(0, 2, 3, 5, 6), pql.GTE, bitDepth, 10); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf("expected 3 values cleared, got %d", n)
+	}
+	cleared := map[uint64]bool{2: true, 3: true, 5: true}
+	for i, columnID := range columnIDs {
+		value, exists, err := f.value(tx, columnID, bitDepth)
+		if err != nil {
+			t.Fatal(err)
+		} else if cleared[columnID] && exists {
+			t.Fatalf("column %d: expected cleared value, got %d", columnID, value)
+		} else if !cleared[columnID] && (!exists || value != values[i]) {
+			t.Fatalf("column %d: expected %d, got %d (exists=%v)", columnID, values[i], value, exists)
+		}
+	}
+
+	// A nil filter matches every column.
+	if n, err := f.clearValuesWhere(tx, nil, pql.LT, bitDepth, 10); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("expected 2 values cleared, got %d", n)
+	}
+	if cols := f.mustRow(tx, bsiExistsBit).Columns(); !sliceEq(cols, []uint64{4, 7}) {
+		t.Fatalf("expected values only in columns [4 7], got %v", cols)
+	}
+}
+
+// Ensure clearValueEQ clears exactly the values equal to a sentinel.
+func TestFragment_ClearValueEQ(t *testing.T) {
+	const bitDepth = 10
+
+	f, _, tx := mustOpenFragment(t, OptFieldTypeInt(-1000, 1000))
+	defer f.Clean(t)
+
+	// Column 6 has no value. The sentinel, -1, is distinct from 1 only by
+	// its sign.
+	columnIDs := []uint64{0, 1, 2, 3, 4, 5, 7}
+	values := []int64{-1, 1, -1, 0, -2, -1, 1000}
+	if err := f.importValueT(tx, columnIDs, values, bitDepth, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := f.clearValueEQ(tx, bitDepth, -1); err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf("expected 3 values cleared, got %d", n)
+	}
+	for i, columnID := range columnIDs {
+		value, exists, err := f.value(tx, columnID, bitDepth)
+		if err != nil {
+			t.Fatal(err)
+		} else if values[i] == -1 && exists {
+			t.Fatalf("column %d: expected cleared value, got %d", columnID, value)
+		} else if values[i] != -1 && (!exists || value != values[i]) {
+			t.Fatalf("column %d: expected %d, got %d (exists=%v)", columnID, values[i], value, exists)
+		}
+	}
+	if _, exists, err := f.value(tx, 6, bitDepth); err != nil {
+		t.Fatal(err)
+	} else if exists {
+		t.Fatal("expected column 6 to still have no value")
+	}
+
+	// Nothing is left to clear.
+	if n, err := f.clearValueEQ(tx, bitDepth, -1); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("expected no values cleared, got %d", n)
+	}
+}
+
+// Ensure merging one ranked fragment into another gives the cache the same
+// rankings as rebuilding it from the merged data, including for rows src's
+// cache doesn't hold.
+func TestFragment_MergeFrom(t *testing.T) {
+	f, _, tx := mustOpenFragment(t, OptFieldTypeSet(CacheTypeRanked, 2))
+	defer f.Clean(t)
+	src, _, stx := mustOpenFragment(t, OptFieldTypeSet(CacheTypeRanked, 2))
+	defer src.Clean(t)
+
+	// Row 1 has 5 bits in f and 3 others in src, too few for src's cache.
+	// Row 2 has 2 bits in f and 6 in src, one in common. Row 3 has 4 bits,
+	// only in src.
+	f.mustSetBits(tx, 1, 0, 1, 2, 3, 4)
+	f.mustSetBits(tx, 2, 0, 1)
+	src.mustSetBits(stx, 1, 5, 6, 7)
+	src.mustSetBits(stx, 2, 1, 2, 3, 4, 5, 6)
+	src.mustSetBits(stx, 3, 0, 1, 2, 3)
+
+	if err := f.mergeFrom(context.Background(), tx, stx, src); err != nil {
+		t.Fatal(err)
+	}
+	merged := append([]bitmapPair(nil), f.cache.Top()...)
+	if exp := []bitmapPair{{ID: 1, Count: 8}, {ID: 2, Count: 7}}; !reflect.DeepEqual(merged, exp) {
+		t.Fatalf("expected merged rankings %v, got %v", exp, merged)
+	}
+
+	if err := f.rebuildRankCache(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt := f.cache.Top(); !reflect.DeepEqual(merged, rebuilt) {
+		t.Fatalf("merged rankings %v differ from rebuilt rankings %v", merged, rebuilt)
+	}
+}
+
+// Ensure rowBytes serializes exactly a row's columns.
+func TestFragment_RowBytes(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	f.mustSetBits(tx, 3, 1, 70000, ShardWidth-1)
+	f.mustSetBits(tx, 4, 2)
+
+	data, err := f.rowBytes(tx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bm := roaring.NewBitmap()
+	if err := bm.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := bm.Slice(), f.mustRow(tx, 3).Columns(); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected columns %v, got %v", exp, got)
+	}
+
+	// An empty row is an empty bitmap.
+	if data, err = f.rowBytes(tx, 5); err != nil {
+		t.Fatal(err)
+	}
+	bm = roaring.NewBitmap()
+	if err := bm.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	} else if n := bm.Count(); n != 0 {
+		t.Fatalf("expected empty row, got %d columns", n)
+	}
+}
+
+// Ensure a roaring import reports its progress periodically, and once done.
+func TestFragment_ImportRoaringWithProgress(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	// One bit in each of n containers.
+	const n = 5*importProgressInterval + 100
+	positions := make([]uint64, n)
+	for i := range positions {
+		positions[i] = uint64(i) << 16
+	}
+	var reports []importProgress
+	if err := f.importRoaringWithProgress(context.Background(), tx, roaringBytes(t, positions...), false, func(p importProgress) {
+		reports = append(reports, p)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 6 {
+		t.Fatalf("expected 6 progress reports, got %d: %v", len(reports), reports)
+	}
+	for i, p := range reports {
+		if p.totalContainers != n {
+			t.Fatalf("report %d: expected %d total containers, got %d", i, n, p.totalContainers)
+		} else if i > 0 && p.containers <= reports[i-1].containers {
+			t.Fatalf("report %d: progress went from %d to %d containers", i, reports[i-1].containers, p.containers)
+		} else if p.bits != p.containers {
+			t.Fatalf("report %d: expected %d bits, got %d", i, p.containers, p.bits)
+		}
+	}
+	if last := reports[len(reports)-1]; last.containers != n {
+		t.Fatalf("expected final report of %d containers, got %d", n, last.containers)
+	}
+	if cols := f.mustRow(tx, 1).Count(); cols != ShardWidth>>16 {
+		t.Fatalf("expected row 1 to have %d bits, got %d", ShardWidth>>16, cols)
+	}
+}
+
+// recordingTracer is a tracer which records the spans started with it.
+type recordingTracer struct {
+	tracing.Tracer
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (t *recordingTracer) StartSpanFromContext(ctx context.Context, operationName string) (tracing.Span, context.Context) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &recordingSpan{name: operationName, kv: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	return span, ctx
+}
+
+// span returns the first span recorded with the given name, or nil.
+func (t *recordingTracer) span(operationName string) *recordingSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, span := range t.spans {
+		if span.name == operationName {
+			return span
+		}
+	}
+	return nil
+}
+
+type recordingSpan struct {
+	name     string
+	finished bool
+	kv       map[string]interface{}
+}
+
+func (s *recordingSpan) Finish() { s.finished = true }
+
+func (s *recordingSpan) LogKV(alternatingKeyValues ...interface{}) {
+	for i := 0; i+1 < len(alternatingKeyValues); i += 2 {
+		s.kv[alternatingKeyValues[i].(string)] = alternatingKeyValues[i+1]
+	}
+}
+
+// Ensure imports into a fragment are traced, with spans identifying the
+// fragment and the size of the import.
+func TestFragment_ImportSpans(t *testing.T) {
+	tracer := &recordingTracer{Tracer: tracing.NopTracer()}
+	defer func(orig tracing.Tracer) { tracing.GlobalTracer = orig }(tracing.GlobalTracer)
+	tracing.GlobalTracer = tracer
+
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	if err := f.bulkImportT(tx, []uint64{1, 1, 2}, []uint64{3, 4, 5}, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data := roaringBytes(t, 3*ShardWidth+6, 3*ShardWidth+7)
+	if err := f.importRoaringT(tx, data, false); err != nil {
+		t.Fatal(err)
+	}
+
+	bf, _, btx := mustOpenFragment(t, OptFieldTypeInt(-1000, 1000))
+	defer bf.Clean(t)
+	if err := bf.importValueT(btx, []uint64{1, 2}, []int64{10, -10}, 10, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		frag *fragment
+		name string
+		kv   map[string]interface{}
+	}{
+		{f, "fragment.bulkImport", map[string]interface{}{"bits": 3}},
+		{f, "fragment.importRoaring", map[string]interface{}{"bytes": len(data), "changed": 2}},
+		{bf, "fragment.importValue", map[string]interface{}{"values": 2, "bitDepth": uint64(10)}},
+	} {
+		span := tracer.span(test.name)
+		if span == nil {
+			t.Fatalf("expected a %s span", test.name)
+		} else if !span.finished {
+			t.Fatalf("expected the %s span to be finished", test.name)
+		}
+		test.kv["index"] = test.frag.index()
+		test.kv["field"] = test.frag.field()
+		test.kv["view"] = test.frag.view()
+		test.kv["shard"] = test.frag.shard
+		if !reflect.DeepEqual(span.kv, test.kv) {
+			t.Fatalf("%s: expected tags %v, got %v", test.name, test.kv, span.kv)
+		}
+	}
+}
+
+// slowTx is a Tx whose Add takes at least delay.
+type slowTx struct {
+	Tx
+	delay time.Duration
+}
+
+func (tx *slowTx) Add(index, field, view string, shard uint64, a ...uint64) (changeCount int, err error) {
+	time.Sleep(tx.delay)
+	return tx.Tx.Add(index, field, view, shard, a...)
+}
+
+// Ensure imports taking longer than the holder's SlowImportThreshold are
+// logged, and that a zero threshold logs nothing.
+func TestFragment_SlowImportThreshold(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		threshold time.Duration
+		logged    bool
+	}{
+		{"Slow", time.Millisecond, true},
+		{"Fast", time.Hour, false},
+		{"Disabled", 0, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, _, tx := mustOpenFragment(t)
+			defer f.Clean(t)
+			logs := logger.NewBufferLogger()
+			f.holder.Logger = logs
+			f.holder.cfg.SlowImportThreshold = tc.threshold
+
+			stx := &slowTx{Tx: tx, delay: 5 * time.Millisecond}
+			if err := f.bulkImportT(stx, []uint64{1, 1, 2}, []uint64{3, 4, 5}, &ImportOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			out, err := logs.ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if logged := bytes.Contains(out, []byte("slow bulkImport")); logged != tc.logged {
+				t.Fatalf("expected logged=%v, got log %q", tc.logged, out)
+			} else if logged && !bytes.Contains(out, []byte("shard=0 bits=3")) {
+				t.Fatalf("expected shard and bit count in log, got %q", out)
+			}
+		})
+	}
+}
+
+// Ensure values exported as roaring data from one fragment can be imported
+// into another with importRoaringValues, replacing existing values.
+func TestFragment_ImportRoaringValues(t *testing.T) {
+	const bitDepth = 10
+
+	src, _, stx := mustOpenFragment(t, OptFieldTypeInt(-1000, 1000))
+	defer src.Clean(t)
+	dst, _, dtx := mustOpenFragment(t, OptFieldTypeInt(-1000, 1000))
+	defer dst.Clean(t)
+
+	columnIDs := []uint64{1, 5, 70000, ShardWidth - 1}
+	values := []int64{-1000, 0, 7, 1000}
+	if err := src.importValueT(stx, columnIDs, values, bitDepth, false); err != nil {
+		t.Fatal(err)
+	}
+	bm, err := stx.RoaringBitmap(src.index(), src.field(), src.view(), src.shard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := bm.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Column 5's value is replaced; column 9's is left alone.
+	if err := dst.importValueT(dtx, []uint64{5, 9}, []int64{123, 4}, bitDepth, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.importRoaringValues(context.Background(), dtx, buf.Bytes(), false); err != nil {
+		t.Fatal(err)
+	}
+	check := func(columnIDs []uint64, values []int64) {
+		t.Helper()
+		for i, columnID := range columnIDs {
+			if value, exists, err := dst.value(dtx, columnID, bitDepth); err != nil {
+				t.Fatal(err)
+			} else if !exists || value != values[i] {
+				t.Fatalf("column %d: expected %d, got %d (exists=%v)", columnID, values[i], value, exists)
+			}
+		}
+	}
+	check(append(columnIDs, 9), append(values, 4))
+
+	// Clearing clears the columns in the data's exists row.
+	if err := dst.importRoaringValues(context.Background(), dtx, roaringBytes(t, 5, 70000), true); err != nil {
+		t.Fatal(err)
+	}
+	if cols := dst.mustRow(dtx, bsiExistsBit).Columns(); !sliceEq(cols, []uint64{1, 9, ShardWidth - 1}) {
+		t.Fatalf("expected values only in columns [1 9 %d], got %v", ShardWidth-1, cols)
+	}
+	check([]uint64{1, 9, ShardWidth - 1}, []int64{-1000, 4, 1000})
+
+	// Data too deep for the field, or with value bits for columns without
+	// an exists bit, is rejected.
+	for _, data := range [][]byte{
+		roaringBytes(t, 1, (bsiOffsetBit+bitDepth)*ShardWidth+1),
+		roaringBytes(t, 1, bsiOffsetBit*ShardWidth+2),
+	} {
+		if err := dst.importRoaringValues(context.Background(), dtx, data, false); err == nil {
+			t.Fatal("expected invalid value data to be rejected")
+		}
+	}
+	check([]uint64{1, 9, ShardWidth - 1}, []int64{-1000, 4, 1000})
+}
+
+// Ensure clearing values clears every bit of the listed columns, whatever
+// values are given, and leaves other columns alone.
+func TestFragment_BulkClearValues(t *testing.T) {
+	const bitDepth = 10
+
+	f, idx, tx := mustOpenFragment(t, OptFieldTypeInt(-1000, 1000))
+	defer f.Clean(t)
+
+	columnIDs := []uint64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	values := []int64{-9, 8, -7, 6, -5, 4, -3, 2, -1, 1000}
+	if err := f.importValueT(tx, columnIDs, values, bitDepth, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx = idx.holder.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: f, Shard: f.shard})
+	if err := f.bulkClearValues(context.Background(), tx, []uint64{1, 3, 4}, bitDepth); err != nil {
+		t.Fatal(err)
+	}
+	// The values given when clearing are irrelevant.
+	if err := f.importValueT(tx, []uint64{8, 9}, []int64{77, -1000}, bitDepth, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx = idx.holder.txf.NewTx(Txo{Write: !writable, Index: idx, Fragment: f, Shard: f.shard})
+	defer tx.Rollback()
+	cleared := map[uint64]bool{1: true, 3: true, 4: true, 8: true, 9: true}
+	for i, columnID := range columnIDs {
+		value, exists, err := f.value(tx, columnID, bitDepth)
+		if err != nil {
+			t.Fatal(err)
+		} else if cleared[columnID] && exists {
+			t.Fatalf("column %d: expected cleared value, got %d", columnID, value)
+		} else if !cleared[columnID] && (!exists || value != values[i]) {
+			t.Fatalf("column %d: expected %d, got %d (exists=%v)", columnID, values[i], value, exists)
+		}
+	}
+	for rowID := uint64(bsiExistsBit); rowID < bsiOffsetBit+bitDepth; rowID++ {
+		for _, columnID := range f.mustRow(tx, rowID).Columns() {
+			if cleared[columnID] {
+				t.Fatalf("row %d: expected column %d to be cleared", rowID, columnID)
+			}
+		}
+	}
+}
+
 func TestImportValueConcurrent(t *testing.T) {
 	f, idx, tx := mustOpenFragment(t)
 	defer f.Clean(t)
@@ -4286,7 +6660,7 @@ func TestImportValueConcurrent(t *testing.T) {
 			tx := idx.holder.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: f, Shard: f.shard})
 			defer tx.Rollback()
 			for j := uint64(0); j < 10; j++ {
-				err := f.importValue(tx, []uint64{j}, []int64{int64(rand.Int63n(1000))}, 10, i%2 == 0)
+				err := f.importValueT(tx, []uint64{j}, []int64{int64(rand.Int63n(1000))}, 10, i%2 == 0)
 				if err != nil {
 					return err
 				}
@@ -4322,7 +6696,7 @@ func TestImportMultipleValues(t *testing.T) {
 			f, _, tx := mustOpenFragment(t)
 			defer f.Clean(t)
 
-			err := f.importValue(tx, test.cols, test.vals, test.depth, false)
+			err := f.importValueT(tx, test.cols, test.vals, test.depth, false)
 			if err != nil {
 				t.Fatalf("importing values: %v", err)
 			}
@@ -4377,7 +6751,7 @@ func TestImportValueRowCache(t *testing.T) {
 			defer f.Clean(t)
 
 			// First import (tc1)
-			if err := f.importValue(tx, test.tc1.cols, test.tc1.vals, test.tc1.depth, false); err != nil {
+			if err := f.importValueT(tx, test.tc1.cols, test.tc1.vals, test.tc1.depth, false); err != nil {
 				t.Fatalf("importing values: %v", err)
 			}
 
@@ -4388,7 +6762,7 @@ func TestImportValueRowCache(t *testing.T) {
 			}
 
 			// Second import (tc2)
-			if err := f.importValue(tx, test.tc2.cols, test.tc2.vals, test.tc2.depth, false); err != nil {
+			if err := f.importValueT(tx, test.tc2.cols, test.tc2.vals, test.tc2.depth, false); err != nil {
 				t.Fatalf("importing values: %v", err)
 			}
 
@@ -4710,7 +7084,7 @@ func TestImportMutexSampleData(t *testing.T) {
 					if len(cols) < max {
 						max = len(cols)
 					}
-					err = f.bulkImport(tx, rows[j:max:max], cols[j:max:max], &ImportOptions{})
+					err = f.bulkImportT(tx, rows[j:max:max], cols[j:max:max], &ImportOptions{})
 					if err != nil {
 						t.Fatalf("bulk importing ids [%d/3] [%d:%d]: %v", i+1, j, max, err)
 					}
@@ -4755,7 +7129,7 @@ func BenchmarkImportMutexSampleData(b *testing.B) {
 			if len(cols) < max {
 				max = len(cols)
 			}
-			err := frag.bulkImport(tx, rows[start:max:max], cols[start:max:max], &ImportOptions{})
+			err := frag.bulkImportT(tx, rows[start:max:max], cols[start:max:max], &ImportOptions{})
 			if err != nil {
 				b.Fatalf("bulk importing ids [%d:%d]: %v", start, max, err)
 			}
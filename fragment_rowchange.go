@@ -0,0 +1,196 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultRowChangeLogSize is the number of row changes a fragment's change
+// log retains if enableRowChangeLog isn't given a size.
+const defaultRowChangeLogSize = 4096
+
+// errRowChangeLogTruncated is returned by rowsChangedSince for a position
+// the change log no longer covers, so that the caller has to scan the
+// fragment instead.
+var errRowChangeLogTruncated = errors.New("row change log does not cover position")
+
+// fragmentRowChanges collects the rows changed by a fragment's mutations,
+// by Tx, until the Tx is committed and they're reported to its
+// OnRowChanged hook, and in its change log, if enabled. It has its own
+// mutex so that rows can be recorded with or without the fragment's mutex
+// held, and reported after it's released.
+type fragmentRowChanges struct {
+	mu   sync.Mutex
+	byTx map[Tx]map[uint64]struct{}
+	log  *rowChangeLog
+}
+
+// rowChangeLog retains the rows of a fragment's most recent changes, in a
+// ring. Each change has a position one past the previous change's. The
+// first position is taken from the clock when the log is created, so that
+// positions from a log which has since been replaced, such as when the
+// fragment is reopened, fall before the new log's.
+type rowChangeLog struct {
+	rows  []uint64
+	first uint64 // position of the log's first change
+	next  uint64 // position of the next change
+}
+
+// add appends a change of rowID to the log, dropping the oldest change if
+// the log is full.
+func (l *rowChangeLog) add(rowID uint64) {
+	l.rows[l.next%uint64(len(l.rows))] = rowID
+	l.next++
+}
+
+// enableRowChangeLog starts logging the rows changed by the fragment's
+// mutations, keeping the most recent size changes, or
+// defaultRowChangeLogSize if size isn't positive. Like OnRowChanged, it
+// must be called before the fragment is used.
+func (f *fragment) enableRowChangeLog(size int) {
+	if size <= 0 {
+		size = defaultRowChangeLogSize
+	}
+	start := uint64(time.Now().UnixNano())
+	rc := &f.rowChanges
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.log = &rowChangeLog{rows: make([]uint64, size), first: start, next: start}
+}
+
+// rowChangePosition returns the position of the fragment's latest logged
+// change, for passing to rowsChangedSince later. It returns an error if the
+// change log isn't enabled.
+func (f *fragment) rowChangePosition() (uint64, error) {
+	rc := &f.rowChanges
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.log == nil {
+		return 0, errors.New("row change log not enabled")
+	}
+	return rc.log.next - 1, nil
+}
+
+// rowsChangedSince returns, in ascending order, the rows changed after the
+// change at pos, as returned by rowChangePosition. Like OnRowChanged, it may
+// include rows which a mutation touched without changing. If changes after
+// pos have been dropped from the log, or pos isn't from this log, it
+// returns an error wrapping errRowChangeLogTruncated.
+func (f *fragment) rowsChangedSince(pos uint64) ([]uint64, error) {
+	rc := &f.rowChanges
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	l := rc.log
+	if l == nil {
+		return nil, errors.New("row change log not enabled")
+	}
+	oldest := l.first
+	if l.next-l.first > uint64(len(l.rows)) {
+		oldest = l.next - uint64(len(l.rows))
+	}
+	if pos+1 < oldest || pos >= l.next {
+		return nil, errors.Wrapf(errRowChangeLogTruncated, "position %d, log has %d to %d", pos, oldest, l.next-1)
+	}
+
+	seen := make(map[uint64]struct{})
+	var rowIDs []uint64
+	for p := pos + 1; p < l.next; p++ {
+		rowID := l.rows[p%uint64(len(l.rows))]
+		if _, ok := seen[rowID]; !ok {
+			seen[rowID] = struct{}{}
+			rowIDs = append(rowIDs, rowID)
+		}
+	}
+	sort.Slice(rowIDs, func(i, j int) bool { return rowIDs[i] < rowIDs[j] })
+	return rowIDs, nil
+}
+
+// recordRowChanged records that rowID was changed under tx, to be reported
+// to the OnRowChanged hook, and in the change log, once tx is committed.
+// It's a no-op if the fragment has neither an OnRowChanged hook nor a
+// change log.
+func (f *fragment) recordRowChanged(tx Tx, rowID uint64) {
+	rc := &f.rowChanges
+	if f.OnRowChanged == nil && rc.log == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	f.pendingRowChanges(tx)[rowID] = struct{}{}
+}
+
+// recordRowsChanged is recordRowChanged for each row in rowSet.
+func (f *fragment) recordRowsChanged(tx Tx, rowSet map[uint64]struct{}) {
+	rc := &f.rowChanges
+	if (f.OnRowChanged == nil && rc.log == nil) || len(rowSet) == 0 {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rows := f.pendingRowChanges(tx)
+	for rowID := range rowSet {
+		rows[rowID] = struct{}{}
+	}
+}
+
+// pendingRowChanges returns the rows recorded as changed under tx. The
+// first time, it registers hooks on tx to report them once it's committed,
+// or drop them if it's rolled back. It must be called with rowChanges.mu
+// held.
+func (f *fragment) pendingRowChanges(tx Tx) map[uint64]struct{} {
+	rc := &f.rowChanges
+	rows := rc.byTx[tx]
+	if rows == nil {
+		if rc.byTx == nil {
+			rc.byTx = make(map[Tx]map[uint64]struct{})
+		}
+		rows = make(map[uint64]struct{})
+		rc.byTx[tx] = rows
+		tx.AfterCommit(func() { f.notifyRowChanges(tx) })
+		tx.OnRollback(func() { f.dropRowChanges(tx) })
+	}
+	return rows
+}
+
+// notifyRowChanges adds the rows recorded under tx, which has been
+// committed, to the change log, and calls the OnRowChanged hook once for
+// each of them, in ascending order. It must not be called with the
+// fragment's mutex held, so that the hook is free to read the fragment.
+func (f *fragment) notifyRowChanges(tx Tx) {
+	rc := &f.rowChanges
+	rc.mu.Lock()
+	rows := rc.byTx[tx]
+	delete(rc.byTx, tx)
+	rowIDs := make([]uint64, 0, len(rows))
+	for rowID := range rows {
+		rowIDs = append(rowIDs, rowID)
+	}
+	sort.Slice(rowIDs, func(i, j int) bool { return rowIDs[i] < rowIDs[j] })
+	if rc.log != nil {
+		for _, rowID := range rowIDs {
+			rc.log.add(rowID)
+		}
+	}
+	rc.mu.Unlock()
+
+	if f.OnRowChanged == nil {
+		return
+	}
+	for _, rowID := range rowIDs {
+		f.OnRowChanged(rowID)
+	}
+}
+
+// dropRowChanges forgets the rows recorded under tx, which has been rolled
+// back.
+func (f *fragment) dropRowChanges(tx Tx) {
+	rc := &f.rowChanges
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	delete(rc.byTx, tx)
+}
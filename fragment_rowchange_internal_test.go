@@ -0,0 +1,207 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// commitRowChanges commits tx, reporting the rows changed under it, and
+// returns a new Tx for f.
+func commitRowChanges(tb testing.TB, f *fragment, tx Tx) Tx {
+	tb.Helper()
+	if err := tx.Commit(); err != nil {
+		tb.Fatal(err)
+	}
+	tx = f.holder.txf.NewTx(Txo{Write: writable, Index: f.idx, Fragment: f, Shard: f.shard})
+	tb.Cleanup(tx.Rollback)
+	return tx
+}
+
+// Ensure the OnRowChanged hook reports changed rows, outside the fragment's
+// mutex, once per row, when their Tx commits.
+func TestFragment_OnRowChanged(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	var got []uint64
+	f.OnRowChanged = func(rowID uint64) {
+		if !f.mu.TryLock() {
+			t.Errorf("row %d: hook called with fragment mutex held", rowID)
+			return
+		}
+		f.mu.Unlock()
+		got = append(got, rowID)
+	}
+	expect := func(desc string, rowIDs ...uint64) {
+		t.Helper()
+		if len(got) != 0 {
+			t.Fatalf("%s: rows %v reported before commit", desc, got)
+		}
+		tx = commitRowChanges(t, f, tx)
+		if !reflect.DeepEqual(got, rowIDs) {
+			t.Fatalf("%s: expected rows %v, got %v", desc, rowIDs, got)
+		}
+		got = nil
+	}
+
+	if _, err := f.setBit(tx, 3, 100); err != nil {
+		t.Fatal(err)
+	}
+	expect("setBit", 3)
+
+	if _, err := f.setBit(tx, 3, 100); err != nil {
+		t.Fatal(err)
+	}
+	expect("setBit of existing bit")
+
+	if _, err := f.clearBit(tx, 3, 100); err != nil {
+		t.Fatal(err)
+	}
+	expect("clearBit", 3)
+
+	rowIDs := []uint64{7, 1, 1, 1, 7, 4}
+	columnIDs := []uint64{1, 1, 2, 3, 2, 9}
+	if err := f.bulkImportT(tx, rowIDs, columnIDs, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expect("bulkImport", 1, 4, 7)
+
+	if err := f.bulkImportT(tx, []uint64{4}, []uint64{9}, &ImportOptions{Clear: true}); err != nil {
+		t.Fatal(err)
+	}
+	expect("bulkImport clear", 4)
+
+	// Rows changed by several mutations in one Tx are reported once.
+	f.mustSetBits(tx, 2, 1, 2)
+	if _, err := f.clearBit(tx, 7, 1); err != nil {
+		t.Fatal(err)
+	}
+	expect("several mutations", 2, 7)
+}
+
+// Ensure rows changed under a Tx which is rolled back are neither reported
+// to the OnRowChanged hook nor logged.
+func TestFragment_OnRowChanged_Rollback(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	f.enableRowChangeLog(8)
+
+	var got []uint64
+	f.OnRowChanged = func(rowID uint64) { got = append(got, rowID) }
+	start, err := f.rowChangePosition()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.mustSetBits(tx, 3, 1, 2)
+	if err := f.bulkImportT(tx, []uint64{4, 5}, []uint64{1, 1}, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+	if len(got) != 0 {
+		t.Fatalf("expected no rows reported after rollback, got %v", got)
+	}
+	if rows, err := f.rowsChangedSince(start); err != nil {
+		t.Fatal(err)
+	} else if len(rows) != 0 {
+		t.Fatalf("expected no rows logged after rollback, got %v", rows)
+	}
+
+	// A later Tx reports only its own changes.
+	tx = f.holder.txf.NewTx(Txo{Write: writable, Index: f.idx, Fragment: f, Shard: f.shard})
+	f.mustSetBits(tx, 6, 1)
+	commitRowChanges(t, f, tx)
+	if !reflect.DeepEqual(got, []uint64{6}) {
+		t.Fatalf("expected rows [6], got %v", got)
+	}
+	if rows, err := f.rowsChangedSince(start); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(rows, []uint64{6}) {
+		t.Fatalf("expected rows [6] logged, got %v", rows)
+	}
+}
+
+// Ensure the OnRowChanged hook reports rows changed by a mutex import,
+// including the rows whose bits were displaced.
+func TestFragment_OnRowChanged_Mutex(t *testing.T) {
+	f, _, tx := mustOpenFragment(t, OptFieldTypeMutex(DefaultCacheType, DefaultCacheSize))
+	defer f.Clean(t)
+
+	if err := f.bulkImportT(tx, []uint64{1, 2}, []uint64{10, 20}, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []uint64
+	f.OnRowChanged = func(rowID uint64) { got = append(got, rowID) }
+	if err := f.bulkImportT(tx, []uint64{5}, []uint64{10}, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	commitRowChanges(t, f, tx)
+	if !reflect.DeepEqual(got, []uint64{1, 5}) {
+		t.Fatalf("expected rows [1 5], got %v", got)
+	}
+}
+
+// Ensure the change log reports the rows changed after a position, and
+// errors once the changes after it have been dropped.
+func TestFragment_RowsChangedSince(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	f.enableRowChangeLog(8)
+
+	start, err := f.rowChangePosition()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows, err := f.rowsChangedSince(start); err != nil {
+		t.Fatal(err)
+	} else if len(rows) != 0 {
+		t.Fatalf("expected no changed rows, got %v", rows)
+	}
+
+	f.mustSetBits(tx, 5, 1)
+	tx = commitRowChanges(t, f, tx)
+	mid, err := f.rowChangePosition()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.mustSetBits(tx, 2, 1, 2)
+	if err := f.bulkImportT(tx, []uint64{9, 2}, []uint64{3, 3}, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	tx = commitRowChanges(t, f, tx)
+
+	if rows, err := f.rowsChangedSince(start); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(rows, []uint64{2, 5, 9}) {
+		t.Fatalf("expected rows [2 5 9] since start, got %v", rows)
+	}
+	if rows, err := f.rowsChangedSince(mid); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(rows, []uint64{2, 9}) {
+		t.Fatalf("expected rows [2 9] since row 5 was set, got %v", rows)
+	}
+
+	// Enough changes to fill the log drop the ones after start.
+	for rowID := uint64(10); rowID < 18; rowID++ {
+		f.mustSetBits(tx, rowID, 1)
+	}
+	commitRowChanges(t, f, tx)
+	if _, err := f.rowsChangedSince(start); errors.Cause(err) != errRowChangeLogTruncated {
+		t.Fatalf("expected errRowChangeLogTruncated, got %v", err)
+	}
+
+	// So does a replacement log, as when the fragment is reopened.
+	end, err := f.rowChangePosition()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.enableRowChangeLog(8)
+	if _, err := f.rowsChangedSince(end); errors.Cause(err) != errRowChangeLogTruncated {
+		t.Fatalf("expected errRowChangeLogTruncated from a replaced log, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"sort"
+
+	"github.com/featurebasedb/featurebase/v3/roaring"
+	"github.com/pkg/errors"
+)
+
+// positionsContainerKeys returns the distinct container keys of positions,
+// in ascending order.
+func positionsContainerKeys(positions []uint64) []uint64 {
+	var keys []uint64
+	seen := make(map[uint64]struct{})
+	for _, pos := range positions {
+		if _, ok := seen[pos>>16]; !ok {
+			seen[pos>>16] = struct{}{}
+			keys = append(keys, pos>>16)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// roaringContainerKeys returns the container keys of roaring encoded data.
+func roaringContainerKeys(data []byte) ([]uint64, error) {
+	citer, err := roaring.NewContainerIterator(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting container iterator")
+	}
+	defer citer.Close()
+	var keys []uint64
+	for citer.Next() {
+		key, _ := citer.Value()
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// applyRunThreshold rewrites the fragment's containers with the given keys
+// as run containers if they have runThreshold runs or fewer, and aren't
+// already. It's a no-op if the fragment has no run threshold.
+func (f *fragment) applyRunThreshold(tx Tx, keys []uint64) error {
+	if f.runThreshold <= 0 {
+		return nil
+	}
+	for _, key := range keys {
+		c, err := tx.Container(f.index(), f.field(), f.view(), f.shard, key)
+		if err != nil {
+			return errors.Wrapf(err, "getting container %d", key)
+		} else if c.N() == 0 || roaring.ContainerType(c) == roaring.ContainerRun {
+			continue
+		}
+		if opt := roaring.OptimizeRuns(c, f.runThreshold); roaring.ContainerType(opt) == roaring.ContainerRun {
+			if err := tx.PutContainer(f.index(), f.field(), f.view(), f.shard, key, opt); err != nil {
+				return errors.Wrapf(err, "putting container %d", key)
+			}
+		}
+	}
+	return nil
+}
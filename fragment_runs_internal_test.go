@@ -0,0 +1,68 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"testing"
+
+	"github.com/featurebasedb/featurebase/v3/rbf"
+	"github.com/featurebasedb/featurebase/v3/roaring"
+)
+
+// Ensure imported containers with few enough runs are stored as run
+// containers when the field has a run threshold, and not otherwise.
+func TestFragment_RunThreshold(t *testing.T) {
+	// Row 1 holds one run of 100 bits, and row 2 holds 50 runs of 1, which
+	// are smaller as an array.
+	var rowIDs, columnIDs, positions []uint64
+	for i := uint64(0); i < 100; i++ {
+		rowIDs = append(rowIDs, 1)
+		columnIDs = append(columnIDs, i)
+	}
+	for i := uint64(0); i < 50; i++ {
+		positions = append(positions, 2*ShardWidth+2*i)
+	}
+
+	containerType := func(t *testing.T, f *fragment, tx Tx, rowID uint64) byte {
+		t.Helper()
+		c, err := tx.Container(f.index(), f.field(), f.view(), f.shard, rowID*ShardWidth>>16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return roaring.ContainerType(c)
+	}
+	for _, tt := range []struct {
+		threshold int32
+		exp       [3]byte // container types of rows 1 and 2, by row ID
+	}{
+		{0, [3]byte{0, roaring.ContainerArray, roaring.ContainerArray}},
+		{4, [3]byte{0, roaring.ContainerRun, roaring.ContainerArray}},
+		{50, [3]byte{0, roaring.ContainerRun, roaring.ContainerRun}},
+	} {
+		f, _, tx := mustOpenFragment(t, OptFieldRunThreshold(tt.threshold))
+		defer f.Clean(t)
+
+		if err := f.bulkImportT(tx, append([]uint64{}, rowIDs...), append([]uint64{}, columnIDs...), &ImportOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.importRoaringT(tx, roaringBytes(t, positions...), false); err != nil {
+			t.Fatal(err)
+		}
+		for _, rowID := range []uint64{1, 2} {
+			if typ := containerType(t, f, tx, rowID); typ != tt.exp[rowID] {
+				t.Fatalf("threshold %d, row %d: expected container type %d, got %d", tt.threshold, rowID, tt.exp[rowID], typ)
+			}
+		}
+		if n := f.mustRow(tx, 1).Count(); n != 100 {
+			t.Fatalf("threshold %d: expected 100 bits in row 1, got %d", tt.threshold, n)
+		} else if n := f.mustRow(tx, 2).Count(); n != 50 {
+			t.Fatalf("threshold %d: expected 50 bits in row 2, got %d", tt.threshold, n)
+		}
+	}
+
+	for _, n := range []int32{-1, rbf.RLEMaxSize + 1} {
+		if err := OptFieldRunThreshold(n)(&FieldOptions{}); err == nil {
+			t.Fatalf("expected error for run threshold %d", n)
+		}
+	}
+}
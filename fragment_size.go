@@ -0,0 +1,172 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"sync"
+
+	"github.com/featurebasedb/featurebase/v3/roaring"
+	"github.com/pkg/errors"
+)
+
+// Fragment sizes are estimated from their containers, rather than measured
+// on disk, since many fragments share one storage file. The estimate is an
+// upper bound on the encoded size: a container with n bits takes no more
+// than 2n bytes as an array, and never more than a bitmap, plus a fixed
+// amount of per-container overhead. Since the bound of a union is at most
+// the sum of the bounds of its parts, an existing fragment's estimate plus
+// the estimate of the bits being imported bounds the size after the import.
+const (
+	// containerSizeOverhead is the per-container overhead in the size
+	// estimate; it covers a container's key, type, and count.
+	containerSizeOverhead = 12
+
+	// maxContainerDataSize is the size of a bitmap container's data, which
+	// no container needs to exceed.
+	maxContainerDataSize = 8192
+)
+
+// containerSizeBound returns the size estimate of a container with n bits.
+func containerSizeBound(n int64) int64 {
+	if size := 2 * n; size < maxContainerDataSize {
+		return size + containerSizeOverhead
+	}
+	return maxContainerDataSize + containerSizeOverhead
+}
+
+// positionsSizeBound returns the size estimate of the bits at positions.
+// Positions are grouped into containers as they appear; unsorted positions
+// split containers into several groups, which only overestimates.
+func positionsSizeBound(positions []uint64) int64 {
+	var size, n int64
+	key := ^uint64(0)
+	for _, pos := range positions {
+		if pos>>16 != key {
+			if n > 0 {
+				size += containerSizeBound(n)
+			}
+			key, n = pos>>16, 0
+		}
+		n++
+	}
+	if n > 0 {
+		size += containerSizeBound(n)
+	}
+	return size
+}
+
+// roaringSizeBound returns the size estimate of the bits in roaring encoded
+// data.
+func roaringSizeBound(data []byte) (int64, error) {
+	citer, err := roaring.NewContainerIterator(data)
+	if err != nil {
+		return 0, errors.Wrap(err, "getting container iterator")
+	}
+	defer citer.Close()
+	var size int64
+	for citer.Next() {
+		_, c := citer.Value()
+		size += containerSizeBound(int64(c.N()))
+	}
+	return size, nil
+}
+
+// maxSize returns the configured maximum fragment size, or zero if it's
+// unlimited.
+func (f *fragment) maxSize() int64 {
+	if f.holder == nil || f.holder.cfg == nil || f.holder.cfg.StorageConfig == nil {
+		return 0
+	}
+	return f.holder.cfg.StorageConfig.MaxFragmentSize
+}
+
+// fragmentSizeBound caches the size estimate of a fragment's data, so that
+// checking the size of an import doesn't have to walk the fragment's
+// containers. It stays an upper bound: every write which can grow the
+// fragment either adds its own estimate, or marks the cache unknown so that
+// it's recomputed from storage. Clears and rolled back writes leave it too
+// high, so a check which fails against it recomputes it before rejecting an
+// import. It has its own mutex, since imports may only hold the fragment's
+// read lock.
+type fragmentSizeBound struct {
+	mu    sync.Mutex
+	n     int64
+	known bool
+}
+
+// grow adds delta to the bound, if it's known.
+func (b *fragmentSizeBound) grow(delta int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.known {
+		b.n += delta
+	}
+}
+
+// invalidate marks the bound unknown, so that the next check recomputes it.
+func (b *fragmentSizeBound) invalidate() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.known = false
+}
+
+// growSizeBound adds the size estimate of bits written outside of a checked
+// import to the fragment's cached bound. If there's no maximum size, the
+// bound is marked unknown rather than estimated, as it is if estimate
+// fails.
+func (f *fragment) growSizeBound(estimate func() (int64, error)) {
+	if f.maxSize() <= 0 {
+		f.sizeBound.invalidate()
+		return
+	}
+	delta, err := estimate()
+	if err != nil {
+		f.sizeBound.invalidate()
+		return
+	}
+	f.sizeBound.grow(delta)
+}
+
+// storageSizeBound returns the size estimate of the fragment's data in tx.
+func (f *fragment) storageSizeBound(tx Tx) (int64, error) {
+	citer, _, err := tx.ContainerIterator(f.index(), f.field(), f.view(), f.shard, 0)
+	if err != nil {
+		return 0, errors.Wrap(err, "getting container iterator")
+	}
+	defer citer.Close()
+	var size int64
+	for citer.Next() {
+		_, c := citer.Value()
+		size += containerSizeBound(int64(c.N()))
+	}
+	return size, nil
+}
+
+// checkImportSize returns an error wrapping ErrFragmentTooLarge if adding
+// bits with a size estimate of delta could grow the fragment past its
+// maximum size, and otherwise adds delta to the fragment's cached bound.
+// The bound is only computed from storage in tx the first time, and when
+// the import would be rejected. It's a no-op if there is no maximum.
+func (f *fragment) checkImportSize(tx Tx, delta int64) error {
+	max := f.maxSize()
+	if max <= 0 || delta == 0 {
+		return nil
+	}
+	b := &f.sizeBound
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.known || b.n+delta > max {
+		size, err := f.storageSizeBound(tx)
+		if err != nil {
+			b.known = false
+			return errors.Wrap(err, "estimating fragment size")
+		}
+		b.n, b.known = size, true
+	}
+	if b.n+delta > max {
+		return errors.Wrapf(ErrFragmentTooLarge, "%s/%s/%s/%d: up to %d bytes, plus up to %d imported, exceeds %d",
+			f.index(), f.field(), f.view(), f.shard, b.n, delta, max)
+	}
+	b.n += delta
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// Ensure imports which could grow a fragment past the maximum size are
+// rejected without writing anything, and others succeed.
+func TestFragment_MaxSize(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	f.holder.cfg.StorageConfig.MaxFragmentSize = 1000
+
+	// 10 bits in one container: 20 bytes plus overhead.
+	if err := f.bulkImportT(tx, []uint64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, []uint64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, &ImportOptions{}); err != nil {
+		t.Fatalf("under-limit import: %v", err)
+	}
+
+	// 500 more bits: 1000 bytes plus overhead.
+	rowIDs, columnIDs := make([]uint64, 500), make([]uint64, 500)
+	positions := make([]uint64, 500)
+	for i := range rowIDs {
+		rowIDs[i], columnIDs[i] = 2, uint64(i)
+		positions[i] = 3*ShardWidth + uint64(i)
+	}
+	if err := f.bulkImportT(tx, rowIDs, columnIDs, &ImportOptions{}); errors.Cause(err) != ErrFragmentTooLarge {
+		t.Fatalf("expected ErrFragmentTooLarge from bulkImport, got %v", err)
+	}
+	if err := f.importRoaringT(tx, roaringBytes(t, positions...), false); errors.Cause(err) != ErrFragmentTooLarge {
+		t.Fatalf("expected ErrFragmentTooLarge from importRoaring, got %v", err)
+	}
+	for _, rowID := range []uint64{2, 3} {
+		if n := f.mustRow(tx, rowID).Count(); n != 0 {
+			t.Fatalf("expected rejected import to write nothing, row %d has %d bits", rowID, n)
+		}
+	}
+
+	// Clearing can't grow the fragment, so it's never rejected.
+	if err := f.bulkImportT(tx, []uint64{1}, []uint64{0}, &ImportOptions{Clear: true}); err != nil {
+		t.Fatalf("clearing import: %v", err)
+	}
+
+	// Zero is unlimited.
+	f.holder.cfg.StorageConfig.MaxFragmentSize = 0
+	if err := f.importRoaringT(tx, roaringBytes(t, positions...), false); err != nil {
+		t.Fatalf("unlimited import: %v", err)
+	} else if n := f.mustRow(tx, 3).Count(); n != 500 {
+		t.Fatalf("expected 500 bits, got %d", n)
+	}
+}
+
+// Ensure the size bound is kept from each import's estimate rather than
+// walking the fragment's containers, and is only recomputed from storage
+// when an import would otherwise be rejected.
+func TestFragment_MaxSize_CachedBound(t *testing.T) {
+	f, _, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	f.holder.cfg.StorageConfig.MaxFragmentSize = 1000
+
+	// 10 bits in one container: 20 bytes plus overhead.
+	if err := f.bulkImportT(tx, []uint64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, []uint64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !f.sizeBound.known || f.sizeBound.n != 32 {
+		t.Fatalf("expected known bound of 32, got %d (known %v)", f.sizeBound.n, f.sizeBound.known)
+	}
+
+	ctx := &countingTx{Tx: tx}
+	if err := f.checkImportSize(ctx, 100); err != nil {
+		t.Fatal(err)
+	} else if ctx.n != 0 {
+		t.Fatalf("expected no containers read under the cached bound, got %d", ctx.n)
+	} else if f.sizeBound.n != 132 {
+		t.Fatalf("expected bound of 132, got %d", f.sizeBound.n)
+	}
+
+	// A single bit adds at most a new container.
+	f.mustSetBits(tx, 5, 1)
+	if f.sizeBound.n != 146 {
+		t.Fatalf("expected bound of 146 after setBit, got %d", f.sizeBound.n)
+	}
+
+	// The bound includes 100 bytes which were never written, so an import
+	// it would reject recomputes it from storage, and is admitted.
+	if err := f.checkImportSize(ctx, 900); err != nil {
+		t.Fatalf("expected recomputed bound to admit import: %v", err)
+	} else if ctx.n == 0 {
+		t.Fatal("expected the bound to be recomputed from storage")
+	} else if f.sizeBound.n != 946 {
+		t.Fatalf("expected bound of 946, got %d", f.sizeBound.n)
+	}
+
+	// An unknown bound, as after Open, is recomputed by the next check.
+	f.sizeBound.invalidate()
+	if err := f.checkImportSize(ctx, 1); err != nil {
+		t.Fatal(err)
+	} else if f.sizeBound.n != 47 {
+		t.Fatalf("expected bound recomputed as 47, got %d", f.sizeBound.n)
+	}
+}
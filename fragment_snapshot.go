@@ -0,0 +1,96 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// errFragmentSnapshotReleased is returned when reading through a fragment
+// snapshot whose last reference has been released.
+var errFragmentSnapshotReleased = errors.New("fragment snapshot released")
+
+// fragmentSnapshot is an immutable, reference counted view of a fragment's
+// committed data. Reads through a snapshot don't take the fragment's mutex,
+// so many readers can share one without contending with writers.
+//
+// A snapshot is backed by a read-only Tx. The storage layer keeps every page
+// visible to an open read transaction valid, including across a concurrent
+// commit, checkpoint, or remap of the underlying file, so the data a
+// snapshot sees can't change or be unmapped out from under it. That
+// generation of the data is retained until the last reference to the
+// snapshot is released, which rolls back the Tx. Since a long-lived read
+// transaction holds back reclamation of old pages, snapshots should be
+// released promptly.
+//
+// A snapshot only sees committed data; writes pending in the fragment's
+// write buffer or in an uncommitted Tx are not visible.
+type fragmentSnapshot struct {
+	f    *fragment
+	tx   Tx
+	refs int32
+}
+
+// snapshot returns a new snapshot of the fragment's committed data, holding
+// a single reference. The caller must release it.
+func (f *fragment) snapshot() *fragmentSnapshot {
+	tx := f.holder.txf.NewTx(Txo{Write: !writable, Index: f.idx, Fragment: f, Shard: f.shard})
+	return &fragmentSnapshot{f: f, tx: tx, refs: 1}
+}
+
+// retain adds a reference to the snapshot, for handing it to another
+// reader. It returns an error if the snapshot has already been released.
+func (s *fragmentSnapshot) retain() error {
+	for {
+		n := atomic.LoadInt32(&s.refs)
+		if n <= 0 {
+			return errFragmentSnapshotReleased
+		}
+		if atomic.CompareAndSwapInt32(&s.refs, n, n+1) {
+			return nil
+		}
+	}
+}
+
+// release drops a reference to the snapshot. Dropping the last reference
+// releases the underlying Tx.
+func (s *fragmentSnapshot) release() {
+	switch n := atomic.AddInt32(&s.refs, -1); {
+	case n == 0:
+		s.tx.Rollback()
+	case n < 0:
+		panic("fragment snapshot released more times than retained")
+	}
+}
+
+// live returns an error if the snapshot has been released.
+func (s *fragmentSnapshot) live() error {
+	if atomic.LoadInt32(&s.refs) <= 0 {
+		return errFragmentSnapshotReleased
+	}
+	return nil
+}
+
+// row returns a row of the snapshot. The caller must hold a reference to
+// the snapshot for the duration of the call.
+func (s *fragmentSnapshot) row(rowID uint64) (*Row, error) {
+	if err := s.live(); err != nil {
+		return nil, err
+	}
+	return s.f.rowFromStorage(s.tx, rowID)
+}
+
+// bit returns the value of a bit in the snapshot. The caller must hold a
+// reference to the snapshot for the duration of the call.
+func (s *fragmentSnapshot) bit(rowID, columnID uint64) (bool, error) {
+	if err := s.live(); err != nil {
+		return false, err
+	}
+	pos, err := s.f.pos(rowID, columnID)
+	if err != nil {
+		return false, errors.Wrap(err, "getting bit pos")
+	}
+	return s.tx.Contains(s.f.index(), s.f.field(), s.f.view(), s.f.shard, pos)
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"sync"
+	"testing"
+)
+
+// Ensure snapshot handles stay consistent and readable while writers commit.
+func TestFragment_Snapshot(t *testing.T) {
+	f, idx, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+
+	f.mustSetBits(tx, 1, 0)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	const commits = 50
+	done := make(chan struct{})
+	errs := make(chan error, 16)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := uint64(1); i <= commits; i++ {
+			wtx := idx.holder.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: f, Shard: f.shard})
+			if _, err := f.setBit(wtx, 1, i); err != nil {
+				wtx.Rollback()
+				errs <- err
+				return
+			}
+			if err := wtx.Commit(); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s := f.snapshot()
+				first, err := s.row(1)
+				if err != nil {
+					s.release()
+					errs <- err
+					return
+				}
+
+				// Hand the snapshot to another reader, and drop our own
+				// reference before it's done with it.
+				if err := s.retain(); err != nil {
+					s.release()
+					errs <- err
+					return
+				}
+				var inner sync.WaitGroup
+				inner.Add(1)
+				go func() {
+					defer inner.Done()
+					defer s.release()
+					for i := 0; i < 3; i++ {
+						row, err := s.row(1)
+						if err != nil {
+							errs <- err
+							return
+						} else if row.Count() != first.Count() {
+							t.Errorf("snapshot changed: count %d, then %d", first.Count(), row.Count())
+							return
+						}
+						if ok, err := s.bit(1, 0); err != nil {
+							errs <- err
+							return
+						} else if !ok {
+							t.Error("expected committed bit to be visible in snapshot")
+							return
+						}
+					}
+				}()
+				s.release()
+				inner.Wait()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// A fully released snapshot can't be read or retained.
+	s := f.snapshot()
+	if n := s.mustRowCount(t, 1); n != commits+1 {
+		t.Fatalf("expected count %d, got %d", commits+1, n)
+	}
+	s.release()
+	if _, err := s.row(1); err != errFragmentSnapshotReleased {
+		t.Fatalf("expected released error, got %v", err)
+	} else if err := s.retain(); err != errFragmentSnapshotReleased {
+		t.Fatalf("expected released error from retain, got %v", err)
+	}
+}
+
+func (s *fragmentSnapshot) mustRowCount(tb testing.TB, rowID uint64) uint64 {
+	row, err := s.row(rowID)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return row.Count()
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/featurebasedb/featurebase/v3/roaring"
+	"github.com/pkg/errors"
+)
+
+// tombstones records the positions of recently cleared bits, so that a late
+// set of the same bit, racing with the clear in a concurrent stream, can be
+// suppressed instead of resurrecting the bit. A tombstone expires after the
+// window, after which sets proceed normally. Expired tombstones are swept
+// at most once per window, so memory is bounded by the clears made in the
+// last two windows.
+//
+// tombstones is not safe for concurrent use; a fragment's tombstones are
+// protected by its mu.
+type tombstones struct {
+	window time.Duration
+	now    func() time.Time
+
+	entries   map[uint64]time.Time // expiry by position
+	nextSweep time.Time
+}
+
+// newTombstones returns tombstones which expire after window.
+func newTombstones(window time.Duration) *tombstones {
+	return &tombstones{
+		window:  window,
+		now:     time.Now,
+		entries: make(map[uint64]time.Time),
+	}
+}
+
+// add records a tombstone for pos, replacing any earlier one.
+func (t *tombstones) add(pos uint64) {
+	now := t.now()
+	t.sweep(now)
+	t.entries[pos] = now.Add(t.window)
+}
+
+// has returns true if pos has an unexpired tombstone.
+func (t *tombstones) has(pos uint64) bool {
+	expiry, ok := t.entries[pos]
+	if !ok {
+		return false
+	} else if !t.now().Before(expiry) {
+		delete(t.entries, pos)
+		return false
+	}
+	return true
+}
+
+// filter removes the positions with unexpired tombstones from positions, in
+// place, and returns the result.
+func (t *tombstones) filter(positions []uint64) []uint64 {
+	if len(t.entries) == 0 {
+		return positions
+	}
+	kept := positions[:0]
+	for _, pos := range positions {
+		if !t.has(pos) {
+			kept = append(kept, pos)
+		}
+	}
+	return kept
+}
+
+// sweep deletes expired tombstones, unless it has been less than a window
+// since the last sweep.
+func (t *tombstones) sweep(now time.Time) {
+	if now.Before(t.nextSweep) {
+		return
+	}
+	for pos, expiry := range t.entries {
+		if !now.Before(expiry) {
+			delete(t.entries, pos)
+		}
+	}
+	t.nextSweep = now.Add(t.window)
+}
+
+// addRoaringTombstones records tombstones for the positions in data, a
+// roaring encoded bitmap of bits being cleared. As for clearBit, they're
+// recorded whether or not the bits were set. f.mu must be held.
+func (f *fragment) addRoaringTombstones(data []byte) error {
+	if f.tombstones == nil {
+		return nil
+	}
+	citer, err := roaring.NewContainerIterator(data)
+	if err != nil {
+		return errors.Wrap(err, "getting container iterator")
+	}
+	defer citer.Close()
+	for citer.Next() {
+		key, c := citer.Value()
+		for _, v := range c.Slice() {
+			f.tombstones.add(key<<16 | uint64(v))
+		}
+	}
+	return nil
+}
+
+// filterRoaringTombstones returns data, a roaring encoded bitmap of bits
+// being set, without the positions which have unexpired tombstones. f.mu
+// must be held.
+func (f *fragment) filterRoaringTombstones(data []byte) ([]byte, error) {
+	if f.tombstones == nil || len(f.tombstones.entries) == 0 {
+		return data, nil
+	}
+	bm := roaring.NewBitmap()
+	if err := bm.UnmarshalBinary(data); err != nil {
+		return nil, errors.Wrap(err, "decoding import")
+	}
+	var dropped []uint64
+	for pos := range f.tombstones.entries {
+		if bm.Contains(pos) && f.tombstones.has(pos) {
+			dropped = append(dropped, pos)
+		}
+	}
+	if len(dropped) == 0 {
+		return data, nil
+	}
+	if _, err := bm.Remove(dropped...); err != nil {
+		return nil, errors.Wrap(err, "removing tombstoned bits")
+	}
+	var buf bytes.Buffer
+	if _, err := bm.WriteTo(&buf); err != nil {
+		return nil, errors.Wrap(err, "writing filtered import")
+	}
+	return buf.Bytes(), nil
+}
+
+// applyRoaringTombstones is addRoaringTombstones if clear is true, and
+// filterRoaringTombstones otherwise.
+func (f *fragment) applyRoaringTombstones(data []byte, clear bool) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if clear {
+		return data, f.addRoaringTombstones(data)
+	}
+	return f.filterRoaringTombstones(data)
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Ensure a set of a bit soon after it was cleared is suppressed, and one
+// after the tombstone window succeeds.
+func TestFragment_Tombstones(t *testing.T) {
+	f, _, tx := mustOpenFragment(t, OptFieldTombstoneWindow(time.Minute))
+	defer f.Clean(t)
+
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	f.tombstones.now = func() time.Time { return now }
+
+	f.mustSetBits(tx, 1, 10, 11)
+	if _, err := f.clearBit(tx, 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	// Clearing an unset bit still guards against a late set of it.
+	if _, err := f.clearBit(tx, 1, 12); err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, err := f.setBit(tx, 1, 10); err != nil {
+		t.Fatal(err)
+	} else if changed {
+		t.Fatal("expected set within the window to be suppressed")
+	}
+	if err := f.bulkImportT(tx, []uint64{1, 1, 2}, []uint64{12, 13, 10}, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.mustRow(tx, 1).Columns(); !sliceEq(got, []uint64{11, 13}) {
+		t.Fatalf("expected row 1 to be [11 13], got %v", got)
+	} else if got := f.mustRow(tx, 2).Columns(); !sliceEq(got, []uint64{10}) {
+		t.Fatalf("expected row 2 to be [10], got %v", got)
+	}
+
+	// A clearing import records tombstones too.
+	if err := f.bulkImportT(tx, []uint64{2}, []uint64{10}, &ImportOptions{Clear: true}); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := f.setBit(tx, 2, 10); err != nil {
+		t.Fatal(err)
+	} else if changed {
+		t.Fatal("expected set after clearing import to be suppressed")
+	}
+
+	// Roaring imports and clearing records record and honor tombstones in
+	// the same way.
+	ctx := context.Background()
+	if err := f.importRoaring(ctx, tx, roaringBytes(t, 3*ShardWidth+1), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.importRoaring(ctx, tx, roaringBytes(t, 3*ShardWidth+1, 3*ShardWidth+2), false); err != nil {
+		t.Fatal(err)
+	} else if got := f.mustRow(tx, 3).Columns(); !sliceEq(got, []uint64{2}) {
+		t.Fatalf("expected row 3 to be [2], got %v", got)
+	}
+	if err := f.ImportRoaringClearAndSet(ctx, tx, roaringBytes(t, 3*ShardWidth+2), roaringBytes(t, 3*ShardWidth+1, 3*ShardWidth+3)); err != nil {
+		t.Fatal(err)
+	} else if got := f.mustRow(tx, 3).Columns(); !sliceEq(got, []uint64{3}) {
+		t.Fatalf("expected row 3 to be [3], got %v", got)
+	}
+	if _, err := f.ClearRecords(tx, []uint64{3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.importRoaring(ctx, tx, roaringBytes(t, 3*ShardWidth+2, 3*ShardWidth+3), false); err != nil {
+		t.Fatal(err)
+	} else if got := f.mustRow(tx, 3).Columns(); len(got) != 0 {
+		t.Fatalf("expected row 3 to be empty, got %v", got)
+	}
+
+	// Once the window passes, sets proceed, and the tombstones are swept.
+	now = now.Add(time.Minute)
+	if changed, err := f.setBit(tx, 1, 10); err != nil {
+		t.Fatal(err)
+	} else if !changed {
+		t.Fatal("expected set after the window to succeed")
+	}
+	if _, err := f.clearBit(tx, 3, 0); err != nil {
+		t.Fatal(err)
+	} else if n := len(f.tombstones.entries); n != 1 {
+		t.Fatalf("expected expired tombstones to be swept, have %d", n)
+	}
+
+	if err := OptFieldTombstoneWindow(-time.Second)(&FieldOptions{}); err == nil {
+		t.Fatal("expected error for negative tombstone window")
+	}
+}
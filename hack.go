@@ -20,6 +20,7 @@ func UnmarshalIndexOptions(name string, createdAt int64, buf []byte) (*IndexOpti
 	return &IndexOptions{
 		Keys:           io.Keys,
 		TrackExistence: io.TrackExistence,
+		PartitionN:     int(io.PartitionN),
 	}, nil
 
 }
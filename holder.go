@@ -10,6 +10,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -29,6 +31,10 @@ const (
 	// defaultCacheFlushInterval is the default value for Fragment.CacheFlushInterval.
 	defaultCacheFlushInterval = 1 * time.Minute
 
+	// defaultCacheRecalculateCheckInterval is how often the holder checks
+	// for fields due a periodic cache recalculation.
+	defaultCacheRecalculateCheckInterval = 1 * time.Second
+
 	// existenceFieldName is the name of the internal field used to store existence values.
 	existenceFieldName = "_exists"
 
@@ -85,6 +91,10 @@ type Holder struct {
 	// The interval at which the cached row ids are persisted to disk.
 	cacheFlushInterval time.Duration
 
+	// The interval at which fields are checked for a due periodic cache
+	// recalculation (see OptFieldCacheRecalculateInterval).
+	cacheRecalculateCheckInterval time.Duration
+
 	Logger logger.Logger
 
 	// Instantiates new translation stores
@@ -133,6 +143,13 @@ type Holder struct {
 	imu     sync.RWMutex
 	indexes map[string]*Index
 
+	// importSem limits the number of fragment imports running at once. It
+	// is nil if imports are unlimited.
+	importSem chan struct{}
+
+	// quiesce tracks writes in flight, and whether new ones are accepted.
+	quiesce holderQuiesce
+
 	// directive is the latest directive applied to the node.
 	directive *dax.Directive
 
@@ -259,6 +276,15 @@ type HolderConfig struct {
 	RBFConfig     *rbfcfg.Config
 
 	LookupDBDSN string
+
+	// LazyFragmentOpen, if set, defers opening the fragments found when
+	// the holder opens, which loads their caches, until each is first
+	// accessed. Which shards have data is still known from the start.
+	LazyFragmentOpen bool
+
+	// SlowImportThreshold, if set, is how long a fragment's bulk or roaring
+	// import can take before it's logged as slow.
+	SlowImportThreshold time.Duration
 }
 
 // DefaultHolderConfig provides a holder config with reasonable
@@ -330,12 +356,17 @@ func NewHolder(path string, cfg *HolderConfig) *Holder {
 		Logger:               cfg.Logger,
 		Opts:                 HolderOpts{StorageBackend: cfg.StorageConfig.Backend},
 
+		cacheRecalculateCheckInterval: defaultCacheRecalculateCheckInterval,
+
 		Auditor: NewAuditor(),
 
 		path: path,
 
 		indexes: make(map[string]*Index),
 	}
+	if n := cfg.StorageConfig.MaxConcurrentImports; n > 0 {
+		h.importSem = make(chan struct{}, n)
+	}
 
 	txf, err := NewTxFactory(cfg.StorageConfig.Backend, h.IndexesPath(), h)
 	vprint.PanicOn(err)
@@ -345,6 +376,22 @@ func NewHolder(path string, cfg *HolderConfig) *Holder {
 	return h
 }
 
+// acquireImport waits for a slot in which to run a fragment import, and
+// returns a function which releases it. It returns ctx's error if ctx is
+// done first. Callers must not hold any fragment's mutex while waiting, so
+// that the imports holding slots can always finish.
+func (h *Holder) acquireImport(ctx context.Context) (func(), error) {
+	if h == nil || h.importSem == nil {
+		return func() {}, nil
+	}
+	select {
+	case h.importSem <- struct{}{}:
+		return func() { <-h.importSem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 // Path returns the path directory the holder was created with.
 func (h *Holder) Path() string {
 	return h.path
@@ -358,9 +405,9 @@ func (h *Holder) IndexesPath() string {
 func (h *Holder) deletePerShard(index *Index, shard uint64) error {
 	inprocessRecords := NewRow()
 
-	frag := h.fragment(index.name, existenceFieldName, viewStandard, shard)
-	if frag == nil {
-		return nil
+	frag, err := h.fragment(index.name, existenceFieldName, viewStandard, shard)
+	if err != nil || frag == nil {
+		return err
 	}
 
 	tx := h.Txf().NewTx(Txo{Write: !writable, Index: index, Shard: shard})
@@ -579,6 +626,10 @@ func (h *Holder) Activate() {
 	// Periodically flush cache.
 	h.wg.Add(1)
 	go func() { defer h.wg.Done(); h.monitorCacheFlush() }()
+
+	// Periodically recalculate the caches of fields which ask for it.
+	h.wg.Add(1)
+	go func() { defer h.wg.Done(); h.monitorCacheRecalculation() }()
 }
 
 // checkForeignIndex is a check before applying a foreign
@@ -809,6 +860,174 @@ func (h *Holder) IndexPath(name string) string {
 	return filepath.Join(h.IndexesPath(), name)
 }
 
+// FindOrphanFragments walks the holder's data directory and returns the
+// paths of fragment storage which doesn't correspond to the current schema,
+// or to a shard for which owns returns false: the RBF shard directories,
+// <index>/backends/rbf/shard.NNNN, which hold the data of every fragment of
+// an index's shard, and the fragments' cache files. Since replicas are
+// stored locally too, owns should report true for any shard this node holds
+// a replica of, as cluster.findOrphanFragments's does. If owns is nil, only
+// the schema is checked. Nothing is deleted.
+func (h *Holder) FindOrphanFragments(owns func(index string, shard uint64) bool) ([]string, error) {
+	root := h.IndexesPath()
+	var orphans []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if d.IsDir() {
+			// <index>/backends/rbf/shard.NNNN
+			if len(parts) != 4 || parts[1] != backendsDir || parts[2] != rbfTxn.DirectoryName() || !strings.HasPrefix(parts[3], "shard.") {
+				return nil
+			}
+			if !h.isOwnedShard(parts[0], strings.TrimPrefix(parts[3], "shard."), owns) {
+				orphans = append(orphans, path)
+			}
+			return filepath.SkipDir
+		}
+		// <index>/fields/<field>/views/<view>/fragments/<shard>[.cache]
+		if len(parts) != 7 || parts[1] != FieldsDir || parts[3] != "views" || parts[5] != "fragments" {
+			return nil
+		}
+		if !h.isOwnedFragment(parts[0], parts[2], parts[4], strings.TrimSuffix(parts[6], cacheExt), owns) {
+			orphans = append(orphans, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "walking indexes directory")
+	}
+	return orphans, nil
+}
+
+// isOwnedShard returns true if the named index exists and owns reports that
+// the shard belongs to this node.
+func (h *Holder) isOwnedShard(index, shard string, owns func(string, uint64) bool) bool {
+	shardID, err := strconv.ParseUint(shard, 10, 64)
+	if err != nil {
+		return false
+	}
+	return h.Index(index) != nil && (owns == nil || owns(index, shardID))
+}
+
+// isOwnedFragment returns true if the named view exists and owns reports
+// that the shard belongs to this node.
+func (h *Holder) isOwnedFragment(index, field, view, shard string, owns func(string, uint64) bool) bool {
+	if !h.isOwnedShard(index, shard, owns) {
+		return false
+	}
+	idx := h.Index(index)
+	if idx == nil {
+		return false
+	}
+	fld := idx.Field(field)
+	return fld != nil && fld.view(view) != nil
+}
+
+// IndexStorageUsage is the disk space, in bytes, used by an index.
+type IndexStorageUsage struct {
+	// Total is the size of all of the index's files.
+	Total int64 `json:"total"`
+
+	// Data is the size of the index's shard storage. It isn't broken down
+	// by field, since the fields of each shard are stored together.
+	Data int64 `json:"data"`
+
+	// Keys is the size of the index's column key translation stores.
+	Keys int64 `json:"keys"`
+
+	// Fields holds the usage of each field's own files.
+	Fields map[string]*FieldStorageUsage `json:"fields,omitempty"`
+}
+
+// FieldStorageUsage is the disk space, in bytes, used by a field's own
+// files, which don't include its share of the index's shard storage.
+type FieldStorageUsage struct {
+	// Total is the size of all of the field's files.
+	Total int64 `json:"total"`
+
+	// Keys is the size of the field's row key translation store.
+	Keys int64 `json:"keys"`
+
+	// Cache is the size of the field's fragment cache files.
+	Cache int64 `json:"cache"`
+}
+
+// StorageUsage walks the holder's data directory and returns the disk space
+// used by each index, by name. Files which are removed during the walk, as
+// happens when shards or fields are deleted, are skipped.
+func (h *Holder) StorageUsage() (map[string]*IndexStorageUsage, error) {
+	root := h.IndexesPath()
+	usage := make(map[string]*IndexStorageUsage)
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		} else if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) < 2 {
+			return nil
+		}
+
+		iu := usage[parts[0]]
+		if iu == nil {
+			iu = &IndexStorageUsage{Fields: make(map[string]*FieldStorageUsage)}
+			usage[parts[0]] = iu
+		}
+		size := info.Size()
+		iu.Total += size
+		switch parts[1] {
+		case backendsDir:
+			iu.Data += size
+		case translateStoreDir:
+			iu.Keys += size
+		case FieldsDir:
+			// <index>/fields/<field>/...
+			if len(parts) < 4 {
+				return nil
+			}
+			fu := iu.Fields[parts[2]]
+			if fu == nil {
+				fu = &FieldStorageUsage{}
+				iu.Fields[parts[2]] = fu
+			}
+			fu.Total += size
+			if len(parts) == 4 && parts[3] == "keys" {
+				fu.Keys += size
+			} else if strings.HasSuffix(parts[len(parts)-1], cacheExt) {
+				fu.Cache += size
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "walking indexes directory")
+	}
+	return usage, nil
+}
+
 // Index returns the index by name.
 func (h *Holder) Index(name string) (idx *Index) {
 	h.imu.RLock()
@@ -992,6 +1211,7 @@ func (h *Holder) createIndex(cim *CreateIndexMessage, broadcast bool) (*Index, e
 
 	index.keys = cim.Meta.Keys
 	index.trackExistence = cim.Meta.TrackExistence
+	index.partitionN = cim.Meta.PartitionN
 	index.createdAt = cim.CreatedAt
 	index.owner = cim.Owner
 	index.description = cim.Meta.Description
@@ -1035,6 +1255,7 @@ func (h *Holder) createIndexWithPartitions(cim *CreateIndexMessage, translatePar
 
 	index.keys = cim.Meta.Keys
 	index.trackExistence = cim.Meta.TrackExistence
+	index.partitionN = cim.Meta.PartitionN
 	index.createdAt = cim.CreatedAt
 	index.translatePartitions = translatePartitions
 
@@ -1230,11 +1451,12 @@ func (h *Holder) view(index, field, name string) *view {
 	return f.view(name)
 }
 
-// fragment returns the fragment for an index, field & shard.
-func (h *Holder) fragment(index, field, view string, shard uint64) *fragment {
+// fragment returns the fragment for an index, field & shard, or nil if
+// there is none. It returns an error if the fragment can't be opened.
+func (h *Holder) fragment(index, field, view string, shard uint64) (*fragment, error) {
 	v := h.view(index, field, view)
 	if v == nil {
-		return nil
+		return nil, nil
 	}
 	return v.Fragment(shard)
 }
@@ -1266,6 +1488,38 @@ func (h *Holder) flushCaches() {
 	}
 }
 
+// monitorCacheRecalculation periodically recalculates the caches of fields
+// with a CacheRecalculateInterval, once it's passed since their last
+// recalculation. This is run in a goroutine.
+func (h *Holder) monitorCacheRecalculation() {
+	ticker := time.NewTicker(h.cacheRecalculateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.closing:
+			return
+		case now := <-ticker.C:
+			h.recalculateDueCaches(now)
+		}
+	}
+}
+
+func (h *Holder) recalculateDueCaches(now time.Time) {
+	for _, index := range h.Indexes() {
+		for _, field := range index.Fields() {
+			select {
+			case <-h.closing:
+				return
+			default:
+			}
+			if field.cacheRecalculationDue(now) {
+				field.recalculateCaches()
+			}
+		}
+	}
+}
+
 // recalculateCaches recalculates caches on every index in the holder. This is
 // probably not practical to call in real-world workloads, but makes writing
 // integration tests much eaiser, since one doesn't have to wait 10 seconds
@@ -1596,8 +1850,8 @@ func (s *holderSyncer) initializeReplication(snap *disco.ClusterSnapshot) error
 // from the primary node.
 func (s *holderSyncer) populateFieldReplication(nodeMaps map[string]TranslateOffsetMap, snap *disco.ClusterSnapshot) error {
 	// Set up field translation
-	if !snap.IsPrimaryFieldTranslationNode(s.Cluster.Node.ID) {
-		primaryID := snap.PrimaryFieldTranslationNode().ID
+	if primary := snap.PrimaryFieldTranslationNode(); primary != nil && primary.ID != s.Cluster.Node.ID {
+		primaryID := primary.ID
 		// Build a map of field key offsets to stream from.
 		m := nodeMaps[primaryID]
 		if m == nil {
@@ -1640,7 +1894,7 @@ func (s *holderSyncer) populateIndexReplication(nodeMaps map[string]TranslateOff
 			if !index.Keys() {
 				continue
 			}
-			for partitionID := 0; partitionID < snap.PartitionN; partitionID++ {
+			for partitionID := 0; partitionID < index.PartitionN(); partitionID++ {
 				partitionNodes := snap.PartitionNodes(partitionID)
 				isPrimary := partitionNodes[0].ID == node.ID                       // remote is primary?
 				isReplica := disco.Nodes(partitionNodes[1:]).ContainsID(s.Node.ID) // local is replica?
@@ -1700,7 +1954,7 @@ func (s *holderSyncer) readBothTranslateReader(rd TranslateEntryReader, snap *di
 				s.Holder.Logger.Errorf("index not found: %q", entry.Index)
 				return
 			}
-			store = idx.TranslateStore(snap.KeyToKeyPartition(entry.Index, entry.Key))
+			store = idx.TranslateStore(idx.keyPartition(entry.Key))
 			if store == nil {
 				s.Holder.Logger.Errorf("no translate store suitable for index %q, key %q", entry.Index, entry.Key)
 				return
@@ -0,0 +1,375 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// An index backup is a tar stream with the following entries, in order:
+//
+//	schema
+//	indexes/<index>/translate/<partition>
+//	indexes/<index>/fields/<field>/translate
+//	indexes/<index>/fields/<field>/views/<view>/shards/<shard>
+//
+// The schema entry is a JSON encoded indexBackupSchema. Translate entries
+// hold the raw contents of a translate store, as written by
+// TranslatorTx.WriteTo, and are only present for keyed indexes and fields.
+// Shard entries hold a fragment archive, as written by fragment.WriteTo.
+// Translation data precedes fragment data so that a restore can reject a
+// backup before it has written any bits.
+
+// indexBackupSchema is the schema entry of an index backup.
+type indexBackupSchema struct {
+	Index      *IndexInfo `json:"index"`
+	PartitionN int        `json:"partitionN"`
+}
+
+// BackupIndex writes the schema, translation data, and fragments of the
+// named index to w as a single tar stream.
+func (h *Holder) BackupIndex(index string, w io.Writer) error {
+	idx := h.Index(index)
+	if idx == nil {
+		return newNotFoundError(ErrIndexNotFound, index)
+	}
+
+	schema, err := h.Schema()
+	if err != nil {
+		return errors.Wrap(err, "getting schema")
+	}
+	bs := &indexBackupSchema{PartitionN: idx.PartitionN()}
+	for _, ii := range schema {
+		if ii.Name == index {
+			bs.Index = ii
+		}
+	}
+	if bs.Index == nil {
+		return newNotFoundError(ErrIndexNotFound, index)
+	}
+
+	tw := tar.NewWriter(w)
+	buf, err := json.Marshal(bs)
+	if err != nil {
+		return errors.Wrap(err, "marshaling schema")
+	}
+	if err := writeBackupEntry(tw, "schema", buf); err != nil {
+		return err
+	}
+
+	prefix := "indexes/" + index
+	if idx.Keys() {
+		for partitionID := 0; partitionID < idx.PartitionN(); partitionID++ {
+			store := idx.TranslateStore(partitionID)
+			if store == nil {
+				continue
+			}
+			name := fmt.Sprintf("%s/translate/%04d", prefix, partitionID)
+			if err := writeBackupTranslateStore(tw, name, store); err != nil {
+				return err
+			}
+		}
+	}
+
+	fields := idx.Fields()
+	for _, fld := range fields {
+		if !fld.Keys() || fld.TranslateStore() == nil {
+			continue
+		}
+		name := fmt.Sprintf("%s/fields/%s/translate", prefix, fld.Name())
+		if err := writeBackupTranslateStore(tw, name, fld.TranslateStore()); err != nil {
+			return err
+		}
+	}
+
+	for _, fld := range fields {
+		for _, view := range fld.views() {
+			frags, err := view.allFragments()
+			if err != nil {
+				return err
+			}
+			for _, frag := range frags {
+				var fb bytes.Buffer
+				if _, err := frag.WriteTo(&fb); err != nil {
+					return errors.Wrapf(err, "writing fragment %s/%s/%d", fld.Name(), view.name, frag.shard)
+				}
+				name := fmt.Sprintf("%s/fields/%s/views/%s/shards/%04d", prefix, fld.Name(), view.name, frag.shard)
+				if err := writeBackupEntry(tw, name, fb.Bytes()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return errors.Wrap(tw.Close(), "closing tar writer")
+}
+
+// writeBackupTranslateStore writes the contents of store to tw as a single
+// entry.
+func writeBackupTranslateStore(tw *tar.Writer, name string, store TranslateStore) error {
+	var buf bytes.Buffer
+	if _, err := store.WriteTo(&buf); err != nil {
+		return errors.Wrapf(err, "writing translate store %s", name)
+	}
+	return writeBackupEntry(tw, name, buf.Bytes())
+}
+
+// writeBackupEntry writes data to tw as a single entry.
+func writeBackupEntry(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o666,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return errors.Wrapf(err, "writing header for %s", name)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return errors.Wrapf(err, "writing %s", name)
+	}
+	return nil
+}
+
+// RestoreIndex restores an index from a tar stream written by BackupIndex.
+// The index must not already exist. If the restore fails, the partially
+// restored index is deleted.
+func (h *Holder) RestoreIndex(r io.Reader) error {
+	return h.restoreIndex(r, false)
+}
+
+// MergeIndex is like RestoreIndex, but if the index already exists, the
+// backed up fields are created if they don't exist and the backed up bits
+// are added to it. Merging translation data into a non-empty translate
+// store is rejected, since the existing key/ID assignments could conflict
+// with those in the backup.
+//
+// The bits for each shard are written in a single transaction, and no
+// shard's transaction is committed until every entry has been read, so a
+// merge which fails before then leaves the index as it was: the fields it
+// created are deleted and the translate stores it filled are emptied again.
+// Only a failure while committing can leave some shards merged.
+func (h *Holder) MergeIndex(r io.Reader) error {
+	return h.restoreIndex(r, true)
+}
+
+// indexRestore holds the work of a restore which hasn't been committed: a
+// write transaction for each shard restored, the fragments written in them,
+// and functions undoing the restore's other changes.
+type indexRestore struct {
+	h     *Holder
+	idx   *Index
+	txs   map[uint64]Tx
+	frags []*fragment
+	undo  []func() error
+}
+
+// tx returns the restore's transaction for shard, beginning one if needed.
+func (rs *indexRestore) tx(shard uint64) (Tx, error) {
+	if tx, ok := rs.txs[shard]; ok {
+		return tx, nil
+	}
+	tx, err := rs.h.BeginTx(writable, rs.idx, shard)
+	if err != nil {
+		return nil, errors.Wrapf(err, "beginning transaction for shard %d", shard)
+	}
+	rs.txs[shard] = tx
+	return tx, nil
+}
+
+// commit commits each shard's transaction, and then rebuilds the caches of
+// the fragments written, from storage: a fragment's cache in the backup
+// only counts the backup's bits, not those of a fragment merged into.
+func (rs *indexRestore) commit() error {
+	shards := make([]uint64, 0, len(rs.txs))
+	for shard := range rs.txs {
+		shards = append(shards, shard)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i] < shards[j] })
+	for _, shard := range shards {
+		tx := rs.txs[shard]
+		delete(rs.txs, shard)
+		if err := tx.Commit(); err != nil {
+			return errors.Wrapf(err, "committing shard %d", shard)
+		}
+	}
+	for _, frag := range rs.frags {
+		if err := frag.markCacheStale(); err != nil {
+			return errors.Wrapf(err, "invalidating cache of fragment %s/%s/%d", frag.field(), frag.view(), frag.shard)
+		}
+		frag.RecalculateCache()
+	}
+	return nil
+}
+
+// rollback rolls back the transactions not yet committed, and undoes the
+// restore's other changes, in reverse order. Errors are logged, since the
+// restore is already failing.
+func (rs *indexRestore) rollback() {
+	for _, tx := range rs.txs {
+		tx.Rollback()
+	}
+	rs.txs = nil
+	for i := len(rs.undo) - 1; i >= 0; i-- {
+		if err := rs.undo[i](); err != nil {
+			rs.h.Logger.Errorf("undoing restore of index %s: %v", rs.idx.Name(), err)
+		}
+	}
+}
+
+func (h *Holder) restoreIndex(r io.Reader, merge bool) (err error) {
+	tr := tar.NewReader(r)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return errors.Wrap(err, "reading schema header")
+	} else if hdr.Name != "schema" {
+		return fmt.Errorf("invalid index backup: expected schema, got %s", hdr.Name)
+	}
+	var bs indexBackupSchema
+	if err := json.NewDecoder(tr).Decode(&bs); err != nil {
+		return errors.Wrap(err, "decoding schema")
+	} else if bs.Index == nil {
+		return errors.New("invalid index backup: no index in schema")
+	} else if bs.Index.ShardWidth != 0 && bs.Index.ShardWidth != ShardWidth {
+		return fmt.Errorf("backup shard width %d does not match %d", bs.Index.ShardWidth, ShardWidth)
+	}
+	ii := bs.Index
+
+	idx := h.Index(ii.Name)
+	if idx != nil && !merge {
+		return newConflictError(ErrIndexExists)
+	}
+	rs := &indexRestore{h: h, txs: make(map[uint64]Tx)}
+	if idx == nil {
+		if idx, err = h.CreateIndex(ii.Name, ii.Owner, ii.Options); err != nil {
+			return errors.Wrap(err, "creating index")
+		}
+		rs.undo = append(rs.undo, func() error { return h.DeleteIndex(ii.Name) })
+	}
+	rs.idx = idx
+	defer func() {
+		if err != nil {
+			rs.rollback()
+		}
+	}()
+
+	// The index's partition count comes from its options, so it's only
+	// known once the index exists.
+	if ii.Options.Keys && bs.PartitionN != idx.PartitionN() {
+		return fmt.Errorf("backup partition count %d does not match %d", bs.PartitionN, idx.PartitionN())
+	}
+	for _, fi := range ii.Fields {
+		if idx.Field(fi.Name) != nil {
+			continue
+		}
+		if _, err := idx.CreateFieldIfNotExistsWithOptions(fi.Name, "", &fi.Options); err != nil {
+			return errors.Wrapf(err, "creating field %s", fi.Name)
+		}
+		name := fi.Name
+		rs.undo = append(rs.undo, func() error { return idx.DeleteField(name) })
+	}
+
+	prefix := "indexes/" + ii.Name + "/"
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Wrap(err, "reading backup")
+		}
+		if !strings.HasPrefix(hdr.Name, prefix) {
+			return fmt.Errorf("invalid index backup entry: %s", hdr.Name)
+		}
+		if err := rs.restoreEntry(strings.Split(strings.TrimPrefix(hdr.Name, prefix), "/"), tr); err != nil {
+			return errors.Wrapf(err, "restoring %s", hdr.Name)
+		}
+	}
+	return rs.commit()
+}
+
+// restoreEntry restores a single non-schema backup entry, identified by
+// the components of its name following the index prefix. Fragment data is
+// written in the shard's transaction, to be committed with the rest.
+func (rs *indexRestore) restoreEntry(path []string, r io.Reader) error {
+	idx := rs.idx
+	switch {
+	case len(path) == 2 && path[0] == "translate":
+		partitionID, err := strconv.Atoi(path[1])
+		if err != nil {
+			return errors.Wrap(err, "parsing partition")
+		}
+		store := idx.TranslateStore(partitionID)
+		if store == nil {
+			return ErrTranslateStoreNotFound
+		}
+		return rs.restoreTranslateStore(store, r)
+
+	case len(path) == 3 && path[0] == "fields" && path[2] == "translate":
+		fld := idx.Field(path[1])
+		if fld == nil {
+			return newNotFoundError(ErrFieldNotFound, path[1])
+		} else if fld.TranslateStore() == nil {
+			return ErrTranslateStoreNotFound
+		}
+		return rs.restoreTranslateStore(fld.TranslateStore(), r)
+
+	case len(path) == 6 && path[0] == "fields" && path[2] == "views" && path[4] == "shards":
+		fld := idx.Field(path[1])
+		if fld == nil {
+			return newNotFoundError(ErrFieldNotFound, path[1])
+		}
+		shard, err := strconv.ParseUint(path[5], 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "parsing shard")
+		}
+		view, err := fld.createViewIfNotExists(path[3])
+		if err != nil {
+			return errors.Wrap(err, "creating view")
+		}
+		frag, err := view.CreateFragmentIfNotExists(shard)
+		if err != nil {
+			return errors.Wrap(err, "creating fragment")
+		}
+		tx, err := rs.tx(shard)
+		if err != nil {
+			return err
+		}
+		if err := frag.readDataFromArchive(tx, r); err != nil {
+			return err
+		}
+		rs.frags = append(rs.frags, frag)
+		return nil
+	}
+	return errors.New("unrecognized backup entry")
+}
+
+// restoreTranslateStore replaces the contents of store with the translate
+// data read from r. The store must not contain any keys; should the restore
+// fail, the store is emptied again.
+func (rs *indexRestore) restoreTranslateStore(store TranslateStore, r io.Reader) error {
+	if maxID, err := store.MaxID(); err != nil {
+		return errors.Wrap(err, "getting max id")
+	} else if maxID != 0 {
+		return errors.New("cannot restore translation data into a non-empty translate store")
+	}
+	var empty bytes.Buffer
+	if _, err := store.WriteTo(&empty); err != nil {
+		return errors.Wrap(err, "saving empty translate store")
+	}
+	rs.undo = append(rs.undo, func() error {
+		_, err := store.ReadFrom(&empty)
+		return errors.Wrap(err, "emptying translate store")
+	})
+	_, err := store.ReadFrom(r)
+	return err
+}
@@ -3,7 +3,19 @@
 package pilosa
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 func setupTest(t *testing.T, h *Holder, rowCol []rowCols, indexName string) (*Index, *Field) {
@@ -96,3 +108,453 @@ func TestHolder_ProcessDeleteInflight(t *testing.T) {
 
 	}
 }
+
+// Ensure a keyed index with set and BSI fields survives a backup round trip.
+func TestHolder_BackupRestoreIndex(t *testing.T) {
+	src := newTestHolder(t)
+	idx, err := src.CreateIndex("i", "", IndexOptions{Keys: true, TrackExistence: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := idx.CreateField("set", "", OptFieldKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bsi, err := idx.CreateField("bsi", "", OptFieldTypeInt(-1000, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	colIDs, err := idx.TranslateStore(0).CreateKeys("c0", "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rowIDs, err := set.TranslateStore().CreateKeys("r0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols := []uint64{colIDs["c0"], colIDs["c1"], 3*ShardWidth + 7}
+
+	qcx := src.Txf().NewWritableQcx()
+	for i, col := range cols {
+		if _, err := set.SetBit(qcx, rowIDs["r0"], col, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := bsi.SetValue(qcx, col, int64(i*100-150)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := qcx.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.BackupIndex("i", &buf); err != nil {
+		t.Fatal(err)
+	}
+	backup := buf.Bytes()
+
+	dst := newTestHolder(t)
+	if err := dst.RestoreIndex(bytes.NewReader(backup)); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.RestoreIndex(bytes.NewReader(backup)); err == nil {
+		t.Fatal("expected restore into existing index to fail")
+	}
+
+	ridx := dst.Index("i")
+	if ridx == nil || !ridx.Keys() {
+		t.Fatal("expected restored keyed index")
+	}
+	if ids, err := ridx.TranslateStore(0).FindKeys("c0", "c1"); err != nil {
+		t.Fatal(err)
+	} else if ids["c0"] != colIDs["c0"] || ids["c1"] != colIDs["c1"] {
+		t.Fatalf("unexpected column keys: %v", ids)
+	}
+	rset := ridx.Field("set")
+	if key, err := rset.TranslateStore().TranslateID(rowIDs["r0"]); err != nil {
+		t.Fatal(err)
+	} else if key != "r0" {
+		t.Fatalf("unexpected row key: %q", key)
+	}
+
+	qcx = dst.Txf().NewQcx()
+	defer qcx.Abort()
+	row, err := rset.Row(qcx, rowIDs["r0"])
+	if err != nil {
+		t.Fatal(err)
+	} else if got := row.Columns(); len(got) != len(cols) {
+		t.Fatalf("expected columns %v, got %v", cols, got)
+	}
+	rbsi := ridx.Field("bsi")
+	for i, col := range cols {
+		if v, exists, err := rbsi.Value(qcx, col); err != nil {
+			t.Fatal(err)
+		} else if !exists || v != int64(i*100-150) {
+			t.Fatalf("column %d: expected %d, got %d (exists=%v)", col, i*100-150, v, exists)
+		}
+	}
+}
+
+// Ensure merging a backup into an existing index counts the merged bits in
+// the rank cache, and a failed merge leaves the index as it was.
+func TestHolder_MergeIndex(t *testing.T) {
+	setBits := func(h *Holder, field string, bits map[uint64][]uint64) {
+		t.Helper()
+		idx, err := h.CreateIndexIfNotExists("i", "", IndexOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := idx.CreateFieldIfNotExists(field, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		qcx := h.Txf().NewWritableQcx()
+		for row, cols := range bits {
+			for _, col := range cols {
+				if _, err := f.SetBit(qcx, row, col, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := qcx.Finish(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backup := func(h *Holder) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := h.BackupIndex("i", &buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	rowCount := func(h *Holder, field string, row, shard uint64) uint64 {
+		t.Helper()
+		frag, err := h.fragment("i", field, viewStandard, shard)
+		if err != nil {
+			t.Fatal(err)
+		} else if frag == nil {
+			return 0
+		}
+		return frag.cache.Get(row)
+	}
+
+	src := newTestHolder(t)
+	setBits(src, "f", map[uint64][]uint64{1: {1, 2, ShardWidth + 1}})
+	dst := newTestHolder(t)
+	setBits(dst, "f", map[uint64][]uint64{1: {3}, 2: {4}})
+
+	if err := dst.MergeIndex(bytes.NewReader(backup(src))); err != nil {
+		t.Fatal(err)
+	}
+	if n := rowCount(dst, "f", 1, 0); n != 3 {
+		t.Fatalf("expected merged row 1 to count 3 bits in shard 0, got %d", n)
+	} else if n := rowCount(dst, "f", 2, 0); n != 1 {
+		t.Fatalf("expected row 2 to keep its count, got %d", n)
+	} else if n := rowCount(dst, "f", 1, 1); n != 1 {
+		t.Fatalf("expected merged row 1 to count 1 bit in shard 1, got %d", n)
+	}
+
+	// A backup with a new field, and bits in two shards, which fails on its
+	// last entry.
+	bad := newTestHolder(t)
+	setBits(bad, "f", map[uint64][]uint64{7: {5, ShardWidth + 5}})
+	setBits(bad, "g", map[uint64][]uint64{1: {5}})
+	var buf bytes.Buffer
+	tr := tar.NewReader(bytes.NewReader(backup(bad)))
+	tw := tar.NewWriter(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := writeBackupEntry(tw, hdr.Name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writeBackupEntry(tw, "indexes/i/bogus", nil); err != nil {
+		t.Fatal(err)
+	} else if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dst.MergeIndex(&buf); err == nil {
+		t.Fatal("expected merge of a bad backup to fail")
+	}
+	if dst.Index("i").Field("g") != nil {
+		t.Fatal("expected field created by failed merge to be deleted")
+	}
+	qcx := dst.Txf().NewQcx()
+	defer qcx.Abort()
+	if row, err := dst.Index("i").Field("f").Row(qcx, 7); err != nil {
+		t.Fatal(err)
+	} else if got := row.Columns(); len(got) != 0 {
+		t.Fatalf("expected failed merge to set no bits, got %v", got)
+	}
+	if n := rowCount(dst, "f", 1, 0); n != 3 {
+		t.Fatalf("expected row 1 to keep its count after failed merge, got %d", n)
+	}
+}
+
+func TestHolder_FindOrphanFragments(t *testing.T) {
+	h := newTestHolder(t)
+	idx, err := h.CreateIndex("i", "", IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qcx := h.Txf().NewWritableQcx()
+	for shard := uint64(0); shard < 3; shard++ {
+		if _, err := f.SetBit(qcx, 1, shard*ShardWidth+1, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := qcx.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	shardDir := func(shard uint64) string {
+		return filepath.Join(h.IndexesPath(), "i", backendsDir, rbfTxn.DirectoryName(), fmt.Sprintf("shard.%04v", shard))
+	}
+	var cachePaths []string
+	for shard := uint64(0); shard < 3; shard++ {
+		frag, err := h.fragment("i", "f", viewStandard, shard)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := frag.FlushCache(); err != nil {
+			t.Fatal(err)
+		} else if _, err := os.Stat(frag.cachePath()); err != nil {
+			t.Fatal(err)
+		} else if _, err := os.Stat(shardDir(shard)); err != nil {
+			t.Fatal(err)
+		}
+		cachePaths = append(cachePaths, frag.cachePath())
+	}
+
+	// Every shard belongs to the schema, so without an ownership check
+	// nothing is reported.
+	if orphans, err := h.FindOrphanFragments(nil); err != nil {
+		t.Fatal(err)
+	} else if len(orphans) != 0 {
+		t.Fatalf("expected no orphans without ownership check, got %v", orphans)
+	}
+
+	// Shard 2 has moved to another node: both its RBF database and its
+	// cache file are left behind.
+	owns := func(index string, shard uint64) bool { return shard != 2 }
+	orphans, err := h.FindOrphanFragments(owns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{shardDir(2), cachePaths[2]}
+	sort.Strings(orphans)
+	sort.Strings(exp)
+	if !reflect.DeepEqual(orphans, exp) {
+		t.Fatalf("unexpected orphans:\n got %v\n exp %v", orphans, exp)
+	}
+
+	// Once the field is gone, its cache files no longer match the schema,
+	// but the shards' databases still hold the index's other data.
+	if err := os.MkdirAll(filepath.Join(idx.FieldsPath(), "gone", "views", viewStandard, "fragments"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	gone := filepath.Join(idx.FieldsPath(), "gone", "views", viewStandard, "fragments", "0"+cacheExt)
+	if err := os.Rename(cachePaths[0], gone); err != nil {
+		t.Fatal(err)
+	}
+	if orphans, err := h.FindOrphanFragments(nil); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(orphans, []string{gone}) {
+		t.Fatalf("unexpected orphans: got %v, exp %v", orphans, []string{gone})
+	}
+}
+
+// Ensure storage usage is reported by index, and by field where files
+// belong to one field.
+func TestHolder_StorageUsage(t *testing.T) {
+	h := newTestHolder(t)
+	idx, err := h.CreateIndex("i", "", IndexOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := idx.CreateField("f", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.CreateField("empty", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	qcx := h.Txf().NewWritableQcx()
+	for col := uint64(0); col < 1000; col++ {
+		if _, err := f.SetBit(qcx, col%10, col, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := qcx.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	frag, err := h.fragment("i", "f", viewStandard, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := frag.FlushCache(); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := os.Stat(frag.cachePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The test holder keeps keys in memory, so plant translation stores of
+	// known sizes.
+	planted := map[string]int{
+		idx.TranslateStorePath(0): 100,
+		idx.TranslateStorePath(1): 50,
+		f.TranslateStorePath():    30,
+	}
+	for path, size := range planted {
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		} else if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := h.StorageUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	iu := usage["i"]
+	if iu == nil {
+		t.Fatalf("expected usage for index i, got %v", usage)
+	}
+	if iu.Keys != 150 {
+		t.Fatalf("expected 150 bytes of index keys, got %d", iu.Keys)
+	}
+	if iu.Data <= 0 || iu.Data > 1<<30 {
+		t.Fatalf("unexpected data size: %d", iu.Data)
+	}
+	fu := iu.Fields["f"]
+	if fu == nil {
+		t.Fatalf("expected usage for field f, got %v", iu.Fields)
+	}
+	if fu.Keys != 30 {
+		t.Fatalf("expected 30 bytes of field keys, got %d", fu.Keys)
+	}
+	if fu.Cache != cache.Size() {
+		t.Fatalf("expected %d bytes of cache, got %d", cache.Size(), fu.Cache)
+	}
+	if fu.Total < fu.Keys+fu.Cache {
+		t.Fatalf("field total %d is less than its parts", fu.Total)
+	}
+	if empty := iu.Fields["empty"]; empty != nil && (empty.Keys != 0 || empty.Cache != 0) {
+		t.Fatalf("unexpected usage for empty field: %+v", empty)
+	}
+	var fields int64
+	for _, fu := range iu.Fields {
+		fields += fu.Total
+	}
+	if iu.Total < iu.Data+iu.Keys+fields {
+		t.Fatalf("index total %d is less than its parts", iu.Total)
+	}
+}
+
+// Ensure a field with a cache recalculation interval leaves its cache alone
+// on import, and the holder recalculates it once the interval passes.
+func TestHolder_CacheRecalculateInterval(t *testing.T) {
+	h, _, f := newTestField(t, OptFieldTypeSet(CacheTypeRanked, DefaultCacheSize), OptFieldCacheRecalculateInterval(20*time.Millisecond))
+	h.cacheRecalculateCheckInterval = 5 * time.Millisecond
+
+	var rowIDs, columnIDs []uint64
+	for rowID := uint64(1); rowID <= 3; rowID++ {
+		for col := uint64(0); col < 10*rowID; col++ {
+			rowIDs, columnIDs = append(rowIDs, rowID), append(columnIDs, col)
+		}
+	}
+	qcx := h.Txf().NewWritableQcx()
+	if err := f.Import(context.Background(), qcx, rowIDs, columnIDs, nil, 0, &ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := qcx.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	frag, err := f.view(viewStandard).Fragment(0)
+	if err != nil {
+		t.Fatal(err)
+	} else if n := frag.cache.Len(); n != 0 {
+		t.Fatalf("expected import not to update the cache, got %d entries", n)
+	}
+
+	h.Activate()
+	exp := []bitmapPair{{ID: 3, Count: 30}, {ID: 2, Count: 20}, {ID: 1, Count: 10}}
+	deadline := time.Now().Add(5 * time.Second)
+	for pairs := frag.cache.Top(); !reflect.DeepEqual(pairs, exp); pairs = frag.cache.Top() {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected recalculated cache %v, got %v", exp, pairs)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Ensure PinShards opens and pins the fragments of the shards it's given,
+// and that UnpinShards and deleting a pinned fragment still work.
+func TestHolder_PinShards(t *testing.T) {
+	h, idx, _, v := newTestView(t)
+	frag, err := v.CreateFragmentIfNotExists(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := h.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: frag, Shard: 3})
+	if _, err := frag.setBit(tx, 1, 3*ShardWidth+5); err != nil {
+		t.Fatal(err)
+	} else if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// Shard 4's fragment is known, but not yet opened, as with lazy opening.
+	lazy := v.newFragment(4)
+	v.fragments[4] = lazy
+
+	// A missing shard fails the whole call.
+	if err := h.PinShards("i", "f", []uint64{4, 9}); !errors.Is(err, ErrFragmentNotFound) {
+		t.Fatalf("expected fragment not found, got %v", err)
+	} else if lazy.isOpen() || lazy.isPinned() {
+		t.Fatal("expected nothing to be pinned after an error")
+	}
+	if err := h.PinShards("i", "nope", []uint64{3}); err == nil {
+		t.Fatal("expected error pinning shards of a missing field")
+	}
+
+	if err := h.PinShards("i", "f", []uint64{3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*fragment{frag, lazy} {
+		if !f.isOpen() || !f.isPinned() {
+			t.Fatalf("expected shard %d to be opened and pinned", f.shard)
+		}
+	}
+
+	if err := h.UnpinShards("i", "f", []uint64{4}); err != nil {
+		t.Fatal(err)
+	} else if lazy.isPinned() || !frag.isPinned() {
+		t.Fatal("expected only shard 4 to be unpinned")
+	}
+
+	// A pinned fragment can still be deleted, as when its shard moves.
+	if err := v.deleteFragment(3); err != nil {
+		t.Fatal(err)
+	} else if frag, err := v.Fragment(3); err != nil {
+		t.Fatal(err)
+	} else if frag != nil {
+		t.Fatal("expected shard 3 to be deleted")
+	}
+}
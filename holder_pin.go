@@ -0,0 +1,100 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// PinShards warms the fragments of the given shards, in every view of a
+// field, ahead of the queries which will use them: each is opened, even if
+// the holder opens fragments lazily, and its data is read through, so the
+// first queries find it in memory. The fragments are marked pinned until
+// UnpinShards. Pinning doesn't stop a fragment from being deleted, as when
+// its shard moves to another node. It's an error for a shard to have no
+// fragment in any of the field's views, in which case nothing is pinned.
+func (h *Holder) PinShards(index, field string, shards []uint64) error {
+	frags, err := h.shardFragments(index, field, shards)
+	if err != nil {
+		return err
+	}
+	for _, frag := range frags {
+		if err := frag.ensureOpen(); err != nil {
+			return errors.Wrapf(err, "opening fragment %s/%s/%s/%d", index, field, frag.view(), frag.shard)
+		}
+		if err := frag.warm(); err != nil {
+			return errors.Wrapf(err, "warming fragment %s/%s/%s/%d", index, field, frag.view(), frag.shard)
+		}
+		atomic.StoreUint32(&frag.pinned, 1)
+	}
+	return nil
+}
+
+// UnpinShards unmarks the fragments pinned by PinShards.
+func (h *Holder) UnpinShards(index, field string, shards []uint64) error {
+	frags, err := h.shardFragments(index, field, shards)
+	if err != nil {
+		return err
+	}
+	for _, frag := range frags {
+		atomic.StoreUint32(&frag.pinned, 0)
+	}
+	return nil
+}
+
+// shardFragments returns the fragments of the given shards in every view of
+// a field, without opening them.
+func (h *Holder) shardFragments(index, field string, shards []uint64) ([]*fragment, error) {
+	fld := h.Field(index, field)
+	if fld == nil {
+		if h.Index(index) == nil {
+			return nil, newNotFoundError(ErrIndexNotFound, index)
+		}
+		return nil, newNotFoundError(ErrFieldNotFound, field)
+	}
+	views := fld.views()
+	var frags []*fragment
+	for _, shard := range shards {
+		found := false
+		for _, v := range views {
+			v.mu.RLock()
+			frag := v.fragments[shard]
+			v.mu.RUnlock()
+			if frag != nil {
+				frags = append(frags, frag)
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.Wrapf(ErrFragmentNotFound, "%s/%s shard %d", index, field, shard)
+		}
+	}
+	return frags, nil
+}
+
+// isPinned returns true if the fragment is pinned by Holder.PinShards.
+func (f *fragment) isPinned() bool {
+	return atomic.LoadUint32(&f.pinned) == 1
+}
+
+// warm reads all of the fragment's containers from storage.
+func (f *fragment) warm() error {
+	tx := f.holder.txf.NewTx(Txo{Write: !writable, Index: f.idx, Fragment: f, Shard: f.shard})
+	defer tx.Rollback()
+
+	citer, _, err := tx.ContainerIterator(f.index(), f.field(), f.view(), f.shard, 0)
+	if err != nil {
+		return errors.Wrap(err, "getting container iterator")
+	}
+	defer citer.Close()
+	for citer.Next() {
+		_, c := citer.Value()
+		if _, err := c.WriteTo(io.Discard); err != nil {
+			return errors.Wrap(err, "reading container")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"context"
+	"sync"
+)
+
+// holderQuiesce tracks the writes in flight on a holder, and its open write
+// transactions, so that the holder can stop accepting new writes and wait
+// for the rest to finish and be committed or rolled back.
+type holderQuiesce struct {
+	mu        sync.Mutex
+	quiescing bool
+	inFlight  int
+
+	// drained, if non-nil, is closed once no writes are in flight.
+	drained chan struct{}
+}
+
+// Quiesce stops the holder from accepting writes, which fail with
+// ErrNodeQuiescing until Unquiesce is called, and waits for the writes
+// already in flight to finish, and for every open write transaction to be
+// committed or rolled back. Reads are unaffected. If ctx is done first,
+// Quiesce returns its error, and the holder remains quiescing.
+func (h *Holder) Quiesce(ctx context.Context) error {
+	q := &h.quiesce
+	q.mu.Lock()
+	q.quiescing = true
+	if q.inFlight == 0 {
+		q.mu.Unlock()
+		return nil
+	}
+	if q.drained == nil {
+		q.drained = make(chan struct{})
+	}
+	drained := q.drained
+	q.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Unquiesce allows the holder to accept writes again after Quiesce.
+func (h *Holder) Unquiesce() {
+	q := &h.quiesce
+	q.mu.Lock()
+	q.quiescing = false
+	q.mu.Unlock()
+}
+
+// Quiescing returns true if the holder isn't accepting writes.
+func (h *Holder) Quiescing() bool {
+	q := &h.quiesce
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.quiescing
+}
+
+// beginWrite registers a write in flight, and returns a function to call
+// once it's finished. It returns ErrNodeQuiescing if the holder isn't
+// accepting writes.
+func (h *Holder) beginWrite() (func(), error) {
+	if h == nil {
+		return func() {}, nil
+	}
+	q := &h.quiesce
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.quiescing {
+		return nil, ErrNodeQuiescing
+	}
+	q.inFlight++
+	return h.endWrite, nil
+}
+
+// trackWriteTx registers an open write transaction as a write in flight,
+// and returns a function to call once it's committed or rolled back. Unlike
+// beginWrite, it doesn't fail while quiescing; writes made through the
+// transaction are refused by beginWrite instead.
+func (h *Holder) trackWriteTx() func() {
+	q := &h.quiesce
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inFlight++
+	return h.endWrite
+}
+
+// endWrite finishes a write registered by beginWrite or trackWriteTx.
+func (h *Holder) endWrite() {
+	q := &h.quiesce
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inFlight--
+	if q.inFlight == 0 && q.drained != nil {
+		close(q.drained)
+		q.drained = nil
+	}
+}
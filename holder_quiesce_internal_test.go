@@ -0,0 +1,123 @@
+// Copyright 2022 Molecula Corp. (DBA FeatureBase).
+// SPDX-License-Identifier: Apache-2.0
+package pilosa
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Ensure a quiesced holder rejects writes, serves reads, and waits for the
+// writes in flight to finish.
+func TestHolder_Quiesce(t *testing.T) {
+	f, idx, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	h := idx.holder
+
+	f.mustSetBits(tx, 1, 1, 2, 3)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An in-flight import, held waiting for an import slot.
+	h.importSem = make(chan struct{}, 1)
+	h.importSem <- struct{}{}
+	data := roaringBytes(t, 2*ShardWidth+5)
+	imported := make(chan error, 1)
+	go func() {
+		tx := h.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: f, Shard: f.shard})
+		defer tx.Rollback()
+		if err := f.importRoaringT(tx, data, false); err != nil {
+			imported <- err
+			return
+		}
+		imported <- tx.Commit()
+	}()
+	// Wait for the import, as well as its transaction, to register as in
+	// flight.
+	for {
+		h.quiesce.mu.Lock()
+		n := h.quiesce.inFlight
+		h.quiesce.mu.Unlock()
+		if n > 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	quiesced := make(chan error, 1)
+	go func() { quiesced <- h.Quiesce(context.Background()) }()
+
+	// New writes are rejected, while reads continue.
+	for !h.Quiescing() {
+		time.Sleep(time.Millisecond)
+	}
+	rtx := h.txf.NewTx(Txo{Write: !writable, Index: idx, Fragment: f, Shard: f.shard})
+	if n := f.mustRow(rtx, 1).Count(); n != 3 {
+		t.Fatalf("expected 3 bits while quiescing, got %d", n)
+	}
+	rtx.Rollback()
+	if _, err := f.setBit(nil, 1, 4); errors.Cause(err) != ErrNodeQuiescing {
+		t.Fatalf("expected ErrNodeQuiescing from setBit, got %v", err)
+	}
+	if err := f.bulkImportT(nil, []uint64{1}, []uint64{4}, &ImportOptions{}); errors.Cause(err) != ErrNodeQuiescing {
+		t.Fatalf("expected ErrNodeQuiescing from bulkImport, got %v", err)
+	}
+
+	// Quiesce waits for the in-flight import.
+	select {
+	case err := <-quiesced:
+		t.Fatalf("quiesce returned with an import in flight: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := h.Quiesce(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	<-h.importSem
+	if err := <-imported; err != nil {
+		t.Fatalf("in-flight import: %v", err)
+	}
+	if err := <-quiesced; err != nil {
+		t.Fatalf("quiescing: %v", err)
+	}
+
+	// Writes are accepted again once unquiesced.
+	h.Unquiesce()
+	tx = h.txf.NewTx(Txo{Write: writable, Index: idx, Fragment: f, Shard: f.shard})
+	defer tx.Rollback()
+	if _, err := f.setBit(tx, 1, 4); err != nil {
+		t.Fatalf("setBit after unquiesce: %v", err)
+	}
+	if n := f.mustRow(tx, 2).Count(); n != 1 {
+		t.Fatalf("expected in-flight import to be committed, got %d bits", n)
+	}
+}
+
+// Ensure Quiesce waits for an open write transaction to be committed, even
+// once none of its writes are in flight.
+func TestHolder_QuiesceOpenTx(t *testing.T) {
+	f, idx, tx := mustOpenFragment(t)
+	defer f.Clean(t)
+	h := idx.holder
+
+	f.mustSetBits(tx, 1, 1)
+	quiesced := make(chan error, 1)
+	go func() { quiesced <- h.Quiesce(context.Background()) }()
+	select {
+	case err := <-quiesced:
+		t.Fatalf("quiesce returned with a write transaction open: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-quiesced; err != nil {
+		t.Fatalf("quiescing: %v", err)
+	}
+	h.Unquiesce()
+}
@@ -557,6 +557,7 @@ func newRouter(handler *Handler) http.Handler {
 	router.HandleFunc("/internal/mem-usage", handler.chkAuthZ(handler.handleGetMemUsage, authz.Read)).Methods("GET").Name("GetUsage")
 	router.HandleFunc("/internal/disk-usage", handler.chkAuthZ(handler.handleGetDiskUsage, authz.Read)).Methods("GET").Name("GetUsage")
 	router.HandleFunc("/internal/disk-usage/{index}", handler.chkAuthZ(handler.handleGetDiskUsage, authz.Read)).Methods("GET").Name("GetUsage")
+	router.HandleFunc("/internal/orphan-fragments", handler.chkAuthZ(handler.handleGetOrphanFragments, authz.Admin)).Methods("GET").Name("GetOrphanFragments")
 	router.HandleFunc("/internal/fragment/block/data", handler.chkAuthN(handler.handleGetFragmentBlockData)).Methods("GET").Name("GetFragmentBlockData")
 	router.HandleFunc("/internal/fragment/blocks", handler.chkAuthN(handler.handleGetFragmentBlocks)).Methods("GET").Name("GetFragmentBlocks")
 	router.HandleFunc("/internal/fragment/data", handler.chkAuthN(handler.handleGetFragmentData)).Methods("GET").Name("GetFragmentData")
@@ -1223,6 +1224,25 @@ func (h *Handler) handleGetDiskUsage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleGetOrphanFragments handles GET /internal/orphan-fragments requests.
+func (h *Handler) handleGetOrphanFragments(w http.ResponseWriter, r *http.Request) {
+	if !validHeaderAcceptJSON(r.Header) {
+		http.Error(w, "JSON only acceptable response", http.StatusNotAcceptable)
+		return
+	}
+
+	paths, err := h.api.OrphanFragments(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(paths); err != nil {
+		h.logger.Errorf("write orphan fragments response error: %s", err)
+	}
+}
+
 // handleGetShardDistribution handles GET /ui/shard-distribution requests.
 func (h *Handler) handleGetShardDistribution(w http.ResponseWriter, r *http.Request) {
 	dist := h.api.ShardDistribution(r.Context())
@@ -2448,7 +2468,11 @@ func (h *Handler) handlePostTransaction(w http.ResponseWriter, r *http.Request)
 		id = reqTrns.ID
 	}
 
-	if primary := h.api.PrimaryNode(); h.api.NodeID() == primary.ID {
+	primary := h.api.PrimaryNode()
+	if primary == nil {
+		http.Error(w, "no coordinator", http.StatusServiceUnavailable)
+		return
+	} else if h.api.NodeID() == primary.ID {
 		trns, err := h.api.StartTransaction(r.Context(), id, reqTrns.Timeout, reqTrns.Exclusive, false)
 		h.doTransactionResponse(w, err, trns)
 		return
@@ -2828,6 +2852,15 @@ func (h *Handler) handleGetFragmentData(w http.ResponseWriter, r *http.Request)
 func (h *Handler) handleGetTranslateData(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 
+	// Compress the data if asked to. Readers detect compressed data by its
+	// format marker, so older nodes which don't ask still get it raw.
+	writeTo := func(tx TranslatorTx) (int64, error) {
+		if q.Get("compress") == "true" {
+			return writeCompressedTranslateData(w, tx)
+		}
+		return tx.WriteTo(w)
+	}
+
 	// Perform field translation copy, if field specified.
 	if fieldName := q.Get("field"); fieldName != "" {
 		// Retrieve field data from holder.
@@ -2844,7 +2877,7 @@ func (h *Handler) handleGetTranslateData(w http.ResponseWriter, r *http.Request)
 		defer tx.Rollback()
 
 		// Stream translate data to response body.
-		if _, err := tx.WriteTo(w); err != nil {
+		if _, err := writeTo(tx); err != nil {
 			h.logger.Errorf("error streaming translation data: %s", err)
 		}
 		return
@@ -2876,7 +2909,7 @@ func (h *Handler) handleGetTranslateData(w http.ResponseWriter, r *http.Request)
 	}
 	defer tx.Rollback()
 	// Stream translate partition to response body.
-	if _, err := tx.WriteTo(w); err != nil {
+	if _, err := writeTo(tx); err != nil {
 		h.logger.Errorf("error streaming translation data: %s", err)
 	}
 }
@@ -3136,6 +3169,8 @@ func (h *Handler) handlePostImportAtomicRecord(w http.ResponseWriter, r *http.Re
 			http.Error(w, err.Error(), http.StatusPreconditionFailed)
 		case ErrBSIGroupValueTooLow, ErrBSIGroupValueTooHigh, ErrDecimalOutOfRange:
 			http.Error(w, err.Error(), http.StatusBadRequest)
+		case ErrNodeQuiescing:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		default:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
@@ -3211,6 +3246,8 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, err.Error(), http.StatusPreconditionFailed)
 			case ErrBSIGroupValueTooLow, ErrBSIGroupValueTooHigh, ErrDecimalOutOfRange:
 				http.Error(w, err.Error(), http.StatusBadRequest)
+			case ErrNodeQuiescing:
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
 			default:
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
@@ -3240,6 +3277,8 @@ func (h *Handler) handlePostImport(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, err.Error(), http.StatusPreconditionFailed)
 			case ErrBSIGroupValueTooLow, ErrBSIGroupValueTooHigh, ErrDecimalOutOfRange:
 				http.Error(w, err.Error(), http.StatusBadRequest)
+			case ErrNodeQuiescing:
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
 			default:
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
@@ -3391,6 +3430,8 @@ func (h *Handler) handlePostImportRoaring(w http.ResponseWriter, r *http.Request
 			w.WriteHeader(http.StatusNotFound)
 		} else if _, ok := err.(PreconditionFailedError); ok {
 			w.WriteHeader(http.StatusPreconditionFailed)
+		} else if errors.Is(err, ErrNodeQuiescing) {
+			w.WriteHeader(http.StatusServiceUnavailable)
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
 		}
@@ -3466,6 +3507,8 @@ func (h *Handler) handlePostShardImportRoaring(w http.ResponseWriter, r *http.Re
 			w.WriteHeader(http.StatusNotFound)
 		} else if errors.As(err, &PreconditionFailedError{}) {
 			w.WriteHeader(http.StatusPreconditionFailed)
+		} else if errors.Is(err, ErrNodeQuiescing) {
+			w.WriteHeader(http.StatusServiceUnavailable)
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
 		}
@@ -35,6 +35,9 @@ type Index struct {
 	qualifiedName string
 	keys          bool // use string keys
 
+	// Number of key-partitions, if fewer than the holder's.
+	partitionN int
+
 	// Existence tracking.
 	trackExistence bool
 	existenceFld   *Field
@@ -146,6 +149,33 @@ func (i *Index) TranslateStore(partitionID int) TranslateStore {
 // Keys returns true if the index uses string keys.
 func (i *Index) Keys() bool { return i.keys }
 
+// PartitionN returns the number of key-partitions the index's keys are
+// translated in. It's the holder's partition count, unless the index was
+// created with fewer; an index can't have more key-partitions than the
+// cluster has partitions.
+func (i *Index) PartitionN() int {
+	if i.partitionN <= 0 || i.partitionN > i.holder.partitionN {
+		return i.holder.partitionN
+	}
+	return i.partitionN
+}
+
+// keyPartition returns the key-partition which translates key.
+func (i *Index) keyPartition(key string) int {
+	return disco.KeyToKeyPartition(i.name, key, i.PartitionN())
+}
+
+// idPartition returns the key-partition which allocated the column id.
+//
+// IDs are allocated in shards whose partition, among the cluster's
+// partitions, is the key-partition's number, so a column's shard is owned by
+// the same nodes as its key, even if the index has fewer key-partitions than
+// the cluster. An ID in any other shard wasn't allocated for a key, and its
+// partition is PartitionN or more.
+func (i *Index) idPartition(id uint64) int {
+	return disco.ShardToShardPartition(i.name, id/ShardWidth, i.holder.partitionN)
+}
+
 // Options returns all options for this index.
 func (i *Index) Options() IndexOptions {
 	i.mu.RLock()
@@ -158,6 +188,7 @@ func (i *Index) options() IndexOptions {
 		Description:    i.description,
 		Keys:           i.keys,
 		TrackExistence: i.trackExistence,
+		PartitionN:     i.partitionN,
 	}
 }
 
@@ -182,6 +213,7 @@ func (i *Index) OpenWithSchema(idx *disco.Index) error {
 	i.createdAt = cim.CreatedAt
 	i.trackExistence = cim.Meta.TrackExistence
 	i.keys = cim.Meta.Keys
+	i.partitionN = cim.Meta.PartitionN
 
 	return i.open(idx)
 }
@@ -273,7 +305,9 @@ func (i *Index) open(idx *disco.Index) (err error) {
 		// - DefaultPartitionN
 		//
 		//
-		for partitionID := 0; partitionID < i.holder.partitionN; partitionID++ {
+		// Each store allocates IDs among the cluster's partitions, as
+		// idPartition expects.
+		for partitionID := 0; partitionID < i.PartitionN(); partitionID++ {
 			partitionID := partitionID
 
 			g.Go(func() error {
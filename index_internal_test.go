@@ -3,7 +3,10 @@
 package pilosa
 
 import (
+	"fmt"
 	"testing"
+
+	"github.com/featurebasedb/featurebase/v3/disco"
 )
 
 // mustOpenIndex returns a new, opened index at a temporary path. Panic on error.
@@ -20,3 +23,44 @@ func mustOpenIndex(tb testing.TB, opt IndexOptions) *Index {
 
 	return index
 }
+
+// Ensure an index created with fewer key-partitions than the holder only
+// translates keys in those partitions.
+func TestIndex_PartitionN(t *testing.T) {
+	idx := mustOpenIndex(t, IndexOptions{Keys: true, PartitionN: 4})
+	if n := idx.PartitionN(); n != 4 {
+		t.Fatalf("expected 4 partitions, got %d", n)
+	} else if opt := idx.Options(); opt.PartitionN != 4 {
+		t.Fatalf("expected partitionN 4 in options, got %d", opt.PartitionN)
+	}
+	if idx.TranslateStore(3) == nil {
+		t.Fatal("expected translate store for partition 3")
+	} else if idx.TranslateStore(4) != nil {
+		t.Fatal("unexpected translate store for partition 4")
+	}
+
+	used := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		partitionID := idx.keyPartition(key)
+		if partitionID < 0 || partitionID >= 4 {
+			t.Fatalf("key %q in partition %d", key, partitionID)
+		}
+		used[partitionID] = true
+
+		ids, err := idx.TranslateStore(partitionID).CreateKeys(key)
+		if err != nil {
+			t.Fatal(err)
+		} else if p := idx.idPartition(ids[key]); p != partitionID {
+			t.Fatalf("id %d for key %q in partition %d, expected %d", ids[key], key, p, partitionID)
+		}
+	}
+	if len(used) != 4 {
+		t.Fatalf("expected keys in all 4 partitions, got %v", used)
+	}
+
+	// Indexes without a partition count use the holder's.
+	if n := mustOpenIndex(t, IndexOptions{Keys: true}).PartitionN(); n != disco.DefaultPartitionN {
+		t.Fatalf("expected %d partitions, got %d", disco.DefaultPartitionN, n)
+	}
+}
@@ -56,6 +56,10 @@ type InternalClient struct {
 	// In that case, a path might look like `localhost:8080/compute/schema`,
 	// where `/compute` is the pathPrefix.
 	pathPrefix string
+
+	// compressTranslateData asks for translate partitions to be sent
+	// compressed.
+	compressTranslateData bool
 }
 
 // NewInternalClient returns a new instance of InternalClient to connect to host.
@@ -115,6 +119,15 @@ func WithClientRetryPeriod(period time.Duration) InternalClientOption {
 	}
 }
 
+// WithCompressedTranslateData sets whether translate partitions retrieved
+// from other nodes are requested compressed. Nodes which don't support
+// compression send them uncompressed, which is read just the same.
+func WithCompressedTranslateData(compress bool) InternalClientOption {
+	return func(c *InternalClient) {
+		c.compressTranslateData = compress
+	}
+}
+
 func WithClientLogger(log logger.Logger) InternalClientOption {
 	return func(c *InternalClient) {
 		c.log = log
@@ -2056,10 +2069,14 @@ func (c *InternalClient) RetrieveTranslatePartitionFromURI(ctx context.Context,
 	}
 
 	u := nodePathToURL(node, fmt.Sprintf("%s/internal/translate/data", c.prefix()))
-	u.RawQuery = url.Values{
+	q := url.Values{
 		"index":     {index},
 		"partition": {strconv.FormatInt(int64(partition), 10)},
-	}.Encode()
+	}
+	if c.compressTranslateData {
+		q.Set("compress", "true")
+	}
+	u.RawQuery = q.Encode()
 
 	// Build request.
 	req, err := http.NewRequest("GET", u.String(), nil)
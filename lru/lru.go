@@ -82,6 +82,16 @@ func (c *Cache) Get(key Key) (value interface{}, ok bool) {
 	return nil, false
 }
 
+// Remove removes the provided key from the cache.
+func (c *Cache) Remove(key Key) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		c.removeElement(ele)
+	}
+}
+
 // removeOldest removes the oldest item from the cache.
 func (c *Cache) removeOldest() {
 	if c.cache == nil {
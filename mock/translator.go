@@ -85,6 +85,10 @@ func (s *TranslateStore) ReadFrom(r io.Reader) (int64, error) {
 	return 0, nil
 }
 
+func (s *TranslateStore) Restore(r io.Reader) (int64, error) {
+	return 0, nil
+}
+
 var _ pilosa.TranslateEntryReader = (*TranslateEntryReader)(nil)
 
 type TranslateEntryReader struct {
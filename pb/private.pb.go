@@ -26,6 +26,7 @@ type IndexMeta struct {
 	Keys                 bool     `protobuf:"varint,3,opt,name=Keys,proto3" json:"Keys,omitempty"`
 	TrackExistence       bool     `protobuf:"varint,4,opt,name=TrackExistence,proto3" json:"TrackExistence,omitempty"`
 	Description          string   `protobuf:"bytes,5,opt,name=Description,proto3" json:"Description,omitempty"`
+	PartitionN           uint32   `protobuf:"varint,6,opt,name=PartitionN,proto3" json:"PartitionN,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -85,27 +86,37 @@ func (m *IndexMeta) GetDescription() string {
 	return ""
 }
 
+func (m *IndexMeta) GetPartitionN() uint32 {
+	if m != nil {
+		return m.PartitionN
+	}
+	return 0
+}
+
 type FieldOptions struct {
-	Type                 string   `protobuf:"bytes,8,opt,name=Type,proto3" json:"Type,omitempty"`
-	CacheType            string   `protobuf:"bytes,3,opt,name=CacheType,proto3" json:"CacheType,omitempty"`
-	CacheSize            uint32   `protobuf:"varint,4,opt,name=CacheSize,proto3" json:"CacheSize,omitempty"`
-	TimeQuantum          string   `protobuf:"bytes,5,opt,name=TimeQuantum,proto3" json:"TimeQuantum,omitempty"`
-	OldMin               int64    `protobuf:"varint,9,opt,name=OldMin,proto3" json:"OldMin,omitempty"`
-	OldMax               int64    `protobuf:"varint,10,opt,name=OldMax,proto3" json:"OldMax,omitempty"`
-	Keys                 bool     `protobuf:"varint,11,opt,name=Keys,proto3" json:"Keys,omitempty"`
-	NoStandardView       bool     `protobuf:"varint,12,opt,name=NoStandardView,proto3" json:"NoStandardView,omitempty"`
-	Base                 int64    `protobuf:"varint,13,opt,name=Base,proto3" json:"Base,omitempty"`
-	BitDepth             uint64   `protobuf:"varint,14,opt,name=BitDepth,proto3" json:"BitDepth,omitempty"`
-	Scale                int64    `protobuf:"varint,15,opt,name=Scale,proto3" json:"Scale,omitempty"`
-	ForeignIndex         string   `protobuf:"bytes,16,opt,name=ForeignIndex,proto3" json:"ForeignIndex,omitempty"`
-	Min                  *Decimal `protobuf:"bytes,17,opt,name=Min,proto3" json:"Min,omitempty"`
-	Max                  *Decimal `protobuf:"bytes,18,opt,name=Max,proto3" json:"Max,omitempty"`
-	TimeUnit             string   `protobuf:"bytes,19,opt,name=TimeUnit,proto3" json:"TimeUnit,omitempty"`
-	TTL                  string   `protobuf:"bytes,20,opt,name=TTL,proto3" json:"TTL,omitempty"`
-	TrackExistence       bool     `protobuf:"varint,21,opt,name=TrackExistence,proto3" json:"TrackExistence,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Type                     string   `protobuf:"bytes,8,opt,name=Type,proto3" json:"Type,omitempty"`
+	CacheType                string   `protobuf:"bytes,3,opt,name=CacheType,proto3" json:"CacheType,omitempty"`
+	CacheSize                uint32   `protobuf:"varint,4,opt,name=CacheSize,proto3" json:"CacheSize,omitempty"`
+	TimeQuantum              string   `protobuf:"bytes,5,opt,name=TimeQuantum,proto3" json:"TimeQuantum,omitempty"`
+	OldMin                   int64    `protobuf:"varint,9,opt,name=OldMin,proto3" json:"OldMin,omitempty"`
+	OldMax                   int64    `protobuf:"varint,10,opt,name=OldMax,proto3" json:"OldMax,omitempty"`
+	Keys                     bool     `protobuf:"varint,11,opt,name=Keys,proto3" json:"Keys,omitempty"`
+	NoStandardView           bool     `protobuf:"varint,12,opt,name=NoStandardView,proto3" json:"NoStandardView,omitempty"`
+	Base                     int64    `protobuf:"varint,13,opt,name=Base,proto3" json:"Base,omitempty"`
+	BitDepth                 uint64   `protobuf:"varint,14,opt,name=BitDepth,proto3" json:"BitDepth,omitempty"`
+	Scale                    int64    `protobuf:"varint,15,opt,name=Scale,proto3" json:"Scale,omitempty"`
+	ForeignIndex             string   `protobuf:"bytes,16,opt,name=ForeignIndex,proto3" json:"ForeignIndex,omitempty"`
+	Min                      *Decimal `protobuf:"bytes,17,opt,name=Min,proto3" json:"Min,omitempty"`
+	Max                      *Decimal `protobuf:"bytes,18,opt,name=Max,proto3" json:"Max,omitempty"`
+	TimeUnit                 string   `protobuf:"bytes,19,opt,name=TimeUnit,proto3" json:"TimeUnit,omitempty"`
+	TTL                      string   `protobuf:"bytes,20,opt,name=TTL,proto3" json:"TTL,omitempty"`
+	TrackExistence           bool     `protobuf:"varint,21,opt,name=TrackExistence,proto3" json:"TrackExistence,omitempty"`
+	RunThreshold             int32    `protobuf:"varint,22,opt,name=RunThreshold,proto3" json:"RunThreshold,omitempty"`
+	TombstoneWindow          int64    `protobuf:"varint,23,opt,name=TombstoneWindow,proto3" json:"TombstoneWindow,omitempty"`
+	CacheRecalculateInterval int64    `protobuf:"varint,24,opt,name=CacheRecalculateInterval,proto3" json:"CacheRecalculateInterval,omitempty"`
+	XXX_NoUnkeyedLiteral     struct{} `json:"-"`
+	XXX_unrecognized         []byte   `json:"-"`
+	XXX_sizecache            int32    `json:"-"`
 }
 
 func (m *FieldOptions) Reset()         { *m = FieldOptions{} }
@@ -260,6 +271,27 @@ func (m *FieldOptions) GetTrackExistence() bool {
 	return false
 }
 
+func (m *FieldOptions) GetRunThreshold() int32 {
+	if m != nil {
+		return m.RunThreshold
+	}
+	return 0
+}
+
+func (m *FieldOptions) GetTombstoneWindow() int64 {
+	if m != nil {
+		return m.TombstoneWindow
+	}
+	return 0
+}
+
+func (m *FieldOptions) GetCacheRecalculateInterval() int64 {
+	if m != nil {
+		return m.CacheRecalculateInterval
+	}
+	return 0
+}
+
 type ImportResponse struct {
 	Err                  string   `protobuf:"bytes,1,opt,name=Err,proto3" json:"Err,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -443,6 +475,7 @@ func (m *BlockDataResponse) GetColumnIDs() []uint64 {
 
 type Cache struct {
 	IDs                  []uint64 `protobuf:"varint,1,rep,packed,name=IDs,proto3" json:"IDs,omitempty"`
+	Stale                bool     `protobuf:"varint,2,opt,name=Stale,proto3" json:"Stale,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -488,6 +521,13 @@ func (m *Cache) GetIDs() []uint64 {
 	return nil
 }
 
+func (m *Cache) GetStale() bool {
+	if m != nil {
+		return m.Stale
+	}
+	return false
+}
+
 type MaxShards struct {
 	Standard             map[string]uint64 `protobuf:"bytes,1,rep,name=Standard,proto3" json:"Standard,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
 	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
@@ -2904,6 +2944,69 @@ func (m *DeleteDataframeMessage) GetIndex() string {
 	return ""
 }
 
+type ShardRouteMessage struct {
+	Index                string   `protobuf:"bytes,1,opt,name=Index,proto3" json:"Index,omitempty"`
+	Shard                uint64   `protobuf:"varint,2,opt,name=Shard,proto3" json:"Shard,omitempty"`
+	NodeIDs              []string `protobuf:"bytes,3,rep,name=NodeIDs,proto3" json:"NodeIDs,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ShardRouteMessage) Reset()         { *m = ShardRouteMessage{} }
+func (m *ShardRouteMessage) String() string { return proto.CompactTextString(m) }
+func (*ShardRouteMessage) ProtoMessage()    {}
+func (*ShardRouteMessage) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d2a91b51c7bdc125, []int{46}
+}
+func (m *ShardRouteMessage) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ShardRouteMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ShardRouteMessage.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ShardRouteMessage) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ShardRouteMessage.Merge(m, src)
+}
+func (m *ShardRouteMessage) XXX_Size() int {
+	return m.Size()
+}
+func (m *ShardRouteMessage) XXX_DiscardUnknown() {
+	xxx_messageInfo_ShardRouteMessage.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ShardRouteMessage proto.InternalMessageInfo
+
+func (m *ShardRouteMessage) GetIndex() string {
+	if m != nil {
+		return m.Index
+	}
+	return ""
+}
+
+func (m *ShardRouteMessage) GetShard() uint64 {
+	if m != nil {
+		return m.Shard
+	}
+	return 0
+}
+
+func (m *ShardRouteMessage) GetNodeIDs() []string {
+	if m != nil {
+		return m.NodeIDs
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*IndexMeta)(nil), "pb.IndexMeta")
 	proto.RegisterType((*FieldOptions)(nil), "pb.FieldOptions")
@@ -2954,122 +3057,129 @@ func init() {
 	proto.RegisterType((*ShardedIngestRequest)(nil), "pb.ShardedIngestRequest")
 	proto.RegisterMapType((map[uint64]*ShardIngestOperations)(nil), "pb.ShardedIngestRequest.OpsEntry")
 	proto.RegisterType((*DeleteDataframeMessage)(nil), "pb.DeleteDataframeMessage")
+	proto.RegisterType((*ShardRouteMessage)(nil), "pb.ShardRouteMessage")
 }
 
 func init() { proto.RegisterFile("private.proto", fileDescriptor_d2a91b51c7bdc125) }
 
 var fileDescriptor_d2a91b51c7bdc125 = []byte{
-	// 1750 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x58, 0xcd, 0x6e, 0x24, 0x49,
-	0x11, 0xa6, 0x7e, 0xdc, 0x3f, 0xd1, 0x6e, 0x4f, 0x3b, 0xd7, 0x6b, 0x6a, 0xbc, 0x83, 0xd5, 0x93,
-	0xa0, 0x99, 0x66, 0x24, 0x8c, 0xf0, 0x1e, 0x16, 0xb1, 0x97, 0x1d, 0x77, 0x7b, 0x96, 0x66, 0x77,
-	0x7e, 0x36, 0xed, 0x99, 0x23, 0x28, 0x5d, 0x9d, 0xd8, 0xa5, 0xa9, 0xae, 0x6a, 0xaa, 0xaa, 0x3d,
-	0xdd, 0x7b, 0x40, 0x02, 0x09, 0xc1, 0x85, 0x3b, 0xe2, 0xc0, 0x5b, 0xf0, 0x0e, 0x5c, 0x90, 0x78,
-	0x04, 0x34, 0xdc, 0x78, 0x0a, 0x94, 0x91, 0x99, 0x55, 0xd9, 0xed, 0xb2, 0xbd, 0x58, 0x7b, 0xab,
-	0xf8, 0x22, 0x2b, 0xf2, 0x8b, 0x9f, 0x8c, 0x8c, 0x2a, 0xe8, 0xce, 0xb2, 0xe8, 0x92, 0x17, 0xe2,
-	0x60, 0x96, 0xa5, 0x45, 0x4a, 0xdc, 0xd9, 0xd9, 0xde, 0xe6, 0x6c, 0x7e, 0x16, 0x47, 0xa1, 0x42,
-	0x68, 0x04, 0xed, 0x71, 0x32, 0x11, 0x8b, 0xe7, 0xa2, 0xe0, 0x84, 0x80, 0xff, 0x85, 0x58, 0xe6,
-	0x81, 0xd7, 0x77, 0x06, 0x2d, 0x86, 0xcf, 0xe4, 0x11, 0x6c, 0x9d, 0x66, 0x3c, 0x7c, 0x7b, 0xbc,
-	0x88, 0xf2, 0x42, 0x24, 0xa1, 0x08, 0x7c, 0xd4, 0xae, 0xa1, 0xa4, 0x0f, 0x9d, 0x91, 0xc8, 0xc3,
-	0x2c, 0x9a, 0x15, 0x51, 0x9a, 0x04, 0x1b, 0x7d, 0x67, 0xd0, 0x66, 0x36, 0x44, 0xff, 0xeb, 0xc1,
-	0xe6, 0xb3, 0x48, 0xc4, 0x93, 0x97, 0x28, 0xe7, 0x72, 0xbb, 0xd3, 0xe5, 0x4c, 0x04, 0x2d, 0x5c,
-	0x8b, 0xcf, 0xe4, 0x01, 0xb4, 0x87, 0x3c, 0xbc, 0x10, 0xa8, 0xf0, 0x50, 0x51, 0x01, 0xa5, 0xf6,
-	0x24, 0xfa, 0x5a, 0xf1, 0xe8, 0xb2, 0x0a, 0x90, 0x14, 0x4e, 0xa3, 0xa9, 0xf8, 0x6a, 0xce, 0x93,
-	0x62, 0x3e, 0x35, 0x14, 0x2c, 0x88, 0xec, 0x42, 0xe3, 0x65, 0x3c, 0x79, 0x1e, 0x25, 0x41, 0xbb,
-	0xef, 0x0c, 0x3c, 0xa6, 0x25, 0x83, 0xf3, 0x45, 0x00, 0x15, 0xce, 0x17, 0x65, 0x40, 0x3a, 0xab,
-	0x01, 0x79, 0x91, 0x9e, 0x14, 0x3c, 0x99, 0xf0, 0x6c, 0xf2, 0x26, 0x12, 0xef, 0x82, 0x4d, 0x15,
-	0x90, 0x55, 0x54, 0xbe, 0x7b, 0xc4, 0x73, 0x11, 0x74, 0xd1, 0x22, 0x3e, 0x93, 0x3d, 0x68, 0x1d,
-	0x45, 0xc5, 0x48, 0xcc, 0x8a, 0x8b, 0x60, 0xab, 0xef, 0x0c, 0x7c, 0x56, 0xca, 0x64, 0x07, 0x36,
-	0x4e, 0x42, 0x1e, 0x8b, 0xe0, 0x1e, 0xbe, 0xa0, 0x04, 0x42, 0x61, 0xf3, 0x59, 0x9a, 0x89, 0xe8,
-	0x3c, 0xc1, 0x34, 0x05, 0x3d, 0x74, 0x6a, 0x05, 0x23, 0xdf, 0x03, 0x4f, 0xba, 0xb4, 0xdd, 0x77,
-	0x06, 0x9d, 0xc3, 0xce, 0xc1, 0xec, 0xec, 0x60, 0x24, 0xc2, 0x68, 0xca, 0x63, 0x26, 0x71, 0x54,
-	0xf3, 0x45, 0x40, 0xea, 0xd4, 0x7c, 0x21, 0x39, 0xc9, 0x10, 0xbd, 0x4e, 0xa2, 0x22, 0xf8, 0x00,
-	0xad, 0x97, 0x32, 0xe9, 0x81, 0x77, 0x7a, 0xfa, 0x65, 0xb0, 0x83, 0xb0, 0x7c, 0xac, 0x29, 0x87,
-	0x0f, 0xeb, 0xca, 0x81, 0x52, 0xd8, 0x1a, 0x4f, 0x67, 0x69, 0x56, 0x30, 0x91, 0xcf, 0xd2, 0x24,
-	0x17, 0xd2, 0xd6, 0x71, 0x96, 0x05, 0x8e, 0xb2, 0x75, 0x9c, 0x65, 0xf4, 0xb7, 0xd0, 0x3b, 0x8a,
-	0xd3, 0xf0, 0xed, 0x88, 0x17, 0x9c, 0x89, 0xdf, 0xcc, 0x45, 0x5e, 0xc8, 0x28, 0x28, 0x47, 0xd5,
-	0x3a, 0x25, 0x48, 0x14, 0x2b, 0x27, 0x70, 0x15, 0x8a, 0x82, 0x8c, 0x30, 0xc6, 0x5f, 0x25, 0x1a,
-	0x9f, 0x31, 0x8a, 0x17, 0x3c, 0x9b, 0x60, 0x75, 0xf8, 0x4c, 0x09, 0x12, 0xc5, 0x9d, 0xb0, 0xa2,
-	0x7c, 0xa6, 0x04, 0x3a, 0x86, 0x6d, 0x6b, 0x7f, 0x4d, 0x73, 0x17, 0x1a, 0x2c, 0x7d, 0x37, 0x1e,
-	0xe5, 0x81, 0xd3, 0xf7, 0x06, 0x3e, 0xd3, 0x12, 0x96, 0x5e, 0x1a, 0xcf, 0xa7, 0x89, 0x54, 0xb9,
-	0xa8, 0xaa, 0x00, 0x7a, 0x1f, 0x36, 0xb0, 0x0e, 0xa5, 0x97, 0xd5, 0xbb, 0xf2, 0x91, 0xfe, 0xce,
-	0x81, 0xf6, 0x73, 0xbe, 0x40, 0x22, 0x39, 0xf9, 0x04, 0x5a, 0xa6, 0x4a, 0x70, 0x51, 0xe7, 0xf0,
-	0x23, 0x99, 0x91, 0x72, 0xc1, 0x81, 0xd1, 0x1e, 0x27, 0x45, 0xb6, 0x64, 0xe5, 0xe2, 0xbd, 0x4f,
-	0xa1, 0xbb, 0xa2, 0x92, 0x3b, 0xbd, 0x15, 0x4b, 0x13, 0xcf, 0xb7, 0x62, 0x29, 0xbd, 0xbc, 0xe4,
-	0xf1, 0x5c, 0x60, 0x94, 0x7c, 0xa6, 0x84, 0x9f, 0xb9, 0x3f, 0x75, 0xe8, 0x1b, 0x20, 0xc3, 0x4c,
-	0xf0, 0x42, 0xe0, 0x26, 0xcf, 0x45, 0x9e, 0xf3, 0x73, 0x71, 0x5b, 0xac, 0x3d, 0x3b, 0xd6, 0x65,
-	0x5c, 0x5d, 0x2b, 0xae, 0xf4, 0x09, 0x90, 0x91, 0x88, 0x45, 0x21, 0x74, 0x0f, 0xb9, 0xc1, 0xae,
-	0x8c, 0x83, 0x26, 0x71, 0xfb, 0x62, 0xf2, 0x10, 0x7c, 0xd9, 0x91, 0x70, 0xb7, 0xce, 0x61, 0x57,
-	0x86, 0xa8, 0x6c, 0x53, 0x0c, 0x55, 0x98, 0x10, 0x34, 0x37, 0x79, 0x5a, 0x20, 0x57, 0x8f, 0x55,
-	0x80, 0x34, 0xfb, 0xf2, 0x5d, 0x22, 0x32, 0x5d, 0x1c, 0x4a, 0xa0, 0x7f, 0x2d, 0x39, 0xa0, 0x57,
-	0xdf, 0x30, 0x10, 0x2b, 0x45, 0xf7, 0x03, 0xcd, 0xcc, 0x43, 0x66, 0x3d, 0xc9, 0xcc, 0x6e, 0x6a,
-	0x75, 0xe4, 0xfc, 0x6f, 0x46, 0xee, 0x0f, 0x0e, 0x90, 0xd7, 0xb3, 0xc9, 0x3a, 0xb9, 0x67, 0x75,
-	0x94, 0x91, 0x69, 0xe7, 0x70, 0x57, 0x6e, 0x7f, 0x55, 0xcb, 0xea, 0x9c, 0x7c, 0x0c, 0x0d, 0x65,
-	0x5d, 0x07, 0xf5, 0x5e, 0x49, 0x5d, 0xc1, 0x4c, 0xab, 0xe9, 0xa7, 0xd0, 0xb1, 0x60, 0xec, 0x8d,
-	0xaa, 0xa7, 0xab, 0xe8, 0x68, 0x49, 0x3a, 0xf1, 0xa6, 0xac, 0xb6, 0x36, 0x53, 0x02, 0xfd, 0xcc,
-	0x54, 0xc4, 0x5d, 0x03, 0x4c, 0x43, 0xf8, 0x48, 0x59, 0x78, 0x7a, 0xc9, 0xa3, 0x98, 0x9f, 0xc5,
-	0xff, 0x57, 0xd1, 0xae, 0xe4, 0x2a, 0x80, 0x26, 0xbe, 0x3b, 0x1e, 0xe9, 0x83, 0x6f, 0x44, 0x3a,
-	0x87, 0xaa, 0x87, 0xbc, 0xe0, 0x53, 0xa1, 0xad, 0xe1, 0x73, 0x99, 0x62, 0xf7, 0xc6, 0x14, 0x4b,
-	0xff, 0x23, 0xf1, 0x4e, 0xde, 0x96, 0x1e, 0xfa, 0x2f, 0x85, 0x9b, 0x13, 0x4f, 0x7f, 0x04, 0x8d,
-	0x93, 0xf0, 0x42, 0x4c, 0x39, 0xf9, 0x3e, 0x34, 0x91, 0xb9, 0xc8, 0x75, 0x1b, 0x68, 0x97, 0x35,
-	0xce, 0x8c, 0x46, 0x56, 0x84, 0xf6, 0xaf, 0x8e, 0xe6, 0xca, 0x56, 0xee, 0x7a, 0x8d, 0x3d, 0x86,
-	0xa6, 0xe6, 0x8b, 0x55, 0x76, 0xe5, 0x10, 0x19, 0x2d, 0x79, 0x08, 0x0d, 0xf4, 0x2e, 0x0f, 0xfc,
-	0x8a, 0x08, 0x22, 0x4c, 0x2b, 0xe8, 0x31, 0x78, 0xaf, 0xd9, 0x58, 0x56, 0x02, 0xb2, 0x37, 0x34,
-	0xb4, 0x24, 0xc9, 0xfd, 0x3c, 0xcd, 0x0b, 0x1d, 0x7b, 0x7c, 0x96, 0xd8, 0xab, 0x34, 0x53, 0x07,
-	0xb3, 0xcb, 0xf0, 0x99, 0xfe, 0xc9, 0x01, 0xff, 0x45, 0x3a, 0x11, 0x64, 0x0b, 0xdc, 0xf1, 0x48,
-	0x1b, 0x71, 0xc7, 0x23, 0x72, 0x1f, 0xed, 0xeb, 0x78, 0x37, 0xe5, 0xfe, 0xaf, 0xd9, 0x98, 0xe1,
-	0x9e, 0x0f, 0xa0, 0x3d, 0xce, 0x5f, 0x65, 0xd1, 0x94, 0x67, 0x4b, 0x3d, 0x97, 0x54, 0x00, 0x76,
-	0xa5, 0x42, 0x96, 0xb4, 0xaf, 0xd2, 0x8e, 0x02, 0x79, 0x08, 0xcd, 0xcf, 0xd9, 0xab, 0xa1, 0x34,
-	0xb9, 0xb1, 0x6a, 0xd2, 0xe0, 0xf4, 0x33, 0xe8, 0x49, 0x26, 0xb8, 0xde, 0x54, 0xd6, 0x2e, 0x34,
-	0x24, 0x56, 0x32, 0xd3, 0x52, 0xb5, 0x89, 0x6b, 0x6d, 0x42, 0x9f, 0x29, 0x0b, 0xc7, 0x97, 0x22,
-	0x29, 0xac, 0xda, 0x44, 0x19, 0x0d, 0x74, 0x99, 0x12, 0xc8, 0x03, 0xe5, 0xb5, 0x76, 0xaf, 0x25,
-	0xb9, 0x48, 0x99, 0x21, 0x4a, 0x97, 0x00, 0x86, 0xc9, 0x3c, 0x2f, 0xd7, 0x3a, 0x75, 0x6b, 0x09,
-	0x35, 0xe5, 0xa3, 0xbb, 0x0f, 0x48, 0xbd, 0x42, 0x98, 0x29, 0xac, 0x1f, 0x56, 0x85, 0xa5, 0xf2,
-	0x79, 0xaf, 0xcc, 0xbb, 0xda, 0xa3, 0x2a, 0xaf, 0x0b, 0xe8, 0x58, 0x78, 0x6d, 0x8d, 0x3d, 0x2e,
-	0x8b, 0xc3, 0xad, 0x8c, 0x21, 0xa2, 0x8d, 0x69, 0xf5, 0xcd, 0xdd, 0x98, 0x46, 0xba, 0xa5, 0xdc,
-	0xb0, 0xd3, 0x00, 0xee, 0xad, 0x1e, 0x78, 0x73, 0xcb, 0xae, 0xc3, 0xb7, 0x6c, 0xf5, 0x47, 0x07,
-	0xba, 0xc3, 0x78, 0x9e, 0x17, 0x22, 0x2b, 0x63, 0xda, 0xd6, 0x40, 0x99, 0xda, 0x0a, 0xa8, 0xcf,
-	0x2e, 0xd9, 0x87, 0x0d, 0x19, 0x71, 0x75, 0xb8, 0xed, 0x44, 0x28, 0xd8, 0xca, 0x84, 0x7f, 0x5d,
-	0x26, 0xe8, 0x1b, 0x68, 0x1d, 0x9d, 0x8c, 0x3f, 0xcf, 0xd2, 0xf9, 0xac, 0xd6, 0x63, 0x33, 0xfe,
-	0xba, 0xd6, 0xf8, 0xdb, 0x53, 0xa3, 0x9c, 0xf2, 0x0a, 0xa7, 0xb7, 0x9e, 0x9a, 0xde, 0x7c, 0x8d,
-	0xf0, 0x05, 0x3d, 0x81, 0x6d, 0xe5, 0xae, 0xec, 0x38, 0x77, 0x69, 0x8b, 0x66, 0x6e, 0xf2, 0xaa,
-	0xb9, 0x49, 0x1a, 0x55, 0x5d, 0xf7, 0xdb, 0x34, 0xfa, 0x4f, 0x17, 0xb6, 0x99, 0xc8, 0xa3, 0xaf,
-	0xc5, 0x38, 0xc9, 0x8b, 0x6c, 0x1e, 0x9a, 0x8b, 0xe3, 0x17, 0xe9, 0x99, 0xce, 0x85, 0xc7, 0x94,
-	0x70, 0xf3, 0x29, 0x21, 0x14, 0x9a, 0x76, 0x13, 0xb0, 0x17, 0x18, 0x05, 0x79, 0x02, 0xcd, 0x93,
-	0x74, 0x9e, 0x85, 0x65, 0xe5, 0x63, 0xe7, 0x56, 0xfb, 0x2b, 0x05, 0x33, 0x0b, 0xc8, 0x17, 0x40,
-	0x4e, 0x33, 0x9e, 0xe4, 0x31, 0x97, 0x94, 0xcc, 0x6b, 0xad, 0x6a, 0x20, 0xb3, 0xb4, 0x2b, 0x16,
-	0x6a, 0x5e, 0x23, 0x07, 0xf6, 0x11, 0x0e, 0x9a, 0xc8, 0x6f, 0xcb, 0xf0, 0xd3, 0xe7, 0xc4, 0x3e,
-	0xe4, 0x9f, 0xac, 0x55, 0x68, 0xd0, 0xc0, 0x57, 0xb6, 0xf1, 0x32, 0xb7, 0x15, 0x6c, 0x75, 0x1d,
-	0xfd, 0xbd, 0x03, 0x9b, 0x36, 0x9b, 0x5b, 0xda, 0x45, 0x99, 0x3e, 0xf7, 0xf6, 0xf9, 0xce, 0xa4,
-	0xcf, 0xaf, 0x9b, 0xa5, 0x37, 0xec, 0x99, 0x2f, 0x85, 0xef, 0x5e, 0x13, 0x9c, 0x3b, 0xd1, 0xe9,
-	0x43, 0xe7, 0x15, 0xcf, 0x8a, 0x48, 0x1a, 0xd3, 0xf7, 0xf4, 0x06, 0xb3, 0x21, 0x2a, 0xe0, 0xfe,
-	0x95, 0x22, 0x1a, 0xa6, 0xd3, 0x99, 0xac, 0xd6, 0x3b, 0x15, 0x93, 0x6c, 0xd3, 0x59, 0x96, 0x66,
-	0x26, 0x02, 0x28, 0xd0, 0x23, 0x68, 0x9d, 0xa6, 0xb3, 0x34, 0x4e, 0xcf, 0x97, 0xb7, 0xb4, 0x8c,
-	0x00, 0x9a, 0xea, 0x6a, 0x50, 0x2d, 0xaa, 0xcd, 0x8c, 0x48, 0x3f, 0x90, 0xf5, 0x1e, 0xf2, 0x38,
-	0x9c, 0xc7, 0xbc, 0x10, 0xf8, 0x45, 0x80, 0xe0, 0x97, 0x29, 0x9f, 0xa8, 0xae, 0xa0, 0x8f, 0x16,
-	0xfd, 0x95, 0x2e, 0x40, 0x8e, 0xee, 0x58, 0x57, 0xd0, 0xd3, 0xd0, 0x9e, 0xb5, 0x94, 0x44, 0x7e,
-	0x02, 0x1d, 0x6b, 0xb5, 0x3d, 0xc0, 0x59, 0x30, 0xb3, 0xd7, 0xd0, 0xbf, 0x3b, 0x2b, 0xef, 0x5c,
-	0xb9, 0x73, 0xf5, 0x56, 0x97, 0x2a, 0x48, 0x2d, 0xa6, 0x25, 0xe9, 0xfa, 0xf1, 0x22, 0x8c, 0xe7,
-	0xb9, 0x54, 0xe9, 0x0b, 0xb7, 0x04, 0xa4, 0xeb, 0xf2, 0xe3, 0x30, 0x9d, 0x9b, 0xe1, 0xc6, 0x88,
-	0xf2, 0x33, 0x72, 0x24, 0xf8, 0x24, 0x8e, 0x12, 0x81, 0xf5, 0xe2, 0xb1, 0x52, 0x26, 0x4f, 0x54,
-	0x8f, 0x35, 0x85, 0xbe, 0xb3, 0x46, 0x1c, 0x75, 0xaa, 0xf3, 0xe6, 0x94, 0x40, 0x6f, 0x5d, 0x45,
-	0x77, 0x80, 0xa8, 0x0a, 0x78, 0x7a, 0x96, 0x66, 0xe6, 0xb6, 0xa5, 0x43, 0xd3, 0x5c, 0x64, 0xf4,
-	0x6f, 0xbb, 0xc4, 0xab, 0xc8, 0xba, 0x76, 0x64, 0xe9, 0x2f, 0x61, 0x4b, 0xcf, 0x76, 0x22, 0xc3,
-	0x82, 0x96, 0x01, 0x60, 0x22, 0x4c, 0xe5, 0x98, 0x68, 0xbe, 0xe3, 0x2a, 0x40, 0xda, 0xc1, 0x41,
-	0xd7, 0xdc, 0x4e, 0x5a, 0xc2, 0xd9, 0x28, 0x3a, 0x4f, 0xc4, 0x04, 0x6f, 0x0c, 0x8f, 0x69, 0x89,
-	0xfe, 0xd9, 0x85, 0x1d, 0x35, 0x74, 0x26, 0xe7, 0x22, 0x2f, 0xaa, 0x6d, 0x70, 0xac, 0xc6, 0xfe,
-	0x5f, 0x8e, 0xd5, 0x78, 0x03, 0x3c, 0x82, 0xad, 0x61, 0x2c, 0x78, 0x56, 0x71, 0x50, 0x1b, 0xad,
-	0xa1, 0xf2, 0xdc, 0x20, 0xa2, 0xaf, 0x67, 0x35, 0x84, 0xda, 0x10, 0x39, 0x82, 0x96, 0x76, 0xcd,
-	0x34, 0xc4, 0x47, 0x78, 0x4b, 0xd5, 0xb0, 0x31, 0xf3, 0x6d, 0xae, 0xbf, 0x3a, 0x8d, 0xb8, 0xf7,
-	0x12, 0xba, 0x2b, 0xaa, 0x9a, 0xaf, 0xce, 0x81, 0xfd, 0xd5, 0xd9, 0x39, 0x24, 0xd6, 0xb8, 0xac,
-	0xad, 0xdb, 0x5f, 0xa2, 0x43, 0xf8, 0xb0, 0x8e, 0x40, 0x4e, 0x9e, 0x80, 0x27, 0x89, 0xaa, 0x61,
-	0x38, 0xb8, 0x8e, 0x28, 0x93, 0x8b, 0xe8, 0xdf, 0x1c, 0x1d, 0x54, 0xa1, 0xf5, 0xe6, 0xef, 0xc1,
-	0xc7, 0xb6, 0x91, 0x87, 0xa5, 0x91, 0xb5, 0x65, 0x07, 0xa5, 0xa3, 0x72, 0xf5, 0xde, 0x57, 0xd0,
-	0xaa, 0x73, 0xcf, 0x57, 0xee, 0xfd, 0x78, 0xd5, 0xbd, 0xfb, 0xd7, 0x31, 0xcb, 0x6d, 0x2f, 0x0f,
-	0x60, 0x57, 0xdd, 0xa6, 0x23, 0x5e, 0xf0, 0x5f, 0x67, 0x7c, 0x2a, 0x6e, 0xbc, 0x52, 0x8f, 0x7a,
-	0xff, 0x78, 0xbf, 0xef, 0xfc, 0xeb, 0xfd, 0xbe, 0xf3, 0xef, 0xf7, 0xfb, 0xce, 0x5f, 0xfe, 0xb3,
-	0xff, 0x9d, 0xb3, 0x06, 0xfe, 0x9e, 0xfb, 0xf8, 0x7f, 0x01, 0x00, 0x00, 0xff, 0xff, 0xe2, 0x0d,
-	0x5d, 0xdb, 0xc1, 0x13, 0x00, 0x00,
+	// 1853 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x58, 0xdd, 0x6e, 0x23, 0x49,
+	0x15, 0xa6, 0xbb, 0xed, 0xd8, 0x3e, 0x8e, 0xf3, 0x53, 0x9b, 0xcd, 0xf6, 0x64, 0x87, 0xc8, 0x53,
+	0xa0, 0x1d, 0x33, 0x12, 0x59, 0x91, 0xbd, 0x58, 0xb4, 0x7b, 0xb3, 0x93, 0x38, 0xb3, 0x98, 0xdd,
+	0x99, 0xcc, 0x56, 0x3c, 0x83, 0xb8, 0x01, 0x55, 0xda, 0x45, 0xd2, 0x9a, 0x76, 0xb7, 0xe9, 0x9f,
+	0xc4, 0xd9, 0x0b, 0x24, 0x90, 0x10, 0x70, 0xc1, 0x3d, 0xe2, 0x82, 0xb7, 0xe0, 0x1d, 0xb8, 0x41,
+	0xe2, 0x11, 0xd0, 0xf0, 0x0a, 0x3c, 0x00, 0xaa, 0x53, 0x55, 0xdd, 0x65, 0x4f, 0x27, 0x5e, 0x46,
+	0x7b, 0xd7, 0xe7, 0x3b, 0xd5, 0xa7, 0xbe, 0xf3, 0xd3, 0xa7, 0x4e, 0x35, 0xf4, 0x66, 0x69, 0x78,
+	0xc5, 0x73, 0x71, 0x30, 0x4b, 0x93, 0x3c, 0x21, 0xee, 0xec, 0x7c, 0x6f, 0x7d, 0x56, 0x9c, 0x47,
+	0x61, 0xa0, 0x10, 0xfa, 0x27, 0x07, 0x3a, 0xa3, 0x78, 0x22, 0xe6, 0x4f, 0x45, 0xce, 0x09, 0x81,
+	0xc6, 0x17, 0xe2, 0x26, 0xf3, 0xbd, 0xbe, 0x33, 0x68, 0x33, 0x7c, 0x26, 0x1f, 0xc0, 0xc6, 0x38,
+	0xe5, 0xc1, 0xab, 0x93, 0x79, 0x98, 0xe5, 0x22, 0x0e, 0x84, 0xdf, 0x40, 0xed, 0x12, 0x4a, 0xfa,
+	0xd0, 0x1d, 0x8a, 0x2c, 0x48, 0xc3, 0x59, 0x1e, 0x26, 0xb1, 0xdf, 0xec, 0x3b, 0x83, 0x0e, 0xb3,
+	0x21, 0xb2, 0x0f, 0xf0, 0x9c, 0xa7, 0x79, 0x28, 0x85, 0x67, 0xfe, 0x5a, 0xdf, 0x19, 0xf4, 0x98,
+	0x85, 0xd0, 0xff, 0x36, 0x60, 0xfd, 0x49, 0x28, 0xa2, 0xc9, 0x29, 0xae, 0xcf, 0x24, 0x9d, 0xf1,
+	0xcd, 0x4c, 0xf8, 0x6d, 0xb4, 0x85, 0xcf, 0xe4, 0x3e, 0x74, 0x8e, 0x79, 0x70, 0x29, 0x50, 0xe1,
+	0xa1, 0xa2, 0x02, 0x4a, 0xed, 0x59, 0xf8, 0xb5, 0xe2, 0xd9, 0x63, 0x15, 0x20, 0x29, 0x8e, 0xc3,
+	0xa9, 0xf8, 0xaa, 0xe0, 0x71, 0x5e, 0x4c, 0x0d, 0x45, 0x0b, 0x22, 0xbb, 0xb0, 0x76, 0x1a, 0x4d,
+	0x9e, 0x86, 0xb1, 0xdf, 0xe9, 0x3b, 0x03, 0x8f, 0x69, 0xc9, 0xe0, 0x7c, 0xee, 0x43, 0x85, 0xf3,
+	0x79, 0x19, 0xb0, 0xee, 0x62, 0xc0, 0x9e, 0x25, 0x67, 0x39, 0x8f, 0x27, 0x3c, 0x9d, 0xbc, 0x0c,
+	0xc5, 0xb5, 0xbf, 0xae, 0x02, 0xb6, 0x88, 0xca, 0x77, 0x8f, 0x78, 0x26, 0xfc, 0x1e, 0x5a, 0xc4,
+	0x67, 0xb2, 0x07, 0xed, 0xa3, 0x30, 0x1f, 0x8a, 0x59, 0x7e, 0xe9, 0x6f, 0xf4, 0x9d, 0x41, 0x83,
+	0x95, 0x32, 0xd9, 0x81, 0xe6, 0x59, 0xc0, 0x23, 0xe1, 0x6f, 0xe2, 0x0b, 0x4a, 0x20, 0x14, 0xd6,
+	0x9f, 0x24, 0xa9, 0x08, 0x2f, 0x62, 0x4c, 0xa3, 0xbf, 0x85, 0x4e, 0x2d, 0x60, 0xe4, 0xbb, 0xe0,
+	0x49, 0x97, 0xb6, 0xfb, 0xce, 0xa0, 0x7b, 0xd8, 0x3d, 0x98, 0x9d, 0x1f, 0x0c, 0x45, 0x10, 0x4e,
+	0x79, 0xc4, 0x24, 0x8e, 0x6a, 0x3e, 0xf7, 0x49, 0x9d, 0x9a, 0xcf, 0x25, 0x27, 0x19, 0xa2, 0x17,
+	0x71, 0x98, 0xfb, 0xef, 0xa0, 0xf5, 0x52, 0x26, 0x5b, 0xe0, 0x8d, 0xc7, 0x5f, 0xfa, 0x3b, 0x08,
+	0xcb, 0xc7, 0x9a, 0x72, 0x79, 0xb7, 0xb6, 0x5c, 0x28, 0xac, 0xb3, 0x22, 0x1e, 0x5f, 0xa6, 0x22,
+	0xbb, 0x4c, 0xa2, 0x89, 0xbf, 0xdb, 0x77, 0x06, 0x4d, 0xb6, 0x80, 0x91, 0x01, 0x6c, 0x8e, 0x93,
+	0xe9, 0x79, 0x96, 0x27, 0xb1, 0xf8, 0x59, 0x18, 0x4f, 0x92, 0x6b, 0xff, 0x3d, 0xf4, 0x7d, 0x19,
+	0x26, 0x9f, 0x80, 0x8f, 0x69, 0x66, 0x22, 0xe0, 0x51, 0x50, 0x44, 0x3c, 0x17, 0xa3, 0x38, 0x17,
+	0xe9, 0x15, 0x8f, 0x7c, 0x1f, 0x5f, 0xb9, 0x55, 0x4f, 0x29, 0x6c, 0x8c, 0xa6, 0xb3, 0x24, 0xcd,
+	0x99, 0xc8, 0x66, 0x49, 0x9c, 0x09, 0xe9, 0xd5, 0x49, 0x9a, 0xfa, 0x8e, 0xf2, 0xea, 0x24, 0x4d,
+	0xe9, 0x6f, 0x60, 0xeb, 0x28, 0x4a, 0x82, 0x57, 0x43, 0x9e, 0x73, 0x26, 0x7e, 0x5d, 0x88, 0x2c,
+	0x97, 0xf9, 0x50, 0x21, 0x57, 0xeb, 0x94, 0x20, 0x51, 0xac, 0x61, 0xdf, 0x55, 0x28, 0x0a, 0x32,
+	0xd7, 0x58, 0x09, 0xaa, 0xe4, 0xf0, 0x19, 0xf3, 0x79, 0xc9, 0xd3, 0x09, 0xd6, 0x69, 0x83, 0x29,
+	0x41, 0xa2, 0xb8, 0x13, 0xd6, 0x76, 0x83, 0x29, 0x81, 0x8e, 0x60, 0xdb, 0xda, 0x5f, 0xd3, 0xdc,
+	0x85, 0x35, 0x96, 0x5c, 0x8f, 0x86, 0x99, 0xef, 0xf4, 0xbd, 0x41, 0x83, 0x69, 0x09, 0x3f, 0x82,
+	0x24, 0x2a, 0xa6, 0xb1, 0x54, 0xb9, 0xa8, 0xaa, 0x00, 0xfa, 0x21, 0x34, 0x31, 0x14, 0xd2, 0xcb,
+	0xea, 0x5d, 0xf9, 0x88, 0x8c, 0x72, 0x59, 0x61, 0x2e, 0xa6, 0x4c, 0x09, 0xf4, 0xb7, 0x0e, 0x74,
+	0x9e, 0xf2, 0x39, 0xd2, 0xcb, 0xc8, 0xc7, 0xd0, 0x36, 0x55, 0x8c, 0xaf, 0x76, 0x0f, 0xdf, 0x97,
+	0x15, 0x53, 0x2e, 0x38, 0x30, 0xda, 0x93, 0x38, 0x4f, 0x6f, 0x58, 0xb9, 0x78, 0xef, 0x53, 0xe8,
+	0x2d, 0xa8, 0xe4, 0xfe, 0xaf, 0xc4, 0x8d, 0x89, 0xf2, 0x2b, 0x71, 0x23, 0xf7, 0xbf, 0xe2, 0x51,
+	0xa1, 0xf6, 0x6f, 0x30, 0x25, 0x7c, 0xe2, 0xfe, 0xd8, 0xa1, 0x2f, 0x81, 0x1c, 0xa7, 0x82, 0xe7,
+	0x02, 0x37, 0x79, 0x2a, 0xb2, 0x8c, 0x5f, 0x88, 0x55, 0x19, 0xf0, 0xec, 0x0c, 0x94, 0xd1, 0x76,
+	0xad, 0x68, 0xd3, 0x47, 0x40, 0x86, 0x22, 0x12, 0xb9, 0xd0, 0x3d, 0xf0, 0x0e, 0xbb, 0x32, 0x0e,
+	0x9a, 0xc4, 0xea, 0xc5, 0xe4, 0x01, 0x34, 0x64, 0x47, 0xc5, 0xdd, 0xba, 0x87, 0x3d, 0x19, 0xa2,
+	0xb2, 0xcd, 0x32, 0x54, 0x61, 0x9a, 0xd0, 0xdc, 0xe4, 0x71, 0x8e, 0x5c, 0x3d, 0x56, 0x01, 0xd2,
+	0xec, 0xe9, 0x75, 0x2c, 0x52, 0x5d, 0x32, 0x4a, 0xa0, 0x7f, 0x2d, 0x39, 0xa0, 0x57, 0xdf, 0x30,
+	0x10, 0x0b, 0xa5, 0xf8, 0x7d, 0xcd, 0xcc, 0x43, 0x66, 0x5b, 0x92, 0x99, 0xdd, 0x74, 0xeb, 0xc8,
+	0x35, 0xbe, 0x19, 0xb9, 0xdf, 0x3b, 0x40, 0x5e, 0xcc, 0x26, 0xcb, 0xe4, 0x9e, 0xd4, 0x51, 0x46,
+	0xa6, 0xdd, 0xc3, 0x5d, 0xb9, 0xfd, 0x9b, 0x5a, 0x56, 0xe7, 0xe4, 0x43, 0x58, 0x53, 0xd6, 0x75,
+	0x50, 0x37, 0x4b, 0xea, 0x0a, 0x66, 0x5a, 0x4d, 0x3f, 0x85, 0xae, 0x05, 0x63, 0xef, 0x56, 0x67,
+	0x92, 0x8a, 0x8e, 0x96, 0xa4, 0x13, 0x2f, 0xcb, 0x6a, 0xeb, 0x30, 0x25, 0xd0, 0xcf, 0x4c, 0x45,
+	0xbc, 0x6d, 0x80, 0x69, 0x00, 0xef, 0x2b, 0x0b, 0x8f, 0xaf, 0x78, 0x18, 0xf1, 0xf3, 0xe8, 0xff,
+	0x2a, 0xda, 0x85, 0x5c, 0xf9, 0xd0, 0xc2, 0x77, 0x47, 0x43, 0xdd, 0x0e, 0x8c, 0x48, 0x0b, 0xa8,
+	0x3a, 0xcb, 0x33, 0x3e, 0x15, 0xda, 0x1a, 0x3e, 0x97, 0x29, 0x76, 0xef, 0x4c, 0xb1, 0xf4, 0x3f,
+	0x14, 0xd7, 0xf2, 0xb4, 0xf7, 0xd0, 0x7f, 0x29, 0xdc, 0x9d, 0x78, 0xfa, 0x43, 0x58, 0x3b, 0x0b,
+	0x2e, 0xc5, 0x94, 0x93, 0xef, 0x41, 0x0b, 0x99, 0x8b, 0x4c, 0xb7, 0x81, 0x4e, 0x59, 0xe3, 0xcc,
+	0x68, 0x64, 0x45, 0x68, 0xff, 0xea, 0x68, 0x2e, 0x6c, 0xe5, 0x2e, 0xd7, 0xd8, 0x43, 0x68, 0x69,
+	0xbe, 0x7e, 0xb3, 0xee, 0x23, 0x32, 0x5a, 0xf2, 0x00, 0xd6, 0xd0, 0xbb, 0xcc, 0x6f, 0x54, 0x44,
+	0x10, 0x61, 0x5a, 0x41, 0x4f, 0xc0, 0x7b, 0xc1, 0x46, 0x64, 0x57, 0xb3, 0x37, 0x34, 0xb4, 0x24,
+	0xc9, 0xfd, 0x24, 0xc9, 0x72, 0x1d, 0x7b, 0x7c, 0x96, 0xd8, 0xf3, 0x24, 0x55, 0x1f, 0x66, 0x8f,
+	0xe1, 0x33, 0xfd, 0xa3, 0x03, 0x8d, 0x67, 0xc9, 0x44, 0x90, 0x0d, 0x70, 0x47, 0x43, 0x6d, 0xc4,
+	0x1d, 0x0d, 0xc9, 0x3d, 0xb4, 0xaf, 0xe3, 0xdd, 0x92, 0xfb, 0xbf, 0x60, 0x23, 0x86, 0x7b, 0xde,
+	0x87, 0xce, 0x28, 0x7b, 0x9e, 0x86, 0x53, 0x9e, 0xde, 0xe8, 0xb9, 0xaa, 0x02, 0x74, 0xc7, 0xcd,
+	0xd5, 0xac, 0xd2, 0x61, 0x4a, 0x20, 0x0f, 0xa0, 0xf5, 0x39, 0x7b, 0x7e, 0x2c, 0x4d, 0x36, 0x17,
+	0x4d, 0x1a, 0x9c, 0x7e, 0x06, 0x5b, 0x92, 0x09, 0xae, 0x37, 0x95, 0xb5, 0x0b, 0x6b, 0x12, 0x2b,
+	0x99, 0x69, 0xa9, 0xda, 0xc4, 0xb5, 0x36, 0xa1, 0x4f, 0x94, 0x85, 0x93, 0x2b, 0x11, 0xe7, 0x56,
+	0x6d, 0xa2, 0x8c, 0x06, 0x7a, 0x4c, 0x09, 0xe4, 0xbe, 0xf2, 0x5a, 0xbb, 0xd7, 0x96, 0x5c, 0xa4,
+	0xcc, 0x10, 0xa5, 0x37, 0x00, 0x86, 0x49, 0x91, 0x95, 0x6b, 0x9d, 0xba, 0xb5, 0x84, 0x9a, 0xf2,
+	0xd1, 0xdd, 0x07, 0xa4, 0x5e, 0x21, 0x3a, 0x19, 0x9c, 0xfc, 0xa0, 0x2a, 0x2c, 0x95, 0xcf, 0xcd,
+	0x32, 0xef, 0x6a, 0x8f, 0xaa, 0xbc, 0x2e, 0xa1, 0x6b, 0xe1, 0xb5, 0x35, 0xf6, 0xb0, 0x2c, 0x0e,
+	0xb7, 0x32, 0x86, 0x88, 0x36, 0xa6, 0xd5, 0x77, 0x77, 0x63, 0x1a, 0x42, 0xd7, 0x7a, 0xa9, 0x76,
+	0xa7, 0x01, 0x6c, 0x2e, 0x7e, 0xf0, 0xe6, 0xec, 0x5d, 0x86, 0x57, 0x6c, 0xf5, 0x07, 0x07, 0x7a,
+	0xc7, 0x51, 0x91, 0xe5, 0x22, 0x2d, 0x63, 0xda, 0xd1, 0x40, 0x99, 0xda, 0x0a, 0xa8, 0xcf, 0x2e,
+	0xd9, 0x87, 0xa6, 0x8c, 0xb8, 0xfa, 0xb8, 0xed, 0x44, 0x28, 0xd8, 0xca, 0x44, 0xe3, 0xb6, 0x4c,
+	0xd0, 0x97, 0xd0, 0x3e, 0x3a, 0x1b, 0x7d, 0x9e, 0x26, 0xc5, 0xac, 0xd6, 0x63, 0x33, 0x9e, 0xbb,
+	0xd6, 0x78, 0xbe, 0xa5, 0x46, 0x4d, 0xe5, 0x95, 0x7c, 0x44, 0x84, 0xcf, 0x75, 0x2b, 0x91, 0x8f,
+	0xf4, 0x0c, 0xb6, 0x95, 0xbb, 0xb2, 0xe3, 0xbc, 0x4d, 0x5b, 0x34, 0xd3, 0x94, 0x57, 0x4d, 0x53,
+	0xd2, 0xa8, 0xea, 0xba, 0xdf, 0xa6, 0xd1, 0x7f, 0xba, 0xb0, 0xcd, 0x44, 0x16, 0x7e, 0x2d, 0x46,
+	0x71, 0x96, 0xa7, 0x45, 0x60, 0x0e, 0x8e, 0x9f, 0x26, 0xe7, 0x3a, 0x17, 0x1e, 0x53, 0xc2, 0xdd,
+	0x5f, 0x09, 0xa1, 0xd0, 0xb2, 0x9b, 0x80, 0xbd, 0xc0, 0x28, 0xc8, 0x23, 0x68, 0x9d, 0x25, 0x45,
+	0x1a, 0x94, 0x95, 0x8f, 0x9d, 0x5b, 0xed, 0xaf, 0x14, 0xcc, 0x2c, 0x20, 0x5f, 0x00, 0x19, 0xa7,
+	0x3c, 0xce, 0x22, 0x2e, 0x29, 0x99, 0xd7, 0xda, 0xd5, 0x40, 0x66, 0x69, 0x17, 0x2c, 0xd4, 0xbc,
+	0x46, 0x0e, 0xec, 0x4f, 0xd8, 0x6f, 0x21, 0xbf, 0x0d, 0xc3, 0x4f, 0xa1, 0xcc, 0xfe, 0xc8, 0x3f,
+	0x5e, 0xaa, 0x50, 0xbc, 0xcb, 0x75, 0x0f, 0xb7, 0xf1, 0x30, 0xb7, 0x15, 0x6c, 0x71, 0x1d, 0xfd,
+	0x9d, 0x03, 0xeb, 0x36, 0x9b, 0x15, 0xed, 0xa2, 0x4c, 0x9f, 0xbb, 0x7a, 0xbe, 0x33, 0xe9, 0x6b,
+	0xd4, 0x4d, 0xd8, 0x4d, 0x7b, 0xe6, 0x4b, 0xe0, 0xbd, 0x5b, 0x82, 0xf3, 0x56, 0x74, 0xfa, 0xd0,
+	0x2d, 0xef, 0xb0, 0xfa, 0x9c, 0x6e, 0x32, 0x1b, 0xa2, 0x02, 0xee, 0xbd, 0x51, 0x44, 0xc7, 0xc9,
+	0x74, 0x26, 0xab, 0xf5, 0xad, 0x8a, 0x49, 0xb6, 0xe9, 0x34, 0x4d, 0x52, 0x13, 0x01, 0x14, 0xe8,
+	0x11, 0xb4, 0xc7, 0xc9, 0x2c, 0x89, 0x92, 0x8b, 0x9b, 0x15, 0x2d, 0xc3, 0x87, 0x96, 0x3a, 0x1a,
+	0x54, 0x8b, 0xea, 0x30, 0x23, 0xd2, 0x77, 0x60, 0xdb, 0xba, 0x22, 0xe1, 0x3d, 0x01, 0xc1, 0x2f,
+	0x13, 0x3e, 0x51, 0x5d, 0x41, 0x7f, 0x5a, 0xf4, 0x97, 0xba, 0x00, 0x39, 0xba, 0x63, 0x1d, 0x41,
+	0x8f, 0x03, 0x7b, 0xd6, 0x52, 0x12, 0xf9, 0x11, 0x74, 0xad, 0xd5, 0xf6, 0x00, 0x67, 0xc1, 0xcc,
+	0x5e, 0x43, 0xff, 0xee, 0x2c, 0xbc, 0xf3, 0xc6, 0x99, 0xab, 0xb7, 0xba, 0x32, 0xb7, 0x15, 0x2d,
+	0x49, 0xd7, 0x4f, 0xe6, 0x41, 0x54, 0x64, 0x52, 0xa5, 0x0f, 0xdc, 0x12, 0x90, 0xae, 0xcb, 0xcb,
+	0x6b, 0x52, 0x98, 0xe1, 0xc6, 0x88, 0xf2, 0x9a, 0x3b, 0x14, 0x7c, 0x12, 0x85, 0xb1, 0xc0, 0x7a,
+	0xf1, 0x58, 0x29, 0x93, 0x47, 0xaa, 0xc7, 0x9a, 0x42, 0xdf, 0x59, 0x22, 0x8e, 0x3a, 0xd5, 0x79,
+	0x33, 0x4a, 0x60, 0x6b, 0x59, 0x45, 0x77, 0x80, 0xa8, 0x0a, 0x78, 0x7c, 0x9e, 0xa4, 0xe6, 0xb4,
+	0xa5, 0xc7, 0xa6, 0xb9, 0xc8, 0xe8, 0xaf, 0x3a, 0xc4, 0xab, 0xc8, 0xba, 0x76, 0x64, 0xe9, 0x2f,
+	0x60, 0x43, 0xcf, 0x76, 0x22, 0xc5, 0x82, 0x96, 0x01, 0x60, 0x22, 0x48, 0xe4, 0x98, 0x68, 0x6e,
+	0x77, 0x15, 0x20, 0xed, 0xe0, 0xa0, 0x6b, 0x4e, 0x27, 0x2d, 0x49, 0xfc, 0x2c, 0xbc, 0x88, 0xc5,
+	0x04, 0x4f, 0x0c, 0x8f, 0x69, 0x89, 0xfe, 0xd9, 0x85, 0x1d, 0x35, 0x74, 0xc6, 0x17, 0x22, 0xcb,
+	0xab, 0x6d, 0x70, 0xac, 0xc6, 0xfe, 0x5f, 0x8e, 0xd5, 0x52, 0x92, 0x3f, 0x00, 0x8e, 0x23, 0xc1,
+	0xd3, 0x8a, 0x83, 0xda, 0x68, 0x09, 0x95, 0xdf, 0x0d, 0x22, 0xfa, 0x78, 0x56, 0x43, 0xa8, 0x0d,
+	0x91, 0x23, 0x68, 0x6b, 0xd7, 0x4c, 0x43, 0xfc, 0x00, 0x4f, 0xa9, 0x1a, 0x36, 0x66, 0xbe, 0xcd,
+	0xf4, 0xad, 0xd3, 0x88, 0x7b, 0xa7, 0xd0, 0x5b, 0x50, 0xd5, 0xdc, 0x3a, 0x07, 0xf6, 0xad, 0xb3,
+	0x7b, 0x48, 0xac, 0x71, 0x59, 0x5b, 0xb7, 0x6f, 0xa2, 0xc7, 0xf0, 0x6e, 0x1d, 0x81, 0x8c, 0x3c,
+	0x02, 0xef, 0x74, 0xa6, 0x02, 0xde, 0x3d, 0xf4, 0x6f, 0x23, 0xca, 0xe4, 0x22, 0xfa, 0x37, 0x47,
+	0x07, 0x55, 0x68, 0xbd, 0xf9, 0xa7, 0xf0, 0x91, 0x6d, 0xe4, 0x41, 0x69, 0x64, 0x69, 0xd9, 0x41,
+	0xe9, 0xa8, 0x5c, 0xbd, 0xf7, 0x15, 0xb4, 0xeb, 0xdc, 0x6b, 0x28, 0xf7, 0x3e, 0x5c, 0x74, 0xef,
+	0xde, 0x6d, 0xcc, 0x32, 0xdb, 0xcb, 0x03, 0xd8, 0x55, 0xa7, 0xa9, 0xfc, 0xe1, 0xf0, 0xab, 0x94,
+	0x4f, 0xc5, 0xdd, 0x77, 0xe3, 0x9f, 0xc3, 0x36, 0xda, 0x64, 0x49, 0x91, 0x8b, 0x95, 0xa7, 0xef,
+	0x9b, 0x17, 0x71, 0xbb, 0x25, 0x79, 0x0b, 0x2d, 0xe9, 0x68, 0xeb, 0x1f, 0xaf, 0xf7, 0x9d, 0x7f,
+	0xbd, 0xde, 0x77, 0xfe, 0xfd, 0x7a, 0xdf, 0xf9, 0xcb, 0x7f, 0xf6, 0xbf, 0x73, 0xbe, 0x86, 0xbf,
+	0x2e, 0x3f, 0xfa, 0xdf, 0x00, 0xd6, 0xa0, 0xce, 0x1b, 0xdd, 0x14, 0x00, 0x00,
 }
 
 func (m *IndexMeta) Marshal() (dAtA []byte, err error) {
@@ -3096,6 +3206,11 @@ func (m *IndexMeta) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if m.PartitionN != 0 {
+		i = encodeVarintPrivate(dAtA, i, uint64(m.PartitionN))
+		i--
+		dAtA[i] = 0x30
+	}
 	if len(m.Description) > 0 {
 		i -= len(m.Description)
 		copy(dAtA[i:], m.Description)
@@ -3150,6 +3265,27 @@ func (m *FieldOptions) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if m.CacheRecalculateInterval != 0 {
+		i = encodeVarintPrivate(dAtA, i, uint64(m.CacheRecalculateInterval))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xc0
+	}
+	if m.TombstoneWindow != 0 {
+		i = encodeVarintPrivate(dAtA, i, uint64(m.TombstoneWindow))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xb8
+	}
+	if m.RunThreshold != 0 {
+		i = encodeVarintPrivate(dAtA, i, uint64(m.RunThreshold))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0xb0
+	}
 	if m.TrackExistence {
 		i--
 		if m.TrackExistence {
@@ -3470,6 +3606,16 @@ func (m *Cache) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if m.Stale {
+		i--
+		if m.Stale {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x10
+	}
 	if len(m.IDs) > 0 {
 		dAtA8 := make([]byte, len(m.IDs)*10)
 		var j7 int
@@ -5562,6 +5708,54 @@ func (m *DeleteDataframeMessage) MarshalToSizedBuffer(dAtA []byte) (int, error)
 	return len(dAtA) - i, nil
 }
 
+func (m *ShardRouteMessage) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ShardRouteMessage) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ShardRouteMessage) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.NodeIDs) > 0 {
+		for iNdEx := len(m.NodeIDs) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.NodeIDs[iNdEx])
+			copy(dAtA[i:], m.NodeIDs[iNdEx])
+			i = encodeVarintPrivate(dAtA, i, uint64(len(m.NodeIDs[iNdEx])))
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
+	if m.Shard != 0 {
+		i = encodeVarintPrivate(dAtA, i, uint64(m.Shard))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Index) > 0 {
+		i -= len(m.Index)
+		copy(dAtA[i:], m.Index)
+		i = encodeVarintPrivate(dAtA, i, uint64(len(m.Index)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintPrivate(dAtA []byte, offset int, v uint64) int {
 	offset -= sovPrivate(v)
 	base := offset
@@ -5589,6 +5783,9 @@ func (m *IndexMeta) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovPrivate(uint64(l))
 	}
+	if m.PartitionN != 0 {
+		n += 1 + sovPrivate(uint64(m.PartitionN))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -5660,6 +5857,15 @@ func (m *FieldOptions) Size() (n int) {
 	if m.TrackExistence {
 		n += 3
 	}
+	if m.RunThreshold != 0 {
+		n += 2 + sovPrivate(uint64(m.RunThreshold))
+	}
+	if m.TombstoneWindow != 0 {
+		n += 2 + sovPrivate(uint64(m.TombstoneWindow))
+	}
+	if m.CacheRecalculateInterval != 0 {
+		n += 2 + sovPrivate(uint64(m.CacheRecalculateInterval))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -5751,6 +5957,9 @@ func (m *Cache) Size() (n int) {
 		}
 		n += 1 + sovPrivate(uint64(l)) + l
 	}
+	if m.Stale {
+		n += 2
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -6703,6 +6912,31 @@ func (m *DeleteDataframeMessage) Size() (n int) {
 	return n
 }
 
+func (m *ShardRouteMessage) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Index)
+	if l > 0 {
+		n += 1 + l + sovPrivate(uint64(l))
+	}
+	if m.Shard != 0 {
+		n += 1 + sovPrivate(uint64(m.Shard))
+	}
+	if len(m.NodeIDs) > 0 {
+		for _, s := range m.NodeIDs {
+			l = len(s)
+			n += 1 + l + sovPrivate(uint64(l))
+		}
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
 func sovPrivate(x uint64) (n int) {
 	return (math_bits.Len64(x|1) + 6) / 7
 }
@@ -6810,6 +7044,25 @@ func (m *IndexMeta) Unmarshal(dAtA []byte) error {
 			}
 			m.Description = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PartitionN", wireType)
+			}
+			m.PartitionN = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPrivate
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.PartitionN |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPrivate(dAtA[iNdEx:])
@@ -7299,6 +7552,63 @@ func (m *FieldOptions) Unmarshal(dAtA []byte) error {
 				}
 			}
 			m.TrackExistence = bool(v != 0)
+		case 22:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RunThreshold", wireType)
+			}
+			m.RunThreshold = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPrivate
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RunThreshold |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 23:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TombstoneWindow", wireType)
+			}
+			m.TombstoneWindow = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPrivate
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TombstoneWindow |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 24:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CacheRecalculateInterval", wireType)
+			}
+			m.CacheRecalculateInterval = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPrivate
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CacheRecalculateInterval |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPrivate(dAtA[iNdEx:])
@@ -7897,6 +8207,26 @@ func (m *Cache) Unmarshal(dAtA []byte) error {
 			} else {
 				return fmt.Errorf("proto: wrong wireType = %d for field IDs", wireType)
 			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stale", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPrivate
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Stale = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPrivate(dAtA[iNdEx:])
@@ -13694,6 +14024,140 @@ func (m *DeleteDataframeMessage) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *ShardRouteMessage) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowPrivate
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ShardRouteMessage: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ShardRouteMessage: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Index", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPrivate
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthPrivate
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPrivate
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Index = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Shard", wireType)
+			}
+			m.Shard = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPrivate
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Shard |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NodeIDs", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPrivate
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthPrivate
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPrivate
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.NodeIDs = append(m.NodeIDs, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipPrivate(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthPrivate
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipPrivate(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -56,6 +56,21 @@ var (
 	ErrQueryTimeout     = errors.New("query timeout")
 	ErrTooManyWrites    = errors.New("too many write commands")
 
+	// ErrFragmentTooLarge is returned when an import could grow a fragment
+	// past the configured maximum size. Nothing is written, so the import
+	// can be retried once space has been made or the limit raised.
+	ErrFragmentTooLarge = errors.New("import would exceed maximum fragment size")
+
+	// ErrImportRowOutOfRange is returned when imported roaring data has a
+	// bit in a row above the configured maximum row ID, which usually means
+	// the data was malformed. Nothing is written.
+	ErrImportRowOutOfRange = errors.New("import has a row above the maximum row ID")
+
+	// ErrNodeQuiescing is returned for writes to a node which has been
+	// quiesced for maintenance. The write can be retried once the node is
+	// back.
+	ErrNodeQuiescing = errors.New("node is quiescing")
+
 	// TODO(2.0) poorly named - used when a *node* doesn't own a shard. Probably
 	// we won't need this error at all by 2.0 though.
 	ErrClusterDoesNotOwnShard = errors.New("node does not own shard")
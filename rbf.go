@@ -55,6 +55,9 @@ func (w *RbfDBWrapper) CleanupTx(tx Tx) {
 	}
 	r.done = true
 	r.mu.Unlock()
+	if r.endWrite != nil {
+		r.endWrite()
+	}
 
 	// try not to hold r.mu while locking w.muDb
 	w.muDb.Lock()
@@ -171,6 +174,17 @@ type RBFTx struct {
 
 	done bool
 	mu   sync.Mutex // protect done as it changes state
+
+	// endWrite, if set, is called once a write transaction is committed or
+	// rolled back, so the holder can stop waiting for it to quiesce.
+	endWrite func()
+
+	// beforeCommit, afterCommit and onRollback hold the functions
+	// registered by BeforeCommit, AfterCommit and OnRollback, protected by
+	// mu.
+	beforeCommit []func() error
+	afterCommit  []func()
+	onRollback   []func()
 }
 
 func (tx *RBFTx) DBPath() string {
@@ -184,14 +198,79 @@ func (tx *RBFTx) Type() string {
 func (tx *RBFTx) Rollback() {
 	tx.tx.Rollback()
 	tx.Db.CleanupTx(tx)
+	tx.runHooks(false)
 }
 
 func (tx *RBFTx) Commit() (err error) {
+	if err = tx.runBeforeCommit(); err != nil {
+		tx.Rollback()
+		return err
+	}
 	err = tx.tx.Commit()
 	tx.Db.CleanupTx(tx)
+	tx.runHooks(err == nil)
 	return err
 }
 
+func (tx *RBFTx) BeforeCommit(fn func() error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if !tx.done {
+		tx.beforeCommit = append(tx.beforeCommit, fn)
+	}
+}
+
+// runBeforeCommit calls the functions registered by BeforeCommit, including
+// any registered while they run, stopping at the first error.
+func (tx *RBFTx) runBeforeCommit() error {
+	for {
+		tx.mu.Lock()
+		hooks := tx.beforeCommit
+		tx.beforeCommit = nil
+		tx.mu.Unlock()
+		if len(hooks) == 0 {
+			return nil
+		}
+		for _, fn := range hooks {
+			if err := fn(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (tx *RBFTx) AfterCommit(fn func()) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if !tx.done {
+		tx.afterCommit = append(tx.afterCommit, fn)
+	}
+}
+
+func (tx *RBFTx) OnRollback(fn func()) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if !tx.done {
+		tx.onRollback = append(tx.onRollback, fn)
+	}
+}
+
+// runHooks calls the functions registered by AfterCommit, if committed is
+// true, or by OnRollback otherwise, and drops all of them, so that a
+// Rollback following a Commit doesn't call anything.
+func (tx *RBFTx) runHooks(committed bool) {
+	tx.mu.Lock()
+	hooks := tx.onRollback
+	if committed {
+		hooks = tx.afterCommit
+	}
+	tx.beforeCommit, tx.afterCommit, tx.onRollback = nil, nil, nil
+	tx.mu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
 func (tx *RBFTx) RoaringBitmap(index, field, view string, shard uint64) (*roaring.Bitmap, error) {
 	return tx.tx.RoaringBitmap(rbfName(index, field, view, shard))
 }
@@ -495,6 +574,12 @@ func (tx *RBFTx) GetFieldSizeBytes(index, field string) (uint64, error) {
 	return tx.tx.GetSizeBytesWithPrefix(string(txkey.FieldPrefix(index, field)))
 }
 
+// SizeBytes returns the size of the database, as seen by the transaction,
+// from its page count.
+func (tx *RBFTx) SizeBytes() int64 {
+	return int64(tx.tx.PageN()) * rbf.PageSize
+}
+
 // SnapshotReader returns a reader that provides a snapshot of the current database.
 func (tx *RBFTx) SnapshotReader() (io.Reader, error) {
 	return tx.tx.SnapshotReader()
@@ -601,6 +686,9 @@ func (w *RbfDBWrapper) NewTx(write bool, initialIndex string, o Txo) (_ Tx, err
 		o:            o,
 		Db:           w,
 	}
+	if write && o.Index != nil && o.Index.holder != nil {
+		rtx.endWrite = o.Index.holder.trackWriteTx()
+	}
 
 	w.muDb.Lock()
 	w.openTx[rtx] = true
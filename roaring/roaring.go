@@ -3683,6 +3683,24 @@ func (c *Container) runMax() uint16 {
 	return runs[len(runs)-1].Last
 }
 
+// min returns the minimum value in the container.
+func (c *Container) min() uint16 {
+	if c == nil || c.N() == 0 {
+		return 0
+	}
+	if c.isArray() {
+		return c.array()[0]
+	} else if c.isRun() {
+		return c.runs()[0].Start
+	}
+	for i, v := range c.bitmap() {
+		if v != 0 {
+			return uint16(i*64 + bits.TrailingZeros64(v))
+		}
+	}
+	return 0
+}
+
 // bitmapToArray converts from bitmap format to array format.
 func (c *Container) bitmapToArray() *Container {
 	statsHit("bitmapToArray")
@@ -7607,6 +7625,25 @@ func Optimize(c *Container) *Container {
 	return c.optimize()
 }
 
+// OptimizeRuns is like Optimize, except that c is converted to a run
+// container whenever it has maxRuns runs or fewer, even if another type
+// would be smaller. It's equivalent to Optimize if maxRuns is zero.
+func OptimizeRuns(c *Container, maxRuns int32) *Container {
+	if c.N() == 0 || maxRuns <= 0 {
+		return c.optimize()
+	}
+	runs := c.countRuns()
+	if runs > maxRuns || runs > runMaxSize {
+		return c.optimize()
+	}
+	if c.isArray() {
+		return c.arrayToRun(runs)
+	} else if c.isBitmap() {
+		return c.bitmapToRun(runs)
+	}
+	return c
+}
+
 func Union(a, b *Container) (c *Container) {
 	c = union(a, b)
 	// c can be have arrays that are too big, and need
@@ -7644,6 +7681,11 @@ func (c *Container) Max() uint16 {
 	return c.max()
 }
 
+// Min returns the smallest value in the container, or 0 if it's empty.
+func (c *Container) Min() uint16 {
+	return c.min()
+}
+
 func (c *Container) CountRange(start, end int32) (n int32) {
 	return c.countRange(start, end)
 }
@@ -71,6 +71,7 @@ type Server struct { // nolint: maligned
 	queryLogger logger.Logger
 
 	nodeID               string
+	zone                 string
 	uri                  pnet.URI
 	grpcURI              pnet.URI
 	metricInterval       time.Duration
@@ -81,6 +82,13 @@ type Server struct { // nolint: maligned
 	confirmDownRetries   int
 	syncer               holderSyncer
 	maxQueryMemory       int64
+	maxQueryShards       int
+	maxKeyCreates        int
+
+	// coordinatorLease, if coordinatorLeaseTTL is set, makes the holder of
+	// a lease kept by the DisCo the coordinator.
+	coordinatorLeaseTTL time.Duration
+	coordinatorLease    *disco.CoordinatorLease
 
 	translationSyncer      TranslationSyncer
 	resetTranslationSyncCh chan struct{}
@@ -407,6 +415,36 @@ func OptServerMaxQueryMemory(v int64) ServerOption {
 	}
 }
 
+// OptServerMaxKeyCreatesPerPartition sets the number of key creations run at
+// once on each of this node's translate partitions. Zero is unlimited.
+func OptServerMaxKeyCreatesPerPartition(n int) ServerOption {
+	return func(s *Server) error {
+		s.maxKeyCreates = n
+		return nil
+	}
+}
+
+// OptServerCoordinatorLeaseTTL makes the coordinator the holder of a lease
+// kept by the DisCo, which must be a disco.Leaser, rather than the node the
+// hash picks. The coordinator renews the lease while it's up; should it
+// stop, another node takes the lease over once it expires, after ttl. Zero
+// disables the lease.
+func OptServerCoordinatorLeaseTTL(ttl time.Duration) ServerOption {
+	return func(s *Server) error {
+		s.coordinatorLeaseTTL = ttl
+		return nil
+	}
+}
+
+// OptServerMaxConcurrentQueryShards sets the number of a query's shards
+// processed at once on this node. Zero is unlimited.
+func OptServerMaxConcurrentQueryShards(n int) ServerOption {
+	return func(s *Server) error {
+		s.maxQueryShards = n
+		return nil
+	}
+}
+
 // OptServerDisCo is a functional option on Server
 // used to set the Distributed Consensus implementation.
 func OptServerDisCo(disCo disco.DisCo,
@@ -423,6 +461,15 @@ func OptServerDisCo(disCo disco.DisCo,
 	}
 }
 
+// OptServerSlowImportThreshold sets how long a fragment's bulk or roaring
+// import can take before it's logged as slow. Zero disables the logging.
+func OptServerSlowImportThreshold(d time.Duration) ServerOption {
+	return func(s *Server) error {
+		s.holderConfig.SlowImportThreshold = d
+		return nil
+	}
+}
+
 // OptServerLookupDB configures a connection to an external postgres database for ExternalLookup queries.
 func OptServerLookupDB(dsn string) ServerOption {
 	return func(s *Server) error {
@@ -431,6 +478,16 @@ func OptServerLookupDB(dsn string) ServerOption {
 	}
 }
 
+// OptServerZone is a functional option on Server used to set the zone (or
+// rack) label of the local node, which replica placement uses to spread a
+// partition's replicas across failure domains.
+func OptServerZone(zone string) ServerOption {
+	return func(s *Server) error {
+		s.zone = zone
+		return nil
+	}
+}
+
 func OptServerPartitionAssigner(p string) ServerOption {
 	return func(s *Server) error {
 		s.cluster.partitionAssigner = p
@@ -438,6 +495,24 @@ func OptServerPartitionAssigner(p string) ServerOption {
 	}
 }
 
+// OptServerShardRouter is a functional option on Server used to override
+// the assignment of shards to nodes.
+func OptServerShardRouter(r disco.ShardRouter) ServerOption {
+	return func(s *Server) error {
+		s.cluster.shardRouter = r
+		return nil
+	}
+}
+
+// OptServerCoordinatorProvider is a functional option on Server used to
+// override the choice of coordinator, e.g. with an external leader election.
+func OptServerCoordinatorProvider(p disco.CoordinatorProvider) ServerOption {
+	return func(s *Server) error {
+		s.cluster.coordinatorProvider = p
+		return nil
+	}
+}
+
 func OptServerExecutionPlannerFn(fn ExecutionPlannerFn) ServerOption {
 	return func(s *Server) error {
 		s.executionPlannerFn = fn
@@ -540,6 +615,7 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 	executorOpts := []executorOption{
 		optExecutorInternalQueryClient(s.defaultClient),
 		optExecutorMaxMemory(maxQueryMemory),
+		optExecutorMaxConcurrentShards(s.maxQueryShards),
 	}
 	if s.executorPoolSize > 0 {
 		executorOpts = append(executorOpts, optExecutorWorkerPoolSize(s.executorPoolSize))
@@ -565,6 +641,9 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 	s.cluster.holder = s.holder
 	s.cluster.disCo = s.disCo
 	s.cluster.noder = s.noder
+	if stator, ok := s.noder.(disco.Stator); ok {
+		s.cluster.stator = stator
+	}
 	s.cluster.sharder = s.sharder
 	s.cluster.serverlessStorage = s.serverlessStorage
 
@@ -574,6 +653,7 @@ func NewServer(opts ...ServerOption) (*Server, error) {
 	s.executor.MaxWritesPerRequest = s.maxWritesPerRequest
 	s.cluster.broadcaster = s
 	s.cluster.maxWritesPerRequest = s.maxWritesPerRequest
+	s.cluster.maxKeyCreatesPerPartition = s.maxKeyCreates
 	s.cluster.confirmDownRetries = s.confirmDownRetries
 	s.cluster.confirmDownSleep = s.confirmDownSleep
 	s.holder.broadcaster = s
@@ -668,6 +748,28 @@ func (s *Server) Open() error {
 		GRPCURI:   s.grpcURI,
 		State:     nodeState,
 		IsPrimary: s.IsPrimary(),
+		Zone:      s.zone,
+
+		PartitionN: s.cluster.partitionN,
+		ReplicaN:   s.cluster.ReplicaN,
+		Hasher:     s.cluster.hasherName(),
+	}
+
+	// Refuse to start with an inconsistent topology, or to join a cluster
+	// whose nodes were configured with a different partition count, replica
+	// count, or hasher, or which was created with a different hasher.
+	if err := s.cluster.validateNodeTopology(s.noder.Nodes()); err != nil {
+		return errors.Wrap(err, "joining cluster")
+	}
+	if err := s.cluster.checkHasher(); err != nil {
+		return errors.Wrap(err, "joining cluster")
+	}
+
+	if err := s.cluster.loadShardMoves(); err != nil {
+		return errors.Wrap(err, "loading shard moves")
+	}
+	if err := s.cluster.loadCoordinator(); err != nil {
+		return errors.Wrap(err, "loading coordinator")
 	}
 
 	if err := s.noder.SetMetadata(context.Background(), node); err != nil {
@@ -677,6 +779,12 @@ func (s *Server) Open() error {
 	s.cluster.Node = node
 	s.executor.Node = node
 
+	if s.coordinatorLeaseTTL > 0 {
+		if err := s.startCoordinatorLease(); err != nil {
+			return errors.Wrap(err, "starting coordinator lease")
+		}
+	}
+
 	// Set up the holderSyncer.
 	s.syncer.Holder = s.holder
 	s.syncer.Node = node
@@ -821,6 +929,16 @@ func (s *Server) Close() error {
 		close(s.closing)
 		s.wg.Wait()
 
+		// Hand the coordinator role off now, rather than once the lease
+		// expires.
+		if s.coordinatorLease != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := s.coordinatorLease.Release(ctx); err != nil {
+				s.logger.Warnf("releasing coordinator lease: %v", err)
+			}
+			cancel()
+		}
+
 		errE := s.executor.Close()
 
 		var errh, errd error
@@ -1030,6 +1148,23 @@ func (s *Server) receiveMessage(m Message) error {
 			return err
 		}
 
+	case *TruncateFieldMessage:
+		f := s.holder.Field(obj.Index, obj.Field)
+		if f == nil {
+			return fmt.Errorf("local field not found: %s", obj.Field)
+		}
+		if err := f.Truncate(); err != nil {
+			return err
+		}
+
+	case *SetCoordinatorMessage:
+		s.cluster.setCoordinator(obj.NodeID)
+
+	case *ShardRouteMessage:
+		if err := s.cluster.applyShardRoute(obj.Index, obj.Shard, obj.NodeIDs); err != nil {
+			return err
+		}
+
 	case *DeleteAvailableShardMessage:
 		f := s.holder.Field(obj.Index, obj.Field)
 		if err := f.RemoveAvailableShard(obj.ShardID); err != nil {
@@ -1210,8 +1345,43 @@ func (s *Server) mergeRemoteStatus(ns *NodeStatus) error {
 	return nil
 }
 
+// startCoordinatorLease makes the holder of the coordinator lease the
+// coordinator, and keeps checking the lease until the server closes.
+func (s *Server) startCoordinatorLease() error {
+	leaser, ok := s.disCo.(disco.Leaser)
+	if !ok {
+		return errors.New("DisCo doesn't support leases")
+	}
+	lease := disco.NewCoordinatorLease(leaser, s.noder, s.cluster.Hasher, s.nodeID, s.coordinatorLeaseTTL)
+	s.coordinatorLease = lease
+	s.cluster.coordinatorProvider = lease
+
+	if ok := s.addToWaitGroup(1); !ok {
+		return fmt.Errorf("closing server while opening server is NOT allowed")
+	}
+	go func() {
+		defer s.wg.Done()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-s.closing:
+			case <-ctx.Done():
+			}
+			cancel()
+		}()
+		lease.Run(ctx, func(err error) {
+			s.logger.Warnf("checking coordinator lease: %v", err)
+		})
+	}()
+	return nil
+}
+
 // IsPrimary returns if this node is primary right now or not.
 func (s *Server) IsPrimary() bool {
+	if s.cluster.coordinatorProvider != nil || s.cluster.transferredCoordinatorID() != "" {
+		return s.cluster.NewSnapshot().IsPrimaryFieldTranslationNode(s.nodeID)
+	}
 	return s.nodeID == s.noder.PrimaryNodeID(s.cluster.Hasher)
 }
 
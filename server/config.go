@@ -152,6 +152,11 @@ type Config struct {
 	// Limits the total amount of memory to be used by Extract() & SELECT queries.
 	MaxQueryMemory int64 `toml:"max-query-memory"`
 
+	// MaxConcurrentQueryShards limits how many of a query's shards are
+	// processed at once on each node, so that one heavy query can't occupy
+	// every query worker. Zero is unlimited.
+	MaxConcurrentQueryShards int `toml:"max-concurrent-query-shards"`
+
 	// On startup, featurebase server contacts a web server to check the latest version.
 	// This stores the address for that check
 	VerChkAddress string `toml:"verchk-address"`
@@ -167,6 +172,26 @@ type Config struct {
 		// This LongQueryTime is deprecated but still exists for backward compatibility
 		LongQueryTime             toml.Duration `toml:"long-query-time"`
 		PartitionToNodeAssignment string        `toml:"partition-to-node-assignment"`
+		Zone                      string        `toml:"zone"`
+		// Hasher names the hash used to assign partitions to nodes:
+		// jump-hash (the default) or rendezvous. It must be the same on
+		// every node, and can't be changed once a cluster exists. It
+		// can't be set with partition-to-node-assignment modulus, which
+		// doesn't hash.
+		Hasher string `toml:"hasher"`
+		// MaxKeyCreatesPerPartition limits how many key creations run at
+		// once on each of this node's translate partitions, each of which
+		// fsyncs. Zero is unlimited.
+		MaxKeyCreatesPerPartition int `toml:"max-key-creates-per-partition"`
+		// Membership selects how the cluster's nodes are tracked: etcd
+		// (the default), or local, for a single node which tracks only
+		// itself, in memory. It's fixed at startup, and every node in a
+		// cluster must use the same.
+		Membership string `toml:"membership"`
+		// CoordinatorLeaseTTL, if set, makes the coordinator the holder
+		// of a lease kept in etcd, which another node takes over if the
+		// coordinator stops renewing it for this long.
+		CoordinatorLeaseTTL toml.Duration `toml:"coordinator-lease-ttl"`
 	} `toml:"cluster"`
 
 	// Etcd config is based on embedded etcd.
@@ -174,6 +199,10 @@ type Config struct {
 
 	LongQueryTime toml.Duration `toml:"long-query-time"`
 
+	// SlowImportThreshold is how long a fragment's import can take before
+	// it's logged as slow. Zero disables the logging.
+	SlowImportThreshold toml.Duration `toml:"slow-import-threshold"`
+
 	Translation struct {
 		MapSize int `toml:"map-size"`
 		// DEPRECATED: Translation config supports translation store replication.
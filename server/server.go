@@ -428,6 +428,54 @@ func (m *Command) Wait() error {
 	}
 }
 
+// Cluster membership implementations, for cluster.membership.
+const (
+	membershipEtcd  = "etcd"
+	membershipLocal = "local"
+)
+
+// membershipOptions returns the server options which set up cluster
+// membership as cluster.membership selects. A single implementation
+// provides the DisCo, Noder, Sharder and Schemator, so implementations
+// can't be mixed, and the rest of the cluster only sees the interfaces.
+// DAX compute nodes always use local membership.
+func (m *Command) membershipOptions(advertiseURI *pnet.URI, version string) ([]pilosa.ServerOption, error) {
+	membership := m.Config.Cluster.Membership
+	if membership == "" {
+		membership = membershipEtcd
+		if m.isComputeNode {
+			membership = membershipLocal
+		}
+	}
+	if m.isComputeNode && membership != membershipLocal {
+		return nil, errors.Errorf("compute nodes require %s membership, not %s", membershipLocal, membership)
+	}
+
+	switch membership {
+	case membershipEtcd:
+		m.Config.Etcd.Id = m.Config.Name // TODO(twg) rethink this
+		e := petcd.NewEtcd(m.Config.Etcd, m.logger, m.Config.Cluster.ReplicaN, version)
+		return []pilosa.ServerOption{pilosa.OptServerDisCo(e, e, e, e)}, nil
+	case membershipLocal:
+		nodeID := m.Config.Name
+		if m.isComputeNode {
+			nodeID = "localcmd"
+		}
+		return []pilosa.ServerOption{
+			pilosa.OptServerDisCo(
+				disco.NewInMemDisCo(nodeID),
+				disco.NewLocalNoder([]*disco.Node{
+					{ID: nodeID, URI: *advertiseURI, IsPrimary: true, State: disco.NodeStateStarted},
+				}),
+				disco.NewInMemSharder(),
+				disco.NewInMemSchemator(),
+			),
+			pilosa.OptServerNodeID(nodeID),
+		}, nil
+	}
+	return nil, errors.Errorf("unknown membership %q: must be %s or %s", membership, membershipEtcd, membershipLocal)
+}
+
 // setupServer uses the cluster configuration to set up this server.
 func (m *Command) setupServer() error {
 	runtime.SetBlockProfileRate(m.Config.Profile.BlockRate)
@@ -580,13 +628,14 @@ func (m *Command) setupServer() error {
 
 	serverOptions := []pilosa.ServerOption{
 		pilosa.OptServerLongQueryTime(time.Duration(longQueryTime)),
+		pilosa.OptServerSlowImportThreshold(time.Duration(m.Config.SlowImportThreshold)),
 		pilosa.OptServerDataDir(m.Config.DataDir),
 		pilosa.OptServerReplicaN(m.Config.Cluster.ReplicaN),
 		pilosa.OptServerMaxWritesPerRequest(m.Config.MaxWritesPerRequest),
 		pilosa.OptServerMetricInterval(time.Duration(m.Config.Metric.PollInterval)),
 		pilosa.OptServerDiagnosticsInterval(diagnosticsInterval),
 		pilosa.OptServerExecutorPoolSize(m.Config.WorkerPoolSize),
-		pilosa.OptServerOpenTranslateStore(pilosa.OpenTranslateStore),
+		pilosa.OptServerOpenTranslateStore(pilosa.OpenTranslateStoreWithFsyncPolicy(pilosa.TranslateFsyncPolicy(m.Config.Storage.TranslateFsync))),
 		pilosa.OptServerOpenTranslateReader(pilosa.GetOpenTranslateReaderWithLockerFunc(c, &sync.Mutex{})),
 		pilosa.OptServerOpenIDAllocator(pilosa.OpenIDAllocator),
 		pilosa.OptServerLogger(m.logger),
@@ -600,8 +649,12 @@ func (m *Command) setupServer() error {
 		pilosa.OptServerStorageConfig(m.Config.Storage),
 		pilosa.OptServerRBFConfig(m.Config.RBFConfig),
 		pilosa.OptServerMaxQueryMemory(m.Config.MaxQueryMemory),
+		pilosa.OptServerMaxConcurrentQueryShards(m.Config.MaxConcurrentQueryShards),
 		pilosa.OptServerQueryHistoryLength(m.Config.QueryHistoryLength),
 		pilosa.OptServerPartitionAssigner(m.Config.Cluster.PartitionToNodeAssignment),
+		pilosa.OptServerZone(m.Config.Cluster.Zone),
+		pilosa.OptServerMaxKeyCreatesPerPartition(m.Config.Cluster.MaxKeyCreatesPerPartition),
+		pilosa.OptServerCoordinatorLeaseTTL(time.Duration(m.Config.Cluster.CoordinatorLeaseTTL)),
 		pilosa.OptServerExecutionPlannerFn(executionPlannerFn),
 		pilosa.OptServerServerlessStorage(m.serverlessStorage),
 		pilosa.OptServerIsDataframeEnabled(m.Config.Dataframe.Enable),
@@ -610,23 +663,21 @@ func (m *Command) setupServer() error {
 		pilosa.OptServerUUIDFile(m.Config.UUIDFile),
 	}
 
-	if m.isComputeNode {
-		nodeID := "localcmd"
-		serverOptions = append(serverOptions,
-			pilosa.OptServerDisCo(
-				disco.NewInMemDisCo(nodeID),
-				disco.NewLocalNoder([]*disco.Node{
-					{ID: nodeID, URI: *advertiseURI, IsPrimary: true, State: disco.NodeStateStarted},
-				}),
-				disco.NewInMemSharder(),
-				disco.NewInMemSchemator(),
-			),
-			pilosa.OptServerNodeID(nodeID),
-		)
-	} else {
-		m.Config.Etcd.Id = m.Config.Name // TODO(twg) rethink this
-		e := petcd.NewEtcd(m.Config.Etcd, m.logger, m.Config.Cluster.ReplicaN, version)
-		serverOptions = append(serverOptions, pilosa.OptServerDisCo(e, e, e, e))
+	membershipOptions, err := m.membershipOptions(advertiseURI, version)
+	if err != nil {
+		return errors.Wrap(err, "cluster.membership")
+	}
+	serverOptions = append(serverOptions, membershipOptions...)
+
+	if m.Config.Cluster.Hasher != "" {
+		if m.Config.Cluster.PartitionToNodeAssignment == PartitionToNodeModulus {
+			return errors.Errorf("cluster.hasher %s can't be used with cluster.partition-to-node-assignment %s", m.Config.Cluster.Hasher, PartitionToNodeModulus)
+		}
+		hasher, err := disco.NewHasher(m.Config.Cluster.Hasher)
+		if err != nil {
+			return errors.Wrap(err, "cluster.hasher")
+		}
+		serverOptions = append(serverOptions, pilosa.OptServerClusterHasher(hasher))
 	}
 
 	if m.Config.LookupDBDSN != "" {
@@ -635,11 +686,14 @@ func (m *Command) setupServer() error {
 
 	serverOptions = append(serverOptions, m.serverOptions...)
 
+	clientOptions := []pilosa.InternalClientOption{
+		pilosa.WithSerializer(proto.Serializer{}),
+		pilosa.WithCompressedTranslateData(m.Config.Storage.CompressTranslateTransfers),
+	}
 	if m.Config.Auth.Enable {
-		serverOptions = append(serverOptions, pilosa.OptServerInternalClient(pilosa.NewInternalClientFromURI(uri, c, pilosa.WithSecretKey(m.Config.Auth.SecretKey), pilosa.WithSerializer(proto.Serializer{}))))
-	} else {
-		serverOptions = append(serverOptions, pilosa.OptServerInternalClient(pilosa.NewInternalClientFromURI(uri, c, pilosa.WithSerializer(proto.Serializer{}))))
+		clientOptions = append(clientOptions, pilosa.WithSecretKey(m.Config.Auth.SecretKey))
 	}
+	serverOptions = append(serverOptions, pilosa.OptServerInternalClient(pilosa.NewInternalClientFromURI(uri, c, clientOptions...)))
 
 	m.Server, err = pilosa.NewServer(serverOptions...)
 	if err != nil {
@@ -3,6 +3,8 @@ package server
 import (
 	"fmt"
 	"testing"
+
+	pnet "github.com/featurebasedb/featurebase/v3/net"
 )
 
 // unit tests for internal functions
@@ -38,3 +40,43 @@ func TestIsAllowed(t *testing.T) {
 	}
 
 }
+
+// Ensure cluster.membership selects one membership implementation, and
+// rejects unknown ones and mixing compute nodes with etcd.
+func TestCommand_membershipOptions(t *testing.T) {
+	uri, err := pnet.NewURIFromAddress("localhost:10101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		membership string
+		compute    bool
+		opts       int // 1 for etcd, 2 for local, which also sets the node ID
+		err        bool
+	}{
+		{membership: "", opts: 1},
+		{membership: "etcd", opts: 1},
+		{membership: "local", opts: 2},
+		{membership: "", compute: true, opts: 2},
+		{membership: "local", compute: true, opts: 2},
+		{membership: "etcd", compute: true, err: true},
+		{membership: "gossip", err: true},
+	} {
+		t.Run(fmt.Sprintf("%s/compute=%v", test.membership, test.compute), func(t *testing.T) {
+			m := &Command{Config: NewConfig(), isComputeNode: test.compute}
+			m.Config.Cluster.Membership = test.membership
+			opts, err := m.membershipOptions(uri, "v0")
+			if test.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if len(opts) != test.opts {
+				t.Fatalf("expected %d options, got %d", test.opts, len(opts))
+			}
+		})
+	}
+}
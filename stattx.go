@@ -515,3 +515,15 @@ func (c *statTx) GetSortedFieldViewList(idx *Index, shard uint64) (fvs []txkey.F
 func (tx *statTx) GetFieldSizeBytes(index, field string) (uint64, error) {
 	return 0, nil
 }
+
+func (c *statTx) BeforeCommit(fn func() error) {
+	c.b.BeforeCommit(fn)
+}
+
+func (c *statTx) AfterCommit(fn func()) {
+	c.b.AfterCommit(fn)
+}
+
+func (c *statTx) OnRollback(fn func()) {
+	c.b.OnRollback(fn)
+}
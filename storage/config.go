@@ -17,6 +17,33 @@ type Config struct {
 
 	// Set before calling db.Open()
 	FsyncEnabled bool `toml:"fsync"`
+
+	// TranslateFsync is the fsync policy for key translation stores:
+	// "always", "batched", or "never". If empty, translation stores fsync
+	// according to FsyncEnabled.
+	TranslateFsync string `toml:"translate-fsync"`
+
+	// CompressTranslateTransfers asks other nodes to compress the
+	// translate partitions they send this node. Nodes which don't support
+	// compression send them uncompressed.
+	CompressTranslateTransfers bool `toml:"compress-translate-transfers"`
+
+	// MaxFragmentSize is the maximum estimated size, in bytes, of the data
+	// in any one fragment. Imports which could grow a fragment past it are
+	// rejected. Zero means unlimited.
+	MaxFragmentSize int64 `toml:"max-fragment-size"`
+
+	// MaxConcurrentImports is the maximum number of fragment imports which
+	// may run at once; further imports wait for one to finish. Zero means
+	// unlimited.
+	MaxConcurrentImports int `toml:"max-concurrent-imports"`
+
+	// MaxImportRowID is the highest row ID roaring imports may set bits
+	// in. Imports with bits in higher rows are rejected, unless
+	// ClampImportRows is set, in which case those bits are dropped with a
+	// warning. Zero means unlimited.
+	MaxImportRowID  uint64 `toml:"max-import-row-id"`
+	ClampImportRows bool   `toml:"clamp-import-rows"`
 }
 
 // NewDefaultConfig returns a new Config with default values.
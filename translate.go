@@ -83,9 +83,30 @@ type TranslateStore interface { // TODO: refactor this interface; readonly shoul
 	// the read payload.
 	ReadFrom(io.Reader) (int64, error)
 
+	// WriteTo ensures that the TranslateStore implements io.WriterTo.
+	// It should write a consistent copy of the data store, in the form
+	// ReadFrom reads.
+	WriteTo(io.Writer) (int64, error)
+
+	// Restore replaces the store's contents with a snapshot from WriteTo,
+	// as ReadFrom does, for rebuilding a store from a backup and continuing
+	// to create keys, and leaves the store as read-only as it was. New IDs
+	// are always above the snapshot's largest ID, including freed ones.
+	Restore(io.Reader) (int64, error)
+
 	Delete(records *roaring.Bitmap) (Commitor, error)
 }
 
+// keyAllocator is implemented by a TranslateStore which can report the IDs
+// CreateKeys allocates, so that a failed request can delete exactly the keys
+// it created.
+type keyAllocator interface {
+	// createKeysAllocated is like CreateKeys, but also returns the IDs
+	// allocated to keys which didn't exist. If it fails, it returns the IDs
+	// allocated and committed before the failure.
+	createKeysAllocated(keys ...string) (map[string]uint64, []uint64, error)
+}
+
 // TranslatorTx reproduces a subset of the methods on the BoltDB Tx
 // object. Others may be needed in the future and we should just add
 // them here. The idea is not to scatter direct references to bolt
@@ -3,7 +3,9 @@
 package pilosa
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/binary"
 	"fmt"
@@ -34,6 +36,11 @@ var (
 	bucketIDs  = []byte("ids")
 	bucketFree = []byte("free")
 	freeKey    = []byte("free")
+
+	// floorKey, in the free bucket, holds an ID which no new ID may be
+	// at or below, as well as the IDs in the ids bucket. Restore sets it,
+	// so that IDs a snapshot had freed aren't reissued.
+	floorKey = []byte("floor")
 )
 
 const (
@@ -41,6 +48,31 @@ const (
 	snapshotExt = ".snapshotting"
 
 	errFmtTranslateBucketNotFound = "boltdb: translate bucket '%s' not found"
+
+	// defaultTranslateFsyncInterval is how often a store using
+	// TranslateFsyncBatched flushes its writes to disk.
+	defaultTranslateFsyncInterval = 100 * time.Millisecond
+)
+
+// TranslateFsyncPolicy determines when a boltdb translate store flushes
+// committed writes to disk.
+type TranslateFsyncPolicy string
+
+const (
+	// TranslateFsyncAlways fsyncs every write transaction before it
+	// returns. This is the default when fsync is enabled.
+	TranslateFsyncAlways TranslateFsyncPolicy = "always"
+
+	// TranslateFsyncBatched fsyncs on a timer, and on close, rather than on
+	// every write. Writes are visible to readers as soon as they commit,
+	// but a crash can lose keys created since the last fsync, which the
+	// caller may already have used.
+	TranslateFsyncBatched TranslateFsyncPolicy = "batched"
+
+	// TranslateFsyncNever leaves flushing to the operating system. A crash
+	// can lose any amount of key data, so it is unsafe for production use.
+	// This is the default when fsync is disabled.
+	TranslateFsyncNever TranslateFsyncPolicy = "never"
 )
 
 // OpenTranslateStore opens and initializes a boltdb translation store.
@@ -53,6 +85,23 @@ func OpenTranslateStore(path, index, field string, partitionID, partitionN int,
 	return s, nil
 }
 
+// OpenTranslateStoreWithFsyncPolicy returns an OpenTranslateStoreFunc which
+// opens boltdb translation stores with the given fsync policy. An empty
+// policy defers to the fsyncEnabled argument, as OpenTranslateStore does.
+func OpenTranslateStoreWithFsyncPolicy(policy TranslateFsyncPolicy) OpenTranslateStoreFunc {
+	return func(path, index, field string, partitionID, partitionN int, fsyncEnabled bool) (TranslateStore, error) {
+		s := NewBoltTranslateStore(index, field, partitionID, partitionN, fsyncEnabled)
+		s.Path = path
+		if policy != "" {
+			s.FsyncPolicy = policy
+		}
+		if err := s.Open(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+}
+
 // Ensure type implements interface.
 var _ TranslateStore = &BoltTranslateStore{}
 
@@ -78,24 +127,46 @@ type BoltTranslateStore struct {
 	once    sync.Once
 	closing chan struct{}
 
-	readOnly     bool
-	fsyncEnabled bool
-	writeNotify  chan struct{}
+	readOnly    bool
+	writeNotify chan struct{}
+
+	// restoreMu is held for writing by Restore, and for reading by
+	// CreateKeys, so that no IDs are allocated while the store is replaced.
+	restoreMu sync.RWMutex
+
+	// syncN is the number of batched fsyncs performed, and syncedTxID the
+	// id of the last write transaction they flushed.
+	syncMu     sync.Mutex
+	syncN      int
+	syncedTxID int
+	syncStop   chan struct{}
+	syncWG     sync.WaitGroup
 
 	// File path to database file.
 	Path string
+
+	// FsyncPolicy and FsyncInterval determine when writes are flushed to
+	// disk. They must be set before calling Open. FsyncInterval only
+	// applies to TranslateFsyncBatched.
+	FsyncPolicy   TranslateFsyncPolicy
+	FsyncInterval time.Duration
 }
 
 // NewBoltTranslateStore returns a new instance of TranslateStore.
 func NewBoltTranslateStore(index, field string, partitionID, partitionN int, fsyncEnabled bool) *BoltTranslateStore {
+	policy := TranslateFsyncNever
+	if fsyncEnabled {
+		policy = TranslateFsyncAlways
+	}
 	return &BoltTranslateStore{
-		index:        index,
-		field:        field,
-		partitionID:  partitionID,
-		partitionN:   partitionN,
-		closing:      make(chan struct{}),
-		writeNotify:  make(chan struct{}),
-		fsyncEnabled: fsyncEnabled,
+		index:         index,
+		field:         field,
+		partitionID:   partitionID,
+		partitionN:    partitionN,
+		closing:       make(chan struct{}),
+		writeNotify:   make(chan struct{}),
+		FsyncPolicy:   policy,
+		FsyncInterval: defaultTranslateFsyncInterval,
 	}
 }
 
@@ -109,9 +180,19 @@ func (s *BoltTranslateStore) Open() (err error) {
 		}
 	}()
 
+	switch s.FsyncPolicy {
+	case TranslateFsyncAlways, TranslateFsyncNever:
+	case TranslateFsyncBatched:
+		if s.FsyncInterval <= 0 {
+			return errors.Errorf("invalid translate fsync interval: %s", s.FsyncInterval)
+		}
+	default:
+		return errors.Errorf("invalid translate fsync policy: %q", s.FsyncPolicy)
+	}
+
 	if err := os.MkdirAll(filepath.Dir(s.Path), 0o750); err != nil {
 		return errors.Wrapf(err, "mkdir %s", filepath.Dir(s.Path))
-	} else if s.db, err = bolt.Open(s.Path, 0o600, &bolt.Options{Timeout: 1 * time.Second, NoSync: !s.fsyncEnabled, InitialMmapSize: 0}); err != nil {
+	} else if s.db, err = bolt.Open(s.Path, 0o600, &bolt.Options{Timeout: 1 * time.Second, NoSync: s.FsyncPolicy != TranslateFsyncAlways, InitialMmapSize: 0}); err != nil {
 		return errors.Wrapf(err, "open file: %s", err)
 	}
 
@@ -130,14 +211,40 @@ func (s *BoltTranslateStore) Open() (err error) {
 		return err
 	}
 
+	if s.FsyncPolicy == TranslateFsyncBatched {
+		// Only writes from here on need to be synced.
+		tx, err := s.db.Begin(false)
+		if err != nil {
+			s.db.Close()
+			return err
+		}
+		s.syncMu.Lock()
+		s.syncedTxID = tx.ID()
+		s.syncMu.Unlock()
+		_ = tx.Rollback()
+
+		s.syncStop = make(chan struct{})
+		s.syncWG.Add(1)
+		go s.monitorSync(s.syncStop)
+	}
 	return nil
 }
 
 // Close closes the underlying database.
 func (s *BoltTranslateStore) Close() (err error) {
 	s.once.Do(func() { close(s.closing) })
+	if s.syncStop != nil {
+		close(s.syncStop)
+		s.syncWG.Wait()
+		s.syncStop = nil
+	}
 
 	if s.db != nil {
+		if s.FsyncPolicy == TranslateFsyncBatched {
+			if err := s.sync(); err != nil {
+				return errors.Wrap(err, "syncing translate store")
+			}
+		}
 		if err := s.db.Close(); err != nil {
 			return err
 		}
@@ -145,6 +252,46 @@ func (s *BoltTranslateStore) Close() (err error) {
 	return nil
 }
 
+// monitorSync periodically flushes writes to disk until stop is closed.
+func (s *BoltTranslateStore) monitorSync(stop <-chan struct{}) {
+	defer s.syncWG.Done()
+	ticker := time.NewTicker(s.FsyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// On failure, the next tick, or Close, tries again.
+			_ = s.sync()
+		}
+	}
+}
+
+// sync fsyncs the database if there have been any write transactions since
+// the last sync. Bolt increments the transaction id on each write commit, so
+// comparing it covers every write path, including transactions from Begin.
+func (s *BoltTranslateStore) sync() error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	tx, err := s.db.Begin(false)
+	if err != nil {
+		return err
+	}
+	txID := tx.ID()
+	_ = tx.Rollback()
+	if txID == s.syncedTxID {
+		return nil
+	}
+	if err := s.db.Sync(); err != nil {
+		return err
+	}
+	s.syncedTxID = txID
+	s.syncN++
+	return nil
+}
+
 // PartitionID returns the partition id the store was initialized with.
 func (s *BoltTranslateStore) PartitionID() int {
 	return s.partitionID
@@ -215,14 +362,26 @@ const translateTransactionSize = 16384
 // CreateKeys maps all keys to IDs, creating the IDs if they do not exist.
 // If the translator is read-only, this will return an error.
 func (s *BoltTranslateStore) CreateKeys(keys ...string) (map[string]uint64, error) {
+	result, _, err := s.createKeysAllocated(keys...)
+	return result, err
+}
+
+// createKeysAllocated implements keyAllocator. Keys are created in batches,
+// each in its own transaction, so if it fails, the IDs allocated by the
+// batches committed before the failure are returned with the error.
+func (s *BoltTranslateStore) createKeysAllocated(keys ...string) (map[string]uint64, []uint64, error) {
+	s.restoreMu.RLock()
+	defer s.restoreMu.RUnlock()
 	if s.ReadOnly() {
-		return nil, ErrTranslateStoreReadOnly
+		return nil, nil, ErrTranslateStoreReadOnly
 	}
 
 	written := false
 	result := make(map[string]uint64, len(keys))
+	var allocated []uint64
 	idScratch := make([]byte, translateTransactionSize*8)
 	for len(keys) > 0 {
+		var batch []uint64
 		// boltdb performs badly if you write really large numbers of
 		// keys all at once...
 		err := s.db.Update(func(tx *bolt.Tx) error {
@@ -263,6 +422,7 @@ func (s *BoltTranslateStore) CreateKeys(keys ...string) (map[string]uint64, erro
 					return err
 				}
 				result[key] = id
+				batch = append(batch, id)
 				written = true
 				if puts == translateTransactionSize {
 					keys = keys[idx+1:]
@@ -273,14 +433,18 @@ func (s *BoltTranslateStore) CreateKeys(keys ...string) (map[string]uint64, erro
 			return nil
 		})
 		if err != nil {
-			return nil, err
+			if len(allocated) > 0 {
+				s.notifyWrite()
+			}
+			return nil, allocated, err
 		}
+		allocated = append(allocated, batch...)
 	}
 	if written {
 		s.notifyWrite()
 	}
 
-	return result, nil
+	return result, allocated, nil
 }
 
 // Match finds the IDs of all keys matching a filter.
@@ -398,8 +562,66 @@ func (s *BoltTranslateStore) Begin(write bool) (TranslatorTx, error) {
 	return s.db.Begin(write)
 }
 
-// ReadFrom reads the content and overwrites the existing store.
+// WriteTo writes a consistent copy of the store's database to w. Since it's
+// the whole database, a store restored from it with ReadFrom keeps its free
+// IDs and its largest ID, and so doesn't reissue IDs.
+func (s *BoltTranslateStore) WriteTo(w io.Writer) (int64, error) {
+	tx, err := s.db.Begin(false)
+	if err != nil {
+		return 0, errors.Wrap(err, "starting transaction")
+	}
+	defer func() { _ = tx.Rollback() }()
+	return tx.WriteTo(w)
+}
+
+// translateGzipMagic begins a compressed translate store stream; it's the
+// gzip header's magic number. A bolt database begins with its first page's
+// ID, which is zero, so an uncompressed stream is never mistaken for one.
+var translateGzipMagic = []byte{0x1f, 0x8b}
+
+// writeCompressedTranslateData writes src's translate data to w compressed,
+// in the form ReadFrom detects and decompresses. Like src.WriteTo, it
+// returns the number of uncompressed bytes, so the count is the same as
+// ReadFrom's whether or not the data was compressed.
+func writeCompressedTranslateData(w io.Writer, src io.WriterTo) (int64, error) {
+	zw := gzip.NewWriter(w)
+	n, err := src.WriteTo(zw)
+	if err != nil {
+		return n, err
+	}
+	if err := zw.Close(); err != nil {
+		return n, errors.Wrap(err, "closing compressor")
+	}
+	return n, nil
+}
+
+// decompressTranslateData returns a reader of r's translate data,
+// decompressing it if it was written by writeCompressedTranslateData.
+func decompressTranslateData(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(translateGzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "reading format marker")
+	}
+	if !bytes.Equal(magic, translateGzipMagic) {
+		return br, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, errors.Wrap(err, "starting decompressor")
+	}
+	return zr, nil
+}
+
+// ReadFrom reads the content and overwrites the existing store. The content
+// may be compressed, as by writeCompressedTranslateData; n is the number of
+// uncompressed bytes either way.
 func (s *BoltTranslateStore) ReadFrom(r io.Reader) (n int64, err error) {
+	r, err = decompressTranslateData(r)
+	if err != nil {
+		return 0, err
+	}
+
 	// Close store.
 	if err := s.Close(); err != nil {
 		return 0, errors.Wrap(err, "closing store")
@@ -435,12 +657,63 @@ func (s *BoltTranslateStore) ReadFrom(r io.Reader) (n int64, err error) {
 	return n, nil
 }
 
-// MaxID returns the highest id in the store.
+// Restore replaces the store's contents with a snapshot from WriteTo and
+// then returns the store to the read-only state it had. The store is
+// read-only until the snapshot is loaded, and stays read-only if loading
+// fails; key creation waits for Restore to finish, so no key is created
+// from a partly restored store.
+//
+// The snapshot's free IDs are discarded rather than reused, since data
+// restored alongside it may be from a different point in time and still
+// refer to them. The largest of them and of the snapshot's IDs is kept as
+// the store's ID floor, and new IDs are generated above it, so no ID in
+// the snapshot is ever reissued.
+func (s *BoltTranslateStore) Restore(r io.Reader) (int64, error) {
+	s.restoreMu.Lock()
+	defer s.restoreMu.Unlock()
+
+	readOnly := s.ReadOnly()
+	s.SetReadOnly(true)
+	n, err := s.ReadFrom(r)
+	if err != nil {
+		return n, errors.Wrap(err, "reading snapshot")
+	}
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		floor := maxID(tx)
+		bkt := tx.Bucket(bucketFree)
+		if b := bkt.Get(freeKey); b != nil {
+			free := roaring.NewBitmap()
+			if err := free.UnmarshalBinary(b); err != nil {
+				return errors.Wrap(err, "unmarshaling free ids")
+			}
+			if max := free.Max(); max > floor {
+				floor = max
+			}
+		}
+		if err := bkt.Put(floorKey, u64tob(floor)); err != nil {
+			return errors.Wrap(err, "setting id floor")
+		}
+		return bkt.Delete(freeKey)
+	}); err != nil {
+		return n, errors.Wrap(err, "discarding free ids")
+	}
+	s.SetReadOnly(readOnly)
+	return n, nil
+}
+
+// maxID returns the highest id in the store, or its ID floor, if that's
+// higher.
 func maxID(tx *bolt.Tx) uint64 {
+	var max uint64
 	if key, _ := tx.Bucket(bucketIDs).Cursor().Last(); key != nil {
-		return btou64(key)
+		max = btou64(key)
+	}
+	if bkt := tx.Bucket(bucketFree); bkt != nil {
+		if floor := bkt.Get(floorKey); floor != nil && btou64(floor) > max {
+			max = btou64(floor)
+		}
 	}
-	return 0
+	return max
 }
 
 type BoltTranslateEntryReader struct {
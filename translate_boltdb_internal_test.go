@@ -1,9 +1,15 @@
 package pilosa
 
 import (
+	"bytes"
+	"fmt"
 	"path/filepath"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/featurebasedb/featurebase/v3/disco"
 	"github.com/featurebasedb/featurebase/v3/roaring"
 	bolt "go.etcd.io/bbolt"
 )
@@ -105,3 +111,180 @@ func TestGetFreeID(t *testing.T) {
 		}
 	})
 }
+
+func newFsyncPolicyTranslateStore(tb testing.TB, policy TranslateFsyncPolicy, interval time.Duration) *BoltTranslateStore {
+	s := NewBoltTranslateStore("i", "f", 0, disco.DefaultPartitionN, true)
+	s.Path = filepath.Join(tb.TempDir(), "keys")
+	s.FsyncPolicy = policy
+	s.FsyncInterval = interval
+	if err := s.Open(); err != nil {
+		tb.Fatal(err)
+	}
+	return s
+}
+
+func (s *BoltTranslateStore) syncCount() int {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	return s.syncN
+}
+
+// Ensure batched fsyncs coalesce many writes, and that writes are readable
+// before they're synced.
+func TestBoltTranslateStore_FsyncBatched(t *testing.T) {
+	s := newFsyncPolicyTranslateStore(t, TranslateFsyncBatched, time.Hour)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("k%d", i)
+		ids, err := s.CreateKeys(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found, err := s.FindKeys(key); err != nil {
+			t.Fatal(err)
+		} else if found[key] != ids[key] {
+			t.Fatalf("expected to read back %s=%d, got %v", key, ids[key], found)
+		}
+	}
+	if n := s.syncCount(); n != 0 {
+		t.Fatalf("expected no fsyncs before the interval, got %d", n)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	} else if n := s.syncCount(); n != 1 {
+		t.Fatalf("expected a single fsync on close, got %d", n)
+	}
+
+	// The keys survive reopening.
+	s2 := NewBoltTranslateStore("i", "f", 0, disco.DefaultPartitionN, true)
+	s2.Path = s.Path
+	if err := s2.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+	if found, err := s2.FindKeys("k0", "k99"); err != nil {
+		t.Fatal(err)
+	} else if len(found) != 2 {
+		t.Fatalf("expected keys after reopen, got %v", found)
+	}
+}
+
+// Ensure batched fsyncs happen on a timer.
+func TestBoltTranslateStore_FsyncBatchedInterval(t *testing.T) {
+	s := newFsyncPolicyTranslateStore(t, TranslateFsyncBatched, time.Millisecond)
+	defer s.Close()
+
+	if _, err := s.CreateKeys("a"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for s.syncCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for batched fsync")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Without new writes, there's nothing more to sync.
+	n := s.syncCount()
+	time.Sleep(20 * time.Millisecond)
+	if got := s.syncCount(); got != n {
+		t.Fatalf("expected idle store not to fsync, got %d fsyncs after %d", got, n)
+	}
+}
+
+func TestBoltTranslateStore_FsyncPolicyInvalid(t *testing.T) {
+	s := NewBoltTranslateStore("i", "f", 0, disco.DefaultPartitionN, true)
+	s.Path = filepath.Join(t.TempDir(), "keys")
+	s.FsyncPolicy = "sometimes"
+	if err := s.Open(); err == nil {
+		s.Close()
+		t.Fatal("expected error opening store with invalid fsync policy")
+	}
+}
+
+func BenchmarkBoltTranslateStore_FsyncPolicy(b *testing.B) {
+	for _, policy := range []TranslateFsyncPolicy{TranslateFsyncAlways, TranslateFsyncBatched, TranslateFsyncNever} {
+		b.Run(string(policy), func(b *testing.B) {
+			s := newFsyncPolicyTranslateStore(b, policy, defaultTranslateFsyncInterval)
+			defer s.Close()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := s.CreateKeys(strconv.Itoa(i)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Ensure compressed translate data reads back the same as uncompressed data,
+// is smaller, and that ReadFrom still reads uncompressed data.
+func TestBoltTranslateStore_CompressedReadFrom(t *testing.T) {
+	src := newFsyncPolicyTranslateStore(t, TranslateFsyncNever, 0)
+	defer src.Close()
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	exp, err := src.CreateKeys(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw, compressed bytes.Buffer
+	tx, err := src.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawN, err := tx.WriteTo(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressedN, err := writeCompressedTranslateData(&compressed, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if compressedN != rawN {
+		t.Fatalf("expected compressed write to count %d bytes, got %d", rawN, compressedN)
+	} else if compressed.Len() >= raw.Len() {
+		t.Fatalf("expected compressed size below %d, got %d", raw.Len(), compressed.Len())
+	}
+
+	for name, buf := range map[string]*bytes.Buffer{"Compressed": &compressed, "Uncompressed": &raw} {
+		t.Run(name, func(t *testing.T) {
+			dst := newFsyncPolicyTranslateStore(t, TranslateFsyncNever, 0)
+			defer dst.Close()
+			if n, err := dst.ReadFrom(buf); err != nil {
+				t.Fatal(err)
+			} else if n != rawN {
+				t.Fatalf("expected to read %d bytes, got %d", rawN, n)
+			}
+			if found, err := dst.FindKeys(keys...); err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(found, exp) {
+				t.Fatalf("expected %d keys to read back, got %d", len(exp), len(found))
+			}
+		})
+	}
+}
+
+// Ensure createKeysAllocated reports only the IDs of keys it created.
+func TestBoltTranslateStore_CreateKeysAllocated(t *testing.T) {
+	s := newFsyncPolicyTranslateStore(t, TranslateFsyncNever, 0)
+	defer s.Close()
+	existing, err := s.CreateKeys("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trans, allocated, err := s.createKeysAllocated("a", "b", "c")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(trans) != 3 || trans["a"] != existing["a"] {
+		t.Fatalf("expected a=%d and two new keys, got %v", existing["a"], trans)
+	} else if exp := []uint64{trans["b"], trans["c"]}; !reflect.DeepEqual(allocated, exp) {
+		t.Fatalf("expected allocated IDs %v, got %v", exp, allocated)
+	}
+}
@@ -5,6 +5,7 @@ package pilosa_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -434,6 +435,85 @@ func TestTranslateStore_Delete(t *testing.T) {
 		t.Fatalf("expected to have 2 free ids")
 	}
 }
+
+// Ensure a store restored from a snapshot keeps its read-only state, and
+// never reissues an ID from the snapshot, even one which had been freed.
+func TestTranslateStore_Restore(t *testing.T) {
+	src := MustOpenNewTranslateStore(t)
+	defer MustCloseTranslateStore(src)
+	restored, err := src.CreateKeys("a", "b", "c", "freed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	freed := restored["freed"]
+	c, err := src.Delete(roaring.NewBitmap(freed))
+	if err != nil {
+		t.Fatal(err)
+	} else if err := c.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	delete(restored, "freed")
+	var buf bytes.Buffer
+	if _, err := src.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := buf.Bytes()
+	for _, id := range restored {
+		if id >= freed {
+			t.Fatalf("expected freed id %d to be the snapshot's largest, got %v", freed, restored)
+		}
+	}
+
+	// Restoring into a read-only store, with keys of its own, replaces
+	// them and leaves it read-only.
+	s := MustOpenNewTranslateStore(t)
+	defer MustCloseTranslateStore(s)
+	if _, err := s.CreateKeys("other"); err != nil {
+		t.Fatal(err)
+	}
+	s.SetReadOnly(true)
+	if _, err := s.Restore(bytes.NewReader(snapshot)); err != nil {
+		t.Fatalf("restoring: %v", err)
+	} else if !s.ReadOnly() {
+		t.Fatal("expected store to stay read-only after restoring")
+	}
+	if ids, err := s.FindKeys("a", "b", "c", "other"); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(ids, restored) {
+		t.Fatalf("expected restored keys %v, got %v", restored, ids)
+	}
+
+	// Restoring into a writable store leaves it writable, and new IDs are
+	// above the freed one.
+	s.SetReadOnly(false)
+	if _, err := s.Restore(bytes.NewReader(snapshot)); err != nil {
+		t.Fatalf("restoring: %v", err)
+	} else if s.ReadOnly() {
+		t.Fatal("expected store to stay writable after restoring")
+	}
+	created, err := s.CreateKeys("d", "e", "f")
+	if err != nil {
+		t.Fatalf("creating keys after restoring: %v", err)
+	}
+	for key, id := range created {
+		if id <= freed {
+			t.Fatalf("expected new key %q to have an id above %d, got %d", key, freed, id)
+		}
+	}
+
+	// A failed restore leaves the store read-only.
+	if _, err := s.Restore(errReader{}); err == nil {
+		t.Fatal("expected error restoring from a failing reader")
+	} else if !s.ReadOnly() {
+		t.Fatal("expected store to be read-only after a failed restore")
+	}
+}
+
+// errReader is an io.Reader which always fails.
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
 func TestTranslateStore_ReadWrite(t *testing.T) {
 	t.Run("WriteTo_ReadFrom", func(t *testing.T) {
 		s := MustOpenNewTranslateStore(t)
@@ -507,6 +587,62 @@ func TestTranslateStore_ReadWrite(t *testing.T) {
 			t.Fatalf("last expected different ids[key150]: %d, but got: %d", expIDs["key150"], ids["key150"])
 		}
 	})
+
+	t.Run("WriteTo_FreshStore", func(t *testing.T) {
+		s := MustOpenNewTranslateStore(t)
+		defer MustCloseTranslateStore(s)
+
+		keys := []string{}
+		for i := 0; i < 100; i++ {
+			keys = append(keys, fmt.Sprintf("key%d", i))
+		}
+		ids, err := s.CreateKeys(keys...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Free an ID, so the restored store must know not to reuse the
+		// largest one and to reuse this one.
+		c, err := s.Delete(roaring.NewBitmap(ids["key10"]))
+		if err != nil {
+			t.Fatal(err)
+		} else if err := c.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		delete(ids, "key10")
+
+		buf := bytes.NewBuffer(nil)
+		if n, err := s.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		} else if n != int64(buf.Len()) {
+			t.Fatalf("expected %d bytes written, got %d", buf.Len(), n)
+		}
+
+		s2 := MustOpenNewTranslateStore(t)
+		defer MustCloseTranslateStore(s2)
+		if _, err := s2.ReadFrom(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := s2.FindKeys(keys...); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(got, ids) {
+			t.Fatalf("expected restored keys %v, got %v", ids, got)
+		}
+		max, err := s.MaxID()
+		require.NoError(t, err)
+		max2, err := s2.MaxID()
+		require.NoError(t, err)
+		require.Equal(t, max, max2)
+
+		// Both stores allocate the same IDs for new keys.
+		for _, key := range []string{"new0", "new1"} {
+			exp, err := s.CreateKeys(key)
+			require.NoError(t, err)
+			got, err := s2.CreateKeys(key)
+			require.NoError(t, err)
+			require.Equal(t, exp, got)
+		}
+	})
 }
 
 // MustOpenNewTranslateStore returns a new, opened TranslateStore.
@@ -58,6 +58,9 @@ type Tx interface {
 	// Return value 'found' is true when the ckey container was present.
 	// ckey of 0 gives all containers (in the fragment).
 	//
+	// Containers must be returned in ascending key order; fragment.forEachBit
+	// relies on this.
+	//
 	// ContainerIterator must not have side-effects.
 	//
 	// citer.Close() must be called when the client is done using it.
@@ -157,6 +160,20 @@ type Tx interface {
 	GetSortedFieldViewList(idx *Index, shard uint64) (fvs []txkey.FieldView, err error)
 
 	GetFieldSizeBytes(index, field string) (uint64, error)
+
+	// BeforeCommit registers fn to be called when the Tx is committed,
+	// before its updates are made visible, so that it can still write to the
+	// Tx, or register further functions. If fn returns an error, the Tx is
+	// rolled back instead, and Commit returns the error.
+	BeforeCommit(fn func() error)
+
+	// AfterCommit registers fn to be called once the Tx has been committed,
+	// and OnRollback registers fn to be called if it's rolled back instead,
+	// or its commit fails. The functions are called after the Tx is
+	// finished, in the order they were registered, at most once. Registering
+	// a function on a finished Tx does nothing.
+	AfterCommit(fn func())
+	OnRollback(fn func())
 }
 
 // GenericApplyFilter implements ApplyFilter in terms of tx.ContainerIterator,
@@ -175,7 +175,7 @@ func (f *TxFactory) NewQcx() (qcx *Qcx) {
 		Grp: f.NewTxGroup(),
 		Txf: f,
 	}
-	if f.typeOfTx == "roaring" {
+	if !f.Capabilities().DBPerShard {
 		qcx.isRoaring = true
 	}
 	if f.holder != nil {
@@ -197,7 +197,7 @@ func (f *TxFactory) NewWritableQcx() (qcx *Qcx) {
 	if f.holder != nil && f.holder.executor != nil {
 		qcx.workers = f.holder.executor.workers
 	}
-	if f.typeOfTx == "roaring" {
+	if !f.Capabilities().DBPerShard {
 		qcx.isRoaring = true
 	}
 	_ = testhook.Opened(f.holder.Auditor, qcx, nil)
@@ -322,9 +322,14 @@ func (qcx *Qcx) GetTx(o Txo) (tx Tx, finisher func(perr *error), err error) {
 			}
 			finisherDone = true // only Commit once.
 			// so defer finisher(nil) means always Commit writes, ignoring
-			// the enclosing functions return status.
-			if perr == nil || *perr == nil {
-				vprint.PanicOn(tx.Commit())
+			// the enclosing functions return status. A failed Commit is
+			// reported through perr, or logged if there's no perr.
+			if perr == nil {
+				if err := tx.Commit(); err != nil {
+					qcx.Txf.holder.Logger.Errorf("committing write tx: %v", err)
+				}
+			} else if *perr == nil {
+				*perr = errors.Wrap(tx.Commit(), "committing write tx")
 			} else {
 				tx.Rollback()
 			}
@@ -471,6 +476,50 @@ func (f *TxFactory) TxTyp() txtype {
 	return f.typ
 }
 
+// TxCapabilities describes the traits of a storage backend which callers
+// may need to adapt to, so they can check for a trait rather than compare
+// backend names.
+type TxCapabilities struct {
+	// Backend is the name of the storage backend, as from TxType.
+	Backend string
+
+	// DBPerShard is true if all fragments of a shard share one database,
+	// so one read Tx on a shard can serve every fragment in it.
+	DBPerShard bool
+
+	// MVCC is true if read transactions see a consistent snapshot of the
+	// data, unaffected by writes committed after they begin.
+	MVCC bool
+
+	// SingleWriter is true if only one write Tx may be open on a database
+	// at a time.
+	SingleWriter bool
+
+	// NeedsSnapshot is true if the backend relies on its data files being
+	// periodically snapshotted, as the old roaring backend did to compact
+	// its op logs, rather than checkpointing by itself.
+	NeedsSnapshot bool
+}
+
+// Capabilities returns the capabilities of the factory's storage backend.
+func (f *TxFactory) Capabilities() TxCapabilities {
+	return f.typ.capabilities(f.typeOfTx)
+}
+
+// capabilities returns the capabilities of the backend, called name.
+func (ty txtype) capabilities(name string) TxCapabilities {
+	switch ty {
+	case rbfTxn:
+		return TxCapabilities{
+			Backend:      name,
+			DBPerShard:   true,
+			MVCC:         true,
+			SingleWriter: true,
+		}
+	}
+	return TxCapabilities{Backend: name}
+}
+
 func (f *TxFactory) DeleteIndex(name string) (err error) {
 	return f.dbPerShard.DeleteIndex(name)
 }
@@ -18,3 +18,37 @@ func Test_TxFactory_verifyStringConstantsMatch(t *testing.T) {
 		}
 	}
 }
+
+// Ensure a holder's TxFactory reports the capabilities of the storage
+// backend it was configured with.
+func Test_TxFactory_Capabilities(t *testing.T) {
+	tests := []struct {
+		backend string
+		exp     TxCapabilities
+	}{
+		{
+			backend: RBFTxn,
+			exp: TxCapabilities{
+				Backend:      RBFTxn,
+				DBPerShard:   true,
+				MVCC:         true,
+				SingleWriter: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			cfg := TestHolderConfig()
+			cfg.StorageConfig.Backend = tt.backend
+			h := NewHolder(t.TempDir(), cfg)
+			if err := h.Open(); err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close()
+
+			if got := h.Txf().Capabilities(); got != tt.exp {
+				t.Fatalf("expected capabilities %+v, got %+v", tt.exp, got)
+			}
+		})
+	}
+}
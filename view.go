@@ -45,9 +45,13 @@ type view struct {
 	idx    *Index
 	fld    *Field
 
-	fieldType string
-	cacheType string
-	cacheSize uint32
+	fieldType    string
+	cacheType    string
+	cacheSize    uint32
+	runThreshold int32
+
+	tombstoneWindow          time.Duration
+	cacheRecalculateInterval time.Duration
 
 	// Fragments by shard.
 	fragments map[uint64]*fragment
@@ -77,6 +81,10 @@ func newView(holder *Holder, path, index, field, name string, fieldOptions Field
 		cacheType: fieldOptions.CacheType,
 		cacheSize: fieldOptions.CacheSize,
 
+		runThreshold:             fieldOptions.RunThreshold,
+		tombstoneWindow:          fieldOptions.TombstoneWindow,
+		cacheRecalculateInterval: fieldOptions.CacheRecalculateInterval,
+
 		fragments: make(map[uint64]*fragment),
 
 		broadcaster: NopBroadcaster,
@@ -121,6 +129,13 @@ func (v *view) removeKnownShard(shard uint64) {
 	_, _ = v.knownShards.Remove(shard)
 }
 
+// setKnownShards replaces the known shards of v. The caller must hold the
+// write lock. b must not be modified afterwards.
+func (v *view) setKnownShards(b *roaring.Bitmap) {
+	v.knownShards = b
+	atomic.StoreUint32(&v.knownShardsCopied, 0)
+}
+
 // openWithShardSet opens the view. Importantly, it
 // only opens the fragments that have data. This saves
 // a ton of time. If you have no data and want a new
@@ -143,9 +158,13 @@ func (v *view) openWithShardSet(ss *shardSet) error {
 		frags = append(frags, frag)
 		v.fragments[frag.shard] = frag
 	}
+	if v.holder.cfg.LazyFragmentOpen {
+		// Fragments are opened by ensureOpen on first access.
+		frags = nil
+	}
 
 	nGoro := runtime.NumCPU()
-	if v.idx.holder.txf.TxType() != "roaring" {
+	if v.idx.holder.txf.Capabilities().DBPerShard {
 		nGoro = nGoro / 4
 	}
 	if nGoro < 4 {
@@ -243,6 +262,10 @@ fragLoop:
 			break fragLoop
 		default:
 			frag := loopFrag
+			if !frag.isOpen() {
+				// Never opened, so there's no cache to flush.
+				continue
+			}
 			workQueue <- struct{}{}
 			eg.Go(func() error {
 				defer func() {
@@ -265,7 +288,7 @@ fragLoop:
 func (v *view) flushCaches() {
 	// we don't have a lock/cache of the closing mutex here, because
 	// individual view objects never get reopened, just discarded and recreated.
-	for _, f := range v.allFragments() {
+	for _, f := range v.openedFragments() {
 		select {
 		case <-v.closing:
 			return
@@ -290,15 +313,37 @@ func (v *view) availableShards() *roaring.Bitmap {
 	return v.knownShards
 }
 
-// Fragment returns a fragment in the view by shard.
-func (v *view) Fragment(shard uint64) *fragment {
+// Fragment returns a fragment in the view by shard, opening it if it hasn't
+// been opened, or nil if there is no such fragment. It returns an error if
+// the fragment can't be opened.
+func (v *view) Fragment(shard uint64) (*fragment, error) {
 	v.mu.RLock()
-	defer v.mu.RUnlock()
-	return v.fragments[shard]
+	frag := v.fragments[shard]
+	v.mu.RUnlock()
+	if frag == nil {
+		return nil, nil
+	}
+	if err := v.openFragment(frag); err != nil {
+		return nil, err
+	}
+	return frag, nil
+}
+
+// allFragments returns a list of all fragments in the view, opening any
+// which haven't been opened. It returns an error if any can't be opened.
+func (v *view) allFragments() ([]*fragment, error) {
+	all := v.unopenedFragments()
+	for _, frag := range all {
+		if err := v.openFragment(frag); err != nil {
+			return nil, err
+		}
+	}
+	return all, nil
 }
 
-// allFragments returns a list of all fragments in the view.
-func (v *view) allFragments() []*fragment {
+// unopenedFragments returns a list of all fragments in the view, without
+// opening them.
+func (v *view) unopenedFragments() []*fragment {
 	v.mu.RLock()
 	defer v.mu.RUnlock()
 
@@ -309,9 +354,28 @@ func (v *view) allFragments() []*fragment {
 	return other
 }
 
-// recalculateCaches recalculates the cache on every fragment in the view.
+// openedFragments returns a list of the fragments in the view which have
+// been opened. Those which haven't have no cache to maintain.
+func (v *view) openedFragments() []*fragment {
+	all := v.unopenedFragments()
+	other := all[:0]
+	for _, frag := range all {
+		if frag.isOpen() {
+			other = append(other, frag)
+		}
+	}
+	return other
+}
+
+// openFragment opens frag if it hasn't been opened.
+func (v *view) openFragment(frag *fragment) error {
+	return errors.Wrapf(frag.ensureOpen(), "opening fragment %s/%s/%s/%d", v.index, v.field, v.name, frag.shard)
+}
+
+// recalculateCaches recalculates the cache on every opened fragment in the
+// view.
 func (v *view) recalculateCaches() {
-	for _, fragment := range v.allFragments() {
+	for _, fragment := range v.openedFragments() {
 		fragment.RecalculateCache()
 	}
 }
@@ -340,6 +404,9 @@ func (v *view) CreateFragmentIfNotExists(shard uint64) (*fragment, error) {
 
 	// Find fragment in cache first.
 	if frag := v.fragments[shard]; frag != nil {
+		if err := frag.ensureOpen(); err != nil {
+			return nil, errors.Wrap(err, "opening fragment")
+		}
 		return frag, nil
 	}
 
@@ -393,6 +460,11 @@ func (v *view) newFragment(shard uint64) *fragment {
 	frag := newFragment(v.holder, v.idx, v.fld, v, shard)
 	frag.CacheType = v.cacheType
 	frag.CacheSize = v.cacheSize
+	frag.runThreshold = v.runThreshold
+	frag.cacheRecalculateInterval = v.cacheRecalculateInterval
+	if v.tombstoneWindow > 0 {
+		frag.tombstones = newTombstones(v.tombstoneWindow)
+	}
 	if v.fieldType == FieldTypeMutex {
 		frag.mutexVector = newRowsVector(frag)
 	} else if v.fieldType == FieldTypeBool {
@@ -426,7 +498,11 @@ func (v *view) deleteFragment(shard uint64) error {
 // row returns a row for a shard of the view.
 func (v *view) row(qcx *Qcx, rowID uint64) (*Row, error) {
 	row := NewRow()
-	for _, frag := range v.allFragments() {
+	frags, err := v.allFragments()
+	if err != nil {
+		return nil, err
+	}
+	for _, frag := range frags {
 		tx, finisher, err := qcx.GetTx(Txo{Write: !writable, Index: v.idx, Fragment: frag, Shard: frag.shard})
 		if err != nil {
 			return nil, err
@@ -451,7 +527,10 @@ func (v *view) mutexCheck(ctx context.Context, qcx *Qcx, details bool, limit int
 	// It would be nice if the inner functions could use this too...
 	eg, _ := errgroup.WithContext(ctx)
 	throttle := make(chan struct{}, runtime.NumCPU())
-	frags := v.allFragments()
+	frags, err := v.allFragments()
+	if err != nil {
+		return nil, err
+	}
 	results := make([]map[uint64][]uint64, len(frags))
 	for i, frag := range frags {
 		// local copies for the goroutine to use
@@ -474,7 +553,7 @@ func (v *view) mutexCheck(ctx context.Context, qcx *Qcx, details bool, limit int
 			return nil
 		})
 	}
-	err := eg.Wait()
+	err = eg.Wait()
 	if err != nil {
 		return nil, err
 	}
@@ -518,9 +597,9 @@ func (v *view) clearBit(qcx *Qcx, rowID, columnID uint64) (changed bool, err err
 	shard := columnID / ShardWidth
 	tx, finisher, err := qcx.GetTx(Txo{Write: true, Index: v.idx, Shard: shard})
 	defer finisher(&err)
-	frag := v.Fragment(shard)
-	if frag == nil {
-		return false, nil
+	var frag *fragment
+	if frag, err = v.Fragment(shard); err != nil || frag == nil {
+		return false, err
 	}
 
 	return frag.clearBit(tx, rowID, columnID)
@@ -557,9 +636,9 @@ func (v *view) clearValue(qcx *Qcx, columnID uint64, bitDepth uint64, value int6
 	shard := columnID / ShardWidth
 	tx, finisher, err := qcx.GetTx(Txo{Write: true, Index: v.idx, Shard: shard})
 	defer finisher(&err)
-	frag := v.Fragment(shard)
-	if frag == nil {
-		return false, nil
+	var frag *fragment
+	if frag, err = v.Fragment(shard); err != nil || frag == nil {
+		return false, err
 	}
 
 	return frag.clearValue(tx, columnID, bitDepth, value)
@@ -568,7 +647,11 @@ func (v *view) clearValue(qcx *Qcx, columnID uint64, bitDepth uint64, value int6
 // rangeOp returns rows with a field value encoding matching the predicate.
 func (v *view) rangeOp(qcx *Qcx, op pql.Token, bitDepth uint64, predicate int64) (_ *Row, err0 error) {
 	r := NewRow()
-	for _, frag := range v.allFragments() {
+	frags, err := v.allFragments()
+	if err != nil {
+		return nil, err
+	}
+	for _, frag := range frags {
 
 		tx, finisher, err := qcx.GetTx(Txo{Write: !writable, Index: v.idx, Shard: frag.shard})
 		if err != nil {
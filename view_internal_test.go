@@ -3,6 +3,8 @@
 package pilosa
 
 import (
+	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -37,7 +39,9 @@ func TestView_DeleteFragment(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if v.Fragment(shard) != nil {
+	if frag, err := v.Fragment(shard); err != nil {
+		t.Fatal(err)
+	} else if frag != nil {
 		t.Fatal("fragment still exists in view")
 	}
 
@@ -75,6 +79,110 @@ func TestView_CreateFragmentRace(t *testing.T) {
 	}
 }
 
+// Ensure a view opened with LazyFragmentOpen opens its fragments on first
+// access, once.
+func TestView_LazyFragmentOpen(t *testing.T) {
+	h, _, fld, v := newTestView(t)
+	if err := v.openEmpty(); err != nil {
+		t.Fatal(err)
+	}
+	shard := uint64(3)
+	if _, err := v.CreateFragmentIfNotExists(shard); err != nil {
+		t.Fatal(err)
+	}
+
+	h.cfg.LazyFragmentOpen = true
+	lazy := fld.newView(v.path, v.name)
+	ss := newShardSet()
+	ss.add(shard)
+	if err := lazy.openWithShardSet(ss); err != nil {
+		t.Fatal(err)
+	}
+	defer lazy.close()
+
+	if !lazy.knownShards.Contains(shard) {
+		t.Fatalf("expected shard %d to be known before the fragment is opened", shard)
+	}
+	frag := lazy.fragments[shard]
+	if frag == nil {
+		t.Fatal("expected fragment")
+	} else if frag.isOpen() {
+		t.Fatal("expected fragment not to be opened yet")
+	}
+
+	var wg sync.WaitGroup
+	frags := make([]*fragment, 4)
+	errs := make([]error, len(frags))
+	for i := range frags {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			frags[i], errs[i] = lazy.Fragment(shard)
+		}(i)
+	}
+	wg.Wait()
+	for i, got := range frags {
+		if errs[i] != nil {
+			t.Fatalf("access %d: %v", i, errs[i])
+		} else if got != frag {
+			t.Fatalf("access %d: got fragment %p, want %p", i, got, frag)
+		}
+	}
+	if !frag.isOpen() {
+		t.Fatal("expected fragment to be opened")
+	}
+}
+
+// Ensure a lazily opened fragment which fails to open returns the error, and
+// is opened by a later access once the failure is fixed.
+func TestView_LazyFragmentOpenRetry(t *testing.T) {
+	h, _, fld, v := newTestView(t)
+	if err := v.openEmpty(); err != nil {
+		t.Fatal(err)
+	}
+	shard := uint64(3)
+	if _, err := v.CreateFragmentIfNotExists(shard); err != nil {
+		t.Fatal(err)
+	}
+
+	h.cfg.LazyFragmentOpen = true
+	lazy := fld.newView(v.path, v.name)
+	ss := newShardSet()
+	ss.add(shard)
+	if err := lazy.openWithShardSet(ss); err != nil {
+		t.Fatal(err)
+	}
+	defer lazy.close()
+
+	// A directory in place of the cache file can't be read.
+	frag := lazy.fragments[shard]
+	frag.CacheType = CacheTypeRanked
+	if err := os.RemoveAll(frag.cachePath()); err != nil {
+		t.Fatal(err)
+	} else if err := os.MkdirAll(frag.cachePath(), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := lazy.Fragment(shard); err == nil {
+		t.Fatal("expected an error opening the fragment")
+	} else if got != nil {
+		t.Fatalf("expected no fragment, got %p", got)
+	}
+	if _, err := lazy.allFragments(); err == nil {
+		t.Fatal("expected an error opening all fragments")
+	}
+
+	if err := os.Remove(frag.cachePath()); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := lazy.Fragment(shard); err != nil {
+		t.Fatal(err)
+	} else if got != frag {
+		t.Fatalf("got fragment %p, want %p", got, frag)
+	} else if !frag.isOpen() {
+		t.Fatal("expected fragment to be opened")
+	}
+}
+
 // delayBroadcaster is a nopBroadcaster with a configurable delay.
 type delayBroadcaster struct {
 	nopBroadcaster